@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+)
+
+// APIKeyHeader is header scripts use to send API key
+const APIKeyHeader = "X-API-Key"
+
+const apiKeyPrefix = "fin_"
+
+// GenerateAPIKey returns new key and its prefix. Prefix is stored in clear text so user can recognize key in list.
+func GenerateAPIKey() (string, string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", errors.Wrap(err, "could not generate api key")
+	}
+	prefix := apiKeyPrefix + hex.EncodeToString(b)
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	return prefix + "." + secret, prefix, nil
+}
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"finance/internal/database"
 	"finance/internal/models"
 	"finance/internal/utils"
 	"net/http"
@@ -14,19 +15,26 @@ type principalContextKeyType struct{}
 
 var principalContextKey principalContextKeyType
 
-func AuthorizationToken(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		req, err := CheckToken(r)
-		if err != nil {
-			utils.WriteError(w, http.StatusUnauthorized, err.Error(), nil)
-			return
-		}
+func AuthorizationToken(db database.Database) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req, err := CheckToken(db, r)
+			if err != nil {
+				utils.WriteError(w, http.StatusUnauthorized, err.Error(), nil)
+				return
+			}
 
-		next.ServeHTTP(w, req)
-	})
+			next.ServeHTTP(w, req)
+		})
+	}
 }
 
-func CheckToken(r *http.Request) (*http.Request, error) {
+func CheckToken(db database.Database, r *http.Request) (*http.Request, error) {
+	// * Scripts authenticate with API key header
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return checkAPIKey(db, r, key)
+	}
+
 	// * extract token from header
 	token, err := GetToken(r)
 	if err != nil {
@@ -38,12 +46,99 @@ func CheckToken(r *http.Request) (*http.Request, error) {
 		return r, nil
 	}
 
-	principal, err := VerifyToken(token)
+	cliams, err := VerifyToken(token)
+	if err != nil {
+		return r, err
+	}
+
+	return withSessionPrincipal(db, r, cliams)
+}
+
+// CheckStreamTicket authenticates stream with ticket from query. Ticket is bound to session the same way as access token.
+func CheckStreamTicket(db database.Database, r *http.Request, ticket string) (*http.Request, error) {
+	cliams, err := VerifyStreamTicket(ticket)
 	if err != nil {
 		return r, err
 	}
 
-	return r.WithContext(WithPrincipalContext(r.Context(), *principal)), nil
+	return withSessionPrincipal(db, r, cliams)
+}
+
+func withSessionPrincipal(db database.Database, r *http.Request, cliams *Cliams) (*http.Request, error) {
+	principal := models.Principal{
+		UserID:       cliams.UserID,
+		SessionID:    cliams.SessionID,
+		TokenVersion: cliams.Version,
+	}
+
+	if err := CheckPrincipal(r.Context(), db, principal); err != nil {
+		return r, err
+	}
+
+	return r.WithContext(WithPrincipalContext(r.Context(), principal)), nil
+}
+
+// CheckPrincipal checks that credential of principal wasn't revoked. Stream calls it again while it is open.
+func CheckPrincipal(ctx context.Context, db database.Database, principal models.Principal) error {
+	if principal.APIKeyID != models.NilAPIKeyID {
+		active, err := db.IsAPIKeyActive(ctx, principal.APIKeyID)
+		if err != nil {
+			return errors.New("invalid API key")
+		}
+		if !active {
+			return errors.New("API key revoked")
+		}
+		return nil
+	}
+
+	// * Token version is incremented when user's sessions are revoked
+	version, err := db.GetTokenVersion(ctx, principal.UserID)
+	if err != nil {
+		return errors.New("invalid token")
+	}
+	if principal.TokenVersion != version {
+		return errors.New("token revoked")
+	}
+
+	// * Access token is valid only while its session exists, so one device can be logged out
+	active, err := db.IsSessionActive(ctx, principal.UserID, principal.SessionID)
+	if err != nil {
+		return errors.New("invalid token")
+	}
+	if !active {
+		return errors.New("token revoked")
+	}
+	return nil
+}
+
+func checkAPIKey(db database.Database, r *http.Request, key string) (*http.Request, error) {
+	apiKey, err := db.UseAPIKey(r.Context(), HashToken(key))
+	if err != nil {
+		return r, errors.New("invalid API key")
+	}
+
+	if apiKey.Scope == models.ScopeRead && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return r, errors.New("API key is read-only")
+	}
+
+	principal := models.Principal{
+		UserID:   *apiKey.UserID,
+		APIKeyID: apiKey.ID,
+	}
+
+	return r.WithContext(WithPrincipalContext(r.Context(), principal)), nil
+}
+
+// DenyAPIKey - API key gives access to data, not to account. Credentials, sessions and the account itself
+// can be managed only by user who logged in.
+func DenyAPIKey(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if GetPrincipal(r).APIKeyID != models.NilAPIKeyID {
+			utils.WriteError(w, http.StatusForbidden, "Account can't be managed with API key.", nil)
+			return
+		}
+		next(w, r)
+	}
 }
 
 // * Set principal in context to get it in API
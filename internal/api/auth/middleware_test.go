@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"finance/internal/database"
+	"finance/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fakeCredentialDB keeps token versions, active sessions and API keys in memory.
+// Methods which are not needed by middleware are not implemented and panic.
+type fakeCredentialDB struct {
+	database.Database
+
+	mu       sync.Mutex
+	versions map[models.UserID]int64
+	sessions map[models.FamilyID]models.UserID
+	apiKeys  map[string]*models.APIKey // key hash
+}
+
+func newFakeCredentialDB() *fakeCredentialDB {
+	return &fakeCredentialDB{
+		versions: make(map[models.UserID]int64),
+		sessions: make(map[models.FamilyID]models.UserID),
+		apiKeys:  make(map[string]*models.APIKey),
+	}
+}
+
+func (d *fakeCredentialDB) addAPIKey(key string, scope models.APIKeyScope) *models.APIKey {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	userID := models.UserID("user-1")
+	apiKey := &models.APIKey{
+		ID:      models.APIKeyID("key-" + key),
+		UserID:  &userID,
+		KeyHash: HashToken(key),
+		Scope:   scope,
+	}
+	d.apiKeys[apiKey.KeyHash] = apiKey
+	return apiKey
+}
+
+func (d *fakeCredentialDB) GetTokenVersion(ctx context.Context, userID models.UserID) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	version, ok := d.versions[userID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return version, nil
+}
+
+func (d *fakeCredentialDB) IsSessionActive(ctx context.Context, userID models.UserID, familyID models.FamilyID) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	owner, ok := d.sessions[familyID]
+	return ok && owner == userID, nil
+}
+
+func (d *fakeCredentialDB) UseAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	apiKey, ok := d.apiKeys[keyHash]
+	if !ok || apiKey.RevokedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	copied := *apiKey
+	return &copied, nil
+}
+
+// bearerRequest returns request with access token of new session of user
+func bearerRequest(t *testing.T, userID models.UserID, version int64) (*http.Request, models.FamilyID) {
+	t.Helper()
+
+	familyID, err := NewFamilyID()
+	if err != nil {
+		t.Fatalf("NewFamilyID: %v", err)
+	}
+	tokens, err := IssueToken(models.Principal{UserID: userID}, version, familyID)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+
+	r := httptest.NewRequest("GET", "/accounts", nil)
+	r.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	return r, familyID
+}
+
+func TestCheckTokenSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		revoke  func(db *fakeCredentialDB, userID models.UserID, familyID models.FamilyID)
+		wantErr string
+	}{
+		{
+			name:   "active session",
+			revoke: func(db *fakeCredentialDB, userID models.UserID, familyID models.FamilyID) {},
+		},
+		{
+			name: "revoked session",
+			revoke: func(db *fakeCredentialDB, userID models.UserID, familyID models.FamilyID) {
+				delete(db.sessions, familyID)
+			},
+			wantErr: "token revoked",
+		},
+		{
+			name: "all sessions revoked",
+			revoke: func(db *fakeCredentialDB, userID models.UserID, familyID models.FamilyID) {
+				db.versions[userID]++
+			},
+			wantErr: "token revoked",
+		},
+		{
+			name: "session of other user",
+			revoke: func(db *fakeCredentialDB, userID models.UserID, familyID models.FamilyID) {
+				db.sessions[familyID] = "user-2"
+			},
+			wantErr: "token revoked",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeCredentialDB()
+			userID := models.UserID("user-1")
+			db.versions[userID] = 3
+
+			r, familyID := bearerRequest(t, userID, 3)
+			db.sessions[familyID] = userID
+			tt.revoke(db, userID, familyID)
+
+			req, err := CheckToken(db, r)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if principal := GetPrincipal(req); principal.UserID != userID || principal.SessionID != familyID {
+				t.Errorf("principal = %+v, want user %s with session %s", principal, userID, familyID)
+			}
+		})
+	}
+}
+
+func TestAuthorizationTokenRejectsRevokedSession(t *testing.T) {
+	db := newFakeCredentialDB()
+	userID := models.UserID("user-1")
+	db.versions[userID] = 1
+
+	r, _ := bearerRequest(t, userID, 1)
+
+	called := false
+	handler := AuthorizationToken(db)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if called {
+		t.Error("handler was called with token of revoked session")
+	}
+}
+
+func TestCheckTokenAPIKeyScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		scope   models.APIKeyScope
+		method  string
+		key     string
+		wantErr string
+	}{
+		{name: "read key reads", scope: models.ScopeRead, method: "GET", key: "key-1"},
+		{name: "read key checks existence", scope: models.ScopeRead, method: "HEAD", key: "key-1"},
+		{name: "read key creates", scope: models.ScopeRead, method: "POST", key: "key-1", wantErr: "API key is read-only"},
+		{name: "read key updates", scope: models.ScopeRead, method: "PATCH", key: "key-1", wantErr: "API key is read-only"},
+		{name: "read key deletes", scope: models.ScopeRead, method: "DELETE", key: "key-1", wantErr: "API key is read-only"},
+		{name: "write key creates", scope: models.ScopeWrite, method: "POST", key: "key-1"},
+		{name: "write key deletes", scope: models.ScopeWrite, method: "DELETE", key: "key-1"},
+		{name: "unknown key", scope: models.ScopeWrite, method: "GET", key: "key-2", wantErr: "invalid API key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFakeCredentialDB()
+			apiKey := db.addAPIKey("key-1", tt.scope)
+
+			r := httptest.NewRequest(tt.method, "/accounts", nil)
+			r.Header.Set(APIKeyHeader, tt.key)
+
+			req, err := CheckToken(db, r)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err = %v", err)
+			}
+			if principal := GetPrincipal(req); principal.UserID != *apiKey.UserID || principal.APIKeyID != apiKey.ID {
+				t.Errorf("principal = %+v, want key %s of user %s", principal, apiKey.ID, *apiKey.UserID)
+			}
+		})
+	}
+}
+
+func TestDenyAPIKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal models.Principal
+		want      int
+	}{
+		{name: "session", principal: models.Principal{UserID: "user-1", SessionID: "family-1"}, want: http.StatusOK},
+		{name: "API key", principal: models.Principal{UserID: "user-1", APIKeyID: "key-1"}, want: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := DenyAPIKey(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			r := httptest.NewRequest("PATCH", "/users/user-1", nil)
+			r = r.WithContext(WithPrincipalContext(r.Context(), tt.principal))
+
+			w := httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"strings"
+	"time"
+)
+
+// LoginLimit describes how many failed logins are allowed before lockout and how long lockout is
+type LoginLimit struct {
+	Key       string
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// * Counter starts again if there was no failures during this time
+var LoginFailuresWindow = time.Duration(1) * time.Hour
+
+// * Many users can share one IP (office, NAT), so IP limit is higher
+var (
+	emailLoginThreshold = 5
+	ipLoginThreshold    = 20
+	loginBaseDelay      = time.Duration(30) * time.Second
+	loginMaxDelay       = time.Duration(1) * time.Hour
+)
+
+// * Every reset request sends email, so only few requests per hour are allowed
+var (
+	emailResetThreshold = 3
+	ipResetThreshold    = 10
+)
+
+// LoginLimits returns limits we check for login with email from ip
+func LoginLimits(email, ip string) []LoginLimit {
+	return []LoginLimit{
+		{Key: "email:" + strings.ToLower(strings.TrimSpace(email)), Threshold: emailLoginThreshold, BaseDelay: loginBaseDelay, MaxDelay: loginMaxDelay},
+		{Key: "ip:" + ip, Threshold: ipLoginThreshold, BaseDelay: loginBaseDelay, MaxDelay: loginMaxDelay},
+	}
+}
+
+// PasswordResetLimits returns limits we check for password reset of email from ip. Threshold is number of allowed requests in LoginFailuresWindow.
+func PasswordResetLimits(email, ip string) []LoginLimit {
+	return []LoginLimit{
+		{Key: "reset-email:" + strings.ToLower(strings.TrimSpace(email)), Threshold: emailResetThreshold},
+		{Key: "reset-ip:" + ip, Threshold: ipResetThreshold},
+	}
+}
+
+// Backoff returns lockout duration after failures. Lockout doubles with every failure over threshold.
+func (l LoginLimit) Backoff(failures int) time.Duration {
+	if failures < l.Threshold {
+		return 0
+	}
+
+	delay := l.BaseDelay
+	for i := l.Threshold; i < failures; i++ {
+		delay *= 2
+		if delay >= l.MaxDelay {
+			return l.MaxDelay
+		}
+	}
+	return delay
+}
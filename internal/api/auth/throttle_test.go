@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoginLimitBackoff(t *testing.T) {
+	limit := LoginLimit{Key: "email:owner@example.com", Threshold: 5, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 0, want: 0},
+		{failures: 4, want: 0},
+		{failures: 5, want: 30 * time.Second},
+		{failures: 6, want: time.Minute},
+		{failures: 7, want: 2 * time.Minute},
+		{failures: 8, want: 4 * time.Minute},
+		{failures: 9, want: 5 * time.Minute},
+		{failures: 1000, want: 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := limit.Backoff(tt.failures); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginLimits(t *testing.T) {
+	limits := LoginLimits(" Owner@Example.com ", "203.0.113.7")
+	if len(limits) != 2 {
+		t.Fatalf("limits = %d, want 2", len(limits))
+	}
+
+	// * Email key must not depend on how user typed email, otherwise case changes give new attempts
+	if limits[0].Key != "email:owner@example.com" {
+		t.Errorf("email key = %q", limits[0].Key)
+	}
+	if limits[1].Key != "ip:203.0.113.7" {
+		t.Errorf("ip key = %q", limits[1].Key)
+	}
+	if limits[1].Threshold <= limits[0].Threshold {
+		t.Errorf("ip threshold %d is not higher than email threshold %d", limits[1].Threshold, limits[0].Threshold)
+	}
+}
@@ -1,6 +1,10 @@
 package auth
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"finance/internal/models"
 	"time"
 
@@ -13,9 +17,28 @@ var jwtKey = []byte("my_secret_key") // TODO change key
 
 var accessTokenDuration = time.Duration(30) * time.Minute // 30 minuts
 var refreshTokenDuration = time.Duration(2) * time.Hour   // 2 hours
+var mfaTokenDuration = time.Duration(5) * time.Minute     // 5 minutes
+var streamTicketDuration = time.Duration(1) * time.Minute // 1 minute
+
+// TokenType is stored in claims, so token issued for one purpose can't be used for another.
+// Refresh tokens are opaque and never signed as JWT, so claims without type (old refresh tokens) are rejected.
+type TokenType string
+
+const (
+	// Access token is sent in Authorization header
+	AccessToken TokenType = "access"
+	// MFA token is issued after password check, it is exchanged for access token with second factor
+	MFAToken TokenType = "mfa"
+	// Stream ticket opens event stream, browser sends it in URL because EventSource can't send headers
+	StreamTicket TokenType = "stream"
+)
 
 type Cliams struct {
-	UserID models.UserID `json:"userID"`
+	UserID  models.UserID `json:"userID"`
+	Type    TokenType     `json:"typ"`
+	Version int64         `json:"ver"` // user's token version when token was issued
+	// * Refresh token family of session, access token dies with its session
+	SessionID models.FamilyID `json:"sid,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -28,18 +51,18 @@ type Tokens struct {
 }
 
 // * IssueToken generate Access and Refresh token:
-// * Currently we will generate only access token
-func IssueToken(principal models.Principal) (*Tokens, error) {
-	if principal.UserID == models.NilUserID {
+// * Access token is JWT bound to session (token family), refresh token is random string. Only hash of refresh token goes to database.
+func IssueToken(principal models.Principal, tokenVersion int64, familyID models.FamilyID) (*Tokens, error) {
+	if principal.UserID == models.NilUserID || familyID == models.NilFamilyID {
 		return nil, errors.New("invalid principal")
 	}
 
-	accessToken, accessTokenExpiresAt, err := GenerateToken(principal, accessTokenDuration)
+	accessToken, accessTokenExpiresAt, err := generateToken(principal, AccessToken, tokenVersion, familyID, accessTokenDuration)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, refreshTokenExpiresAt, err := GenerateToken(principal, refreshTokenDuration)
+	refreshToken, err := randomToken(32)
 	if err != nil {
 		return nil, err
 	}
@@ -48,19 +71,33 @@ func IssueToken(principal models.Principal) (*Tokens, error) {
 		AccessToken:           accessToken,
 		AccessTokenExpiresAt:  accessTokenExpiresAt,
 		RefreshToken:          refreshToken,
-		RefreshTokenExpiresAt: refreshTokenExpiresAt,
+		RefreshTokenExpiresAt: time.Now().Add(refreshTokenDuration).Unix(),
 	}
 
 	return &tokens, nil
 }
 
-func GenerateToken(principal models.Principal, duration time.Duration) (string, int64, error) {
+func GenerateToken(principal models.Principal, tokenType TokenType, tokenVersion int64, duration time.Duration) (string, int64, error) {
+	return generateToken(principal, tokenType, tokenVersion, models.NilFamilyID, duration)
+}
+
+func generateToken(principal models.Principal, tokenType TokenType, tokenVersion int64, familyID models.FamilyID, duration time.Duration) (string, int64, error) {
 	now := time.Now()
 
-	// * Generate access token
+	// * Token id lets us use token only once (MFA token)
+	tokenID, err := randomToken(16)
+	if err != nil {
+		return "", 0, err
+	}
+
+	// * Generate token
 	claims := &Cliams{
-		UserID: principal.UserID,
+		UserID:    principal.UserID,
+		Type:      tokenType,
+		Version:   tokenVersion,
+		SessionID: familyID,
 		StandardClaims: jwt.StandardClaims{
+			Id:        tokenID,
 			IssuedAt:  now.Unix(),
 			ExpiresAt: now.Add(duration).Unix(),
 		},
@@ -74,27 +111,84 @@ func GenerateToken(principal models.Principal, duration time.Duration) (string,
 	return tokenString, claims.ExpiresAt, nil
 }
 
-func VerifyToken(token string) (*models.Principal, error) {
+// VerifyToken verifies signature and type of access token. Token version and session are checked by CheckToken.
+func VerifyToken(token string) (*Cliams, error) {
+	return parseToken(token, AccessToken)
+}
+
+// IssueMFAToken generates short-lived token which proves that user passed password check. Token can be exchanged only once.
+func IssueMFAToken(principal models.Principal) (string, error) {
+	if principal.UserID == models.NilUserID {
+		return "", errors.New("invalid principal")
+	}
+
+	token, _, err := GenerateToken(principal, MFAToken, 0, mfaTokenDuration)
+	return token, err
+}
+
+// VerifyMFAToken verifies token issued by IssueMFAToken
+func VerifyMFAToken(token string) (*Cliams, error) {
+	cliams, err := parseToken(token, MFAToken)
+	if err != nil {
+		return nil, err
+	}
+	if len(cliams.Id) == 0 {
+		return nil, errors.New("invalid token")
+	}
+	return cliams, nil
+}
+
+// IssueStreamTicket generates short-lived ticket which opens event stream. Ticket belongs to session of access token,
+// so it can be put in URL instead of access token and it is useless for anything else.
+func IssueStreamTicket(principal models.Principal) (string, int64, error) {
+	if principal.UserID == models.NilUserID || principal.SessionID == models.NilFamilyID {
+		return "", 0, errors.New("invalid principal")
+	}
+
+	return generateToken(principal, StreamTicket, principal.TokenVersion, principal.SessionID, streamTicketDuration)
+}
+
+// VerifyStreamTicket verifies ticket issued by IssueStreamTicket. Token version and session are checked by CheckStreamTicket.
+func VerifyStreamTicket(ticket string) (*Cliams, error) {
+	return parseToken(ticket, StreamTicket)
+}
+
+func parseToken(token string, tokenType TokenType) (*Cliams, error) {
 	cliams := &Cliams{}
 	tkn, err := jwt.ParseWithClaims(token, cliams, func(token *jwt.Token) (interface{}, error) {
 		return jwtKey, nil
 	})
-
 	if err != nil {
-		if err == jwt.ErrSignatureInvalid {
-			return nil, err
-		}
 		return nil, err
 	}
 
-	principal := &models.Principal{
-		UserID: cliams.UserID,
+	if !tkn.Valid {
+		return nil, errors.New("invalid token")
 	}
 
-	// We want to return principal even token invalid because we need to get UserID
-	if !tkn.Valid {
-		return principal, err
+	if cliams.Type != tokenType {
+		return nil, errors.New("invalid token type")
 	}
 
-	return principal, nil
+	return cliams, nil
+}
+
+// NewFamilyID generates identifier for new refresh token family
+func NewFamilyID() (models.FamilyID, error) {
+	id, err := randomToken(16)
+	return models.FamilyID(id), err
+}
+
+// HashToken returns hash of opaque token. We use it to store and find tokens in database.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "could not generate token")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
 }
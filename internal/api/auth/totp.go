@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// TOTP (RFC 6238) with parameters every authenticator app supports: SHA1, 6 digits, 30 seconds
+const (
+	totpIssuer = "Finance"
+	totpDigits = 6
+	totpPeriod = 30
+	// * Codes from previous and next period are accepted because clocks are never exact
+	totpSkew = 1
+
+	recoveryCodesCount = 10
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret generates random secret shared with authenticator app
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "could not generate secret")
+	}
+	return base32NoPadding.EncodeToString(b), nil
+}
+
+// TOTPURI returns otpauth:// URI. Apps create account from it (usually shown as QR code).
+func TOTPURI(secret, account string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", totpIssuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(totpIssuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// ValidateTOTP checks code user entered against secret and returns time step of code.
+// Caller must accept only steps after the last accepted one, otherwise code could be replayed.
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return 0, false
+	}
+
+	counter := now.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		expected := totpCode(key, uint64(counter+int64(i)))
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return counter + int64(i), true
+		}
+	}
+	return 0, false
+}
+
+func totpCode(key []byte, counter uint64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	// * Dynamic truncation from RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// GenerateRecoveryCodes returns codes shown to user once and their hashes we store
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+
+	for i := 0; i < recoveryCodesCount; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, errors.Wrap(err, "could not generate recovery code")
+		}
+		raw := strings.ToLower(base32NoPadding.EncodeToString(b))
+		code := raw[:5] + "-" + raw[5:10]
+
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode returns hash of code. Users can type code with or without dash in any case.
+func HashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return HashToken(code)
+}
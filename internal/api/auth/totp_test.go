@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+	key, err := base32NoPadding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	counter := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name        string
+		code        string
+		wantCounter int64
+		wantValid   bool
+	}{
+		{name: "current step", code: totpCode(key, uint64(counter)), wantCounter: counter, wantValid: true},
+		{name: "previous step", code: totpCode(key, uint64(counter-1)), wantCounter: counter - 1, wantValid: true},
+		{name: "next step", code: totpCode(key, uint64(counter+1)), wantCounter: counter + 1, wantValid: true},
+		{name: "step out of skew", code: totpCode(key, uint64(counter-2))},
+		{name: "wrong length", code: "12345"},
+		{name: "empty", code: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, valid := ValidateTOTP(secret, tt.code, now)
+			if valid != tt.wantValid {
+				t.Fatalf("valid = %v, want %v", valid, tt.wantValid)
+			}
+			// * Counter is what replay check compares, it must be step of code, not current step
+			if valid && got != tt.wantCounter {
+				t.Errorf("counter = %d, want %d", got, tt.wantCounter)
+			}
+		})
+	}
+}
+
+func TestHashRecoveryCode(t *testing.T) {
+	want := HashRecoveryCode("abcde-fghij")
+
+	for _, code := range []string{"abcde-fghij", "ABCDE-FGHIJ", "abcdefghij", " abcde-fghij "} {
+		if got := HashRecoveryCode(code); got != want {
+			t.Errorf("HashRecoveryCode(%q) differs from hash of canonical code", code)
+		}
+	}
+	if HashRecoveryCode("abcde-fghik") == want {
+		t.Error("different codes have the same hash")
+	}
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"finance/internal/models"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var verifyEmailTokenDuration = time.Duration(24) * time.Hour  // 24 hours
+var resetPasswordTokenDuration = time.Duration(1) * time.Hour // 1 hour
+
+// ErrInvalidUserToken is returned when token signature is wrong
+var ErrInvalidUserToken = errors.New("invalid token")
+
+// IssueUserToken generates signed single-use token: {random}.{signature}
+// Signature lets us reject forged tokens without database. Token itself is returned only once, database gets hash.
+func IssueUserToken(userID models.UserID, purpose models.UserTokenPurpose) (string, *models.UserToken, error) {
+	duration := verifyEmailTokenDuration
+	if purpose == models.ResetPassword {
+		duration = resetPasswordTokenDuration
+	}
+
+	random, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	token := random + "." + signUserToken(random, purpose)
+
+	userToken := &models.UserToken{
+		TokenHash: HashToken(token),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: time.Now().Add(duration),
+	}
+
+	return token, userToken, nil
+}
+
+// VerifyUserToken checks token signature and returns hash we use to find token in database
+func VerifyUserToken(token string, purpose models.UserTokenPurpose) (string, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return "", ErrInvalidUserToken
+	}
+
+	if !hmac.Equal([]byte(parts[1]), []byte(signUserToken(parts[0], purpose))) {
+		return "", ErrInvalidUserToken
+	}
+
+	return HashToken(token), nil
+}
+
+func signUserToken(random string, purpose models.UserTokenPurpose) string {
+	mac := hmac.New(sha256.New, jwtKey)
+	mac.Write([]byte(string(purpose) + ":" + random))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
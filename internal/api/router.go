@@ -5,12 +5,16 @@ import (
 	"finance/internal/api/v1"
 	"finance/internal/config"
 	"finance/internal/database"
+	"finance/internal/events"
+	"finance/internal/identity"
+	"finance/internal/mailer"
+	"finance/internal/utils"
 	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func NewRouter(db database.Database) (http.Handler, error) {
+func NewRouter(db database.Database, mail mailer.Mailer, broker *events.Broker, providers map[string]identity.Provider) (http.Handler, error) {
 	permissons := auth.NewPermissions(db)
 
 	router := mux.NewRouter().StrictSlash(true)
@@ -18,15 +22,33 @@ func NewRouter(db database.Database) (http.Handler, error) {
 	apiRouter := router.PathPrefix("/api/" + config.Version).Subrouter()
 
 	/* ---------- ROUTES ---------- */
-	v1.SetUserAPI(db, apiRouter, permissons)
+	v1.SetUserAPI(db, mail, providers, apiRouter, permissons)
+	v1.SetSessionAPI(db, apiRouter, permissons)
+	v1.SetAPIKeyAPI(db, apiRouter, permissons)
+	v1.SetAuditAPI(db, apiRouter, permissons)
 	v1.SetRoleApi(db, apiRouter, permissons)
 	v1.SetCategoryAPI(db, apiRouter, permissons)
 	v1.SetAccountAPI(db, apiRouter, permissons)
 	v1.SetMerchantAPI(db, apiRouter, permissons)
 	v1.SetTransactionAPI(db, apiRouter, permissons)
+	v1.SetTrashAPI(db, apiRouter, permissons)
+	v1.SetBackupAPI(db, apiRouter, permissons)
+	v1.SetReconciliationAPI(db, apiRouter, permissons)
+	v1.SetPeriodAPI(db, apiRouter, permissons)
+	v1.SetReportAPI(db, apiRouter, permissons)
+	v1.SetDebtAPI(db, apiRouter, permissons)
+	v1.SetSecurityAPI(db, apiRouter, permissons)
+	v1.SetTradeAPI(db, apiRouter, permissons)
+	v1.SetGoalAPI(db, apiRouter, permissons)
+	v1.SetBillAPI(db, apiRouter, permissons)
+	v1.SetNotificationAPI(db, apiRouter, permissons)
+	v1.SetWebhookAPI(db, apiRouter, permissons)
+	v1.SetStreamAPI(db, broker, apiRouter, permissons)
+	v1.SetSyncAPI(db, apiRouter, permissons)
 
 	/* ---------- MIDDLEWARE ---------- */
-	router.Use(auth.AuthorizationToken)
+	router.Use(utils.RequestID)
+	router.Use(auth.AuthorizationToken(db))
 
 	return router, nil
 }
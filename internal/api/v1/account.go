@@ -7,6 +7,7 @@ import (
 	"finance/internal/models"
 	"finance/internal/utils"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -14,12 +15,14 @@ import (
 
 // AccountAPI - provides REST for Account
 type AccountAPI struct {
-	DB database.Database
+	DB          database.Database
+	Permissions auth.Permissions
 }
 
 func SetAccountAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
 	api := AccountAPI{
-		DB: db,
+		DB:          db,
+		Permissions: permissons,
 	}
 
 	apis := []API{
@@ -29,6 +32,9 @@ func SetAccountAPI(db database.Database, router *mux.Router, permissons auth.Per
 		NewAPI("/users/{userID}/accounts/{accountID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
 		NewAPI("/users/{userID}/accounts/{accountID}", "PATCH", api.Update, auth.Admin, auth.MemberIsTarget),
 		NewAPI("/users/{userID}/accounts/{accountID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/accounts/{accountID}/restore", "POST", api.Restore, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/accounts/{accountID}/statement", "GET", api.Statement, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/accounts/{accountID}/amortization", "GET", api.Amortization, auth.Admin, auth.MemberIsTarget),
 	}
 
 	for _, api := range apis {
@@ -66,7 +72,13 @@ func (api *AccountAPI) Create(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	// Store role in database
-	if err := api.DB.CreateAccount(ctx, &account); err != nil {
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateAccount(ctx, &account); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditAccount, string(account.ID), nil, &account)
+	})
+	if err != nil {
 		logger.WithError(err).Warn("Error creating account.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error creating account.", nil)
 		return
@@ -105,6 +117,11 @@ func (api *AccountAPI) Update(w http.ResponseWriter, r *http.Request) {
 		utils.ResponseErr(err, w, "Error getting account.", http.StatusConflict)
 		return
 	}
+	before := *account
+
+	if !checkIfMatch(w, r, account.Version) {
+		return
+	}
 
 	if accountRequest.Name != nil || len(*accountRequest.Name) != 0 {
 		account.Name = accountRequest.Name
@@ -118,14 +135,53 @@ func (api *AccountAPI) Update(w http.ResponseWriter, r *http.Request) {
 	if accountRequest.Currency != nil || len(*accountRequest.Currency) != 0 {
 		account.Currency = accountRequest.Currency
 	}
+	if accountRequest.CreditLimit != nil {
+		account.CreditLimit = accountRequest.CreditLimit
+	}
+	if accountRequest.StatementDay != nil {
+		account.StatementDay = accountRequest.StatementDay
+	}
+	if accountRequest.PaymentDueDay != nil {
+		account.PaymentDueDay = accountRequest.PaymentDueDay
+	}
+	if accountRequest.APR != nil {
+		account.APR = accountRequest.APR
+	}
+	if accountRequest.InterestRate != nil {
+		account.InterestRate = accountRequest.InterestRate
+	}
+	if accountRequest.LoanPrincipal != nil {
+		account.LoanPrincipal = accountRequest.LoanPrincipal
+	}
+	if accountRequest.LoanTerm != nil {
+		account.LoanTerm = accountRequest.LoanTerm
+	}
+	if accountRequest.LoanStart != nil {
+		account.LoanStart = accountRequest.LoanStart
+	}
+
+	if err := account.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
 
-	if err := api.DB.UpdateAccount(ctx, account); err != nil {
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.UpdateAccount(ctx, account); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditUpdate, models.AuditAccount, string(accountID), &before, account)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, accountID)
+		return
+	} else if err != nil {
 		logger.WithError(err).Warn("Error updating account.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error updating account.", nil)
 		return
 	}
 
 	logger.Info("Account update")
+	writeETag(w, account.Version)
 	utils.WriteJSON(w, http.StatusOK, account)
 }
 
@@ -181,6 +237,7 @@ func (api *AccountAPI) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Info("Account returned")
+	writeETag(w, account.Version)
 	utils.WriteJSON(w, http.StatusOK, account)
 }
 
@@ -201,8 +258,47 @@ func (api *AccountAPI) Delete(w http.ResponseWriter, r *http.Request) {
 	})
 
 	ctx := r.Context()
-	deleted, err := api.DB.DeleteAccount(ctx, accountID)
+	before, _ := api.DB.GetAccountByID(ctx, accountID) // * for audit and If-Match, missing resource is handled by delete
+	var version int64
+	if before != nil {
+		if !checkIfMatch(w, r, before.Version) {
+			return
+		}
+		version = before.Version
+	}
+
+	user, err := api.DB.GetUserByID(ctx, userID)
 	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	// * Only admin can send transactions of closed period to trash together with account
+	lockDate := user.LockDate
+	if api.Permissions.Check(r, auth.Admin) {
+		lockDate = nil
+	}
+
+	var deleted bool
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteAccount(ctx, accountID, version, lockDate); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditAccount, string(accountID), before, nil)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, accountID)
+		return
+	} else if err == database.ErrAccountReconciled {
+		utils.ResponseErr(err, w, "Account has reconciled transactions and can't be deleted.", http.StatusConflict)
+		return
+	} else if err == database.ErrAccountLocked {
+		utils.WriteError(w, http.StatusConflict, "Account has transactions in closed period.", map[string]interface{}{
+			"lock_date": user.LockDate,
+		})
+		return
+	} else if err != nil {
 		utils.ResponseErr(err, w, "Error deleting account.", http.StatusConflict)
 		return
 	}
@@ -212,3 +308,151 @@ func (api *AccountAPI) Delete(w http.ResponseWriter, r *http.Request) {
 		Deleted: deleted,
 	})
 }
+
+// POST - /users/{userID}/accounts/{accountID}/restore
+// Permission - MemberIsTarget
+func (api *AccountAPI) Restore(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "account.go -> Restore()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	accountID := models.AccountID(vars["accountID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"account_id": accountID,
+	})
+
+	ctx := r.Context()
+	var restored bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if restored, err = db.RestoreAccount(ctx, accountID); err != nil || !restored {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditRestore, models.AuditAccount, string(accountID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error restoring account.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Account restored")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: restored,
+	})
+}
+
+// GET - /users/{userID}/accounts/{accountID}/statement
+// Permission - MemberIsTarget
+func (api *AccountAPI) Statement(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "account.go -> Statement()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	accountID := models.AccountID(vars["accountID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"account_id": accountID,
+	})
+
+	ctx := r.Context()
+	account, ok := api.getAccount(w, r, userID, accountID)
+	if !ok {
+		return
+	}
+	if account.Type == nil || *account.Type != models.Credit || account.StatementDay == nil || account.PaymentDueDay == nil {
+		utils.WriteError(w, http.StatusBadRequest, "Account is not a credit account.", nil)
+		return
+	}
+
+	now := time.Now()
+	closing, _ := account.StatementDates(now)
+	afterClosing := closing.AddDate(0, 0, 1) // * transactions of closing day belong to closed statement
+
+	statementBalance, err := api.DB.GetAccountBalance(ctx, accountID, afterClosing)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting statement balance.", http.StatusConflict)
+		return
+	}
+
+	currentBalance, err := api.DB.GetAccountBalance(ctx, accountID, now)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting current balance.", http.StatusConflict)
+		return
+	}
+
+	paid, err := api.DB.SumAccountTransactions(ctx, accountID, models.Income, afterClosing, now)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting payments.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Credit statement returned")
+	utils.WriteJSON(w, http.StatusOK, models.NewCreditStatement(account, now, statementBalance, currentBalance, paid))
+}
+
+// GET - /users/{userID}/accounts/{accountID}/amortization
+// Permission - MemberIsTarget
+func (api *AccountAPI) Amortization(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "account.go -> Amortization()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	accountID := models.AccountID(vars["accountID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"account_id": accountID,
+	})
+
+	account, ok := api.getAccount(w, r, userID, accountID)
+	if !ok {
+		return
+	}
+	if account.Type == nil || *account.Type != models.Loan {
+		utils.WriteError(w, http.StatusBadRequest, "Account is not a loan account.", nil)
+		return
+	}
+	if err := account.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Loan settings are incomplete.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Amortization schedule returned")
+	utils.WriteJSON(w, http.StatusOK, models.NewAmortizationSchedule(account))
+}
+
+// writeChanged - account was changed since client has read it, client gets ETag of current version
+func (api *AccountAPI) writeChanged(w http.ResponseWriter, r *http.Request, accountID models.AccountID) {
+	account, err := api.DB.GetAccountByID(r.Context(), accountID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting account.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, account.Version)
+}
+
+// getAccount - account is found only under path of its owner, so user can't reach account of another user
+func (api *AccountAPI) getAccount(w http.ResponseWriter, r *http.Request, userID models.UserID, accountID models.AccountID) (*models.Account, bool) {
+	account, err := api.DB.GetAccountByID(r.Context(), accountID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting account.", http.StatusConflict)
+		return nil, false
+	}
+
+	if account.UserID == nil || *account.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, "Account not found.", nil)
+		return nil, false
+	}
+
+	return account, true
+}
@@ -0,0 +1,166 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// APIKeyAPI - provides REST for personal API keys
+type APIKeyAPI struct {
+	DB database.Database
+}
+
+func SetAPIKeyAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := APIKeyAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- API KEYS ---------- */
+		NewAPI("/users/{userID}/api-keys", "POST", auth.DenyAPIKey(api.Create), auth.MemberIsTarget),
+		NewAPI("/users/{userID}/api-keys", "GET", auth.DenyAPIKey(api.List), auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/api-keys/{apiKeyID}", "DELETE", auth.DenyAPIKey(api.Revoke), auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// CreatedAPIKey - full key is returned only once, when key is created
+type CreatedAPIKey struct {
+	*models.APIKey
+
+	Key string `json:"key"`
+}
+
+// POST - /users/{userID}/api-keys
+// Permission - MemberIsTarget
+func (api *APIKeyAPI) Create(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "api_key.go -> Create()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// Decode parameters
+	var apiKey models.APIKey
+	if err := json.NewDecoder(r.Body).Decode(&apiKey); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	apiKey.UserID = &userID
+	if apiKey.Scope == "" {
+		apiKey.Scope = models.ScopeWrite
+	}
+
+	if err := apiKey.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	key, prefix, err := auth.GenerateAPIKey()
+	if err != nil {
+		utils.ResponseErr(err, w, "Error generating API key.", http.StatusInternalServerError)
+		return
+	}
+	apiKey.Prefix = prefix
+	apiKey.KeyHash = auth.HashToken(key)
+
+	ctx := r.Context()
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateAPIKey(ctx, &apiKey); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditAPIKey, string(apiKey.ID), nil, &apiKey)
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Error creating API key.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error creating API key.", nil)
+		return
+	}
+
+	logger.WithField("apiKeyID", apiKey.ID).Info("API key created")
+	utils.WriteJSON(w, http.StatusCreated, &CreatedAPIKey{
+		APIKey: &apiKey,
+		Key:    key,
+	})
+}
+
+// GET - /users/{userID}/api-keys
+// Permission - Admin, MemberIsTarget
+func (api *APIKeyAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "api_key.go -> List()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	apiKeys, err := api.DB.ListAPIKeys(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting API keys.", http.StatusConflict)
+		return
+	}
+
+	if apiKeys == nil {
+		apiKeys = make([]*models.APIKey, 0)
+	}
+
+	logger.Info("API keys returned")
+	utils.WriteJSON(w, http.StatusOK, apiKeys)
+}
+
+// DELETE - /users/{userID}/api-keys/{apiKeyID}
+// Permission - Admin, MemberIsTarget
+func (api *APIKeyAPI) Revoke(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "api_key.go -> Revoke()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	apiKeyID := models.APIKeyID(vars["apiKeyID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"api_key_id": apiKeyID,
+	})
+
+	ctx := r.Context()
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.RevokeAPIKey(ctx, userID, apiKeyID); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditAPIKey, string(apiKeyID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error revoking API key.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("API key revoked")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
@@ -0,0 +1,238 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// AuditAPI - provides REST for audit log
+type AuditAPI struct {
+	DB database.Database
+}
+
+func SetAuditAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := AuditAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- AUDIT ---------- */
+		NewAPI("/users/{userID}/audit", "GET", api.ListByUser, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/audit", "GET", api.List, auth.Admin),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// GET - /users/{userID}/audit?action={action}&resource_type={type}&resource_id={id}&from={from}&to={to}&limit={limit}
+// Permission - Admin, MemberIsTarget
+func (api *AuditAPI) ListByUser(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "audit.go -> ListByUser()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	filter, err := auditFilter(r.URL.Query())
+	if err != nil {
+		utils.ResponseErrWithMap(err, w, "Invalid filter.", http.StatusBadRequest)
+		return
+	}
+	filter.UserID = userID
+
+	api.writeEvents(w, r, filter)
+	logger.Info("Audit events returned")
+}
+
+// GET - /audit?user_id={userID}&principal_id={principalID}&action={action}&resource_type={type}&resource_id={id}&from={from}&to={to}&limit={limit}
+// Permission - Admin
+func (api *AuditAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "audit.go -> List()")
+
+	principal := auth.GetPrincipal(r)
+	logger = logger.WithField("principal", principal)
+
+	query := r.URL.Query()
+	filter, err := auditFilter(query)
+	if err != nil {
+		utils.ResponseErrWithMap(err, w, "Invalid filter.", http.StatusBadRequest)
+		return
+	}
+	filter.UserID = models.UserID(query.Get("user_id"))
+	filter.PrincipalID = models.UserID(query.Get("principal_id"))
+
+	api.writeEvents(w, r, filter)
+	logger.Info("Audit events returned")
+}
+
+func (api *AuditAPI) writeEvents(w http.ResponseWriter, r *http.Request, filter models.AuditFilter) {
+	ctx := r.Context()
+	events, err := api.DB.ListAuditEvents(ctx, filter)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting audit events.", http.StatusConflict)
+		return
+	}
+	if events == nil {
+		events = make([]*models.AuditEvent, 0)
+	}
+
+	utils.WriteJSON(w, http.StatusOK, events)
+}
+
+// auditFilter - parse filter fields which are common for user and admin queries
+func auditFilter(query url.Values) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		Action:       models.AuditAction(query.Get("action")),
+		ResourceType: models.AuditResourceType(query.Get("resource_type")),
+		ResourceID:   query.Get("resource_id"),
+	}
+
+	var err error
+	if value := query.Get("from"); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, err
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, err
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+// * Changes of user's credentials which are not visible in user's JSON
+var (
+	auditPasswordChanged = map[string]bool{"password_changed": true}
+	auditEmailVerified   = map[string]bool{"email_verified": true}
+)
+
+// writeAudit - Store who changed what. It is called with database of the same transaction as change,
+// so change is not stored without audit event. before is nil for create and after is nil for delete.
+// For update only changed fields are stored.
+func writeAudit(db database.AuditDB, r *http.Request, userID models.UserID, action models.AuditAction, resourceType models.AuditResourceType, resourceID string, before, after interface{}) error {
+	logger := logrus.WithFields(logrus.Fields{
+		"func":          "audit.go -> writeAudit()",
+		"action":        action,
+		"resource_type": resourceType,
+		"resource_id":   resourceID,
+	})
+
+	principal := auth.GetPrincipal(r)
+	event := models.AuditEvent{
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		IPAddress:    utils.ClientIP(r),
+		RequestID:    utils.GetRequestID(r),
+	}
+	if principal.UserID != models.NilUserID {
+		event.PrincipalID = &principal.UserID
+	}
+	if principal.APIKeyID != models.NilAPIKeyID {
+		event.APIKeyID = &principal.APIKeyID
+	}
+	if userID != models.NilUserID {
+		event.UserID = &userID
+	}
+
+	var err error
+	if event.Before, event.After, err = auditDiff(before, after); err != nil {
+		logger.WithError(err).Warn("Error encoding audit diff.")
+	}
+
+	if err := db.CreateAuditEvent(r.Context(), &event); err != nil {
+		logger.WithError(err).Error("Error storing audit event.")
+		return err
+	}
+	return nil
+}
+
+// auditDiff - encode resource before and after change. Fields which are the same in both are removed.
+func auditDiff(before, after interface{}) (*json.RawMessage, *json.RawMessage, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if beforeFields != nil && afterFields != nil {
+		for key, value := range beforeFields {
+			if afterValue, ok := afterFields[key]; ok && reflect.DeepEqual(value, afterValue) {
+				delete(beforeFields, key)
+				delete(afterFields, key)
+			}
+		}
+	}
+
+	beforeJSON, err := auditJSON(beforeFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterJSON, err := auditJSON(afterFields)
+	if err != nil {
+		return nil, nil, err
+	}
+	return beforeJSON, afterJSON, nil
+}
+
+// auditFields - resource as it is returned by API (so secrets with json:"-" are never stored)
+func auditFields(resource interface{}) (map[string]interface{}, error) {
+	if resource == nil {
+		return nil, nil
+	}
+	if value := reflect.ValueOf(resource); value.Kind() == reflect.Ptr && value.IsNil() {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+func auditJSON(fields map[string]interface{}) (*json.RawMessage, error) {
+	if fields == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	raw := json.RawMessage(data)
+	return &raw, nil
+}
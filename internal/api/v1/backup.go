@@ -0,0 +1,128 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// maxBackupSize limits size of imported backup
+const maxBackupSize = 32 << 20 // 32 MB
+
+// BackupAPI - provides REST for backup and restore of user's ledger
+type BackupAPI struct {
+	DB          database.Database
+	Permissions auth.Permissions
+}
+
+func SetBackupAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := BackupAPI{
+		DB:          db,
+		Permissions: permissons,
+	}
+
+	apis := []API{
+		/* ---------- BACKUP ---------- */
+		NewAPI("/users/{userID}/backup", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/backup", "POST", api.Import, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// GET - /users/{userID}/backup
+// Permission - Admin, MemberIsTarget
+func (api *BackupAPI) Get(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "backup.go -> Get()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	backup, err := api.DB.GetBackup(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error creating backup.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Backup returned")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"backup-%s.json\"", userID))
+	utils.WriteJSON(w, http.StatusOK, backup)
+}
+
+// POST - /users/{userID}/backup
+// Permission - Admin, MemberIsTarget
+func (api *BackupAPI) Import(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "backup.go -> Import()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// Decode parameters
+	var backup models.Backup
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBackupSize)).Decode(&backup); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	if err := backup.Verify(userID); err != nil {
+		utils.ResponseErrWithMap(err, w, "Invalid backup.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := api.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	// * Only admin can import transactions into closed period
+	if !api.Permissions.Check(r, auth.Admin) {
+		for _, transaction := range backup.Transactions {
+			if transaction.Date != nil && user.IsLocked(*transaction.Date) {
+				utils.WriteError(w, http.StatusConflict, "Transaction belongs to closed period.", map[string]interface{}{
+					"lock_date": user.LockDate,
+				})
+				return
+			}
+		}
+	}
+
+	var imported *models.BackupImport
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if imported, err = db.ImportBackup(ctx, userID, &backup); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditBackup, "", nil, imported)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error importing backup.", http.StatusConflict)
+		return
+	}
+
+	logger.WithField("imported", imported).Info("Backup imported")
+	utils.WriteJSON(w, http.StatusCreated, imported)
+}
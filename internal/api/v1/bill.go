@@ -0,0 +1,516 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// calendarPeriod - calendar shows this period when "to" is not given
+const calendarPeriod = 30 * 24 * time.Hour
+
+// BillAPI - provides REST for bills and calendar of upcoming payments
+type BillAPI struct {
+	DB database.Database
+}
+
+func SetBillAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := BillAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- BILLS ---------- */
+		NewAPI("/users/{userID}/bills", "POST", api.Create, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/bills", "GET", api.List, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/bills/{billID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/bills/{billID}", "PATCH", api.Update, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/bills/{billID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/bills/{billID}/restore", "POST", api.Restore, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/bills/{billID}/pay", "POST", api.Pay, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/bills/{billID}/payments", "GET", api.Payments, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/calendar", "GET", api.Calendar, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// POST - /users/{userID}/bills
+// Permission - MemberIsTarget
+func (api *BillAPI) Create(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "bill.go -> Create()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// Decode parameters
+	var bill models.Bill
+	if err := json.NewDecoder(r.Body).Decode(&bill); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	bill.UserID = &userID
+	if bill.Frequency == nil {
+		frequency := models.Once
+		bill.Frequency = &frequency
+	}
+
+	if err := bill.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	// * Bill can be paid only from account of its user
+	if !writeReferences(w, r, api.DB, userID, bill.AccountID, nil, nil) {
+		return
+	}
+
+	ctx := r.Context()
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateBill(ctx, &bill); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditBill, string(bill.ID), nil, &bill)
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Error creating bill.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error creating bill.", nil)
+		return
+	}
+
+	logger.WithField("billID", bill.ID).Info("Bill created")
+	utils.WriteJSON(w, http.StatusCreated, bill)
+}
+
+// PATCH - /users/{userID}/bills/{billID}
+// Permission - MemberIsTarget
+func (api *BillAPI) Update(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "bill.go -> Update()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	billID := models.BillID(vars["billID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"bill_id":   billID,
+	})
+
+	// Decode parameters
+	var billRequest models.Bill
+	if err := json.NewDecoder(r.Body).Decode(&billRequest); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	bill, ok := api.getBill(w, r, userID, billID)
+	if !ok {
+		return
+	}
+	before := *bill
+
+	if !checkIfMatch(w, r, bill.Version) {
+		return
+	}
+
+	if billRequest.AccountID != nil {
+		bill.AccountID = billRequest.AccountID
+	}
+	if billRequest.Name != nil && len(*billRequest.Name) != 0 {
+		bill.Name = billRequest.Name
+	}
+	if billRequest.Amount != nil {
+		bill.Amount = billRequest.Amount
+	}
+	if billRequest.DueDate != nil {
+		bill.SetDueDate(*billRequest.DueDate)
+	}
+	if billRequest.Frequency != nil {
+		bill.Frequency = billRequest.Frequency
+	}
+	if billRequest.Notes != nil {
+		bill.Notes = billRequest.Notes
+	}
+
+	if err := bill.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	if billRequest.AccountID != nil && !writeReferences(w, r, api.DB, userID, bill.AccountID, nil, nil) {
+		return
+	}
+
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.UpdateBill(ctx, bill); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditUpdate, models.AuditBill, string(billID), &before, bill)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, billID)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error updating bill.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error updating bill.", nil)
+		return
+	}
+
+	logger.Info("Bill update")
+	writeETag(w, bill.Version)
+	utils.WriteJSON(w, http.StatusOK, bill)
+}
+
+// GET - /users/{userID}/bills
+// Permission - MemberIsTarget
+func (api *BillAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "bill.go -> List()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	bills, err := api.DB.ListBillsByUserID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting bills.", http.StatusConflict)
+		return
+	}
+
+	if bills == nil {
+		bills = make([]*models.Bill, 0)
+	}
+
+	logger.Info("Bills returned")
+	utils.WriteJSON(w, http.StatusOK, bills)
+}
+
+// GET - /users/{userID}/bills/{billID}
+// Permission - MemberIsTarget
+func (api *BillAPI) Get(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "bill.go -> Get()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	billID := models.BillID(vars["billID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"bill_id":   billID,
+	})
+
+	bill, ok := api.getBill(w, r, userID, billID)
+	if !ok {
+		return
+	}
+
+	logger.Info("Bill returned")
+	writeETag(w, bill.Version)
+	utils.WriteJSON(w, http.StatusOK, bill)
+}
+
+// DELETE - /users/{userID}/bills/{billID}
+// Permission - MemberIsTarget
+func (api *BillAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "bill.go -> Delete()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	billID := models.BillID(vars["billID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"bill_id":   billID,
+	})
+
+	ctx := r.Context()
+	before, ok := api.getBill(w, r, userID, billID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, before.Version) {
+		return
+	}
+
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteBill(ctx, billID, before.Version); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditBill, string(billID), before, nil)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, billID)
+		return
+	} else if err != nil {
+		utils.ResponseErr(err, w, "Error deleting bill.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Bill deleted")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
+
+// POST - /users/{userID}/bills/{billID}/restore
+// Permission - MemberIsTarget
+func (api *BillAPI) Restore(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "bill.go -> Restore()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	billID := models.BillID(vars["billID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"bill_id":   billID,
+	})
+
+	if _, ok := api.getBill(w, r, userID, billID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	var restored bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if restored, err = db.RestoreBill(ctx, billID); err != nil || !restored {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditRestore, models.AuditBill, string(billID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error restoring bill.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Bill restored")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: restored,
+	})
+}
+
+// POST - /users/{userID}/bills/{billID}/pay
+// Permission - MemberIsTarget
+func (api *BillAPI) Pay(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "bill.go -> Pay()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	billID := models.BillID(vars["billID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"bill_id":   billID,
+	})
+
+	// Decode parameters
+	var payment models.BillPayment
+	if err := json.NewDecoder(r.Body).Decode(&payment); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+	if payment.TransactionID == nil || len(*payment.TransactionID) == 0 {
+		utils.ResponseErrWithMap(errors.New("transaction_id is required"), w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	payment.UserID = &userID
+	payment.BillID = &billID
+
+	ctx := r.Context()
+	bill, ok := api.getBill(w, r, userID, billID)
+	if !ok {
+		return
+	}
+	if bill.DeletedAt != nil {
+		utils.WriteError(w, http.StatusConflict, "Bill is deleted.", nil)
+		return
+	}
+
+	transaction, err := api.DB.GetTransactionByID(ctx, *payment.TransactionID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting transaction.", http.StatusConflict)
+		return
+	}
+	if transaction.UserID == nil || *transaction.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, "Transaction not found.", nil)
+		return
+	}
+	if transaction.DeletedAt != nil || *transaction.Type != models.Expense {
+		utils.WriteError(w, http.StatusBadRequest, "Bill can be paid only by expense transaction.", nil)
+		return
+	}
+
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.PayBill(ctx, &payment); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditBillPayment, string(payment.ID), nil, &payment)
+	})
+	if err == database.ErrBillPaid || err == database.ErrTransactionPaysBill {
+		utils.ResponseErr(err, w, "Error paying bill.", http.StatusConflict)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error paying bill.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error paying bill.", nil)
+		return
+	}
+
+	logger.WithField("paymentID", payment.ID).Info("Bill paid")
+	utils.WriteJSON(w, http.StatusCreated, payment)
+}
+
+// GET - /users/{userID}/bills/{billID}/payments
+// Permission - MemberIsTarget
+func (api *BillAPI) Payments(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "bill.go -> Payments()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	billID := models.BillID(vars["billID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"bill_id":   billID,
+	})
+
+	if _, ok := api.getBill(w, r, userID, billID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	payments, err := api.DB.ListBillPayments(ctx, billID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting bill payments.", http.StatusConflict)
+		return
+	}
+
+	if payments == nil {
+		payments = make([]*models.BillPayment, 0)
+	}
+
+	logger.Info("Bill payments returned")
+	utils.WriteJSON(w, http.StatusOK, payments)
+}
+
+// GET - /users/{userID}/calendar?from=2006-01-02&to=2006-01-31
+// Permission - MemberIsTarget
+func (api *BillAPI) Calendar(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "bill.go -> Calendar()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// * Calendar starts today by default, "to" includes the whole day
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to := from.Add(calendarPeriod)
+	query := r.URL.Query()
+
+	var err error
+	if value := query.Get("from"); value != "" {
+		if from, err = time.Parse("2006-01-02", value); err != nil {
+			utils.ResponseErrWithMap(err, w, "Could not parse from.", http.StatusBadRequest)
+			return
+		}
+		to = from.Add(calendarPeriod)
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse("2006-01-02", value); err != nil {
+			utils.ResponseErrWithMap(err, w, "Could not parse to.", http.StatusBadRequest)
+			return
+		}
+	}
+	to = to.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	if to.Before(from) {
+		utils.WriteError(w, http.StatusBadRequest, "to is before from.", nil)
+		return
+	}
+
+	ctx := r.Context()
+	bills, err := api.DB.ListBillsByUserID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting bills.", http.StatusConflict)
+		return
+	}
+
+	payments, err := api.DB.ListBillPaymentsByUserID(ctx, userID, from, to)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting bill payments.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Calendar returned")
+	utils.WriteJSON(w, http.StatusOK, models.NewCalendar(bills, payments, from, to, now))
+}
+
+// writeChanged - bill was changed since client has read it, client gets ETag of current version
+func (api *BillAPI) writeChanged(w http.ResponseWriter, r *http.Request, billID models.BillID) {
+	bill, err := api.DB.GetBillByID(r.Context(), billID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting bill.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, bill.Version)
+}
+
+// getBill - bill is found only under path of its owner, so user can't reach bill of another user
+func (api *BillAPI) getBill(w http.ResponseWriter, r *http.Request, userID models.UserID, billID models.BillID) (*models.Bill, bool) {
+	bill, err := api.DB.GetBillByID(r.Context(), billID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting bill.", http.StatusConflict)
+		return nil, false
+	}
+
+	if bill.UserID == nil || *bill.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, "Bill not found.", nil)
+		return nil, false
+	}
+
+	return bill, true
+}
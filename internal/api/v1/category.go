@@ -29,6 +29,7 @@ func SetCategoryAPI(db database.Database, router *mux.Router, permissons auth.Pe
 		NewAPI("/users/{userID}/categories/{categoryID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
 		NewAPI("/users/{userID}/categories/{categoryID}", "PATCH", api.Update, auth.Admin, auth.MemberIsTarget),
 		NewAPI("/users/{userID}/categories/{categoryID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/categories/{categoryID}/restore", "POST", api.Restore, auth.Admin, auth.MemberIsTarget),
 	}
 
 	for _, api := range apis {
@@ -66,7 +67,13 @@ func (api *CategoryAPI) Create(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	// Store role in database
-	if err := api.DB.CreateCategory(ctx, &category); err != nil {
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateCategory(ctx, &category); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditCategory, string(category.ID), nil, &category)
+	})
+	if err != nil {
 		logger.WithError(err).Warn("Error creating category.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error creating category.", nil)
 		return
@@ -105,6 +112,11 @@ func (api *CategoryAPI) Update(w http.ResponseWriter, r *http.Request) {
 		utils.ResponseErr(err, w, "Error getting category.", http.StatusConflict)
 		return
 	}
+	before := *category
+
+	if !checkIfMatch(w, r, category.Version) {
+		return
+	}
 
 	if categoryRequest.ParentID != "" {
 		category.ParentID = categoryRequest.ParentID
@@ -114,13 +126,23 @@ func (api *CategoryAPI) Update(w http.ResponseWriter, r *http.Request) {
 		category.Name = categoryRequest.Name
 	}
 
-	if err := api.DB.UpdateCategory(ctx, category); err != nil {
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.UpdateCategory(ctx, category); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditUpdate, models.AuditCategory, string(categoryID), &before, category)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, categoryID)
+		return
+	} else if err != nil {
 		logger.WithError(err).Warn("Error updating category.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error updating category.", nil)
 		return
 	}
 
 	logger.Info("Category update")
+	writeETag(w, category.Version)
 	utils.WriteJSON(w, http.StatusOK, category)
 }
 
@@ -177,6 +199,7 @@ func (api *CategoryAPI) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Info("Category returned")
+	writeETag(w, category.Version)
 	utils.WriteJSON(w, http.StatusOK, category)
 }
 
@@ -197,8 +220,26 @@ func (api *CategoryAPI) Delete(w http.ResponseWriter, r *http.Request) {
 	})
 
 	ctx := r.Context()
-	deleted, err := api.DB.DeleteCategory(ctx, categoryID)
-	if err != nil {
+	before, _ := api.DB.GetCategoryByID(ctx, categoryID) // * for audit and If-Match, missing resource is handled by delete
+	var version int64
+	if before != nil {
+		if !checkIfMatch(w, r, before.Version) {
+			return
+		}
+		version = before.Version
+	}
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteCategory(ctx, categoryID, version); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditCategory, string(categoryID), before, nil)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, categoryID)
+		return
+	} else if err != nil {
 		utils.ResponseErr(err, w, "Error deleting category.", http.StatusConflict)
 		return
 	}
@@ -208,3 +249,65 @@ func (api *CategoryAPI) Delete(w http.ResponseWriter, r *http.Request) {
 		Deleted: deleted,
 	})
 }
+
+// POST - /users/{userID}/categories/{categoryID}/restore
+// Permission - MemberIsTarget
+func (api *CategoryAPI) Restore(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "category.go -> Restore()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	categoryID := models.CategoryID(vars["categoryID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"principal":   principal,
+		"category_id": categoryID,
+	})
+
+	ctx := r.Context()
+	category, err := api.DB.GetCategoryByID(ctx, categoryID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting category.", http.StatusConflict)
+		return
+	}
+
+	// * Subcategory can't be restored under deleted parent
+	if category.ParentID != models.NilCategoryID {
+		parent, err := api.DB.GetCategoryByID(ctx, category.ParentID)
+		if err == nil && parent.DeletedAt != nil {
+			utils.WriteError(w, http.StatusConflict, "Parent category is deleted, restore it first.", nil)
+			return
+		}
+	}
+
+	var restored bool
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if restored, err = db.RestoreCategory(ctx, categoryID); err != nil || !restored {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditRestore, models.AuditCategory, string(categoryID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error restoring category.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Category restored")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: restored,
+	})
+}
+
+// writeChanged - category was changed since client has read it, client gets ETag of current version
+func (api *CategoryAPI) writeChanged(w http.ResponseWriter, r *http.Request, categoryID models.CategoryID) {
+	category, err := api.DB.GetCategoryByID(r.Context(), categoryID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting category.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, category.Version)
+}
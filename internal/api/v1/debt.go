@@ -0,0 +1,503 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// DebtAPI - provides REST for money lent and borrowed
+type DebtAPI struct {
+	DB          database.Database
+	Permissions auth.Permissions
+}
+
+func SetDebtAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := DebtAPI{
+		DB:          db,
+		Permissions: permissons,
+	}
+
+	apis := []API{
+		/* ---------- DEBTS ---------- */
+		NewAPI("/users/{userID}/debts", "POST", api.Create, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/debts", "GET", api.List, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/debts/summary", "GET", api.Summary, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/debts/{debtID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/debts/{debtID}", "PATCH", api.Update, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/debts/{debtID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/debts/{debtID}/restore", "POST", api.Restore, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/debts/{debtID}/schedule", "GET", api.Schedule, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/debts/{debtID}/repayments", "POST", api.Repay, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/debts/{debtID}/repayments", "GET", api.Repayments, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// POST - /users/{userID}/debts
+// Permission - MemberIsTarget
+func (api *DebtAPI) Create(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "debt.go -> Create()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// Decode parameters
+	var debt models.Debt
+	if err := json.NewDecoder(r.Body).Decode(&debt); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	debt.UserID = &userID
+
+	if err := debt.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateDebt(ctx, &debt); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditDebt, string(debt.ID), nil, &debt)
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Error creating debt.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error creating debt.", nil)
+		return
+	}
+
+	logger.WithField("debtID", debt.ID).Info("Debt created")
+	utils.WriteJSON(w, http.StatusCreated, debt)
+}
+
+// PATCH - /users/{userID}/debts/{debtID}
+// Permission - MemberIsTarget
+func (api *DebtAPI) Update(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "debt.go -> Update()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	debtID := models.DebtID(vars["debtID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"debt_id":   debtID,
+	})
+
+	// Decode parameters
+	var debtRequest models.Debt
+	if err := json.NewDecoder(r.Body).Decode(&debtRequest); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	debt, ok := api.getDebt(w, r, userID, debtID)
+	if !ok {
+		return
+	}
+	before := *debt
+
+	if !checkIfMatch(w, r, debt.Version) {
+		return
+	}
+
+	if debtRequest.Counterparty != nil && len(*debtRequest.Counterparty) != 0 {
+		debt.Counterparty = debtRequest.Counterparty
+	}
+	if debtRequest.Direction != nil {
+		debt.Direction = debtRequest.Direction
+	}
+	if debtRequest.Principal != nil {
+		debt.Principal = debtRequest.Principal
+	}
+	if debtRequest.InterestRate != nil {
+		debt.InterestRate = debtRequest.InterestRate
+	}
+	if debtRequest.Term != nil {
+		debt.Term = debtRequest.Term
+	}
+	if debtRequest.StartDate != nil {
+		debt.StartDate = debtRequest.StartDate
+	}
+	if debtRequest.Currency != nil && len(*debtRequest.Currency) != 0 {
+		debt.Currency = debtRequest.Currency
+	}
+	if debtRequest.Notes != nil {
+		debt.Notes = debtRequest.Notes
+	}
+
+	if err := debt.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.UpdateDebt(ctx, debt); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditUpdate, models.AuditDebt, string(debtID), &before, debt)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, debtID)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error updating debt.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error updating debt.", nil)
+		return
+	}
+
+	logger.Info("Debt update")
+	writeETag(w, debt.Version)
+	utils.WriteJSON(w, http.StatusOK, debt)
+}
+
+// GET - /users/{userID}/debts
+// Permission - MemberIsTarget
+func (api *DebtAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "debt.go -> List()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	debts, err := api.DB.ListDebtsByUserID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting debts.", http.StatusConflict)
+		return
+	}
+
+	if debts == nil {
+		debts = make([]*models.Debt, 0)
+	}
+
+	logger.Info("Debts returned")
+	utils.WriteJSON(w, http.StatusOK, debts)
+}
+
+// GET - /users/{userID}/debts/summary
+// Permission - MemberIsTarget
+func (api *DebtAPI) Summary(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "debt.go -> Summary()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	debts, err := api.DB.ListDebtsByUserID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting debts.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Debt summary returned")
+	utils.WriteJSON(w, http.StatusOK, models.NewDebtSummaries(debts))
+}
+
+// GET - /users/{userID}/debts/{debtID}
+// Permission - MemberIsTarget
+func (api *DebtAPI) Get(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "debt.go -> Get()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	debtID := models.DebtID(vars["debtID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"debt_id":   debtID,
+	})
+
+	debt, ok := api.getDebt(w, r, userID, debtID)
+	if !ok {
+		return
+	}
+
+	logger.Info("Debt returned")
+	writeETag(w, debt.Version)
+	utils.WriteJSON(w, http.StatusOK, debt)
+}
+
+// DELETE - /users/{userID}/debts/{debtID}
+// Permission - MemberIsTarget
+func (api *DebtAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "debt.go -> Delete()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	debtID := models.DebtID(vars["debtID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"debt_id":   debtID,
+	})
+
+	ctx := r.Context()
+	before, ok := api.getDebt(w, r, userID, debtID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, before.Version) {
+		return
+	}
+
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteDebt(ctx, debtID, before.Version); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditDebt, string(debtID), before, nil)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, debtID)
+		return
+	} else if err != nil {
+		utils.ResponseErr(err, w, "Error deleting debt.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Debt deleted")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
+
+// POST - /users/{userID}/debts/{debtID}/restore
+// Permission - MemberIsTarget
+func (api *DebtAPI) Restore(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "debt.go -> Restore()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	debtID := models.DebtID(vars["debtID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"debt_id":   debtID,
+	})
+
+	if _, ok := api.getDebt(w, r, userID, debtID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	var restored bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if restored, err = db.RestoreDebt(ctx, debtID); err != nil || !restored {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditRestore, models.AuditDebt, string(debtID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error restoring debt.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Debt restored")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: restored,
+	})
+}
+
+// GET - /users/{userID}/debts/{debtID}/schedule
+// Permission - MemberIsTarget
+func (api *DebtAPI) Schedule(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "debt.go -> Schedule()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	debtID := models.DebtID(vars["debtID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"debt_id":   debtID,
+	})
+
+	debt, ok := api.getDebt(w, r, userID, debtID)
+	if !ok {
+		return
+	}
+
+	schedule := debt.Schedule()
+	if schedule == nil {
+		utils.WriteError(w, http.StatusBadRequest, "Debt has no term, it has no schedule.", nil)
+		return
+	}
+
+	logger.Info("Debt schedule returned")
+	utils.WriteJSON(w, http.StatusOK, schedule)
+}
+
+// POST - /users/{userID}/debts/{debtID}/repayments
+// Permission - MemberIsTarget
+func (api *DebtAPI) Repay(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "debt.go -> Repay()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	debtID := models.DebtID(vars["debtID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"debt_id":   debtID,
+	})
+
+	// Decode parameters
+	var transaction models.Transaction
+	if err := json.NewDecoder(r.Body).Decode(&transaction); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	debt, ok := api.getDebt(w, r, userID, debtID)
+	if !ok {
+		return
+	}
+	if debt.DeletedAt != nil {
+		utils.WriteError(w, http.StatusConflict, "Debt is deleted.", nil)
+		return
+	}
+
+	// * Direction of money is given by debt
+	repaymentType := debt.RepaymentType()
+	transaction.UserID = &userID
+	transaction.DebtID = &debtID
+	transaction.Type = &repaymentType
+
+	if err := transaction.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	// * Repayment moves money of user, so it can't be booked to account or category of another user
+	if !writeReferences(w, r, api.DB, userID, transaction.AccountID, transaction.CategoryID, nil) {
+		return
+	}
+
+	transactions := TransactionAPI{DB: api.DB, Permissions: api.Permissions}
+	if !transactions.checkLockDate(w, r, userID, transaction.Date) {
+		return
+	}
+
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateTransaction(ctx, &transaction); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditTransaction, string(transaction.ID), nil, &transaction)
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Error creating repayment.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error creating repayment.", nil)
+		return
+	}
+
+	logger.WithField("transactionID", transaction.ID).Info("Debt repayment created")
+	utils.WriteJSON(w, http.StatusCreated, transaction)
+}
+
+// GET - /users/{userID}/debts/{debtID}/repayments
+// Permission - MemberIsTarget
+func (api *DebtAPI) Repayments(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "debt.go -> Repayments()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	debtID := models.DebtID(vars["debtID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"debt_id":   debtID,
+	})
+
+	if _, ok := api.getDebt(w, r, userID, debtID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	transactions, err := api.DB.ListDebtRepayments(ctx, debtID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting repayments.", http.StatusConflict)
+		return
+	}
+
+	if transactions == nil {
+		transactions = make([]*models.Transaction, 0)
+	}
+
+	logger.Info("Debt repayments returned")
+	utils.WriteJSON(w, http.StatusOK, transactions)
+}
+
+// writeChanged - debt was changed since client has read it, client gets ETag of current version
+func (api *DebtAPI) writeChanged(w http.ResponseWriter, r *http.Request, debtID models.DebtID) {
+	debt, err := api.DB.GetDebtByID(r.Context(), debtID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting debt.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, debt.Version)
+}
+
+// getDebt - debt is found only under path of its owner, so user can't reach debt of another user
+func (api *DebtAPI) getDebt(w http.ResponseWriter, r *http.Request, userID models.UserID, debtID models.DebtID) (*models.Debt, bool) {
+	debt, err := api.DB.GetDebtByID(r.Context(), debtID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting debt.", http.StatusConflict)
+		return nil, false
+	}
+
+	if debt.UserID == nil || *debt.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, "Debt not found.", nil)
+		return nil, false
+	}
+
+	return debt, true
+}
@@ -0,0 +1,54 @@
+package v1
+
+import (
+	"finance/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// * ETag of resource is its version, database changes version on every update of resource.
+// Every stored resource is versioned: users (lock date is part of user), notification preferences, accounts,
+// categories, merchants, transactions, debts, goals, bills, webhooks, trades, reconciliations and securities.
+// Securities have no endpoint which changes one security, so their version is only returned.
+// Notification preference which is not stored yet is at version 0 and has no ETag, If-Match "0" expects it is still so.
+// If-Match is optional (no 428 Precondition Required), so clients written before ETags keep working.
+
+// etag - strong validator of version
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// writeETag - sets ETag of returned resource, resources created before versioning have no ETag
+func writeETag(w http.ResponseWriter, version int64) {
+	if version > 0 {
+		w.Header().Set("ETag", etag(version))
+	}
+}
+
+// checkIfMatch - If-Match must contain ETag of current version of resource, request without If-Match is not checked.
+// Returns false when error response was written.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, version int64) bool {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return true
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(strings.Join(values, ","), ",") {
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == current {
+			return true
+		}
+	}
+
+	writePreconditionFailed(w, version)
+	return false
+}
+
+// writePreconditionFailed - resource was changed since client has read it, client gets ETag of current version
+func writePreconditionFailed(w http.ResponseWriter, version int64) {
+	writeETag(w, version)
+	utils.WriteError(w, http.StatusPreconditionFailed, "Resource was changed.", map[string]interface{}{
+		"etag": etag(version),
+	})
+}
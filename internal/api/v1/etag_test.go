@@ -0,0 +1,284 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"finance/internal/database"
+	"finance/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCheckIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch []string
+		want    bool
+	}{
+		{name: "no If-Match", want: true},
+		{name: "current version", ifMatch: []string{`"3"`}, want: true},
+		{name: "any version", ifMatch: []string{"*"}, want: true},
+		{name: "one of list", ifMatch: []string{`"1", "3"`}, want: true},
+		{name: "one of headers", ifMatch: []string{`"1"`, `"3"`}, want: true},
+		{name: "old version", ifMatch: []string{`"2"`}},
+		{name: "weak validator", ifMatch: []string{`W/"3"`}},
+		{name: "unquoted version", ifMatch: []string{"3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PATCH", "/", nil)
+			for _, value := range tt.ifMatch {
+				r.Header.Add("If-Match", value)
+			}
+
+			w := httptest.NewRecorder()
+			if got := checkIfMatch(w, r, 3); got != tt.want {
+				t.Fatalf("checkIfMatch = %v, want %v", got, tt.want)
+			}
+			if tt.want {
+				return
+			}
+
+			assertPreconditionFailed(t, w, `"3"`)
+		})
+	}
+}
+
+// assertPreconditionFailed checks that response is 412 with ETag of current version in header and body
+func assertPreconditionFailed(t *testing.T, w *httptest.ResponseRecorder, wantETag string) {
+	t.Helper()
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusPreconditionFailed)
+	}
+	if got := w.Header().Get("ETag"); got != wantETag {
+		t.Errorf("ETag header = %s, want %s", got, wantETag)
+	}
+
+	var body struct {
+		Data struct {
+			ETag string `json:"etag"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode %q: %v", w.Body.String(), err)
+	}
+	if body.Data.ETag != wantETag {
+		t.Errorf("body etag = %s, want %s", body.Data.ETag, wantETag)
+	}
+}
+
+// fakeCategoryDB keeps categories in memory. Update applies version condition the way database does.
+// Methods which are not needed by category handlers are not implemented and panic.
+type fakeCategoryDB struct {
+	database.Database
+
+	mu         sync.Mutex
+	categories map[models.CategoryID]*models.Category
+	// * Called between read and update of category, so test can change it as another client
+	beforeUpdate func(category *models.Category)
+}
+
+func (d *fakeCategoryDB) GetCategoryByID(ctx context.Context, categoryID models.CategoryID) (*models.Category, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if category, ok := d.categories[categoryID]; ok {
+		copied := *category
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeCategoryDB) UpdateCategory(ctx context.Context, category *models.Category) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stored := d.categories[category.ID]
+	if d.beforeUpdate != nil {
+		d.beforeUpdate(stored)
+	}
+	if stored.Version != category.Version {
+		return database.ErrVersionConflict
+	}
+
+	category.Version++
+	updated := *category
+	d.categories[category.ID] = &updated
+	return nil
+}
+
+// * Fake has no transactions, changes are applied right away
+func (d *fakeCategoryDB) WithTx(ctx context.Context, fn func(db database.Database) error) error {
+	return fn(d)
+}
+
+func (d *fakeCategoryDB) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return nil
+}
+
+func TestCategoryUpdateIfMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		// * Another client updates category after handler has read it
+		concurrent bool
+		ifMatch    string
+		wantStatus int
+		wantETag   string
+	}{
+		{name: "no If-Match", wantStatus: http.StatusOK, wantETag: `"4"`},
+		{name: "current version", ifMatch: `"3"`, wantStatus: http.StatusOK, wantETag: `"4"`},
+		{name: "old version", ifMatch: `"2"`, wantStatus: http.StatusPreconditionFailed, wantETag: `"3"`},
+		{name: "changed after read", concurrent: true, ifMatch: `"3"`, wantStatus: http.StatusPreconditionFailed, wantETag: `"4"`},
+		{name: "changed after read without If-Match", concurrent: true, wantStatus: http.StatusPreconditionFailed, wantETag: `"4"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, name := models.UserID("user-1"), "Food"
+			db := &fakeCategoryDB{categories: map[models.CategoryID]*models.Category{
+				"category-1": {ID: "category-1", UserID: &userID, Name: &name, Version: 3},
+			}}
+			if tt.concurrent {
+				db.beforeUpdate = func(category *models.Category) { category.Version++ }
+			}
+
+			api := CategoryAPI{DB: db}
+			router := mux.NewRouter()
+			router.HandleFunc("/users/{userID}/categories/{categoryID}", api.Update).Methods("PATCH")
+
+			r := httptest.NewRequest("PATCH", "/users/user-1/categories/category-1", strings.NewReader(`{"name": "Groceries"}`))
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if tt.wantStatus == http.StatusPreconditionFailed {
+				assertPreconditionFailed(t, w, tt.wantETag)
+				return
+			}
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %s, want %s", got, tt.wantETag)
+			}
+		})
+	}
+}
+
+// fakePreferenceDB keeps stored notification preferences of one user in memory, defaults are not stored.
+// Set applies version condition the way database does.
+type fakePreferenceDB struct {
+	database.Database
+
+	mu          sync.Mutex
+	preferences map[models.NotificationEvent]*models.NotificationPreference
+	// * Called between read and set of preference, so test can change it as another client
+	beforeSet func(preferences map[models.NotificationEvent]*models.NotificationPreference)
+}
+
+func (d *fakePreferenceDB) ListNotificationPreferences(ctx context.Context, userID models.UserID) ([]*models.NotificationPreference, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	preferences := make([]*models.NotificationPreference, 0, len(d.preferences))
+	for _, preference := range d.preferences {
+		copied := *preference
+		preferences = append(preferences, &copied)
+	}
+	return preferences, nil
+}
+
+// SetNotificationPreference stores default preference at version 0, otherwise updates stored one which is still at version
+func (d *fakePreferenceDB) SetNotificationPreference(ctx context.Context, preference *models.NotificationPreference) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.beforeSet != nil {
+		d.beforeSet(d.preferences)
+	}
+	if stored, ok := d.preferences[preference.EventType]; ok && stored.Version != preference.Version {
+		return database.ErrVersionConflict
+	}
+
+	preference.Version++
+	stored := *preference
+	d.preferences[preference.EventType] = &stored
+	return nil
+}
+
+// * Fake has no transactions, changes are applied right away
+func (d *fakePreferenceDB) WithTx(ctx context.Context, fn func(db database.Database) error) error {
+	return fn(d)
+}
+
+func (d *fakePreferenceDB) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return nil
+}
+
+func TestNotificationPreferenceIfMatch(t *testing.T) {
+	tests := []struct {
+		name string
+		// * Version of stored preference, 0 means user keeps default
+		stored int64
+		// * Another client stores preference after handler has read it
+		concurrent bool
+		ifMatch    string
+		wantStatus int
+		wantETag   string
+	}{
+		{name: "default without If-Match", wantStatus: http.StatusOK, wantETag: `"1"`},
+		{name: "default still default", ifMatch: `"0"`, wantStatus: http.StatusOK, wantETag: `"1"`},
+		{name: "default stored meanwhile", ifMatch: `"0"`, stored: 2, wantStatus: http.StatusPreconditionFailed, wantETag: `"2"`},
+		{name: "default stored after read", concurrent: true, wantStatus: http.StatusPreconditionFailed, wantETag: `"1"`},
+		{name: "stored at current version", stored: 2, ifMatch: `"2"`, wantStatus: http.StatusOK, wantETag: `"3"`},
+		{name: "stored at old version", stored: 2, ifMatch: `"1"`, wantStatus: http.StatusPreconditionFailed, wantETag: `"2"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID := models.UserID("user-1")
+			db := &fakePreferenceDB{preferences: make(map[models.NotificationEvent]*models.NotificationPreference)}
+			if tt.stored > 0 {
+				preference := models.NewNotificationPreference(userID, models.EventLargeExpense)
+				preference.Version = tt.stored
+				db.preferences[models.EventLargeExpense] = preference
+			}
+			if tt.concurrent {
+				db.beforeSet = func(preferences map[models.NotificationEvent]*models.NotificationPreference) {
+					preference := models.NewNotificationPreference(userID, models.EventLargeExpense)
+					preference.Version = 1
+					preferences[models.EventLargeExpense] = preference
+				}
+			}
+
+			api := NotificationAPI{DB: db}
+			router := mux.NewRouter()
+			router.HandleFunc("/users/{userID}/notifications/preferences/{eventType}", api.SetPreference).Methods("PUT")
+
+			path := "/users/user-1/notifications/preferences/" + string(models.EventLargeExpense)
+			r := httptest.NewRequest("PUT", path, strings.NewReader(`{"email": true}`))
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+
+			if tt.wantStatus == http.StatusPreconditionFailed {
+				assertPreconditionFailed(t, w, tt.wantETag)
+				return
+			}
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %s, want %s", got, tt.wantETag)
+			}
+		})
+	}
+}
@@ -0,0 +1,539 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// GoalAPI - provides REST for savings goals
+type GoalAPI struct {
+	DB database.Database
+}
+
+func SetGoalAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := GoalAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- GOALS ---------- */
+		NewAPI("/users/{userID}/goals", "POST", api.Create, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/goals", "GET", api.List, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/goals/{goalID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/goals/{goalID}", "PATCH", api.Update, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/goals/{goalID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/goals/{goalID}/restore", "POST", api.Restore, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/goals/{goalID}/progress", "GET", api.Progress, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/goals/{goalID}/contributions", "POST", api.Contribute, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/goals/{goalID}/contributions", "GET", api.Contributions, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/goals/{goalID}/contributions/{contributionID}", "DELETE", api.DeleteContribution, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// POST - /users/{userID}/goals
+// Permission - MemberIsTarget
+func (api *GoalAPI) Create(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "goal.go -> Create()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// Decode parameters
+	var goal models.Goal
+	if err := json.NewDecoder(r.Body).Decode(&goal); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	goal.UserID = &userID
+
+	if err := goal.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	// * Goal can track only account of its user
+	if !writeReferences(w, r, api.DB, userID, goal.AccountID, nil, nil) {
+		return
+	}
+
+	ctx := r.Context()
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateGoal(ctx, &goal); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditGoal, string(goal.ID), nil, &goal)
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Error creating goal.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error creating goal.", nil)
+		return
+	}
+
+	logger.WithField("goalID", goal.ID).Info("Goal created")
+	utils.WriteJSON(w, http.StatusCreated, goal)
+}
+
+// PATCH - /users/{userID}/goals/{goalID}
+// Permission - MemberIsTarget
+func (api *GoalAPI) Update(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "goal.go -> Update()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	goalID := models.GoalID(vars["goalID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"goal_id":   goalID,
+	})
+
+	// Decode parameters
+	var goalRequest models.Goal
+	if err := json.NewDecoder(r.Body).Decode(&goalRequest); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	goal, ok := api.getGoal(w, r, userID, goalID)
+	if !ok {
+		return
+	}
+	before := *goal
+
+	if !checkIfMatch(w, r, goal.Version) {
+		return
+	}
+
+	if goalRequest.AccountID != nil {
+		goal.AccountID = goalRequest.AccountID
+	}
+	if goalRequest.Name != nil && len(*goalRequest.Name) != 0 {
+		goal.Name = goalRequest.Name
+	}
+	if goalRequest.TargetAmount != nil {
+		goal.TargetAmount = goalRequest.TargetAmount
+	}
+	if goalRequest.TargetDate != nil {
+		goal.TargetDate = goalRequest.TargetDate
+	}
+
+	if err := goal.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	if goalRequest.AccountID != nil && !writeReferences(w, r, api.DB, userID, goal.AccountID, nil, nil) {
+		return
+	}
+
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.UpdateGoal(ctx, goal); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditUpdate, models.AuditGoal, string(goalID), &before, goal)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, goalID)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error updating goal.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error updating goal.", nil)
+		return
+	}
+
+	logger.Info("Goal update")
+	writeETag(w, goal.Version)
+	utils.WriteJSON(w, http.StatusOK, goal)
+}
+
+// GET - /users/{userID}/goals
+// Permission - MemberIsTarget
+func (api *GoalAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "goal.go -> List()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	goals, err := api.DB.ListGoalsByUserID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting goals.", http.StatusConflict)
+		return
+	}
+
+	if goals == nil {
+		goals = make([]*models.Goal, 0)
+	}
+
+	logger.Info("Goals returned")
+	utils.WriteJSON(w, http.StatusOK, goals)
+}
+
+// GET - /users/{userID}/goals/{goalID}
+// Permission - MemberIsTarget
+func (api *GoalAPI) Get(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "goal.go -> Get()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	goalID := models.GoalID(vars["goalID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"goal_id":   goalID,
+	})
+
+	goal, ok := api.getGoal(w, r, userID, goalID)
+	if !ok {
+		return
+	}
+
+	logger.Info("Goal returned")
+	writeETag(w, goal.Version)
+	utils.WriteJSON(w, http.StatusOK, goal)
+}
+
+// DELETE - /users/{userID}/goals/{goalID}
+// Permission - MemberIsTarget
+func (api *GoalAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "goal.go -> Delete()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	goalID := models.GoalID(vars["goalID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"goal_id":   goalID,
+	})
+
+	ctx := r.Context()
+	before, ok := api.getGoal(w, r, userID, goalID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, before.Version) {
+		return
+	}
+
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteGoal(ctx, goalID, before.Version); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditGoal, string(goalID), before, nil)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, goalID)
+		return
+	} else if err != nil {
+		utils.ResponseErr(err, w, "Error deleting goal.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Goal deleted")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
+
+// POST - /users/{userID}/goals/{goalID}/restore
+// Permission - MemberIsTarget
+func (api *GoalAPI) Restore(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "goal.go -> Restore()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	goalID := models.GoalID(vars["goalID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"goal_id":   goalID,
+	})
+
+	if _, ok := api.getGoal(w, r, userID, goalID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	var restored bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if restored, err = db.RestoreGoal(ctx, goalID); err != nil || !restored {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditRestore, models.AuditGoal, string(goalID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error restoring goal.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Goal restored")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: restored,
+	})
+}
+
+// GET - /users/{userID}/goals/{goalID}/progress
+// Permission - MemberIsTarget
+func (api *GoalAPI) Progress(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "goal.go -> Progress()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	goalID := models.GoalID(vars["goalID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"goal_id":   goalID,
+	})
+
+	ctx := r.Context()
+	goal, ok := api.getGoal(w, r, userID, goalID)
+	if !ok {
+		return
+	}
+
+	contributions, err := api.DB.ListGoalContributions(ctx, goalID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting contributions.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Goal progress returned")
+	utils.WriteJSON(w, http.StatusOK, models.NewGoalProgress(goal, contributions, time.Now()))
+}
+
+// POST - /users/{userID}/goals/{goalID}/contributions
+// Permission - MemberIsTarget
+func (api *GoalAPI) Contribute(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "goal.go -> Contribute()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	goalID := models.GoalID(vars["goalID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"goal_id":   goalID,
+	})
+
+	// Decode parameters
+	var contribution models.GoalContribution
+	if err := json.NewDecoder(r.Body).Decode(&contribution); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	contribution.UserID = &userID
+	contribution.GoalID = &goalID
+
+	ctx := r.Context()
+	goal, ok := api.getGoal(w, r, userID, goalID)
+	if !ok {
+		return
+	}
+	if goal.DeletedAt != nil {
+		utils.WriteError(w, http.StatusConflict, "Goal is deleted.", nil)
+		return
+	}
+
+	// * Amount and date of linked transaction are taken from transaction, income adds to goal and expense takes from it
+	if contribution.TransactionID != nil {
+		transaction, err := api.DB.GetTransactionByID(ctx, *contribution.TransactionID)
+		if err != nil {
+			utils.ResponseErr(err, w, "Error getting transaction.", http.StatusConflict)
+			return
+		}
+		if transaction.UserID == nil || *transaction.UserID != userID {
+			utils.WriteError(w, http.StatusNotFound, "Transaction not found.", nil)
+			return
+		}
+		if transaction.DeletedAt != nil {
+			utils.WriteError(w, http.StatusConflict, "Transaction is deleted.", nil)
+			return
+		}
+		if goal.AccountID != nil && *transaction.AccountID != *goal.AccountID {
+			utils.WriteError(w, http.StatusBadRequest, "Transaction is not in goal's account.", nil)
+			return
+		}
+
+		amount := *transaction.Amount
+		if *transaction.Type == models.Expense {
+			amount = -amount
+		}
+		contribution.Amount = &amount
+		contribution.Date = transaction.Date
+	}
+	if contribution.Date == nil {
+		now := time.Now()
+		contribution.Date = &now
+	}
+
+	if err := contribution.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateGoalContribution(ctx, &contribution); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditContribution, string(contribution.ID), nil, &contribution)
+	})
+	if err == database.ErrContributionExists {
+		utils.ResponseErr(err, w, "Transaction already contributes to goal.", http.StatusConflict)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error creating contribution.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error creating contribution.", nil)
+		return
+	}
+
+	logger.WithField("contributionID", contribution.ID).Info("Goal contribution created")
+	utils.WriteJSON(w, http.StatusCreated, contribution)
+}
+
+// GET - /users/{userID}/goals/{goalID}/contributions
+// Permission - MemberIsTarget
+func (api *GoalAPI) Contributions(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "goal.go -> Contributions()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	goalID := models.GoalID(vars["goalID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"goal_id":   goalID,
+	})
+
+	if _, ok := api.getGoal(w, r, userID, goalID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	contributions, err := api.DB.ListGoalContributions(ctx, goalID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting contributions.", http.StatusConflict)
+		return
+	}
+
+	if contributions == nil {
+		contributions = make([]*models.GoalContribution, 0)
+	}
+
+	logger.Info("Goal contributions returned")
+	utils.WriteJSON(w, http.StatusOK, contributions)
+}
+
+// DELETE - /users/{userID}/goals/{goalID}/contributions/{contributionID}
+// Permission - MemberIsTarget
+func (api *GoalAPI) DeleteContribution(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "goal.go -> DeleteContribution()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	goalID := models.GoalID(vars["goalID"])
+	contributionID := models.GoalContributionID(vars["contributionID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":         userID,
+		"principal":       principal,
+		"goal_id":         goalID,
+		"contribution_id": contributionID,
+	})
+
+	if _, ok := api.getGoal(w, r, userID, goalID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteGoalContribution(ctx, userID, goalID, contributionID); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditContribution, string(contributionID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error deleting contribution.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Goal contribution deleted")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
+
+// writeChanged - goal was changed since client has read it, client gets ETag of current version
+func (api *GoalAPI) writeChanged(w http.ResponseWriter, r *http.Request, goalID models.GoalID) {
+	goal, err := api.DB.GetGoalByID(r.Context(), goalID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting goal.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, goal.Version)
+}
+
+// getGoal - goal is found only under path of its owner, so user can't reach goal of another user
+func (api *GoalAPI) getGoal(w http.ResponseWriter, r *http.Request, userID models.UserID, goalID models.GoalID) (*models.Goal, bool) {
+	goal, err := api.DB.GetGoalByID(r.Context(), goalID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting goal.", http.StatusConflict)
+		return nil, false
+	}
+
+	if goal.UserID == nil || *goal.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, "Goal not found.", nil)
+		return nil, false
+	}
+
+	return goal, true
+}
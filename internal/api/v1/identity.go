@@ -0,0 +1,324 @@
+package v1
+
+import (
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/identity"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+var oauthStateDuration = time.Duration(10) * time.Minute // 10 minutes
+
+// AuthorizationURLResponse - URL where client redirects user to login at identity provider
+type AuthorizationURLResponse struct {
+	URL string `json:"url"`
+}
+
+/* ---------- IDENTITY PROVIDERS ---------- */
+
+// GET - /auth/{provider}/login?device_id={deviceID}
+// Permission - Any
+func (api *UserAPI) ProviderLogin(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "identity.go -> ProviderLogin()")
+
+	vars := mux.Vars(r)
+	providerName := vars["provider"]
+	sessionData := models.SessionData{
+		DeviceID: models.DeviceID(r.URL.Query().Get("device_id")),
+	}
+
+	logger = logger.WithFields(logrus.Fields{
+		"provider":  providerName,
+		"device_id": sessionData.DeviceID,
+	})
+
+	if err := sessionData.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	api.writeAuthorizationURL(w, r, providerName, nil, sessionData.DeviceID)
+	logger.Debug("Authorization URL returned")
+}
+
+// POST - /users/{userID}/identities/{provider}
+// Permission - MemberIsTarget
+func (api *UserAPI) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "identity.go -> LinkIdentity()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	providerName := vars["provider"]
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"provider":  providerName,
+	})
+
+	api.writeAuthorizationURL(w, r, providerName, &userID, models.NilDeviceID)
+	logger.Debug("Authorization URL returned")
+}
+
+// GET - /auth/{provider}/callback?code={code}&state={state}
+// Permission - Any (state we issued is a credential)
+func (api *UserAPI) ProviderCallback(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "identity.go -> ProviderCallback()")
+
+	vars := mux.Vars(r)
+	providerName := vars["provider"]
+	query := r.URL.Query()
+
+	logger = logger.WithField("provider", providerName)
+
+	if providerError := query.Get("error"); providerError != "" {
+		utils.WriteError(w, http.StatusUnauthorized, "Login was canceled.", map[string]string{
+			"error": providerError,
+		})
+		return
+	}
+
+	provider, ok := api.Providers[providerName]
+	if !ok {
+		utils.WriteError(w, http.StatusNotFound, "Unknown identity provider.", nil)
+		return
+	}
+
+	ctx := r.Context()
+	state, err := api.DB.UseOAuthState(ctx, query.Get("state"))
+	if err != nil || state.Provider != providerName {
+		utils.ResponseErr(err, w, "Invalid or expired authorization request.", http.StatusUnauthorized)
+		return
+	}
+
+	externalIdentity, err := provider.Exchange(ctx, query.Get("code"), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting identity from provider.", http.StatusUnauthorized)
+		return
+	}
+
+	logger = logger.WithField("subject", externalIdentity.Subject)
+
+	// * Logged in user links new identity to own account
+	if state.UserID != nil {
+		externalIdentity.UserID = *state.UserID
+		err = api.DB.WithTx(ctx, func(db database.Database) error {
+			if err := db.LinkIdentity(ctx, externalIdentity); err != nil {
+				return err
+			}
+			return writeAudit(db, r, externalIdentity.UserID, models.AuditCreate, models.AuditIdentity, providerName, nil, externalIdentity)
+		})
+		if err == database.ErrIdentityLinked {
+			utils.ResponseErr(err, w, "Identity is linked to another user.", http.StatusConflict)
+			return
+		} else if err != nil {
+			utils.ResponseErr(err, w, "Error linking identity.", http.StatusInternalServerError)
+			return
+		}
+
+		logger.WithField("user_id", externalIdentity.UserID).Info("Identity linked")
+		utils.WriteJSON(w, http.StatusCreated, externalIdentity)
+		return
+	}
+
+	user, err := api.userByIdentity(r, externalIdentity)
+	if err == identity.ErrUnverifiedAccount {
+		utils.ResponseErr(err, w, "Account with this email exists. Log in with password and link identity.", http.StatusConflict)
+		return
+	} else if err != nil {
+		utils.ResponseErr(err, w, "Error logging in with identity provider.", http.StatusConflict)
+		return
+	}
+
+	logger.WithField("userID", user.ID).Debug("user passed identity provider login")
+	api.completeLogin(w, r, user, &models.SessionData{DeviceID: state.DeviceID})
+}
+
+// GET - /users/{userID}/identities
+// Permission - Admin, MemberIsTarget
+func (api *UserAPI) ListIdentities(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "identity.go -> ListIdentities()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	identities, err := api.DB.ListIdentities(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting identities.", http.StatusConflict)
+		return
+	}
+
+	if identities == nil {
+		identities = make([]*models.Identity, 0)
+	}
+
+	logger.Info("Identities returned")
+	utils.WriteJSON(w, http.StatusOK, identities)
+}
+
+// DELETE - /users/{userID}/identities/{provider}
+// Permission - Admin, MemberIsTarget
+func (api *UserAPI) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "identity.go -> UnlinkIdentity()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	providerName := vars["provider"]
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"provider":  providerName,
+	})
+
+	ctx := r.Context()
+	user, err := api.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	// * User without password can't lose last way to login
+	if user.PasswordHash == nil {
+		identities, err := api.DB.ListIdentities(ctx, userID)
+		if err != nil {
+			utils.ResponseErr(err, w, "Error getting identities.", http.StatusConflict)
+			return
+		}
+		if len(identities) <= 1 {
+			utils.WriteError(w, http.StatusConflict, "Set password before removing last identity.", nil)
+			return
+		}
+	}
+
+	var deleted bool
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.UnlinkIdentity(ctx, userID, providerName); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditIdentity, providerName, nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error unlinking identity.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Identity unlinked")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
+
+// writeAuthorizationURL - Store state of authorization request and return URL of provider
+func (api *UserAPI) writeAuthorizationURL(w http.ResponseWriter, r *http.Request, providerName string, userID *models.UserID, deviceID models.DeviceID) {
+	provider, ok := api.Providers[providerName]
+	if !ok {
+		utils.WriteError(w, http.StatusNotFound, "Unknown identity provider.", nil)
+		return
+	}
+
+	state := models.OAuthState{
+		Provider:  providerName,
+		UserID:    userID,
+		DeviceID:  deviceID,
+		ExpiresAt: time.Now().Add(oauthStateDuration),
+	}
+
+	var err error
+	if state.State, err = identity.RandomString(32); err == nil {
+		if state.Nonce, err = identity.RandomString(16); err == nil {
+			state.CodeVerifier, err = identity.NewCodeVerifier()
+		}
+	}
+	if err != nil {
+		utils.ResponseErr(err, w, "Error starting login.", http.StatusInternalServerError)
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(state.State, state.Nonce, identity.CodeChallenge(state.CodeVerifier))
+	if err != nil {
+		utils.ResponseErr(err, w, "Error starting login.", http.StatusBadGateway)
+		return
+	}
+
+	ctx := r.Context()
+	if err := api.DB.CreateOAuthState(ctx, &state); err != nil {
+		utils.ResponseErr(err, w, "Error starting login.", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, &AuthorizationURLResponse{
+		URL: authURL,
+	})
+}
+
+// userByIdentity - Find user linked to identity. If there is no such user, we link user with the same verified email or create new user.
+// Existing user whose email was never verified is not linked, password owner must link identity after login.
+func (api *UserAPI) userByIdentity(r *http.Request, externalIdentity *models.Identity) (*models.User, error) {
+	ctx := r.Context()
+	user, err := api.DB.GetUserByIdentity(ctx, externalIdentity.Provider, externalIdentity.Subject)
+	if err == nil {
+		return user, nil
+	}
+
+	if !externalIdentity.EmailVerified {
+		return nil, identity.ErrInvalidIdentity
+	}
+
+	user, err = api.DB.GetUserByEmail(ctx, externalIdentity.Email)
+	if err == nil && user.EmailVerifiedAt == nil {
+		return nil, identity.ErrUnverifiedAccount
+	}
+	userExists := err == nil
+
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if !userExists {
+			newUser := &models.User{
+				Email: &externalIdentity.Email,
+			}
+			if err := db.CreateUser(ctx, newUser); err != nil {
+				return err
+			}
+
+			// * Provider confirmed email, so we don't need to send verification email
+			if err := db.VerifyUserEmail(ctx, newUser.ID); err != nil {
+				return err
+			}
+
+			var err error
+			if user, err = db.GetUserByID(ctx, newUser.ID); err != nil {
+				return err
+			}
+			if err := writeAudit(db, r, user.ID, models.AuditCreate, models.AuditUser, string(user.ID), nil, user); err != nil {
+				return err
+			}
+		}
+
+		externalIdentity.UserID = user.ID
+		if err := db.LinkIdentity(ctx, externalIdentity); err != nil {
+			return err
+		}
+		return writeAudit(db, r, user.ID, models.AuditCreate, models.AuditIdentity, externalIdentity.Provider, nil, externalIdentity)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,407 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"finance/internal/database"
+	"finance/internal/identity"
+	"finance/internal/identity/identitytest"
+	"finance/internal/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeIdentityDB keeps users, identities and authorization requests in memory.
+// Methods which are not needed by identity handlers are not implemented and panic.
+type fakeIdentityDB struct {
+	database.Database
+
+	mu         sync.Mutex
+	users      map[models.UserID]*models.User
+	identities map[string]*models.Identity // provider/subject
+	states     map[string]*models.OAuthState
+}
+
+func newFakeIdentityDB() *fakeIdentityDB {
+	return &fakeIdentityDB{
+		users:      make(map[models.UserID]*models.User),
+		identities: make(map[string]*models.Identity),
+		states:     make(map[string]*models.OAuthState),
+	}
+}
+
+func (d *fakeIdentityDB) addUser(email string, verified bool, password bool) *models.User {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	user := &models.User{
+		ID:    models.UserID(fmt.Sprintf("user-%d", len(d.users)+1)),
+		Email: &email,
+	}
+	if verified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	if password {
+		if err := user.SetPassword("password-1"); err != nil {
+			panic(err)
+		}
+	}
+	d.users[user.ID] = user
+	return user
+}
+
+func (d *fakeIdentityDB) CreateOAuthState(ctx context.Context, state *models.OAuthState) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.states[state.State] = state
+	return nil
+}
+
+func (d *fakeIdentityDB) UseOAuthState(ctx context.Context, state string) (*models.OAuthState, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	oauthState, ok := d.states[state]
+	if !ok {
+		return nil, database.ErrOAuthStateInvalid
+	}
+	delete(d.states, state)
+	return oauthState, nil
+}
+
+func (d *fakeIdentityDB) GetUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if externalIdentity, ok := d.identities[provider+"/"+subject]; ok {
+		return d.users[externalIdentity.UserID], nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeIdentityDB) LinkIdentity(ctx context.Context, externalIdentity *models.Identity) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := externalIdentity.Provider + "/" + externalIdentity.Subject
+	if linked, ok := d.identities[key]; ok && linked.UserID != externalIdentity.UserID {
+		return database.ErrIdentityLinked
+	}
+	stored := *externalIdentity
+	d.identities[key] = &stored
+	return nil
+}
+
+func (d *fakeIdentityDB) ListIdentities(ctx context.Context, userID models.UserID) ([]*models.Identity, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var identities []*models.Identity
+	for _, externalIdentity := range d.identities {
+		if externalIdentity.UserID == userID {
+			identities = append(identities, externalIdentity)
+		}
+	}
+	return identities, nil
+}
+
+func (d *fakeIdentityDB) UnlinkIdentity(ctx context.Context, userID models.UserID, provider string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for key, externalIdentity := range d.identities {
+		if externalIdentity.UserID == userID && externalIdentity.Provider == provider {
+			delete(d.identities, key)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (d *fakeIdentityDB) GetUserByID(ctx context.Context, userID models.UserID) (*models.User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if user, ok := d.users[userID]; ok {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeIdentityDB) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, user := range d.users {
+		if *user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeIdentityDB) CreateUser(ctx context.Context, user *models.User) error {
+	created := d.addUser(*user.Email, false, false)
+	user.ID = created.ID
+	return nil
+}
+
+func (d *fakeIdentityDB) VerifyUserEmail(ctx context.Context, userID models.UserID) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if user := d.users[userID]; user.EmailVerifiedAt == nil {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+	return nil
+}
+
+// * Fake has no transactions, changes are applied right away
+func (d *fakeIdentityDB) WithTx(ctx context.Context, fn func(db database.Database) error) error {
+	return fn(d)
+}
+
+func (d *fakeIdentityDB) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return nil
+}
+
+func (d *fakeIdentityDB) SaveRefreshToken(ctx context.Context, session models.Session, tokenHash string) error {
+	return nil
+}
+
+func (d *fakeIdentityDB) ResetLoginFailures(ctx context.Context, key string) error {
+	return nil
+}
+
+func (d *fakeIdentityDB) UndoLoginFailure(ctx context.Context, key string) error {
+	return nil
+}
+
+// identityTest is API with fake database and local identity provider named "test"
+type identityTest struct {
+	db       *fakeIdentityDB
+	provider *identitytest.Provider
+	router   *mux.Router
+}
+
+func newIdentityTest(t *testing.T) *identityTest {
+	t.Helper()
+
+	fake := identitytest.NewProvider("client", "secret")
+	t.Cleanup(fake.Close)
+
+	db := newFakeIdentityDB()
+	api := UserAPI{
+		DB: db,
+		Providers: map[string]identity.Provider{
+			"test": identity.NewOIDCProvider(identity.OIDCConfig{
+				Name:         "test",
+				Issuer:       fake.Issuer(),
+				ClientID:     fake.ClientID,
+				ClientSecret: fake.ClientSecret,
+				RedirectURL:  "http://app.test/api/v1/auth/test/callback",
+				Scopes:       []string{"openid", "email"},
+			}),
+		},
+	}
+
+	// * Permissions are checked by middleware, handlers are called directly
+	router := mux.NewRouter()
+	router.HandleFunc("/auth/{provider}/login", api.ProviderLogin).Methods("GET")
+	router.HandleFunc("/auth/{provider}/callback", api.ProviderCallback).Methods("GET")
+	router.HandleFunc("/users/{userID}/identities", api.ListIdentities).Methods("GET")
+	router.HandleFunc("/users/{userID}/identities/{provider}", api.LinkIdentity).Methods("POST")
+	router.HandleFunc("/users/{userID}/identities/{provider}", api.UnlinkIdentity).Methods("DELETE")
+
+	return &identityTest{db: db, provider: fake, router: router}
+}
+
+func (it *identityTest) do(t *testing.T, method, target string, dest interface{}) int {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	it.router.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	if dest != nil && w.Code < http.StatusBadRequest {
+		if err := json.Unmarshal(w.Body.Bytes(), dest); err != nil {
+			t.Fatalf("%s %s: decode %q: %v", method, target, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// authorize starts authorization with API, approves it at provider and returns callback query
+func (it *identityTest) authorize(t *testing.T, method, target string) url.Values {
+	t.Helper()
+
+	var authorization AuthorizationURLResponse
+	if status := it.do(t, method, target, &authorization); status != http.StatusOK {
+		t.Fatalf("%s %s: status %d", method, target, status)
+	}
+
+	code, state, err := it.provider.Authorize(authorization.URL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	return url.Values{"code": {code}, "state": {state}}
+}
+
+func TestProviderCallbackStateMismatch(t *testing.T) {
+	it := newIdentityTest(t)
+
+	query := it.authorize(t, "GET", "/auth/test/login?device_id=device-1")
+	query.Set("state", "forged-state")
+
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), nil); status != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if it.provider.TokenRequests != 0 {
+		t.Errorf("code was exchanged %d times with forged state", it.provider.TokenRequests)
+	}
+}
+
+func TestProviderCallbackStateOfOtherProvider(t *testing.T) {
+	it := newIdentityTest(t)
+
+	query := it.authorize(t, "GET", "/auth/test/login?device_id=device-1")
+	it.db.states[query.Get("state")].Provider = "other"
+
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), nil); status != http.StatusUnauthorized {
+		t.Errorf("status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestProviderCallbackStateIsSingleUse(t *testing.T) {
+	it := newIdentityTest(t)
+
+	query := it.authorize(t, "GET", "/auth/test/login?device_id=device-1")
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), nil); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), nil); status != http.StatusUnauthorized {
+		t.Errorf("second callback status = %d, want %d", status, http.StatusUnauthorized)
+	}
+}
+
+func TestProviderLoginCreatesUser(t *testing.T) {
+	it := newIdentityTest(t)
+
+	query := it.authorize(t, "GET", "/auth/test/login?device_id=device-1")
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), nil); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	user, err := it.db.GetUserByEmail(context.Background(), it.provider.Email)
+	if err != nil {
+		t.Fatalf("user was not created: %v", err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("email of created user is not verified")
+	}
+	if _, err := it.db.GetUserByIdentity(context.Background(), "test", it.provider.Subject); err != nil {
+		t.Errorf("identity was not linked: %v", err)
+	}
+}
+
+func TestProviderLoginRefusesUnverifiedAccount(t *testing.T) {
+	it := newIdentityTest(t)
+
+	// * Somebody registered with victim's email but never verified it
+	user := it.db.addUser(it.provider.Email, false, true)
+
+	query := it.authorize(t, "GET", "/auth/test/login?device_id=device-1")
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), nil); status != http.StatusConflict {
+		t.Fatalf("status = %d, want %d", status, http.StatusConflict)
+	}
+
+	if _, err := it.db.GetUserByIdentity(context.Background(), "test", it.provider.Subject); err == nil {
+		t.Error("identity was linked to unverified account")
+	}
+	if stored, _ := it.db.GetUserByID(context.Background(), user.ID); stored.EmailVerifiedAt != nil {
+		t.Error("email of unverified account was verified")
+	}
+}
+
+func TestProviderLoginLinksVerifiedAccount(t *testing.T) {
+	it := newIdentityTest(t)
+
+	user := it.db.addUser(it.provider.Email, true, true)
+
+	query := it.authorize(t, "GET", "/auth/test/login?device_id=device-1")
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), nil); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	linked, err := it.db.GetUserByIdentity(context.Background(), "test", it.provider.Subject)
+	if err != nil || linked.ID != user.ID {
+		t.Errorf("identity linked to %v (%v), want %s", linked, err, user.ID)
+	}
+}
+
+func TestLinkAndUnlinkIdentity(t *testing.T) {
+	it := newIdentityTest(t)
+
+	user := it.db.addUser("owner@example.com", true, true)
+	identities := "/users/" + string(user.ID) + "/identities"
+
+	// * Provider email doesn't have to match email of logged in user
+	query := it.authorize(t, "POST", identities+"/test")
+	var linked models.Identity
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), &linked); status != http.StatusCreated {
+		t.Fatalf("link status = %d, want %d", status, http.StatusCreated)
+	}
+	if linked.UserID != user.ID || linked.Subject != it.provider.Subject {
+		t.Errorf("linked identity = %+v", linked)
+	}
+
+	var list []*models.Identity
+	if status := it.do(t, "GET", identities, &list); status != http.StatusOK || len(list) != 1 {
+		t.Fatalf("list status = %d, identities = %d, want 1", status, len(list))
+	}
+
+	var deleted ActDeleted
+	if status := it.do(t, "DELETE", identities+"/test", &deleted); status != http.StatusOK || !deleted.Deleted {
+		t.Fatalf("unlink status = %d, deleted = %v", status, deleted.Deleted)
+	}
+	if status := it.do(t, "GET", identities, &list); status != http.StatusOK || len(list) != 0 {
+		t.Errorf("list status = %d, identities = %d, want 0", status, len(list))
+	}
+}
+
+func TestLinkIdentityOfOtherUser(t *testing.T) {
+	it := newIdentityTest(t)
+
+	owner := it.db.addUser("owner@example.com", true, true)
+	other := it.db.addUser("other@example.com", true, true)
+
+	query := it.authorize(t, "POST", "/users/"+string(owner.ID)+"/identities/test")
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), nil); status != http.StatusCreated {
+		t.Fatalf("link status = %d, want %d", status, http.StatusCreated)
+	}
+
+	query = it.authorize(t, "POST", "/users/"+string(other.ID)+"/identities/test")
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), nil); status != http.StatusConflict {
+		t.Errorf("link status = %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func TestUnlinkLastIdentityWithoutPassword(t *testing.T) {
+	it := newIdentityTest(t)
+
+	query := it.authorize(t, "GET", "/auth/test/login?device_id=device-1")
+	if status := it.do(t, "GET", "/auth/test/callback?"+query.Encode(), nil); status != http.StatusOK {
+		t.Fatalf("status = %d, want %d", status, http.StatusOK)
+	}
+
+	user, err := it.db.GetUserByEmail(context.Background(), it.provider.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := it.do(t, "DELETE", "/users/"+string(user.ID)+"/identities/test", nil); status != http.StatusConflict {
+		t.Errorf("status = %d, want %d", status, http.StatusConflict)
+	}
+}
@@ -29,6 +29,7 @@ func SetMerchantAPI(db database.Database, router *mux.Router, permissons auth.Pe
 		NewAPI("/users/{userID}/merchants/{merchantID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
 		NewAPI("/users/{userID}/merchants/{merchantID}", "PATCH", api.Update, auth.Admin, auth.MemberIsTarget),
 		NewAPI("/users/{userID}/merchants/{merchantID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/merchants/{merchantID}/restore", "POST", api.Restore, auth.Admin, auth.MemberIsTarget),
 	}
 
 	for _, api := range apis {
@@ -67,7 +68,13 @@ func (api *MerchantAPI) Create(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	// Store role in database
 
-	if err := api.DB.CreateMerchant(ctx, &merchant); err != nil {
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateMerchant(ctx, &merchant); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditMerchant, string(merchant.ID), nil, &merchant)
+	})
+	if err != nil {
 		logger.WithError(err).Warn("Error creating merchant.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error creating merchant.", nil)
 		return
@@ -106,18 +113,33 @@ func (api *MerchantAPI) Update(w http.ResponseWriter, r *http.Request) {
 		utils.ResponseErr(err, w, "Error getting merchant.", http.StatusConflict)
 		return
 	}
+	before := *merchant
+
+	if !checkIfMatch(w, r, merchant.Version) {
+		return
+	}
 
 	if merchantRequest.Name != nil || len(*merchantRequest.Name) != 0 {
 		merchant.Name = merchantRequest.Name
 	}
 
-	if err := api.DB.UpdateMerchant(ctx, merchant); err != nil {
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.UpdateMerchant(ctx, merchant); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditUpdate, models.AuditMerchant, string(merchantID), &before, merchant)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, merchantID)
+		return
+	} else if err != nil {
 		logger.WithError(err).Warn("Error updating merchant.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error updating merchant.", nil)
 		return
 	}
 
 	logger.Info("Merchant update")
+	writeETag(w, merchant.Version)
 	utils.WriteJSON(w, http.StatusOK, merchant)
 }
 
@@ -174,6 +196,7 @@ func (api *MerchantAPI) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.Info("Merchant returned")
+	writeETag(w, merchant.Version)
 	utils.WriteJSON(w, http.StatusOK, merchant)
 }
 
@@ -194,8 +217,26 @@ func (api *MerchantAPI) Delete(w http.ResponseWriter, r *http.Request) {
 	})
 
 	ctx := r.Context()
-	deleted, err := api.DB.DeleteMerchant(ctx, merchantID)
-	if err != nil {
+	before, _ := api.DB.GetMerchantByID(ctx, merchantID) // * for audit and If-Match, missing resource is handled by delete
+	var version int64
+	if before != nil {
+		if !checkIfMatch(w, r, before.Version) {
+			return
+		}
+		version = before.Version
+	}
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteMerchant(ctx, merchantID, version); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditMerchant, string(merchantID), before, nil)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, merchantID)
+		return
+	} else if err != nil {
 		utils.ResponseErr(err, w, "Error deleting merchant.", http.StatusConflict)
 		return
 	}
@@ -205,3 +246,50 @@ func (api *MerchantAPI) Delete(w http.ResponseWriter, r *http.Request) {
 		Deleted: deleted,
 	})
 }
+
+// POST - /users/{userID}/merchants/{merchantID}/restore
+// Permission - MemberIsTarget
+func (api *MerchantAPI) Restore(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "merchant.go -> Restore()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	merchantID := models.MerchantID(vars["merchantID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"principal":   principal,
+		"merchant_id": merchantID,
+	})
+
+	ctx := r.Context()
+	var restored bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if restored, err = db.RestoreMerchant(ctx, merchantID); err != nil || !restored {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditRestore, models.AuditMerchant, string(merchantID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error restoring merchant.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Merchant restored")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: restored,
+	})
+}
+
+// writeChanged - merchant was changed since client has read it, client gets ETag of current version
+func (api *MerchantAPI) writeChanged(w http.ResponseWriter, r *http.Request, merchantID models.MerchantID) {
+	merchant, err := api.DB.GetMerchantByID(r.Context(), merchantID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting merchant.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, merchant.Version)
+}
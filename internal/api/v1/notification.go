@@ -0,0 +1,278 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/notify"
+	"finance/internal/utils"
+	"finance/internal/webhooks"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationAPI - provides REST for inbox and notification preferences
+type NotificationAPI struct {
+	DB database.Database
+}
+
+func SetNotificationAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := NotificationAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- NOTIFICATIONS ---------- */
+		NewAPI("/users/{userID}/notifications", "GET", api.List, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/notifications/read", "POST", api.ReadAll, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/notifications/{notificationID}/read", "POST", api.Read, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/notifications/preferences", "GET", api.Preferences, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/notifications/preferences/{eventType}", "PUT", api.SetPreference, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// GET - /users/{userID}/notifications?unread=true&limit={limit}
+// Permission - MemberIsTarget
+func (api *NotificationAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "notification.go -> List()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	query := r.URL.Query()
+	unread := query.Get("unread") == "true"
+	var limit int
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			utils.ResponseErrWithMap(err, w, "Could not parse limit.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	notifications, err := api.DB.ListNotifications(ctx, userID, unread, limit)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting notifications.", http.StatusConflict)
+		return
+	}
+
+	if notifications == nil {
+		notifications = make([]*models.Notification, 0)
+	}
+
+	logger.Info("Notifications returned")
+	utils.WriteJSON(w, http.StatusOK, notifications)
+}
+
+// POST - /users/{userID}/notifications/{notificationID}/read
+// Permission - MemberIsTarget
+func (api *NotificationAPI) Read(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "notification.go -> Read()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	notificationID := models.NotificationID(vars["notificationID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":         userID,
+		"principal":       principal,
+		"notification_id": notificationID,
+	})
+
+	ctx := r.Context()
+	updated, err := api.DB.MarkNotificationRead(ctx, userID, notificationID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error marking notification read.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Notification read")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: updated,
+	})
+}
+
+// POST - /users/{userID}/notifications/read
+// Permission - MemberIsTarget
+func (api *NotificationAPI) ReadAll(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "notification.go -> ReadAll()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	updated, err := api.DB.MarkAllNotificationsRead(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error marking notifications read.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Notifications read")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: updated,
+	})
+}
+
+// GET - /users/{userID}/notifications/preferences
+// Permission - MemberIsTarget
+func (api *NotificationAPI) Preferences(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "notification.go -> Preferences()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	preferences, err := notify.Preferences(ctx, api.DB, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting notification preferences.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Notification preferences returned")
+	utils.WriteJSON(w, http.StatusOK, preferences)
+}
+
+// SetNotificationPreference - webhook secret is returned only once, when webhook_url is changed
+type SetNotificationPreference struct {
+	*models.NotificationPreference
+
+	WebhookSecret string `json:"webhook_secret,omitempty"`
+}
+
+// PUT - /users/{userID}/notifications/preferences/{eventType}
+// Permission - MemberIsTarget
+func (api *NotificationAPI) SetPreference(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "notification.go -> SetPreference()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	eventType := models.NotificationEvent(vars["eventType"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"event_type": eventType,
+	})
+
+	if !eventType.IsValid() {
+		utils.ResponseErrWithMap(errors.Errorf("unknown event type %q", eventType), w, "Unknown event type.", http.StatusBadRequest)
+		return
+	}
+
+	// Decode parameters
+	var preferenceRequest models.NotificationPreference
+	if err := json.NewDecoder(r.Body).Decode(&preferenceRequest); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	preference, err := notify.Preference(ctx, api.DB, userID, eventType)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting notification preference.", http.StatusConflict)
+		return
+	}
+	if !checkIfMatch(w, r, preference.Version) {
+		return
+	}
+	before := *preference
+
+	// * Omitted fields keep their value, empty webhook_url turns webhook off
+	if preferenceRequest.InApp != nil {
+		preference.InApp = preferenceRequest.InApp
+	}
+	if preferenceRequest.Email != nil {
+		preference.Email = preferenceRequest.Email
+	}
+	if preferenceRequest.WebhookURL != nil {
+		preference.WebhookURL = preferenceRequest.WebhookURL
+		if len(*preference.WebhookURL) == 0 {
+			preference.WebhookURL = nil
+		}
+	}
+
+	// * New webhook gets new secret, so old receiver can't verify requests sent to new one
+	var secret string
+	switch {
+	case preference.WebhookURL == nil:
+		preference.WebhookSecret = nil
+	case before.WebhookURL == nil || *before.WebhookURL != *preference.WebhookURL || preference.WebhookSecret == nil:
+		var err error
+		if secret, err = webhooks.GenerateSecret(); err != nil {
+			utils.ResponseErr(err, w, "Error generating webhook secret.", http.StatusInternalServerError)
+			return
+		}
+		preference.WebhookSecret = &secret
+	}
+	if preferenceRequest.Threshold != nil && eventType == models.EventLargeExpense {
+		preference.Threshold = preferenceRequest.Threshold
+	}
+
+	if err := preference.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.SetNotificationPreference(ctx, preference); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditUpdate, models.AuditNotificationPreference, string(eventType), &before, preference)
+	})
+	if err == database.ErrVersionConflict {
+		api.writePreferenceChanged(w, r, userID, eventType)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error setting notification preference.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error setting notification preference.", nil)
+		return
+	}
+
+	logger.Info("Notification preference set")
+	writeETag(w, preference.Version)
+	utils.WriteJSON(w, http.StatusOK, &SetNotificationPreference{
+		NotificationPreference: preference,
+		WebhookSecret:          secret,
+	})
+}
+
+// writePreferenceChanged - preference was changed since client has read it, client gets ETag of current version
+func (api *NotificationAPI) writePreferenceChanged(w http.ResponseWriter, r *http.Request, userID models.UserID, eventType models.NotificationEvent) {
+	preference, err := notify.Preference(r.Context(), api.DB, userID, eventType)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting notification preference.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, preference.Version)
+}
@@ -0,0 +1,88 @@
+package v1
+
+import (
+	"context"
+	"database/sql"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// referenceError - resource referenced from request body belongs to another user or is in trash
+type referenceError struct {
+	status  int
+	message string
+	reason  string
+}
+
+// Error is reason of rejection for sync results, REST gets message
+func (e *referenceError) Error() string {
+	return e.reason
+}
+
+// checkReferences - account, category and debt referenced by new or changed record must belong to user and must not be in trash.
+// nil reference is not checked. Returns referenceError for rejected reference.
+func checkReferences(ctx context.Context, db database.Database, userID models.UserID, accountID *models.AccountID, categoryID *models.CategoryID, debtID *models.DebtID) error {
+	if accountID != nil {
+		account, err := db.GetAccountByID(ctx, *accountID)
+		if errors.Cause(err) == sql.ErrNoRows {
+			return &referenceError{http.StatusNotFound, "Account not found.", "account not found"}
+		} else if err != nil {
+			return errors.Wrap(err, "could not get account")
+		}
+		if account.UserID == nil || *account.UserID != userID {
+			return &referenceError{http.StatusNotFound, "Account not found.", "account not found"}
+		}
+		if account.DeletedAt != nil {
+			return &referenceError{http.StatusConflict, "Account is deleted, restore it first.", "account is deleted"}
+		}
+	}
+
+	if categoryID != nil {
+		category, err := db.GetCategoryByID(ctx, *categoryID)
+		if errors.Cause(err) == sql.ErrNoRows {
+			return &referenceError{http.StatusNotFound, "Category not found.", "category not found"}
+		} else if err != nil {
+			return errors.Wrap(err, "could not get category")
+		}
+		if category.UserID == nil || *category.UserID != userID {
+			return &referenceError{http.StatusNotFound, "Category not found.", "category not found"}
+		}
+		if category.DeletedAt != nil {
+			return &referenceError{http.StatusConflict, "Category is deleted, restore it first.", "category is deleted"}
+		}
+	}
+
+	if debtID != nil {
+		debt, err := db.GetDebtByID(ctx, *debtID)
+		if errors.Cause(err) == sql.ErrNoRows {
+			return &referenceError{http.StatusNotFound, "Debt not found.", "debt not found"}
+		} else if err != nil {
+			return errors.Wrap(err, "could not get debt")
+		}
+		if debt.UserID == nil || *debt.UserID != userID {
+			return &referenceError{http.StatusNotFound, "Debt not found.", "debt not found"}
+		}
+		if debt.DeletedAt != nil {
+			return &referenceError{http.StatusConflict, "Debt is deleted, restore it first.", "debt is deleted"}
+		}
+	}
+
+	return nil
+}
+
+// writeReferences - checkReferences for REST handlers. Returns false when error response was written.
+func writeReferences(w http.ResponseWriter, r *http.Request, db database.Database, userID models.UserID, accountID *models.AccountID, categoryID *models.CategoryID, debtID *models.DebtID) bool {
+	err := checkReferences(r.Context(), db, userID, accountID, categoryID, debtID)
+	if rejected, ok := err.(*referenceError); ok {
+		utils.WriteError(w, rejected.status, rejected.message, nil)
+		return false
+	} else if err != nil {
+		utils.ResponseErr(err, w, "Error getting referenced resource.", http.StatusConflict)
+		return false
+	}
+	return true
+}
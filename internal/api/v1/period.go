@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// LockDate - transactions dated on or before lock date are in closed period. Empty lock date means nothing is closed.
+type LockDate struct {
+	LockDate *time.Time `json:"lock_date"`
+}
+
+// PeriodAPI - provides REST for closing and reopening of bookkeeping periods
+type PeriodAPI struct {
+	DB          database.Database
+	Permissions auth.Permissions
+}
+
+func SetPeriodAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := PeriodAPI{
+		DB:          db,
+		Permissions: permissons,
+	}
+
+	apis := []API{
+		/* ---------- PERIODS ---------- */
+		NewAPI("/users/{userID}/lock-date", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/lock-date", "PUT", api.Set, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// GET - /users/{userID}/lock-date
+// Permission - Admin, MemberIsTarget
+func (api *PeriodAPI) Get(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "period.go -> Get()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	user, err := api.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	// * Lock date is part of user, so its ETag is version of user
+	logger.Info("Lock date returned")
+	writeETag(w, user.Version)
+	utils.WriteJSON(w, http.StatusOK, &LockDate{
+		LockDate: user.LockDate,
+	})
+}
+
+// PUT - /users/{userID}/lock-date
+// Permission - Admin, MemberIsTarget (only admin can reopen closed period)
+func (api *PeriodAPI) Set(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "period.go -> Set()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// Decode parameters
+	var request LockDate
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := api.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+	if !checkIfMatch(w, r, user.Version) {
+		return
+	}
+
+	// * Moving lock date back or removing it opens closed transactions for changes
+	action := models.AuditClosePeriod
+	if user.LockDate != nil && (request.LockDate == nil || request.LockDate.Before(*user.LockDate)) {
+		action = models.AuditReopenPeriod
+	}
+
+	if action == models.AuditReopenPeriod && !api.Permissions.Check(r, auth.Admin) {
+		utils.WriteError(w, http.StatusForbidden, "Only admin can reopen closed period.", nil)
+		return
+	}
+
+	before := &LockDate{LockDate: user.LockDate}
+	user.LockDate = request.LockDate
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.SetLockDate(ctx, user); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, action, models.AuditPeriod, string(userID), before, &request)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, userID)
+		return
+	} else if err != nil {
+		utils.ResponseErr(err, w, "Error setting lock date.", http.StatusInternalServerError)
+		return
+	}
+
+	logger.WithField("action", action).Info("Lock date set")
+	writeETag(w, user.Version)
+	utils.WriteJSON(w, http.StatusOK, &request)
+}
+
+// writeChanged - user was changed since client has read lock date, client gets ETag of current version
+func (api *PeriodAPI) writeChanged(w http.ResponseWriter, r *http.Request, userID models.UserID) {
+	user, err := api.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, user.Version)
+}
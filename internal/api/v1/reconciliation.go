@@ -0,0 +1,316 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// ReconciliationAPI - provides REST for reconciliation of account with bank statement
+type ReconciliationAPI struct {
+	DB database.Database
+}
+
+func SetReconciliationAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := ReconciliationAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- RECONCILIATIONS ---------- */
+		NewAPI("/users/{userID}/accounts/{accountID}/reconciliations", "POST", api.Create, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/accounts/{accountID}/reconciliations", "GET", api.List, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/reconciliations/{reconciliationID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/reconciliations/{reconciliationID}/finalize", "POST", api.Finalize, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/reconciliations/{reconciliationID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// POST - /users/{userID}/accounts/{accountID}/reconciliations
+// Permission - MemberIsTarget
+func (api *ReconciliationAPI) Create(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "reconciliation.go -> Create()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	accountID := models.AccountID(vars["accountID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"account_id": accountID,
+	})
+
+	// Decode parameters
+	var reconciliation models.Reconciliation
+	if err := json.NewDecoder(r.Body).Decode(&reconciliation); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	reconciliation.UserID = &userID
+	reconciliation.AccountID = &accountID
+
+	if err := reconciliation.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	if !api.checkAccount(w, r, userID, accountID) {
+		return
+	}
+
+	ctx := r.Context()
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateReconciliation(ctx, &reconciliation); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditReconciliation, string(reconciliation.ID), nil, &reconciliation)
+	})
+	if err == database.ErrReconciliationOpen {
+		utils.ResponseErr(err, w, "Account has open reconciliation.", http.StatusConflict)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error creating reconciliation.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error creating reconciliation.", nil)
+		return
+	}
+
+	logger.WithField("reconciliationID", reconciliation.ID).Info("Reconciliation created")
+	api.writeSummary(w, r, http.StatusCreated, &reconciliation)
+}
+
+// GET - /users/{userID}/accounts/{accountID}/reconciliations
+// Permission - MemberIsTarget
+func (api *ReconciliationAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "reconciliation.go -> List()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	accountID := models.AccountID(vars["accountID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"account_id": accountID,
+	})
+
+	if !api.checkAccount(w, r, userID, accountID) {
+		return
+	}
+
+	ctx := r.Context()
+	reconciliations, err := api.DB.ListReconciliationsByAccountID(ctx, accountID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting reconciliations.", http.StatusConflict)
+		return
+	}
+
+	if reconciliations == nil {
+		reconciliations = make([]*models.Reconciliation, 0)
+	}
+
+	logger.Info("Reconciliations returned")
+	utils.WriteJSON(w, http.StatusOK, reconciliations)
+}
+
+// GET - /users/{userID}/reconciliations/{reconciliationID}
+// Permission - MemberIsTarget
+func (api *ReconciliationAPI) Get(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "reconciliation.go -> Get()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	reconciliationID := models.ReconciliationID(vars["reconciliationID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":           userID,
+		"principal":         principal,
+		"reconciliation_id": reconciliationID,
+	})
+
+	reconciliation, ok := api.getReconciliation(w, r, userID, reconciliationID)
+	if !ok {
+		return
+	}
+
+	logger.Info("Reconciliation returned")
+	writeETag(w, reconciliation.Version)
+	api.writeSummary(w, r, http.StatusOK, reconciliation)
+}
+
+// POST - /users/{userID}/reconciliations/{reconciliationID}/finalize
+// Permission - MemberIsTarget
+func (api *ReconciliationAPI) Finalize(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "reconciliation.go -> Finalize()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	reconciliationID := models.ReconciliationID(vars["reconciliationID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":           userID,
+		"principal":         principal,
+		"reconciliation_id": reconciliationID,
+	})
+
+	before, ok := api.getReconciliation(w, r, userID, reconciliationID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, before.Version) {
+		return
+	}
+
+	ctx := r.Context()
+	var reconciliation *models.Reconciliation
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.FinalizeReconciliation(ctx, reconciliationID, before.Version); err != nil {
+			return err
+		}
+
+		var err error
+		if reconciliation, err = db.GetReconciliationByID(ctx, reconciliationID); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditFinalize, models.AuditReconciliation, string(reconciliationID), nil, reconciliation)
+	})
+	switch err {
+	case nil:
+	case database.ErrReconciliationFinalized:
+		utils.ResponseErr(err, w, "Reconciliation is already finalized.", http.StatusConflict)
+		return
+	case database.ErrReconciliationUnbalanced:
+		utils.ResponseErr(err, w, "Cleared balance doesn't match statement ending balance.", http.StatusConflict)
+		return
+	case database.ErrVersionConflict:
+		api.writeChanged(w, r, reconciliationID)
+		return
+	default:
+		utils.ResponseErr(err, w, "Error finalizing reconciliation.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Reconciliation finalized")
+	writeETag(w, reconciliation.Version)
+	utils.WriteJSON(w, http.StatusOK, reconciliation)
+}
+
+// DELETE - /users/{userID}/reconciliations/{reconciliationID}
+// Permission - MemberIsTarget
+func (api *ReconciliationAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "reconciliation.go -> Delete()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	reconciliationID := models.ReconciliationID(vars["reconciliationID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":           userID,
+		"principal":         principal,
+		"reconciliation_id": reconciliationID,
+	})
+
+	before, ok := api.getReconciliation(w, r, userID, reconciliationID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, before.Version) {
+		return
+	}
+
+	ctx := r.Context()
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteReconciliation(ctx, reconciliationID, before.Version); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditReconciliation, string(reconciliationID), before, nil)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, reconciliationID)
+		return
+	} else if err != nil {
+		utils.ResponseErr(err, w, "Error deleting reconciliation.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Reconciliation deleted")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
+
+// writeSummary - Return reconciliation with cleared balance, difference and transactions to reconcile
+func (api *ReconciliationAPI) writeSummary(w http.ResponseWriter, r *http.Request, status int, reconciliation *models.Reconciliation) {
+	ctx := r.Context()
+	summary, err := api.DB.GetReconciliationSummary(ctx, reconciliation)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting reconciliation summary.", http.StatusConflict)
+		return
+	}
+
+	if summary.Transactions == nil {
+		summary.Transactions = make([]*models.Transaction, 0)
+	}
+
+	utils.WriteJSON(w, status, summary)
+}
+
+// checkAccount - reconciliation can be started only on account of user from path. Returns false when error response was written.
+func (api *ReconciliationAPI) checkAccount(w http.ResponseWriter, r *http.Request, userID models.UserID, accountID models.AccountID) bool {
+	account, err := api.DB.GetAccountByID(r.Context(), accountID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting account.", http.StatusConflict)
+		return false
+	}
+
+	if account.UserID == nil || *account.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, "Account not found.", nil)
+		return false
+	}
+	return true
+}
+
+// getReconciliation - reconciliation is found only under path of its owner, so user can't reach reconciliation of another user
+func (api *ReconciliationAPI) getReconciliation(w http.ResponseWriter, r *http.Request, userID models.UserID, reconciliationID models.ReconciliationID) (*models.Reconciliation, bool) {
+	reconciliation, err := api.DB.GetReconciliationByID(r.Context(), reconciliationID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting reconciliation.", http.StatusConflict)
+		return nil, false
+	}
+
+	if reconciliation.UserID == nil || *reconciliation.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, "Reconciliation not found.", nil)
+		return nil, false
+	}
+
+	return reconciliation, true
+}
+
+// writeChanged - reconciliation was changed since client has read it, client gets ETag of current version
+func (api *ReconciliationAPI) writeChanged(w http.ResponseWriter, r *http.Request, reconciliationID models.ReconciliationID) {
+	reconciliation, err := api.DB.GetReconciliationByID(r.Context(), reconciliationID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting reconciliation.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, reconciliation.Version)
+}
@@ -0,0 +1,72 @@
+package v1
+
+import (
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// ReportAPI - provides REST for reports over user's accounts
+type ReportAPI struct {
+	DB database.Database
+}
+
+func SetReportAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := ReportAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- REPORTS ---------- */
+		NewAPI("/users/{userID}/reports/net-worth", "GET", api.NetWorth, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// GET - /users/{userID}/reports/net-worth?date=2006-01-02
+// Permission - MemberIsTarget
+func (api *ReportAPI) NetWorth(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "report.go -> NetWorth()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// * Report includes all transactions of the date, default is now
+	date, until := time.Now(), time.Now()
+	if value := r.URL.Query().Get("date"); value != "" {
+		var err error
+		if date, err = time.Parse("2006-01-02", value); err != nil {
+			utils.ResponseErrWithMap(err, w, "Could not parse date.", http.StatusBadRequest)
+			return
+		}
+		until = date.AddDate(0, 0, 1)
+	}
+
+	ctx := r.Context()
+	balances, err := api.DB.ListAccountBalances(ctx, userID, until)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting account balances.", http.StatusConflict)
+		return
+	}
+	if balances == nil {
+		balances = make([]*models.AccountBalance, 0)
+	}
+
+	logger.Info("Net worth report returned")
+	utils.WriteJSON(w, http.StatusOK, models.NewNetWorthReport(date, balances))
+}
@@ -54,11 +54,18 @@ func (api *RoleApi) GrantRole(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	// Store role in database
-	if err := api.DB.GrantRole(ctx, userID, userRole.Role); err != nil {
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.GrantRole(ctx, userID, userRole.Role); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditRole, string(userRole.Role), nil, &userRole)
+	})
+	if err != nil {
 		logger.WithError(err).Warn("Error granting role.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error granting role.", nil)
 		return
 	}
+
 	utils.WriteJSON(w, http.StatusCreated, &ActCreated{
 		Created: true,
 	})
@@ -116,11 +123,18 @@ func (api *RoleApi) RevokeRole(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 	// Store role in database
-	if err := api.DB.RevokeRole(ctx, userID, userRole.Role); err != nil {
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.RevokeRole(ctx, userID, userRole.Role); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditRole, string(userRole.Role), &userRole, nil)
+	})
+	if err != nil {
 		logger.WithError(err).Warn("Error revoking role.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error revoking role.", nil)
 		return
 	}
+
 	utils.WriteJSON(w, http.StatusCreated, &ActDeleted{
 		Deleted: true,
 	})
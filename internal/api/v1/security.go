@@ -0,0 +1,189 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// PricesImported - number of imported security prices
+type PricesImported struct {
+	Imported int `json:"imported"`
+}
+
+// SecurityAPI - provides REST for securities and their prices
+type SecurityAPI struct {
+	DB database.Database
+}
+
+func SetSecurityAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := SecurityAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- SECURITIES ---------- */
+		NewAPI("/securities", "POST", api.Create, auth.Admin),
+		NewAPI("/securities", "GET", api.List, auth.Admin, auth.Member),
+		NewAPI("/securities/prices", "POST", api.ImportPrices, auth.Admin),
+		NewAPI("/securities/{securityID}/prices", "GET", api.Prices, auth.Admin, auth.Member),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// POST - /securities
+// Permission - Admin
+func (api *SecurityAPI) Create(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "security.go -> Create()")
+
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"principal": principal,
+	})
+
+	// Decode parameters
+	var security models.Security
+	if err := json.NewDecoder(r.Body).Decode(&security); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	if err := security.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateSecurity(ctx, &security); err != nil {
+			return err
+		}
+		return writeAudit(db, r, models.NilUserID, models.AuditCreate, models.AuditSecurity, string(security.ID), nil, &security)
+	})
+	if err == database.ErrSecurityExists {
+		utils.ResponseErr(err, w, "Security already exists.", http.StatusConflict)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error creating security.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error creating security.", nil)
+		return
+	}
+
+	logger.WithField("securityID", security.ID).Info("Security created")
+	utils.WriteJSON(w, http.StatusCreated, security)
+}
+
+// GET - /securities
+// Permission - Admin, Member
+func (api *SecurityAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "security.go -> List()")
+
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	securities, err := api.DB.ListSecurities(ctx)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting securities.", http.StatusConflict)
+		return
+	}
+
+	if securities == nil {
+		securities = make([]*models.Security, 0)
+	}
+
+	logger.Info("Securities returned")
+	utils.WriteJSON(w, http.StatusOK, securities)
+}
+
+// POST - /securities/prices
+// Permission - Admin
+func (api *SecurityAPI) ImportPrices(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "security.go -> ImportPrices()")
+
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"principal": principal,
+	})
+
+	// Decode parameters
+	var prices []*models.SecurityPrice
+	if err := json.NewDecoder(r.Body).Decode(&prices); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	for _, price := range prices {
+		if err := price.Verify(); err != nil {
+			utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.ImportSecurityPrices(ctx, prices); err != nil {
+			return err
+		}
+		return writeAudit(db, r, models.NilUserID, models.AuditCreate, models.AuditSecurityPrice, "", nil, &PricesImported{
+			Imported: len(prices),
+		})
+	})
+	if errors.Cause(err) == database.ErrUnknownSecurity {
+		utils.ResponseErrWithMap(err, w, "Unknown security.", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error importing security prices.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error importing security prices.", nil)
+		return
+	}
+
+	logger.WithField("imported", len(prices)).Info("Security prices imported")
+	utils.WriteJSON(w, http.StatusOK, &PricesImported{
+		Imported: len(prices),
+	})
+}
+
+// GET - /securities/{securityID}/prices
+// Permission - Admin, Member
+func (api *SecurityAPI) Prices(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "security.go -> Prices()")
+
+	vars := mux.Vars(r)
+	securityID := models.SecurityID(vars["securityID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"principal":   principal,
+		"security_id": securityID,
+	})
+
+	ctx := r.Context()
+	prices, err := api.DB.ListSecurityPrices(ctx, securityID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting security prices.", http.StatusConflict)
+		return
+	}
+
+	if prices == nil {
+		prices = make([]*models.SecurityPrice, 0)
+	}
+
+	logger.Info("Security prices returned")
+	utils.WriteJSON(w, http.StatusOK, prices)
+}
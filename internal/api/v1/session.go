@@ -0,0 +1,177 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// SessionAPI - provides REST for user's sessions (devices)
+type SessionAPI struct {
+	DB database.Database
+}
+
+func SetSessionAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := SessionAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- SESSIONS ---------- */
+		NewAPI("/users/{userID}/sessions", "GET", auth.DenyAPIKey(api.List), auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/sessions", "DELETE", auth.DenyAPIKey(api.RevokeAll), auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/sessions/{deviceID}", "DELETE", auth.DenyAPIKey(api.Revoke), auth.Admin, auth.MemberIsTarget),
+
+		/* ---------- LOGOUT ---------- */
+		NewAPI("/logout", "POST", auth.DenyAPIKey(api.Logout), auth.Member),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// GET - /users/{userID}/sessions
+// Permission - Admin, MemberIsTarget
+func (api *SessionAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "session.go -> List()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	sessions, err := api.DB.ListSessions(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting sessions.", http.StatusConflict)
+		return
+	}
+
+	if sessions == nil {
+		sessions = make([]*models.Session, 0)
+	}
+
+	logger.Info("Sessions returned")
+	utils.WriteJSON(w, http.StatusOK, sessions)
+}
+
+// DELETE - /users/{userID}/sessions/{deviceID}
+// Permission - Admin, MemberIsTarget
+func (api *SessionAPI) Revoke(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "session.go -> Revoke()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	deviceID := models.DeviceID(vars["deviceID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"device_id": deviceID,
+	})
+
+	ctx := r.Context()
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.RevokeSession(ctx, userID, deviceID); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditSession, string(deviceID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error revoking session.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Session revoked")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
+
+// DELETE - /users/{userID}/sessions
+// Permission - Admin, MemberIsTarget
+func (api *SessionAPI) RevokeAll(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "session.go -> RevokeAll()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.RevokeAllSessions(ctx, userID); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditSession, "", nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error revoking sessions.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("All sessions revoked")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: true,
+	})
+}
+
+// POST - /logout
+// Permission - Member
+func (api *SessionAPI) Logout(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "session.go -> Logout()")
+
+	principal := auth.GetPrincipal(r)
+
+	var sessionData models.SessionData
+	if err := json.NewDecoder(r.Body).Decode(&sessionData); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+	if err := sessionData.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	logger = logger.WithFields(logrus.Fields{
+		"principal": principal,
+		"device_id": sessionData.DeviceID,
+	})
+
+	ctx := r.Context()
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.RevokeSession(ctx, principal.UserID, sessionData.DeviceID); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, principal.UserID, models.AuditDelete, models.AuditSession, string(sessionData.DeviceID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error revoking session.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("User logged out")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
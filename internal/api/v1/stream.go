@@ -0,0 +1,201 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/events"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// streamHeartbeat - comment is sent this often, so proxies don't close idle stream
+const streamHeartbeat = 25 * time.Second
+
+// maxStreamReplay - reconnected client which missed more events is asked to resync
+const maxStreamReplay = 500
+
+// StreamAPI - pushes changes of user's resources to clients with Server-Sent Events
+type StreamAPI struct {
+	DB          database.Database
+	Broker      *events.Broker
+	Permissions auth.Permissions
+}
+
+func SetStreamAPI(db database.Database, broker *events.Broker, router *mux.Router, permissons auth.Permissions) {
+	api := StreamAPI{
+		DB:          db,
+		Broker:      broker,
+		Permissions: permissons,
+	}
+
+	/* ---------- STREAM ---------- */
+	// * EventSource in browser can't send headers, so stream checks credential itself and short-lived ticket can be in query
+	router.HandleFunc("/users/{userID}/stream/tickets", permissons.Wrap(api.Ticket, auth.Admin, auth.MemberIsTarget)).Methods("POST")
+	router.HandleFunc("/users/{userID}/stream", api.Stream).Methods("GET")
+}
+
+// StreamTicket - ticket is sent in URL of stream instead of access token
+type StreamTicket struct {
+	Ticket    string `json:"ticket"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// POST - /users/{userID}/stream/tickets
+// Permission - Admin, MemberIsTarget
+func (api *StreamAPI) Ticket(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "stream.go -> Ticket()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// * API key is sent in header, script doesn't need ticket
+	if principal.SessionID == models.NilFamilyID {
+		utils.WriteError(w, http.StatusForbidden, "Stream ticket is issued only for session.", nil)
+		return
+	}
+
+	ticket, expiresAt, err := auth.IssueStreamTicket(principal)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error issuing stream ticket.", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Stream ticket issued")
+	utils.WriteJSON(w, http.StatusCreated, &StreamTicket{
+		Ticket:    ticket,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// GET - /users/{userID}/stream?ticket={ticket}&last_event_id={eventID}
+// Permission - Admin, MemberIsTarget
+func (api *StreamAPI) Stream(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "stream.go -> Stream()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+
+	r, err := api.authenticate(r)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, err.Error(), nil)
+		return
+	}
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	if !api.Permissions.Check(r, auth.Admin, auth.MemberIsTarget) {
+		utils.WriteError(w, http.StatusUnauthorized, "permission denied", nil)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		utils.WriteError(w, http.StatusInternalServerError, "Streaming is not supported.", nil)
+		return
+	}
+
+	// * Subscription starts before replay, so no event is lost between them. Client ignores repeated event IDs.
+	subscription := api.Broker.Subscribe(userID)
+	defer api.Broker.Unsubscribe(subscription)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	ctx := r.Context()
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	if lastEventID != "" {
+		missed, err := api.DB.ListOutboxEventsAfter(ctx, userID, models.OutboxEventID(lastEventID), maxStreamReplay)
+		if err != nil || len(missed) == maxStreamReplay {
+			missed = []*models.OutboxEvent{nil}
+		}
+		for _, event := range missed {
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+		}
+	}
+	flusher.Flush()
+
+	logger.Info("Stream opened")
+
+	ticker := time.NewTicker(streamHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Info("Stream closed")
+			return
+		case event := <-subscription.Events:
+			if err := writeStreamEvent(w, event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			// * Stream of revoked session or API key is closed, client has to authenticate again
+			if err := auth.CheckPrincipal(ctx, api.DB, principal); err != nil {
+				logger.WithError(err).Info("Stream closed, credential revoked")
+				fmt.Fprint(w, "event: revoked\ndata: {}\n\n")
+				flusher.Flush()
+				return
+			}
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// authenticate - request without Authorization header can send stream ticket in query.
+// Access token is never accepted in query, URL ends up in logs of proxies.
+func (api *StreamAPI) authenticate(r *http.Request) (*http.Request, error) {
+	if auth.GetPrincipal(r) != models.NilPrincipal {
+		return r, nil
+	}
+
+	ticket := r.URL.Query().Get("ticket")
+	if ticket == "" {
+		return r, nil
+	}
+
+	return auth.CheckStreamTicket(api.DB, r, ticket)
+}
+
+// writeStreamEvent - nil event tells client that events could be lost and it has to load data again
+func writeStreamEvent(w http.ResponseWriter, event *models.OutboxEvent) error {
+	if event == nil {
+		_, err := fmt.Fprint(w, "event: resync\ndata: {}\n\n")
+		return err
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
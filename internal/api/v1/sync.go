@@ -0,0 +1,423 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/notify"
+	"finance/internal/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// SyncAPI - provides delta sync for offline clients
+type SyncAPI struct {
+	DB          database.Database
+	Permissions auth.Permissions
+}
+
+func SetSyncAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := SyncAPI{
+		DB:          db,
+		Permissions: permissons,
+	}
+
+	apis := []API{
+		/* ---------- SYNC ---------- */
+		NewAPI("/users/{userID}/sync", "GET", api.Changes, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/sync", "POST", api.Push, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// GET - /users/{userID}/sync?since={token}&limit={limit}
+// Permission - MemberIsTarget
+func (api *SyncAPI) Changes(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "sync.go -> Changes()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	query := r.URL.Query()
+	since, err := models.ParseSyncToken(query.Get("since"))
+	if err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not parse since.", http.StatusBadRequest)
+		return
+	}
+
+	var limit int
+	if value := query.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			utils.ResponseErrWithMap(err, w, "Could not parse limit.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := r.Context()
+	changes, err := api.DB.ListSyncChanges(ctx, userID, since, limit)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting changes.", http.StatusConflict)
+		return
+	}
+
+	logger.WithField("token", changes.Token).Info("Changes returned")
+	utils.WriteJSON(w, http.StatusOK, changes)
+}
+
+// POST - /users/{userID}/sync
+// Permission - MemberIsTarget
+// * Changes are applied one by one in order accounts, categories, merchants, transactions, deletions.
+// Every change has its own result, so one conflict doesn't stop the others.
+func (api *SyncAPI) Push(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "sync.go -> Push()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// Decode parameters
+	var push models.SyncPush
+	if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := api.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	pusher := syncPusher{
+		db:     api.DB,
+		r:      r,
+		user:   user,
+		admin:  api.Permissions.Check(r, auth.Admin),
+		logger: logger,
+	}
+
+	results := make([]*models.SyncResult, 0, len(push.Accounts)+len(push.Categories)+len(push.Merchants)+len(push.Transactions)+len(push.Deleted))
+	for _, account := range push.Accounts {
+		results = append(results, pusher.account(account))
+	}
+	for _, category := range push.Categories {
+		results = append(results, pusher.category(category))
+	}
+	for _, merchant := range push.Merchants {
+		results = append(results, pusher.merchant(merchant))
+	}
+	for _, transaction := range push.Transactions {
+		results = append(results, pusher.transaction(transaction))
+	}
+	for _, deletion := range push.Deleted {
+		results = append(results, pusher.deletion(deletion))
+	}
+
+	logger.WithField("changes", len(results)).Info("Changes pushed")
+	utils.WriteJSON(w, http.StatusOK, results)
+}
+
+// syncPusher applies changes pushed by client of user
+type syncPusher struct {
+	db     database.Database
+	r      *http.Request
+	user   *models.User
+	admin  bool
+	logger *logrus.Entry
+}
+
+func (p *syncPusher) account(account *models.Account) *models.SyncResult {
+	result := &models.SyncResult{Type: models.SyncAccount, ID: string(account.ID)}
+	account.UserID = &p.user.ID
+	if !models.IsUUID(string(account.ID)) {
+		return result.Rejected("id must be UUID")
+	}
+	if err := account.Verify(); err != nil {
+		return result.Rejected(err.Error())
+	}
+
+	ctx := p.r.Context()
+	var before *models.Account
+	if account.Version > 0 {
+		if before, _ = p.db.GetAccountByID(ctx, account.ID); before == nil || *before.UserID != p.user.ID {
+			return result.Rejected("account not found")
+		}
+	}
+
+	err := p.push(models.AuditAccount, string(account.ID), before == nil, before, account, func(db database.Database) error {
+		return db.PushAccount(ctx, account)
+	})
+	if err == database.ErrVersionConflict {
+		server, _ := p.db.GetAccountByID(ctx, account.ID)
+		if server == nil || *server.UserID != p.user.ID {
+			return result.Rejected("id is already used")
+		}
+		return result.Conflicted(server, server.Version, server.DeletedAt != nil)
+	} else if err != nil {
+		return p.failed(result, err)
+	}
+
+	return result.Applied(account.Version)
+}
+
+func (p *syncPusher) category(category *models.Category) *models.SyncResult {
+	result := &models.SyncResult{Type: models.SyncCategory, ID: string(category.ID)}
+	category.UserID = &p.user.ID
+	if !models.IsUUID(string(category.ID)) {
+		return result.Rejected("id must be UUID")
+	}
+	if err := category.Verify(); err != nil {
+		return result.Rejected(err.Error())
+	}
+
+	ctx := p.r.Context()
+	var before *models.Category
+	if category.Version > 0 {
+		if before, _ = p.db.GetCategoryByID(ctx, category.ID); before == nil || *before.UserID != p.user.ID {
+			return result.Rejected("category not found")
+		}
+	}
+
+	err := p.push(models.AuditCategory, string(category.ID), before == nil, before, category, func(db database.Database) error {
+		return db.PushCategory(ctx, category)
+	})
+	if err == database.ErrVersionConflict {
+		server, _ := p.db.GetCategoryByID(ctx, category.ID)
+		if server == nil || *server.UserID != p.user.ID {
+			return result.Rejected("id is already used")
+		}
+		return result.Conflicted(server, server.Version, server.DeletedAt != nil)
+	} else if err != nil {
+		return p.failed(result, err)
+	}
+
+	return result.Applied(category.Version)
+}
+
+func (p *syncPusher) merchant(merchant *models.Merchant) *models.SyncResult {
+	result := &models.SyncResult{Type: models.SyncMerchant, ID: string(merchant.ID)}
+	merchant.UserID = &p.user.ID
+	if !models.IsUUID(string(merchant.ID)) {
+		return result.Rejected("id must be UUID")
+	}
+	if err := merchant.Verify(); err != nil {
+		return result.Rejected(err.Error())
+	}
+
+	ctx := p.r.Context()
+	var before *models.Merchant
+	if merchant.Version > 0 {
+		if before, _ = p.db.GetMerchantByID(ctx, merchant.ID); before == nil || *before.UserID != p.user.ID {
+			return result.Rejected("merchant not found")
+		}
+	}
+
+	err := p.push(models.AuditMerchant, string(merchant.ID), before == nil, before, merchant, func(db database.Database) error {
+		return db.PushMerchant(ctx, merchant)
+	})
+	if err == database.ErrVersionConflict {
+		server, _ := p.db.GetMerchantByID(ctx, merchant.ID)
+		if server == nil || *server.UserID != p.user.ID {
+			return result.Rejected("id is already used")
+		}
+		return result.Conflicted(server, server.Version, server.DeletedAt != nil)
+	} else if err != nil {
+		return p.failed(result, err)
+	}
+
+	return result.Applied(merchant.Version)
+}
+
+func (p *syncPusher) transaction(transaction *models.Transaction) *models.SyncResult {
+	result := &models.SyncResult{Type: models.SyncTransaction, ID: string(transaction.ID)}
+	transaction.UserID = &p.user.ID
+	if !models.IsUUID(string(transaction.ID)) {
+		return result.Rejected("id must be UUID")
+	}
+	if err := transaction.Verify(); err != nil {
+		return result.Rejected(err.Error())
+	}
+
+	ctx := p.r.Context()
+	var before *models.Transaction
+	if transaction.Version > 0 {
+		if before, _ = p.db.GetTransactionByID(ctx, transaction.ID); before == nil || *before.UserID != p.user.ID {
+			return result.Rejected("transaction not found")
+		}
+		if before.IsReconciled() {
+			return result.Rejected("transaction is reconciled and can't be changed")
+		}
+		// * Transaction can't be moved out of closed period
+		if p.isLocked(before.Date) {
+			return result.Rejected("transaction belongs to closed period")
+		}
+	}
+	if p.isLocked(transaction.Date) {
+		return result.Rejected("transaction belongs to closed period")
+	}
+
+	// * Pushed transaction can't change balance of account or debt of another user
+	err := checkReferences(ctx, p.db, p.user.ID, transaction.AccountID, transaction.CategoryID, transaction.DebtID)
+	if rejected, ok := err.(*referenceError); ok {
+		return result.Rejected(rejected.Error())
+	} else if err != nil {
+		return p.failed(result, err)
+	}
+
+	err = p.push(models.AuditTransaction, string(transaction.ID), before == nil, before, transaction, func(db database.Database) error {
+		return db.PushTransaction(ctx, transaction)
+	})
+	if err == database.ErrVersionConflict {
+		server, _ := p.db.GetTransactionByID(ctx, transaction.ID)
+		if server == nil || *server.UserID != p.user.ID {
+			return result.Rejected("id is already used")
+		}
+		return result.Conflicted(server, server.Version, server.DeletedAt != nil)
+	} else if err != nil {
+		return p.failed(result, err)
+	}
+
+	if before == nil {
+		if err := notify.LargeExpense(ctx, p.db, transaction); err != nil {
+			p.logger.WithError(err).Warn("Error notifying large expense.")
+		}
+	}
+	return result.Applied(transaction.Version)
+}
+
+func (p *syncPusher) deletion(deletion *models.SyncDeletion) *models.SyncResult {
+	result := &models.SyncResult{Type: deletion.Type, ID: deletion.ID}
+	if err := deletion.Verify(); err != nil {
+		return result.Rejected(err.Error())
+	}
+
+	ctx := p.r.Context()
+	before, version, deletedAt := p.resource(deletion.Type, deletion.ID)
+	if before == nil {
+		return result.Rejected(string(deletion.Type) + " not found")
+	}
+	// * Deletion was already applied, e.g. client retries push after lost response
+	if deletedAt != nil {
+		return result.Applied(version)
+	}
+
+	if transaction, ok := before.(*models.Transaction); ok {
+		if transaction.IsReconciled() {
+			return result.Rejected("transaction is reconciled and can't be deleted")
+		}
+		if p.isLocked(transaction.Date) {
+			return result.Rejected("transaction belongs to closed period")
+		}
+	}
+
+	err := p.push(syncAuditTypes[deletion.Type], deletion.ID, false, before, nil, func(db database.Database) error {
+		return db.PushDeletion(ctx, p.user.ID, deletion, p.lockDate())
+	})
+	if err == database.ErrAccountReconciled {
+		return result.Rejected("account has reconciled transactions and can't be deleted")
+	} else if err == database.ErrAccountLocked {
+		return result.Rejected("account has transactions in closed period")
+	} else if err == database.ErrVersionConflict {
+		server, version, deletedAt := p.resource(deletion.Type, deletion.ID)
+		if server == nil {
+			return result.Rejected(string(deletion.Type) + " not found")
+		}
+		return result.Conflicted(server, version, deletedAt != nil)
+	} else if err != nil {
+		return p.failed(result, err)
+	}
+
+	result.Deleted = true
+	return result.Applied(version)
+}
+
+// syncAuditTypes - audit resource type of synced resource type
+var syncAuditTypes = map[models.SyncResourceType]models.AuditResourceType{
+	models.SyncAccount:     models.AuditAccount,
+	models.SyncCategory:    models.AuditCategory,
+	models.SyncMerchant:    models.AuditMerchant,
+	models.SyncTransaction: models.AuditTransaction,
+}
+
+// resource - current state of resource of user, nil if it doesn't exist or belongs to someone else
+func (p *syncPusher) resource(resourceType models.SyncResourceType, id string) (interface{}, int64, *time.Time) {
+	ctx := p.r.Context()
+	switch resourceType {
+	case models.SyncAccount:
+		if account, _ := p.db.GetAccountByID(ctx, models.AccountID(id)); account != nil && *account.UserID == p.user.ID {
+			return account, account.Version, account.DeletedAt
+		}
+	case models.SyncCategory:
+		if category, _ := p.db.GetCategoryByID(ctx, models.CategoryID(id)); category != nil && *category.UserID == p.user.ID {
+			return category, category.Version, category.DeletedAt
+		}
+	case models.SyncMerchant:
+		if merchant, _ := p.db.GetMerchantByID(ctx, models.MerchantID(id)); merchant != nil && *merchant.UserID == p.user.ID {
+			return merchant, merchant.Version, merchant.DeletedAt
+		}
+	case models.SyncTransaction:
+		if transaction, _ := p.db.GetTransactionByID(ctx, models.TransactionID(id)); transaction != nil && *transaction.UserID == p.user.ID {
+			return transaction, transaction.Version, transaction.DeletedAt
+		}
+	}
+	return nil, 0, nil
+}
+
+// isLocked - only admin can change transactions dated in closed period
+func (p *syncPusher) isLocked(date *time.Time) bool {
+	return date != nil && p.user.IsLocked(*date) && !p.admin
+}
+
+// lockDate - lock date which applies to pusher, nil for admin
+func (p *syncPusher) lockDate() *time.Time {
+	if p.admin {
+		return nil
+	}
+	return p.user.LockDate
+}
+
+// push - applies change and writes its audit event in one transaction, after is nil for deletion
+func (p *syncPusher) push(resourceType models.AuditResourceType, resourceID string, created bool, before, after interface{}, apply func(db database.Database) error) error {
+	action := models.AuditUpdate
+	if created {
+		action = models.AuditCreate
+	} else if after == nil {
+		action = models.AuditDelete
+	}
+
+	return p.db.WithTx(p.r.Context(), func(db database.Database) error {
+		if err := apply(db); err != nil {
+			return err
+		}
+		return writeAudit(db, p.r, p.user.ID, action, resourceType, resourceID, before, after)
+	})
+}
+
+// failed - database error is logged, client gets only generic reason
+func (p *syncPusher) failed(result *models.SyncResult, err error) *models.SyncResult {
+	p.logger.WithError(err).WithFields(logrus.Fields{
+		"type": result.Type,
+		"id":   result.ID,
+	}).Warn("Error applying pushed change.")
+	return result.Rejected("could not apply change")
+}
@@ -0,0 +1,285 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fakePermissions lets every request through, admin decides result of admin check
+type fakePermissions struct {
+	admin bool
+}
+
+func (p fakePermissions) Wrap(next http.HandlerFunc, permissionTypes ...auth.PermissionTypes) http.HandlerFunc {
+	return next
+}
+
+func (p fakePermissions) Check(r *http.Request, permissionTypes ...auth.PermissionTypes) bool {
+	return p.admin
+}
+
+// fakeSyncDB keeps users, accounts, categories, debts and transactions in memory.
+// Methods which are not needed by sync handlers are not implemented and panic.
+type fakeSyncDB struct {
+	database.Database
+
+	mu           sync.Mutex
+	users        map[models.UserID]*models.User
+	accounts     map[models.AccountID]*models.Account
+	categories   map[models.CategoryID]*models.Category
+	debts        map[models.DebtID]*models.Debt
+	transactions map[models.TransactionID]*models.Transaction
+}
+
+func newFakeSyncDB() *fakeSyncDB {
+	return &fakeSyncDB{
+		users:        make(map[models.UserID]*models.User),
+		accounts:     make(map[models.AccountID]*models.Account),
+		categories:   make(map[models.CategoryID]*models.Category),
+		debts:        make(map[models.DebtID]*models.Debt),
+		transactions: make(map[models.TransactionID]*models.Transaction),
+	}
+}
+
+func (d *fakeSyncDB) GetUserByID(ctx context.Context, userID models.UserID) (*models.User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if user, ok := d.users[userID]; ok {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeSyncDB) GetAccountByID(ctx context.Context, accountID models.AccountID) (*models.Account, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if account, ok := d.accounts[accountID]; ok {
+		copied := *account
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeSyncDB) GetCategoryByID(ctx context.Context, categoryID models.CategoryID) (*models.Category, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if category, ok := d.categories[categoryID]; ok {
+		copied := *category
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeSyncDB) GetDebtByID(ctx context.Context, debtID models.DebtID) (*models.Debt, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if debt, ok := d.debts[debtID]; ok {
+		copied := *debt
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeSyncDB) GetTransactionByID(ctx context.Context, transactionID models.TransactionID) (*models.Transaction, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if transaction, ok := d.transactions[transactionID]; ok {
+		copied := *transaction
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+// PushTransaction creates transaction at version 0, otherwise updates live transaction of user which is still at version
+func (d *fakeSyncDB) PushTransaction(ctx context.Context, transaction *models.Transaction) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	stored, ok := d.transactions[transaction.ID]
+	if transaction.Version == 0 {
+		if ok {
+			return database.ErrVersionConflict
+		}
+	} else if !ok || *stored.UserID != *transaction.UserID || stored.DeletedAt != nil || stored.Version != transaction.Version {
+		return database.ErrVersionConflict
+	}
+
+	pushed := *transaction
+	pushed.Version++
+	d.transactions[pushed.ID] = &pushed
+	transaction.Version = pushed.Version
+	return nil
+}
+
+// * Fake has no transactions, changes are applied right away
+func (d *fakeSyncDB) WithTx(ctx context.Context, fn func(db database.Database) error) error {
+	return fn(d)
+}
+
+func (d *fakeSyncDB) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	return nil
+}
+
+const (
+	syncUser  = models.UserID("user-1")
+	otherUser = models.UserID("user-2")
+
+	syncAccount    = models.AccountID("a0000000-0000-4000-8000-000000000001")
+	otherAccount   = models.AccountID("a0000000-0000-4000-8000-000000000002")
+	deletedAccount = models.AccountID("a0000000-0000-4000-8000-000000000003")
+
+	syncCategory  = models.CategoryID("c0000000-0000-4000-8000-000000000001")
+	otherCategory = models.CategoryID("c0000000-0000-4000-8000-000000000002")
+
+	otherDebt = models.DebtID("d0000000-0000-4000-8000-000000000002")
+
+	syncTransaction  = models.TransactionID("70000000-0000-4000-8000-000000000001")
+	otherTransaction = models.TransactionID("70000000-0000-4000-8000-000000000002")
+	newTransaction   = models.TransactionID("70000000-0000-4000-8000-000000000003")
+)
+
+// newSyncTest returns router of sync API. Sync user has account, category and transaction at version 2,
+// other user has account, category, debt and transaction too.
+func newSyncTest(t *testing.T) (*fakeSyncDB, *mux.Router) {
+	t.Helper()
+
+	db := newFakeSyncDB()
+	owner, other := syncUser, otherUser
+	now := time.Now()
+
+	db.users[owner] = &models.User{ID: owner}
+	db.users[other] = &models.User{ID: other}
+	db.accounts[syncAccount] = &models.Account{ID: syncAccount, UserID: &owner, Version: 1}
+	db.accounts[otherAccount] = &models.Account{ID: otherAccount, UserID: &other, Version: 1}
+	db.accounts[deletedAccount] = &models.Account{ID: deletedAccount, UserID: &owner, Version: 2, DeletedAt: &now}
+	db.categories[syncCategory] = &models.Category{ID: syncCategory, UserID: &owner, Version: 1}
+	db.categories[otherCategory] = &models.Category{ID: otherCategory, UserID: &other, Version: 1}
+	db.debts[otherDebt] = &models.Debt{ID: otherDebt, UserID: &other, Version: 1}
+	db.transactions[syncTransaction] = syncTransactionAt(syncTransaction, owner, 2)
+	db.transactions[otherTransaction] = syncTransactionAt(otherTransaction, other, 1)
+
+	api := SyncAPI{DB: db, Permissions: fakePermissions{}}
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{userID}/sync", api.Push).Methods("POST")
+	return db, router
+}
+
+// syncTransactionAt - income transaction of user to account and category of sync user
+func syncTransactionAt(id models.TransactionID, userID models.UserID, version int64) *models.Transaction {
+	accountID, categoryID := syncAccount, syncCategory
+	date := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	transactionType := models.Income
+	amount := int64(1000)
+	return &models.Transaction{
+		ID:         id,
+		UserID:     &userID,
+		AccountID:  &accountID,
+		CategoryID: &categoryID,
+		Date:       &date,
+		Type:       &transactionType,
+		Amount:     &amount,
+		Version:    version,
+	}
+}
+
+func TestSyncPushTransaction(t *testing.T) {
+	tests := []struct {
+		name        string
+		change      func(transaction *models.Transaction)
+		id          models.TransactionID
+		version     int64
+		wantStatus  models.SyncStatus
+		wantVersion int64
+		wantError   string
+	}{
+		{name: "new transaction", id: newTransaction, wantStatus: models.SyncApplied, wantVersion: 1},
+		{name: "update at current version", id: syncTransaction, version: 2, wantStatus: models.SyncApplied, wantVersion: 3},
+		{name: "update at stale version", id: syncTransaction, version: 1, wantStatus: models.SyncConflict, wantVersion: 2},
+		{name: "new transaction with used id", id: syncTransaction, wantStatus: models.SyncConflict, wantVersion: 2},
+		{name: "new transaction with id of other user", id: otherTransaction, wantStatus: models.SyncRejected, wantError: "id is already used"},
+		{name: "update of other user", id: otherTransaction, version: 1, wantStatus: models.SyncRejected, wantError: "transaction not found"},
+		{name: "id is not UUID", id: "transaction-1", wantStatus: models.SyncRejected, wantError: "id must be UUID"},
+		{
+			name: "missing amount", id: newTransaction, wantStatus: models.SyncRejected, wantError: "amount is required",
+			change: func(transaction *models.Transaction) { transaction.Amount = nil },
+		},
+		{
+			name: "account of other user", id: newTransaction, wantStatus: models.SyncRejected, wantError: "account not found",
+			change: func(transaction *models.Transaction) { id := otherAccount; transaction.AccountID = &id },
+		},
+		{
+			name: "deleted account", id: syncTransaction, version: 2, wantStatus: models.SyncRejected, wantError: "account is deleted",
+			change: func(transaction *models.Transaction) { id := deletedAccount; transaction.AccountID = &id },
+		},
+		{
+			name: "category of other user", id: newTransaction, wantStatus: models.SyncRejected, wantError: "category not found",
+			change: func(transaction *models.Transaction) { id := otherCategory; transaction.CategoryID = &id },
+		},
+		{
+			name: "debt of other user", id: newTransaction, wantStatus: models.SyncRejected, wantError: "debt not found",
+			change: func(transaction *models.Transaction) { id := otherDebt; transaction.DebtID = &id },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, router := newSyncTest(t)
+
+			// * Client doesn't send owner, it is taken from URL
+			transaction := syncTransactionAt(tt.id, models.NilUserID, tt.version)
+			transaction.UserID = nil
+			if tt.change != nil {
+				tt.change(transaction)
+			}
+			body, err := json.Marshal(models.SyncPush{Transactions: []*models.Transaction{transaction}})
+			if err != nil {
+				t.Fatalf("encode push: %v", err)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("POST", "/users/"+string(syncUser)+"/sync", bytes.NewReader(body)))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+			}
+
+			var results []*models.SyncResult
+			if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil || len(results) != 1 {
+				t.Fatalf("results = %s (%v), want one result", w.Body.String(), err)
+			}
+			result := results[0]
+			if result.Status != tt.wantStatus || result.Version != tt.wantVersion || result.Error != tt.wantError {
+				t.Errorf("result = %s %d %q, want %s %d %q", result.Status, result.Version, result.Error, tt.wantStatus, tt.wantVersion, tt.wantError)
+			}
+			if tt.wantStatus == models.SyncConflict && result.Server == nil {
+				t.Error("conflict has no server state")
+			}
+
+			// * Rejected and conflicted changes must not touch stored transaction
+			if tt.wantStatus != models.SyncApplied {
+				if stored, _ := db.GetTransactionByID(context.Background(), tt.id); stored != nil && stored.Version != syncStoredVersion(tt.id) {
+					t.Errorf("stored version = %d, want %d", stored.Version, syncStoredVersion(tt.id))
+				}
+			}
+		})
+	}
+}
+
+// syncStoredVersion - version of transaction created by newSyncTest
+func syncStoredVersion(id models.TransactionID) int64 {
+	if id == syncTransaction {
+		return 2
+	}
+	return 1
+}
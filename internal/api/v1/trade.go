@@ -0,0 +1,376 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// TradeAPI - provides REST for trades and holdings of investment accounts
+type TradeAPI struct {
+	DB database.Database
+}
+
+func SetTradeAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := TradeAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- TRADES ---------- */
+		NewAPI("/users/{userID}/accounts/{accountID}/trades", "POST", api.Create, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/accounts/{accountID}/trades", "GET", api.List, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/accounts/{accountID}/holdings", "GET", api.Holdings, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/trades/{tradeID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/trades/{tradeID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/trades/{tradeID}/restore", "POST", api.Restore, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// POST - /users/{userID}/accounts/{accountID}/trades
+// Permission - MemberIsTarget
+func (api *TradeAPI) Create(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "trade.go -> Create()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	accountID := models.AccountID(vars["accountID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"account_id": accountID,
+	})
+
+	// Decode parameters
+	var trade models.Trade
+	if err := json.NewDecoder(r.Body).Decode(&trade); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	trade.UserID = &userID
+	trade.AccountID = &accountID
+
+	if err := trade.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	account, ok := api.getAccount(w, r, userID, accountID)
+	if !ok {
+		return
+	}
+	if account.Type == nil || *account.Type != models.Investment || account.DeletedAt != nil {
+		utils.WriteError(w, http.StatusBadRequest, "Account is not an investment account.", nil)
+		return
+	}
+
+	ctx := r.Context()
+	if _, err := api.DB.GetSecurityByID(ctx, *trade.SecurityID); err != nil {
+		utils.ResponseErr(err, w, "Error getting security.", http.StatusConflict)
+		return
+	}
+
+	if !api.checkHoldings(w, r, accountID, func(trades []*models.Trade) []*models.Trade {
+		return append(trades, &trade)
+	}) {
+		return
+	}
+
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateTrade(ctx, &trade); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditTrade, string(trade.ID), nil, &trade)
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Error creating trade.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error creating trade.", nil)
+		return
+	}
+
+	logger.WithField("tradeID", trade.ID).Info("Trade created")
+	utils.WriteJSON(w, http.StatusCreated, trade)
+}
+
+// GET - /users/{userID}/accounts/{accountID}/trades
+// Permission - MemberIsTarget
+func (api *TradeAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "trade.go -> List()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	accountID := models.AccountID(vars["accountID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"account_id": accountID,
+	})
+
+	if _, ok := api.getAccount(w, r, userID, accountID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	trades, err := api.DB.ListTradesByAccountID(ctx, accountID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting trades.", http.StatusConflict)
+		return
+	}
+
+	if trades == nil {
+		trades = make([]*models.Trade, 0)
+	}
+
+	logger.Info("Trades returned")
+	utils.WriteJSON(w, http.StatusOK, trades)
+}
+
+// GET - /users/{userID}/accounts/{accountID}/holdings
+// Permission - MemberIsTarget
+func (api *TradeAPI) Holdings(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "trade.go -> Holdings()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	accountID := models.AccountID(vars["accountID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"account_id": accountID,
+	})
+
+	if _, ok := api.getAccount(w, r, userID, accountID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	trades, err := api.DB.ListTradesByAccountID(ctx, accountID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting trades.", http.StatusConflict)
+		return
+	}
+
+	prices, err := api.DB.ListLatestPricesByAccountID(ctx, accountID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting security prices.", http.StatusConflict)
+		return
+	}
+
+	portfolio, err := models.NewPortfolio(accountID, trades, prices)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error matching trades.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Holdings returned")
+	utils.WriteJSON(w, http.StatusOK, portfolio)
+}
+
+// GET - /users/{userID}/trades/{tradeID}
+// Permission - MemberIsTarget
+func (api *TradeAPI) Get(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "trade.go -> Get()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	tradeID := models.TradeID(vars["tradeID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"trade_id":  tradeID,
+	})
+
+	trade, ok := api.getTrade(w, r, userID, tradeID)
+	if !ok {
+		return
+	}
+
+	logger.Info("Trade returned")
+	writeETag(w, trade.Version)
+	utils.WriteJSON(w, http.StatusOK, trade)
+}
+
+// DELETE - /users/{userID}/trades/{tradeID}
+// Permission - MemberIsTarget
+func (api *TradeAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "trade.go -> Delete()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	tradeID := models.TradeID(vars["tradeID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"trade_id":  tradeID,
+	})
+
+	ctx := r.Context()
+	trade, ok := api.getTrade(w, r, userID, tradeID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, trade.Version) {
+		return
+	}
+
+	// * Buy can't be deleted when its units were sold later
+	if !api.checkHoldings(w, r, *trade.AccountID, func(trades []*models.Trade) []*models.Trade {
+		left := make([]*models.Trade, 0, len(trades))
+		for _, t := range trades {
+			if t.ID != tradeID {
+				left = append(left, t)
+			}
+		}
+		return left
+	}) {
+		return
+	}
+
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteTrade(ctx, tradeID, trade.Version); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditTrade, string(tradeID), trade, nil)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, tradeID)
+		return
+	} else if err != nil {
+		utils.ResponseErr(err, w, "Error deleting trade.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Trade deleted")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
+
+// POST - /users/{userID}/trades/{tradeID}/restore
+// Permission - MemberIsTarget
+func (api *TradeAPI) Restore(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "trade.go -> Restore()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	tradeID := models.TradeID(vars["tradeID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+		"trade_id":  tradeID,
+	})
+
+	ctx := r.Context()
+	trade, ok := api.getTrade(w, r, userID, tradeID)
+	if !ok {
+		return
+	}
+
+	// * Sell can't be restored when its units are not held anymore
+	if trade.DeletedAt != nil && !api.checkHoldings(w, r, *trade.AccountID, func(trades []*models.Trade) []*models.Trade {
+		return append(trades, trade)
+	}) {
+		return
+	}
+
+	var restored bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if restored, err = db.RestoreTrade(ctx, tradeID); err != nil || !restored {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditRestore, models.AuditTrade, string(tradeID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error restoring trade.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Trade restored")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: restored,
+	})
+}
+
+// checkHoldings - trades of account after change must never sell more units than are held
+func (api *TradeAPI) checkHoldings(w http.ResponseWriter, r *http.Request, accountID models.AccountID, change func([]*models.Trade) []*models.Trade) bool {
+	trades, err := api.DB.ListTradesByAccountID(r.Context(), accountID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting trades.", http.StatusConflict)
+		return false
+	}
+
+	if _, err := models.NewPortfolio(accountID, change(trades), nil); err != nil {
+		utils.ResponseErrWithMap(err, w, "Trade would sell more units than are held.", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// getAccount - trades can be kept only on account of user from path
+func (api *TradeAPI) getAccount(w http.ResponseWriter, r *http.Request, userID models.UserID, accountID models.AccountID) (*models.Account, bool) {
+	account, err := api.DB.GetAccountByID(r.Context(), accountID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting account.", http.StatusConflict)
+		return nil, false
+	}
+
+	if account.UserID == nil || *account.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, "Account not found.", nil)
+		return nil, false
+	}
+
+	return account, true
+}
+
+// getTrade - trade is found only under path of its owner, so user can't reach trade of another user
+func (api *TradeAPI) getTrade(w http.ResponseWriter, r *http.Request, userID models.UserID, tradeID models.TradeID) (*models.Trade, bool) {
+	trade, err := api.DB.GetTradeByID(r.Context(), tradeID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting trade.", http.StatusConflict)
+		return nil, false
+	}
+
+	if trade.UserID == nil || *trade.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, "Trade not found.", nil)
+		return nil, false
+	}
+
+	return trade, true
+}
+
+// writeChanged - trade was changed since client has read it, client gets ETag of current version
+func (api *TradeAPI) writeChanged(w http.ResponseWriter, r *http.Request, tradeID models.TradeID) {
+	trade, err := api.DB.GetTradeByID(r.Context(), tradeID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting trade.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, trade.Version)
+}
@@ -5,8 +5,11 @@ import (
 	"finance/internal/api/auth"
 	"finance/internal/database"
 	"finance/internal/models"
+	"finance/internal/notify"
 	"finance/internal/utils"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -14,12 +17,14 @@ import (
 
 // TransactionAPI - provides REST for Transaction
 type TransactionAPI struct {
-	DB database.Database
+	DB          database.Database
+	Permissions auth.Permissions
 }
 
 func SetTransactionAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
 	api := TransactionAPI{
-		DB: db,
+		DB:          db,
+		Permissions: permissons,
 	}
 
 	apis := []API{
@@ -31,6 +36,9 @@ func SetTransactionAPI(db database.Database, router *mux.Router, permissons auth
 		NewAPI("/users/{userID}/transactions/{transactionID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
 		NewAPI("/users/{userID}/transactions/{transactionID}", "PATCH", api.Update, auth.Admin, auth.MemberIsTarget),
 		NewAPI("/users/{userID}/transactions/{transactionID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/transactions/{transactionID}/restore", "POST", api.Restore, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/transactions/{transactionID}/history", "GET", api.History, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/transactions/{transactionID}/history/{revision}/restore", "POST", api.RestoreVersion, auth.Admin, auth.MemberIsTarget),
 	}
 
 	for _, api := range apis {
@@ -66,15 +74,29 @@ func (api *TransactionAPI) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !api.checkLockDate(w, r, userID, transaction.Date) {
+		return
+	}
+
 	ctx := r.Context()
 	// Store role in database
 
-	if err := api.DB.CreateTransaction(ctx, &transaction); err != nil {
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateTransaction(ctx, &transaction); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditTransaction, string(transaction.ID), nil, &transaction)
+	})
+	if err != nil {
 		logger.WithError(err).Warn("Error creating transaction.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error creating transaction.", nil)
 		return
 	}
 
+	if err := notify.LargeExpense(ctx, api.DB, &transaction); err != nil {
+		logger.WithError(err).Warn("Error notifying large expense.")
+	}
+
 	logger.WithField("transactionID", transaction.ID).Info("Transaction created")
 	utils.WriteJSON(w, http.StatusCreated, transaction)
 }
@@ -103,9 +125,18 @@ func (api *TransactionAPI) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
-	transaction, err := api.DB.GetTransactionByID(ctx, transactionID)
-	if err != nil {
-		utils.ResponseErr(err, w, "Error getting transaction.", http.StatusConflict)
+	transaction, ok := api.getTransaction(w, r, userID, transactionID)
+	if !ok {
+		return
+	}
+
+	if transaction.IsReconciled() {
+		utils.WriteError(w, http.StatusConflict, "Transaction is reconciled and can't be changed.", nil)
+		return
+	}
+	before := *transaction
+
+	if !checkIfMatch(w, r, transaction.Version) {
 		return
 	}
 
@@ -133,13 +164,32 @@ func (api *TransactionAPI) Update(w http.ResponseWriter, r *http.Request) {
 		transaction.Notes = transactionRequest.Notes
 	}
 
-	if err := api.DB.UpdateTransaction(ctx, transaction); err != nil {
+	if transactionRequest.Cleared != nil {
+		transaction.Cleared = transactionRequest.Cleared
+	}
+
+	// * Transaction can't be moved into or out of closed period
+	if !api.checkLockDate(w, r, userID, before.Date, transaction.Date) {
+		return
+	}
+
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.UpdateTransaction(ctx, transaction); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditUpdate, models.AuditTransaction, string(transactionID), &before, transaction)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, transactionID)
+		return
+	} else if err != nil {
 		logger.WithError(err).Warn("Error updating transaction.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error updating transaction.", nil)
 		return
 	}
 
 	logger.Info("Transaction update")
+	writeETag(w, transaction.Version)
 	utils.WriteJSON(w, http.StatusOK, transaction)
 }
 
@@ -159,14 +209,13 @@ func (api *TransactionAPI) Get(w http.ResponseWriter, r *http.Request) {
 		"transaction_id": transactionID,
 	})
 
-	ctx := r.Context()
-	transaction, err := api.DB.GetTransactionByID(ctx, transactionID)
-	if err != nil {
-		utils.ResponseErr(err, w, "Error getting transaction.", http.StatusConflict)
+	transaction, ok := api.getTransaction(w, r, userID, transactionID)
+	if !ok {
 		return
 	}
 
 	logger.Info("Transaction returned")
+	writeETag(w, transaction.Version)
 	utils.WriteJSON(w, http.StatusOK, transaction)
 }
 
@@ -319,8 +368,33 @@ func (api *TransactionAPI) Delete(w http.ResponseWriter, r *http.Request) {
 	})
 
 	ctx := r.Context()
-	deleted, err := api.DB.DeleteTransaction(ctx, transactionID)
-	if err != nil {
+	before, ok := api.getTransaction(w, r, userID, transactionID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, before.Version) {
+		return
+	}
+	if before.IsReconciled() {
+		utils.WriteError(w, http.StatusConflict, "Transaction is reconciled and can't be deleted.", nil)
+		return
+	}
+	if !api.checkLockDate(w, r, userID, before.Date) {
+		return
+	}
+
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteTransaction(ctx, transactionID, before.Version); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditTransaction, string(transactionID), before, nil)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, transactionID)
+		return
+	} else if err != nil {
 		utils.ResponseErr(err, w, "Error deleting transaction.", http.StatusConflict)
 		return
 	}
@@ -330,3 +404,222 @@ func (api *TransactionAPI) Delete(w http.ResponseWriter, r *http.Request) {
 		Deleted: deleted,
 	})
 }
+
+// POST - /users/{userID}/transactions/{transactionID}/restore
+// Permission - MemberIsTarget
+func (api *TransactionAPI) Restore(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "transaction.go -> Restore()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	transactionID := models.TransactionID(vars["transactionID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"principal":   principal,
+		"transaction_id": transactionID,
+	})
+
+	ctx := r.Context()
+	transaction, ok := api.getTransaction(w, r, userID, transactionID)
+	if !ok {
+		return
+	}
+
+	// * Transaction can't reference deleted account or category, they must be restored first
+	if !writeReferences(w, r, api.DB, userID, transaction.AccountID, transaction.CategoryID, nil) {
+		return
+	}
+
+	if !api.checkLockDate(w, r, userID, transaction.Date) {
+		return
+	}
+
+	var restored bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if restored, err = db.RestoreTransaction(ctx, transactionID); err != nil || !restored {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditRestore, models.AuditTransaction, string(transactionID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error restoring transaction.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Transaction restored")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: restored,
+	})
+}
+
+// GET - /users/{userID}/transactions/{transactionID}/history
+// Permission - MemberIsTarget
+func (api *TransactionAPI) History(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "transaction.go -> History()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	transactionID := models.TransactionID(vars["transactionID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":        userID,
+		"principal":      principal,
+		"transaction_id": transactionID,
+	})
+
+	if _, ok := api.getTransaction(w, r, userID, transactionID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	versions, err := api.DB.ListTransactionVersions(ctx, transactionID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting transaction history.", http.StatusConflict)
+		return
+	}
+
+	if versions == nil {
+		versions = make([]*models.TransactionVersion, 0)
+	}
+
+	logger.Info("Transaction history returned")
+	utils.WriteJSON(w, http.StatusOK, versions)
+}
+
+// POST - /users/{userID}/transactions/{transactionID}/history/{revision}/restore
+// Permission - MemberIsTarget
+func (api *TransactionAPI) RestoreVersion(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "transaction.go -> RestoreVersion()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	transactionID := models.TransactionID(vars["transactionID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":        userID,
+		"principal":      principal,
+		"transaction_id": transactionID,
+		"revision":       vars["revision"],
+	})
+
+	revision, err := strconv.Atoi(vars["revision"])
+	if err != nil {
+		utils.ResponseErrWithMap(err, w, "Invalid revision.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	transaction, ok := api.getTransaction(w, r, userID, transactionID)
+	if !ok {
+		return
+	}
+
+	if !checkIfMatch(w, r, transaction.Version) {
+		return
+	}
+
+	if transaction.DeletedAt != nil {
+		utils.WriteError(w, http.StatusConflict, "Transaction is deleted, restore it first.", nil)
+		return
+	}
+
+	if transaction.IsReconciled() {
+		utils.WriteError(w, http.StatusConflict, "Transaction is reconciled and can't be changed.", nil)
+		return
+	}
+
+	transactionVersion, err := api.DB.GetTransactionVersion(ctx, transactionID, revision)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting transaction version.", http.StatusNotFound)
+		return
+	}
+
+	// * Restore is an update too, so current values go to history and restore can be undone
+	before := *transaction
+	transaction.AccountID = transactionVersion.AccountID
+	transaction.CategoryID = transactionVersion.CategoryID
+	transaction.Date = transactionVersion.Date
+	transaction.Type = transactionVersion.Type
+	transaction.Amount = transactionVersion.Amount
+	transaction.Notes = transactionVersion.Notes
+
+	// * Version may reference account or category which was deleted since
+	if !writeReferences(w, r, api.DB, userID, transaction.AccountID, transaction.CategoryID, nil) {
+		return
+	}
+
+	if !api.checkLockDate(w, r, userID, before.Date, transaction.Date) {
+		return
+	}
+
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.UpdateTransaction(ctx, transaction); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditUpdate, models.AuditTransaction, string(transactionID), &before, transaction)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, transactionID)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error restoring transaction version.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error restoring transaction version.", nil)
+		return
+	}
+
+	logger.Info("Transaction version restored")
+	writeETag(w, transaction.Version)
+	utils.WriteJSON(w, http.StatusOK, transaction)
+}
+
+// checkLockDate - Only admin can change transactions dated in closed period. Returns false when error response was written.
+func (api *TransactionAPI) checkLockDate(w http.ResponseWriter, r *http.Request, userID models.UserID, dates ...*time.Time) bool {
+	ctx := r.Context()
+	user, err := api.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return false
+	}
+
+	for _, date := range dates {
+		if date != nil && user.IsLocked(*date) && !api.Permissions.Check(r, auth.Admin) {
+			utils.WriteError(w, http.StatusConflict, "Transaction belongs to closed period.", map[string]interface{}{
+				"lock_date": user.LockDate,
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// writeChanged - transaction was changed since client has read it, client gets ETag of current version
+func (api *TransactionAPI) writeChanged(w http.ResponseWriter, r *http.Request, transactionID models.TransactionID) {
+	transaction, err := api.DB.GetTransactionByID(r.Context(), transactionID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting transaction.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, transaction.Version)
+}
+
+// getTransaction - transaction is found only under path of its owner, so user can't reach transaction of another user
+func (api *TransactionAPI) getTransaction(w http.ResponseWriter, r *http.Request, userID models.UserID, transactionID models.TransactionID) (*models.Transaction, bool) {
+	transaction, err := api.DB.GetTransactionByID(r.Context(), transactionID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting transaction.", http.StatusConflict)
+		return nil, false
+	}
+
+	if transaction.UserID == nil || *transaction.UserID != userID {
+		utils.WriteError(w, http.StatusNotFound, "Transaction not found.", nil)
+		return nil, false
+	}
+
+	return transaction, true
+}
@@ -0,0 +1,71 @@
+package v1
+
+import (
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/jobs"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// TrashAPI - provides REST for soft-deleted resources
+type TrashAPI struct {
+	DB database.Database
+}
+
+func SetTrashAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := TrashAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- TRASH ---------- */
+		NewAPI("/users/{userID}/trash", "GET", api.List, auth.Admin, auth.MemberIsTarget),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// GET - /users/{userID}/trash
+// Permission - Admin, MemberIsTarget
+func (api *TrashAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "trash.go -> List()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	items, err := api.DB.ListTrash(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting trash.", http.StatusConflict)
+		return
+	}
+
+	if items == nil {
+		items = make([]*models.TrashItem, 0)
+	}
+
+	// * Item can be restored until purge job removes it
+	retention := jobs.TrashRetention()
+	for _, item := range items {
+		if item.DeletedAt != nil {
+			purgeAt := item.DeletedAt.Add(retention)
+			item.PurgeAt = &purgeAt
+		}
+	}
+
+	logger.Info("Trash returned")
+	utils.WriteJSON(w, http.StatusOK, items)
+}
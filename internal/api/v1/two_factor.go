@@ -0,0 +1,286 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// TwoFactorEnrollment - secret user adds to authenticator app
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TwoFactorRequest - code from authenticator app or one of recovery codes
+type TwoFactorRequest struct {
+	models.SessionData
+
+	MFAToken     string `json:"mfa_token,omitempty"`
+	Code         string `json:"code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// RecoveryCodesResponse - recovery codes are shown only once after confirmation
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAResponse - returned by login when user has to pass second factor
+type MFAResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+/* ---------- TWO FACTOR ---------- */
+
+// POST - /users/{userID}/2fa
+// Permission - MemberIsTarget
+func (api *UserAPI) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "two_factor.go -> EnrollTwoFactor()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	user, err := api.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	if user.TwoFactorEnabled() {
+		utils.WriteError(w, http.StatusConflict, "2FA already enabled.", nil)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		utils.ResponseErr(err, w, "Error generating secret.", http.StatusInternalServerError)
+		return
+	}
+
+	if err := api.DB.SetTOTPSecret(ctx, userID, secret); err != nil {
+		utils.ResponseErr(err, w, "Error saving secret.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("2FA enrollment started")
+	utils.WriteJSON(w, http.StatusCreated, &TwoFactorEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(secret, *user.Email),
+	})
+}
+
+// POST - /users/{userID}/2fa/confirm
+// Permission - MemberIsTarget
+func (api *UserAPI) ConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "two_factor.go -> ConfirmTwoFactor()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	var request TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := api.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	if user.TwoFactorEnabled() {
+		utils.WriteError(w, http.StatusConflict, "2FA already enabled.", nil)
+		return
+	}
+	if user.TOTPSecret == nil {
+		utils.WriteError(w, http.StatusConflict, "2FA enrollment not started.", nil)
+		return
+	}
+
+	counter, valid := auth.ValidateTOTP(*user.TOTPSecret, request.Code, time.Now())
+	if !valid {
+		utils.WriteError(w, http.StatusBadRequest, "Invalid code.", nil)
+		return
+	}
+
+	if used, err := api.DB.UseTOTPCounter(ctx, userID, counter); err != nil {
+		utils.ResponseErr(err, w, "Error checking code.", http.StatusInternalServerError)
+		return
+	} else if !used {
+		utils.WriteError(w, http.StatusBadRequest, "Code was already used.", nil)
+		return
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		utils.ResponseErr(err, w, "Error generating recovery codes.", http.StatusInternalServerError)
+		return
+	}
+
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.EnableTOTP(ctx, userID, hashes); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditTwoFactor, string(userID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error enabling 2FA.", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("2FA enabled")
+	utils.WriteJSON(w, http.StatusOK, &RecoveryCodesResponse{
+		RecoveryCodes: codes,
+	})
+}
+
+// DELETE - /users/{userID}/2fa
+// Permission - Admin
+func (api *UserAPI) ResetTwoFactor(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "two_factor.go -> ResetTwoFactor()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.ResetTOTP(ctx, userID); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditTwoFactor, string(userID), nil, nil)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error resetting 2FA.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("2FA reset")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: true,
+	})
+}
+
+// POST - /login/2fa
+// Permission - Any (MFA token from login is a credential)
+func (api *UserAPI) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "two_factor.go -> LoginTwoFactor()")
+
+	var request TwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+	if err := request.SessionData.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	cliams, err := auth.VerifyMFAToken(request.MFAToken)
+	if err != nil {
+		utils.ResponseErr(err, w, "Invalid or expired MFA token.", http.StatusUnauthorized)
+		return
+	}
+
+	logger = logger.WithField("user_id", cliams.UserID)
+
+	ctx := r.Context()
+	user, err := api.DB.GetUserByID(ctx, cliams.UserID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusUnauthorized)
+		return
+	}
+
+	if !user.TwoFactorEnabled() {
+		utils.WriteError(w, http.StatusUnauthorized, "2FA is not enabled.", nil)
+		return
+	}
+
+	// * Codes are short, so second step is throttled the same way as password
+	if locked := api.startLoginAttempt(w, r, *user.Email); locked {
+		return
+	}
+
+	switch {
+	case request.Code != "":
+		counter, valid := auth.ValidateTOTP(*user.TOTPSecret, request.Code, time.Now())
+		if !valid {
+			api.loginFailed(r, *user.Email, &user.ID, errors.New("invalid code"))
+			utils.WriteError(w, http.StatusUnauthorized, "Invalid code.", nil)
+			return
+		}
+
+		// * Code seen by somebody else (shoulder surfing, phishing proxy) can't be used again
+		used, err := api.DB.UseTOTPCounter(ctx, user.ID, counter)
+		if err != nil {
+			utils.ResponseErr(err, w, "Error checking code.", http.StatusInternalServerError)
+			return
+		}
+		if !used {
+			api.loginFailed(r, *user.Email, &user.ID, errors.New("code already used"))
+			utils.WriteError(w, http.StatusUnauthorized, "Invalid code.", nil)
+			return
+		}
+	case request.RecoveryCode != "":
+		used, err := api.DB.UseRecoveryCode(ctx, user.ID, auth.HashRecoveryCode(request.RecoveryCode))
+		if err != nil {
+			utils.ResponseErr(err, w, "Error checking recovery code.", http.StatusInternalServerError)
+			return
+		}
+		if !used {
+			api.loginFailed(r, *user.Email, &user.ID, errors.New("invalid recovery code"))
+			utils.WriteError(w, http.StatusUnauthorized, "Invalid code.", nil)
+			return
+		}
+		logger.Info("Recovery code used")
+	default:
+		utils.WriteError(w, http.StatusBadRequest, "Code or recovery code is required.", nil)
+		return
+	}
+
+	// * MFA token is exchanged for tokens only once
+	used, err := api.DB.UseMFAToken(ctx, user.ID, cliams.Id, time.Unix(cliams.ExpiresAt, 0))
+	if err != nil {
+		utils.ResponseErr(err, w, "Error checking MFA token.", http.StatusInternalServerError)
+		return
+	}
+	if !used {
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid or expired MFA token.", nil)
+		return
+	}
+
+	api.loginSucceeded(r, *user.Email)
+
+	logger.Debug("user passed second factor")
+	api.writeTokenResponse(w, r, http.StatusOK, user, &request.SessionData, models.NilFamilyID, true)
+}
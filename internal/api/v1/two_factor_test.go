@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"finance/internal/api/auth"
+	"finance/internal/models"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func (d *fakeLoginDB) UseTOTPCounter(ctx context.Context, userID models.UserID, counter int64) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if last, ok := d.totpCounters[userID]; ok && last >= counter {
+		return false, nil
+	}
+	d.totpCounters[userID] = counter
+	return true, nil
+}
+
+func (d *fakeLoginDB) UseRecoveryCode(ctx context.Context, userID models.UserID, codeHash string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if owner, ok := d.recoveryCodes[codeHash]; !ok || owner != userID {
+		return false, nil
+	}
+	delete(d.recoveryCodes, codeHash)
+	return true, nil
+}
+
+func (d *fakeLoginDB) UseMFAToken(ctx context.Context, userID models.UserID, tokenID string, expiresAt time.Time) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.mfaTokens[tokenID] {
+		return false, nil
+	}
+	d.mfaTokens[tokenID] = true
+	return true, nil
+}
+
+// enableTwoFactor enrolls user to TOTP and gives user one recovery code
+func (d *fakeLoginDB) enableTwoFactor(t *testing.T, userID models.UserID, recoveryCode string) string {
+	t.Helper()
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret: %v", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	d.users[userID].TOTPSecret = &secret
+	d.users[userID].TOTPEnabledAt = &now
+	d.recoveryCodes[auth.HashRecoveryCode(recoveryCode)] = userID
+	return secret
+}
+
+// totpAt is code authenticator app shows for secret at time (RFC 6238, SHA1, 6 digits, 30 seconds)
+func totpAt(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+// loginTwoFactor passes second factor with new MFA token, as if user logged in with password again
+func (lt *loginTest) loginTwoFactor(t *testing.T, userID models.UserID, request TwoFactorRequest) int {
+	t.Helper()
+
+	mfaToken, err := auth.IssueMFAToken(models.Principal{UserID: userID})
+	if err != nil {
+		t.Fatalf("IssueMFAToken: %v", err)
+	}
+	request.MFAToken = mfaToken
+	request.DeviceID = "device-1"
+
+	return lt.post(t, "/login/2fa", request, nil).Code
+}
+
+func TestLoginTwoFactorSingleUse(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		first  func(secret string) TwoFactorRequest
+		second func(secret string) TwoFactorRequest
+		want   int
+	}{
+		{
+			name:   "code replayed",
+			first:  func(secret string) TwoFactorRequest { return TwoFactorRequest{Code: totpAt(t, secret, now)} },
+			second: func(secret string) TwoFactorRequest { return TwoFactorRequest{Code: totpAt(t, secret, now)} },
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:  "code of earlier step after later step was used",
+			first: func(secret string) TwoFactorRequest { return TwoFactorRequest{Code: totpAt(t, secret, now)} },
+			second: func(secret string) TwoFactorRequest {
+				return TwoFactorRequest{Code: totpAt(t, secret, now.Add(-30*time.Second))}
+			},
+			want: http.StatusUnauthorized,
+		},
+		{
+			name:  "code of next step",
+			first: func(secret string) TwoFactorRequest { return TwoFactorRequest{Code: totpAt(t, secret, now)} },
+			second: func(secret string) TwoFactorRequest {
+				return TwoFactorRequest{Code: totpAt(t, secret, now.Add(30*time.Second))}
+			},
+			want: http.StatusOK,
+		},
+		{
+			name:   "recovery code used twice",
+			first:  func(secret string) TwoFactorRequest { return TwoFactorRequest{RecoveryCode: "abcde-fghij"} },
+			second: func(secret string) TwoFactorRequest { return TwoFactorRequest{RecoveryCode: "ABCDEFGHIJ"} },
+			want:   http.StatusUnauthorized,
+		},
+		{
+			name:   "recovery code after code",
+			first:  func(secret string) TwoFactorRequest { return TwoFactorRequest{Code: totpAt(t, secret, now)} },
+			second: func(secret string) TwoFactorRequest { return TwoFactorRequest{RecoveryCode: "abcde-fghij"} },
+			want:   http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lt := newLoginTest(t)
+			user := lt.db.addUser("owner@example.com")
+			secret := lt.db.enableTwoFactor(t, user.ID, "abcde-fghij")
+
+			if status := lt.loginTwoFactor(t, user.ID, tt.first(secret)); status != http.StatusOK {
+				t.Fatalf("first status = %d, want %d", status, http.StatusOK)
+			}
+			if status := lt.loginTwoFactor(t, user.ID, tt.second(secret)); status != tt.want {
+				t.Errorf("second status = %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginTwoFactorTokenIsSingleUse(t *testing.T) {
+	lt := newLoginTest(t)
+	user := lt.db.addUser("owner@example.com")
+	lt.db.enableTwoFactor(t, user.ID, "abcde-fghij")
+	lt.db.recoveryCodes[auth.HashRecoveryCode("klmno-pqrst")] = user.ID
+
+	mfaToken, err := auth.IssueMFAToken(models.Principal{UserID: user.ID})
+	if err != nil {
+		t.Fatalf("IssueMFAToken: %v", err)
+	}
+
+	for i, code := range []string{"abcde-fghij", "klmno-pqrst"} {
+		request := TwoFactorRequest{
+			SessionData:  models.SessionData{DeviceID: "device-1"},
+			MFAToken:     mfaToken,
+			RecoveryCode: code,
+		}
+
+		want := http.StatusOK
+		if i > 0 {
+			want = http.StatusUnauthorized
+		}
+		if w := lt.post(t, "/login/2fa", request, nil); w.Code != want {
+			t.Errorf("attempt %d status = %d, want %d", i+1, w.Code, want)
+		}
+	}
+}
@@ -4,11 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"finance/internal/api/auth"
+	"finance/internal/config"
+	"finance/internal/identity"
+	"finance/internal/mailer"
 	"finance/internal/utils"
+	"fmt"
 
 	"finance/internal/database"
 	"finance/internal/models"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
@@ -16,12 +22,16 @@ import (
 
 // UserAPI - provides REST for Users
 type UserAPI struct {
-	DB database.Database
+	DB        database.Database
+	Mailer    mailer.Mailer
+	Providers map[string]identity.Provider
 }
 
-func SetUserAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+func SetUserAPI(db database.Database, mail mailer.Mailer, providers map[string]identity.Provider, router *mux.Router, permissons auth.Permissions) {
 	api := UserAPI{
-		DB: db,
+		DB:        db,
+		Mailer:    mail,
+		Providers: providers,
 	}
 
 	apis := []API{
@@ -29,14 +39,39 @@ func SetUserAPI(db database.Database, router *mux.Router, permissons auth.Permis
 		NewAPI("/users", "POST", api.Create, auth.Any),
 		NewAPI("/users", "GET", api.List, auth.Admin),
 		NewAPI("/users/{userID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
-		NewAPI("/users/{userID}", "PATCH", api.Update, auth.Admin, auth.MemberIsTarget),
-		NewAPI("/users/{userID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}", "PATCH", auth.DenyAPIKey(api.Update), auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}", "DELETE", auth.DenyAPIKey(api.Delete), auth.Admin, auth.MemberIsTarget),
+
+		/* ---------- USER DATA ---------- */
+		NewAPI("/users/{userID}/export", "GET", api.Export, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/deletion", "GET", api.DeletionStatus, auth.Admin),
+
+		/* ---------- EMAIL VERIFICATION ---------- */
+		NewAPI("/users/{userID}/verify-email", "POST", api.SendVerifyEmail, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/verify-email", "POST", api.VerifyEmail, auth.Any),
+
+		/* ---------- PASSWORD RESET ---------- */
+		NewAPI("/password-reset", "POST", api.RequestPasswordReset, auth.Any),
+		NewAPI("/password-reset/confirm", "POST", api.ConfirmPasswordReset, auth.Any),
+
+		/* ---------- TWO FACTOR ---------- */
+		NewAPI("/users/{userID}/2fa", "POST", auth.DenyAPIKey(api.EnrollTwoFactor), auth.MemberIsTarget),
+		NewAPI("/users/{userID}/2fa/confirm", "POST", auth.DenyAPIKey(api.ConfirmTwoFactor), auth.MemberIsTarget),
+		NewAPI("/users/{userID}/2fa", "DELETE", auth.DenyAPIKey(api.ResetTwoFactor), auth.Admin),
 
 		/* ---------- LOGIN ---------- */
 		NewAPI("/login", "POST", api.Login, auth.Any),
+		NewAPI("/login/2fa", "POST", api.LoginTwoFactor, auth.Any),
+
+		/* ---------- IDENTITY PROVIDERS ---------- */
+		NewAPI("/auth/{provider}/login", "GET", api.ProviderLogin, auth.Any),
+		NewAPI("/auth/{provider}/callback", "GET", api.ProviderCallback, auth.Any),
+		NewAPI("/users/{userID}/identities", "GET", api.ListIdentities, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/identities/{provider}", "POST", auth.DenyAPIKey(api.LinkIdentity), auth.MemberIsTarget),
+		NewAPI("/users/{userID}/identities/{provider}", "DELETE", auth.DenyAPIKey(api.UnlinkIdentity), auth.Admin, auth.MemberIsTarget),
 
 		/* ---------- TOKENS ---------- */
-		NewAPI("/refresh", "POST", api.RefreshToken, auth.Member),
+		NewAPI("/refresh", "POST", api.RefreshToken, auth.Any),
 	}
 
 	for _, api := range apis {
@@ -78,6 +113,10 @@ func (api *UserAPI) Create(w http.ResponseWriter, r *http.Request) {
 		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
 		return
 	}
+	if err := models.VerifyPassword(userParameters.Password); err != nil {
+		utils.ResponseErrWithMap(err, w, "Invalid password.", http.StatusBadRequest)
+		return
+	}
 
 	hashed, err := models.HashPassword(userParameters.Password)
 	if err != nil {
@@ -92,7 +131,19 @@ func (api *UserAPI) Create(w http.ResponseWriter, r *http.Request) {
 
 	ctx := r.Context()
 
-	if err := api.DB.CreateUser(ctx, newUser); err == database.ErrUserExists {
+	var createdUser *models.User
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateUser(ctx, newUser); err != nil {
+			return err
+		}
+
+		var err error
+		if createdUser, err = db.GetUserByID(ctx, newUser.ID); err != nil {
+			return err
+		}
+		return writeAudit(db, r, createdUser.ID, models.AuditCreate, models.AuditUser, string(createdUser.ID), nil, createdUser)
+	})
+	if err == database.ErrUserExists {
 		utils.ResponseErr(err, w, "User already exists.", http.StatusConflict)
 		return
 	} else if err != nil {
@@ -100,10 +151,9 @@ func (api *UserAPI) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	createdUser, err := api.DB.GetUserByID(ctx, newUser.ID)
-	if err != nil {
-		utils.ResponseErr(err, w, "Error creating user.", http.StatusConflict)
-		return
+	// * User is created even if we could not send email, user can ask for it again
+	if err := api.sendUserToken(ctx, createdUser, models.VerifyEmail); err != nil {
+		logger.WithError(err).Warn("Error sending verification email.")
 	}
 
 	logger.WithField("userID", createdUser.ID).Info("User created")
@@ -151,6 +201,7 @@ func (api *UserAPI) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	logger.WithField("userID", userID).Debug("Get user complete")
+	writeETag(w, user.Version)
 	utils.WriteJSON(w, http.StatusOK, user)
 }
 
@@ -181,21 +232,43 @@ func (api *UserAPI) Update(w http.ResponseWriter, r *http.Request) {
 		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
 		return
 	}
+	if !checkIfMatch(w, r, user.Version) {
+		return
+	}
 
 	if len(userRequest.Password) != 0 {
+		if err := models.VerifyPassword(userRequest.Password); err != nil {
+			utils.ResponseErrWithMap(err, w, "Invalid password.", http.StatusBadRequest)
+			return
+		}
 		if err := user.SetPassword(userRequest.Password); err != nil {
 			utils.ResponseErr(err, w, "Error setting password.", http.StatusInternalServerError)
 			return
 		}
 	}
 
-	if err := api.DB.UpdateUser(ctx, user); err != nil {
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+
+		// * Password hash is never stored in audit, we only record that it was changed
+		if len(userRequest.Password) != 0 {
+			return writeAudit(db, r, userID, models.AuditUpdate, models.AuditUser, string(userID), nil, auditPasswordChanged)
+		}
+		return nil
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, userID)
+		return
+	} else if err != nil {
 		logger.WithError(err).Warn("Error updating user.")
 		utils.WriteError(w, http.StatusInternalServerError, "Error updating user.", nil)
 		return
 	}
 
 	logger.Info("User update")
+	writeETag(w, user.Version)
 	utils.WriteJSON(w, http.StatusOK, user)
 }
 
@@ -214,8 +287,43 @@ func (api *UserAPI) Delete(w http.ResponseWriter, r *http.Request) {
 	})
 
 	ctx := r.Context()
-	deleted, err := api.DB.DeleteUser(ctx, userID)
-	if err != nil {
+	before, _ := api.DB.GetUserByID(ctx, userID) // * for audit and If-Match, missing user is handled by delete
+	var version int64
+	if before != nil {
+		if !checkIfMatch(w, r, before.Version) {
+			return
+		}
+		version = before.Version
+	}
+
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteUser(ctx, userID, version); err != nil {
+			return err
+		}
+
+		// * Deletion is requested even if user was deleted already, so repeated request continues deletion which was
+		// * interrupted. User changed by another client is not deleted and deletion is not requested.
+		// * User's data is removed by background job (see jobs.DeleteUsers).
+		if err := db.RequestUserDeletion(ctx, userID, &principal.UserID); err != nil {
+			return err
+		}
+
+		// * Sessions are closed right away, we don't wait for deletion job
+		if err := db.RevokeAllSessions(ctx, userID); err != nil {
+			return err
+		}
+
+		if deleted {
+			return writeAudit(db, r, userID, models.AuditDelete, models.AuditUser, string(userID), before, nil)
+		}
+		return nil
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, userID)
+		return
+	} else if err != nil {
 		utils.ResponseErr(err, w, "Error deleting user.", http.StatusConflict)
 		return
 	}
@@ -226,7 +334,257 @@ func (api *UserAPI) Delete(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// writeChanged - user was changed since client has read it, client gets ETag of current version
+func (api *UserAPI) writeChanged(w http.ResponseWriter, r *http.Request, userID models.UserID) {
+	user, err := api.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, user.Version)
+}
+
+/* ---------- EMAIL VERIFICATION ---------- */
+
+// UserTokenRequest - Data user send to confirm action with token from email
+type UserTokenRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password,omitempty"`
+}
+
+// POST - /users/{userID}/verify-email
+// Permission - Admin, MemberIsTarget
+func (api *UserAPI) SendVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "user.go -> SendVerifyEmail()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	user, err := api.DB.GetUserByID(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		utils.WriteError(w, http.StatusConflict, "Email already verified.", nil)
+		return
+	}
+
+	if err := api.sendUserToken(ctx, user, models.VerifyEmail); err != nil {
+		utils.ResponseErr(err, w, "Error sending email.", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Verification email sent")
+	utils.WriteJSON(w, http.StatusCreated, &ActCreated{
+		Created: true,
+	})
+}
+
+// POST - /verify-email
+// Permission - Any
+func (api *UserAPI) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "user.go -> VerifyEmail()")
+
+	var request UserTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	token, err := api.useUserToken(ctx, request.Token, models.VerifyEmail)
+	if err != nil {
+		utils.ResponseErr(err, w, "Invalid or expired token.", http.StatusBadRequest)
+		return
+	}
+
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.VerifyUserEmail(ctx, token.UserID); err != nil {
+			return err
+		}
+		return writeAudit(db, r, token.UserID, models.AuditUpdate, models.AuditUser, string(token.UserID), nil, auditEmailVerified)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error verifying email.", http.StatusInternalServerError)
+		return
+	}
+
+	logger.WithField("user_id", token.UserID).Info("Email verified")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: true,
+	})
+}
+
+/* ---------- PASSWORD RESET ---------- */
+
+// POST - /password-reset
+// Permission - Any
+func (api *UserAPI) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "user.go -> RequestPasswordReset()")
+
+	var credential models.Credential
+	if err := json.NewDecoder(r.Body).Decode(&credential); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	logger = logger.WithField("email", credential.Email)
+
+	if limited := api.checkPasswordResetLimit(w, r, credential.Email); limited {
+		return
+	}
+
+	// * We always answer the same way and at once, so nobody can find out which emails are registered
+	go api.sendPasswordReset(logger, credential.Email)
+
+	utils.WriteJSON(w, http.StatusOK, &ActCreated{
+		Created: true,
+	})
+}
+
+// POST - /password-reset/confirm
+// Permission - Any
+func (api *UserAPI) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "user.go -> ConfirmPasswordReset()")
+
+	var request UserTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.VerifyPassword(request.Password); err != nil {
+		utils.ResponseErrWithMap(err, w, "Invalid password.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	token, err := api.useUserToken(ctx, request.Token, models.ResetPassword)
+	if err != nil {
+		utils.ResponseErr(err, w, "Invalid or expired token.", http.StatusBadRequest)
+		return
+	}
+
+	logger = logger.WithField("user_id", token.UserID)
+
+	user, err := api.DB.GetUserByID(ctx, token.UserID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
+		return
+	}
+
+	if err := user.SetPassword(request.Password); err != nil {
+		utils.ResponseErr(err, w, "Error setting password.", http.StatusInternalServerError)
+		return
+	}
+
+	// * Somebody could know old password, so all sessions are closed together with password change
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.ResetUserPassword(ctx, user); err != nil {
+			return err
+		}
+		return writeAudit(db, r, user.ID, models.AuditUpdate, models.AuditUser, string(user.ID), nil, auditPasswordChanged)
+	})
+	if err != nil {
+		utils.ResponseErr(err, w, "Error updating user.", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("Password reset")
+	utils.WriteJSON(w, http.StatusOK, &ActUpdated{
+		Updated: true,
+	})
+}
+
+// checkPasswordResetLimit - Count reset request and write error if email or IP asked for reset too many times
+func (api *UserAPI) checkPasswordResetLimit(w http.ResponseWriter, r *http.Request, email string) bool {
+	ctx := r.Context()
+
+	for _, limit := range auth.PasswordResetLimits(email, utils.ClientIP(r)) {
+		requests, err := api.DB.RecordLoginFailure(ctx, limit.Key, auth.LoginFailuresWindow)
+		if err != nil {
+			utils.ResponseErr(err, w, "Error checking password reset requests.", http.StatusInternalServerError)
+			return true
+		}
+
+		if requests > limit.Threshold {
+			w.Header().Set("Retry-After", strconv.Itoa(int(auth.LoginFailuresWindow.Seconds())))
+			utils.WriteError(w, http.StatusTooManyRequests, "Too many password reset requests. Try again later.", nil)
+			return true
+		}
+	}
+	return false
+}
+
+// * Request context is done when response is written, reset email is sent with own timeout
+var passwordResetTimeout = time.Duration(30) * time.Second
+
+// sendPasswordReset - Runs after response is written, so response time doesn't show which emails are registered
+func (api *UserAPI) sendPasswordReset(logger *logrus.Entry, email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), passwordResetTimeout)
+	defer cancel()
+
+	user, err := api.DB.GetUserByEmail(ctx, email)
+	if err != nil {
+		logger.WithError(err).Debug("Password reset for unknown email")
+		return
+	}
+
+	if err := api.sendUserToken(ctx, user, models.ResetPassword); err != nil {
+		logger.WithError(err).Warn("Error sending password reset email.")
+	}
+}
+
+// sendUserToken - Generate single-use token, store it and send link with token to user's email
+func (api *UserAPI) sendUserToken(ctx context.Context, user *models.User, purpose models.UserTokenPurpose) error {
+	token, userToken, err := auth.IssueUserToken(user.ID, purpose)
+	if err != nil {
+		return err
+	}
+
+	if err := api.DB.CreateUserToken(ctx, userToken); err != nil {
+		return err
+	}
+
+	message := mailer.Message{
+		To: *user.Email,
+	}
+
+	switch purpose {
+	case models.VerifyEmail:
+		message.Subject = "Verify your email"
+		message.Body = fmt.Sprintf("Open link to verify your email: %s/verify-email?token=%s", *config.AppURL, token)
+	case models.ResetPassword:
+		message.Subject = "Reset your password"
+		message.Body = fmt.Sprintf("Open link to reset your password: %s/password-reset?token=%s\nIf you didn't ask for it, ignore this email.", *config.AppURL, token)
+	}
+
+	return api.Mailer.Send(ctx, message)
+}
+
+// useUserToken - Verify signature of token and mark it as used
+func (api *UserAPI) useUserToken(ctx context.Context, token string, purpose models.UserTokenPurpose) (*models.UserToken, error) {
+	tokenHash, err := auth.VerifyUserToken(token, purpose)
+	if err != nil {
+		return nil, err
+	}
+	return api.DB.UseUserToken(ctx, tokenHash, purpose)
+}
+
 /* ---------- LOGIN ---------- */
+
+// POST - /login
+// Permission - Any
 func (api *UserAPI) Login(w http.ResponseWriter, r *http.Request) {
 	logger := logrus.WithField("func", "users.go -> Login()")
 
@@ -240,25 +598,123 @@ func (api *UserAPI) Login(w http.ResponseWriter, r *http.Request) {
 		"email": credential.Email,
 	})
 
+	if err := credential.SessionData.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
 	ctx := r.Context()
-	user, err := api.DB.GetUserByEmail(ctx, credential.Email)
-	if err != nil {
-		utils.ResponseErr(err, w, "Invalid email or password.", http.StatusConflict)
+	if locked := api.startLoginAttempt(w, r, credential.Email); locked {
 		return
 	}
-	if err := credential.SessionData.Verify(); err != nil {
-		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+
+	// * Unknown email and wrong password get the same response, so nobody can find out which emails are registered
+	user, err := api.DB.GetUserByEmail(ctx, credential.Email)
+	if err != nil {
+		api.loginFailed(r, credential.Email, nil, models.CheckDummyPassword(credential.Password))
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid email or password.", nil)
 		return
 	}
 
 	// Checking if password is correct
 	if err := user.CheckPassword(credential.Password); err != nil {
-		utils.ResponseErr(err, w, "Invalid email or password.", http.StatusUnauthorized)
+		api.loginFailed(r, credential.Email, &user.ID, err)
+		utils.WriteError(w, http.StatusUnauthorized, "Invalid email or password.", nil)
+		return
+	}
+
+	logger.WithField("userID", user.ID).Debug("user passed password check")
+	api.completeLogin(w, r, user, &credential.SessionData)
+}
+
+// completeLogin - Issue tokens for user who passed first factor. If 2FA is enabled tokens are issued only after second factor.
+func (api *UserAPI) completeLogin(w http.ResponseWriter, r *http.Request, user *models.User, sessionData *models.SessionData) {
+	if user.TwoFactorEnabled() {
+		mfaToken, err := auth.IssueMFAToken(models.Principal{UserID: user.ID})
+		if err != nil {
+			utils.ResponseErr(err, w, "Error issuing token.", http.StatusUnauthorized)
+			return
+		}
+
+		utils.WriteJSON(w, http.StatusOK, &MFAResponse{
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
 		return
 	}
 
-	logger.WithField("userID", user.ID).Debug("user logged in")
-	api.writeTokenResponse(ctx, w, http.StatusOK, user, &credential.SessionData, true)
+	api.loginSucceeded(r, *user.Email)
+	api.writeTokenResponse(w, r, http.StatusOK, user, sessionData, models.NilFamilyID, true)
+}
+
+// startLoginAttempt - Count attempt as failed before credentials are checked, so parallel requests can't get more attempts
+// than limit allows. Write error and return true if email or IP is locked after too many failed logins.
+func (api *UserAPI) startLoginAttempt(w http.ResponseWriter, r *http.Request, email string) bool {
+	ctx := r.Context()
+	logger := logrus.WithFields(logrus.Fields{
+		"func":  "users.go -> startLoginAttempt()",
+		"email": email,
+		"ip":    utils.ClientIP(r),
+	})
+
+	for _, limit := range auth.LoginLimits(email, utils.ClientIP(r)) {
+		lockedUntil, err := api.DB.RecordLoginAttempt(ctx, limit.Key, auth.LoginFailuresWindow, limit.Backoff)
+		if err != nil {
+			utils.ResponseErr(err, w, "Error checking login attempts.", http.StatusInternalServerError)
+			return true
+		}
+		if lockedUntil == nil {
+			continue
+		}
+
+		api.createAuthEvent(r, models.LoginLocked, email, nil)
+		logger.WithField("key", limit.Key).Warn("Login locked")
+
+		retryAfter := int(time.Until(*lockedUntil).Seconds()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		utils.WriteError(w, http.StatusTooManyRequests, "Too many login attempts. Try again later.", nil)
+		return true
+	}
+
+	return false
+}
+
+// loginFailed - Failure was already counted by startLoginAttempt, only event is recorded
+func (api *UserAPI) loginFailed(r *http.Request, email string, userID *models.UserID, reason error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"func":  "users.go -> loginFailed()",
+		"email": email,
+		"ip":    utils.ClientIP(r),
+	})
+
+	api.createAuthEvent(r, models.LoginFailed, email, userID)
+	logger.WithError(reason).Info("Login failed")
+}
+
+// loginSucceeded - Reset failed login counter of email. IP counter is not reset, so attacker can't reset it with own account,
+// only this attempt is taken back.
+func (api *UserAPI) loginSucceeded(r *http.Request, email string) {
+	limits := auth.LoginLimits(email, utils.ClientIP(r))
+	if err := api.DB.ResetLoginFailures(r.Context(), limits[0].Key); err != nil {
+		logrus.WithError(err).Warn("Error resetting login failures.")
+	}
+	if err := api.DB.UndoLoginFailure(r.Context(), limits[1].Key); err != nil {
+		logrus.WithError(err).Warn("Error undoing login failure.")
+	}
+}
+
+func (api *UserAPI) createAuthEvent(r *http.Request, eventType models.AuthEventType, email string, userID *models.UserID) {
+	event := models.AuthEvent{
+		Type:      eventType,
+		UserID:    userID,
+		Email:     email,
+		IPAddress: utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+	}
+
+	if err := api.DB.CreateAuthEvent(r.Context(), &event); err != nil {
+		logrus.WithError(err).Warn("Error creating auth event.")
+	}
 }
 
 /* ---------- TOKEN ---------- */
@@ -268,6 +724,8 @@ type RefreshTokenRequest struct {
 	DeviceID     models.DeviceID `json:"device_id"`
 }
 
+// POST - /refresh
+// Permission - Any (refresh token itself is a credential)
 func (api *UserAPI) RefreshToken(w http.ResponseWriter, r *http.Request) {
 	logger := logrus.WithField("func", "users.go -> RefreshToken()")
 
@@ -282,37 +740,52 @@ func (api *UserAPI) RefreshToken(w http.ResponseWriter, r *http.Request) {
 		"device_id": request.DeviceID,
 	})
 
-	principal, err := auth.VerifyToken(request.RefreshToken)
+	// * Refresh token is opaque, we find it by hash
+	ctx := r.Context()
+	tokenHash := auth.HashToken(request.RefreshToken)
+	token, err := api.DB.GetRefreshToken(ctx, tokenHash)
 	if err != nil {
-		utils.ResponseErr(err, w, "Error verifing refresh token.", http.StatusUnauthorized)
+		utils.ResponseErr(err, w, "Error session not exists.", http.StatusUnauthorized)
 		return
 	}
 
-	// * If token is valid we need to check if combination UserID - DeviceID - Refresh token exists in database
-	data := models.Session{
-		UserID:       principal.UserID,
-		DeviceID:     request.DeviceID,
-		RefreshToken: request.RefreshToken,
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   token.UserID,
+		"family_id": token.FamilyID,
+	})
+
+	if token.RevokedAt != nil || token.DeviceID != request.DeviceID || token.IsExpired() {
+		utils.WriteError(w, http.StatusUnauthorized, "Error session not exists.", nil)
+		return
 	}
 
-	ctx := r.Context()
-	session, err := api.DB.GetSession(ctx, data)
-	if err != nil || session == nil {
-		utils.ResponseErr(err, w, "Error session not exists.", http.StatusUnauthorized)
+	// * Token was already rotated. Somebody replays old token, so we revoke whole family.
+	// * Second condition catches two requests racing with the same token.
+	used, err := api.DB.UseRefreshToken(ctx, tokenHash)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error verifing refresh token.", http.StatusInternalServerError)
+		return
+	}
+	if token.UsedAt != nil || !used {
+		logger.Warn("Refresh token reuse detected, revoking token family")
+		if err := api.DB.RevokeTokenFamily(ctx, token.FamilyID); err != nil {
+			logger.WithError(err).Error("Error revoking token family.")
+		}
+		utils.WriteError(w, http.StatusUnauthorized, "Refresh token already used.", nil)
 		return
 	}
 
 	// if session exists and valid we generate new access and refresh tokens.
-	logger.WithField("user_id", principal.UserID).Debug("Refresh token")
+	logger.Debug("Refresh token")
 
 	// Check if user exists
-	user, err := api.DB.GetUserByID(ctx, principal.UserID)
+	user, err := api.DB.GetUserByID(ctx, token.UserID)
 	if err != nil {
 		utils.ResponseErr(err, w, "Error getting user.", http.StatusConflict)
 		return
 	}
 
-	api.writeTokenResponse(ctx, w, http.StatusOK, user, &models.SessionData{DeviceID: request.DeviceID}, true)
+	api.writeTokenResponse(w, r, http.StatusOK, user, &models.SessionData{DeviceID: request.DeviceID}, token.FamilyID, true)
 }
 
 type TokenResponse struct {
@@ -320,24 +793,37 @@ type TokenResponse struct {
 	User   *models.User `json:"user,omitempty"`
 }
 
-// writeTokenResponse - Generate Access and refresh token are return them to  user. Refresh token is stored in database as session
-func (api *UserAPI) writeTokenResponse(ctx context.Context, w http.ResponseWriter, status int, user *models.User, sessionData *models.SessionData, cookie bool) {
+// writeTokenResponse - Generate Access and refresh token are return them to  user. Refresh token hash is stored in database as session.
+// Empty familyID starts new token family (login), otherwise token is rotated inside of family (refresh).
+func (api *UserAPI) writeTokenResponse(w http.ResponseWriter, r *http.Request, status int, user *models.User, sessionData *models.SessionData, familyID models.FamilyID, cookie bool) {
+	ctx := r.Context()
+
 	// Issue token:
 	// TODO: add user role to Principal
-	tokens, err := auth.IssueToken(models.Principal{UserID: user.ID})
+	if familyID == models.NilFamilyID {
+		var err error
+		if familyID, err = auth.NewFamilyID(); err != nil {
+			utils.ResponseErr(err, w, "Error issuing token.", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	tokens, err := auth.IssueToken(models.Principal{UserID: user.ID}, user.TokenVersion, familyID)
 	if err != nil || tokens == nil {
 		utils.ResponseErr(err, w, "Error issuing token.", http.StatusUnauthorized)
 		return
 	}
 
 	session := models.Session{
-		UserID:       user.ID,
-		DeviceID:     sessionData.DeviceID,
-		RefreshToken: tokens.RefreshToken,
-		ExpiresAt:    tokens.RefreshTokenExpiresAt,
+		UserID:    user.ID,
+		DeviceID:  sessionData.DeviceID,
+		FamilyID:  familyID,
+		ExpiresAt: tokens.RefreshTokenExpiresAt,
+		IPAddress: utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
 	}
 
-	if err := api.DB.SaveRefreshToken(ctx, session); err != nil {
+	if err := api.DB.SaveRefreshToken(ctx, session, auth.HashToken(tokens.RefreshToken)); err != nil {
 		utils.ResponseErr(err, w, "Error issuing token.", http.StatusUnauthorized)
 		return
 	}
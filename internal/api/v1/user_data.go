@@ -0,0 +1,129 @@
+package v1
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+/* ---------- USER DATA ---------- */
+
+// GET - /users/{userID}/export
+// Permission - Admin, MemberIsTarget
+func (api *UserAPI) Export(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "user_data.go -> Export()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	export, err := api.DB.ExportUserData(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error exporting user's data.", http.StatusConflict)
+		return
+	}
+
+	archive, err := exportArchive(export)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error exporting user's data.", http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("User's data exported")
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"export-%s.zip\"", userID))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(archive); err != nil {
+		logger.WithError(err).Warn("Error writing response.")
+	}
+}
+
+// GET - /users/{userID}/deletion
+// Permission - Admin
+func (api *UserAPI) DeletionStatus(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "user_data.go -> DeletionStatus()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	deletion, err := api.DB.GetUserDeletion(ctx, userID)
+	if err != nil {
+		utils.ResponseErr(err, w, "User deletion not found.", http.StatusNotFound)
+		return
+	}
+
+	logger.Info("User deletion returned")
+	utils.WriteJSON(w, http.StatusOK, deletion)
+}
+
+// exportArchive - ZIP archive with one JSON file per kind of data
+func exportArchive(export *models.UserExport) ([]byte, error) {
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"user.json", export.User},
+		{"roles.json", export.Roles},
+		{"sessions.json", export.Sessions},
+		{"api_keys.json", export.APIKeys},
+		{"identities.json", export.Identities},
+		{"accounts.json", export.Accounts},
+		{"categories.json", export.Categories},
+		{"merchants.json", export.Merchants},
+		{"transactions.json", export.Transactions},
+		{"transaction_versions.json", export.TransactionVersions},
+		{"reconciliations.json", export.Reconciliations},
+		{"debts.json", export.Debts},
+		{"trades.json", export.Trades},
+		{"goals.json", export.Goals},
+		{"goal_contributions.json", export.GoalContributions},
+		{"bills.json", export.Bills},
+		{"bill_payments.json", export.BillPayments},
+		{"notifications.json", export.Notifications},
+		{"notification_preferences.json", export.NotificationPreferences},
+		{"webhooks.json", export.Webhooks},
+		{"auth_events.json", export.AuthEvents},
+		{"audit_events.json", export.AuditEvents},
+	}
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for _, file := range files {
+		writer, err := archive.Create(file.name)
+		if err != nil {
+			return nil, err
+		}
+
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,434 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// fakeLoginDB keeps users and refresh tokens in memory.
+// Methods which are not needed by login handlers are not implemented and panic.
+type fakeLoginDB struct {
+	database.Database
+
+	mu            sync.Mutex
+	users         map[models.UserID]*models.User
+	tokens        map[string]*models.RefreshToken // token hash
+	totpCounters  map[models.UserID]int64         // last accepted time step
+	recoveryCodes map[string]models.UserID        // code hash
+	mfaTokens     map[string]bool                 // exchanged token IDs
+	throttles     map[string]*fakeThrottle        // throttle key
+}
+
+// fakeThrottle is row of login_throttles
+type fakeThrottle struct {
+	failures      int
+	lastFailureAt time.Time
+	lockedUntil   *time.Time
+}
+
+func newFakeLoginDB() *fakeLoginDB {
+	return &fakeLoginDB{
+		users:         make(map[models.UserID]*models.User),
+		tokens:        make(map[string]*models.RefreshToken),
+		totpCounters:  make(map[models.UserID]int64),
+		recoveryCodes: make(map[string]models.UserID),
+		mfaTokens:     make(map[string]bool),
+		throttles:     make(map[string]*fakeThrottle),
+	}
+}
+
+func (d *fakeLoginDB) addUser(email string) *models.User {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	user := &models.User{
+		ID:    models.UserID(fmt.Sprintf("user-%d", len(d.users)+1)),
+		Email: &email,
+	}
+	if err := user.SetPassword("password-1"); err != nil {
+		panic(err)
+	}
+	d.users[user.ID] = user
+	return user
+}
+
+// familyRevoked checks that every token of family is revoked
+func (d *fakeLoginDB) familyRevoked(familyID models.FamilyID) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, token := range d.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *fakeLoginDB) GetUserByID(ctx context.Context, userID models.UserID) (*models.User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if user, ok := d.users[userID]; ok {
+		copied := *user
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeLoginDB) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, user := range d.users {
+		if *user.Email == email {
+			copied := *user
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeLoginDB) SaveRefreshToken(ctx context.Context, session models.Session, tokenHash string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for _, token := range d.tokens {
+		if token.UserID == session.UserID && token.DeviceID == session.DeviceID && token.FamilyID != session.FamilyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	d.tokens[tokenHash] = &models.RefreshToken{
+		TokenHash: tokenHash,
+		FamilyID:  session.FamilyID,
+		UserID:    session.UserID,
+		DeviceID:  session.DeviceID,
+		CreatedAt: &now,
+		ExpiresAt: session.ExpiresAt,
+	}
+	return nil
+}
+
+func (d *fakeLoginDB) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if token, ok := d.tokens[tokenHash]; ok {
+		copied := *token
+		return &copied, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (d *fakeLoginDB) UseRefreshToken(ctx context.Context, tokenHash string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	token, ok := d.tokens[tokenHash]
+	if !ok || token.UsedAt != nil || token.RevokedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.UsedAt = &now
+	return true, nil
+}
+
+func (d *fakeLoginDB) RevokeTokenFamily(ctx context.Context, familyID models.FamilyID) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for _, token := range d.tokens {
+		if token.FamilyID == familyID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// failures returns failures counted for throttle key
+func (d *fakeLoginDB) failures(key string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if throttle, ok := d.throttles[key]; ok {
+		return throttle.failures
+	}
+	return 0
+}
+
+// ageThrottles moves failures and locks of all keys to the past
+func (d *fakeLoginDB) ageThrottles(age time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, throttle := range d.throttles {
+		throttle.lastFailureAt = throttle.lastFailureAt.Add(-age)
+		if throttle.lockedUntil != nil {
+			lockedUntil := throttle.lockedUntil.Add(-age)
+			throttle.lockedUntil = &lockedUntil
+		}
+	}
+}
+
+func (d *fakeLoginDB) RecordLoginAttempt(ctx context.Context, key string, window time.Duration, backoff func(failures int) time.Duration) (*time.Time, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	throttle, ok := d.throttles[key]
+	if !ok {
+		throttle = &fakeThrottle{lastFailureAt: now}
+		d.throttles[key] = throttle
+	}
+	if throttle.lockedUntil != nil && throttle.lockedUntil.After(now) {
+		return throttle.lockedUntil, nil
+	}
+
+	throttle.failures++
+	if throttle.lastFailureAt.Before(now.Add(-window)) {
+		throttle.failures = 1
+	}
+	throttle.lastFailureAt = now
+	if delay := backoff(throttle.failures); delay > 0 {
+		lockedUntil := now.Add(delay)
+		throttle.lockedUntil = &lockedUntil
+	}
+	return nil, nil
+}
+
+func (d *fakeLoginDB) ResetLoginFailures(ctx context.Context, key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.throttles, key)
+	return nil
+}
+
+func (d *fakeLoginDB) UndoLoginFailure(ctx context.Context, key string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if throttle, ok := d.throttles[key]; ok && throttle.failures > 0 {
+		throttle.failures--
+	}
+	return nil
+}
+
+func (d *fakeLoginDB) CreateAuthEvent(ctx context.Context, event *models.AuthEvent) error {
+	return nil
+}
+
+// loginTest is user API with fake database
+type loginTest struct {
+	db     *fakeLoginDB
+	router *mux.Router
+}
+
+func newLoginTest(t *testing.T) *loginTest {
+	t.Helper()
+
+	db := newFakeLoginDB()
+	api := UserAPI{DB: db}
+
+	// * Permissions are checked by middleware, handlers are called directly
+	router := mux.NewRouter()
+	router.HandleFunc("/login", api.Login).Methods("POST")
+	router.HandleFunc("/login/2fa", api.LoginTwoFactor).Methods("POST")
+	router.HandleFunc("/refresh", api.RefreshToken).Methods("POST")
+
+	return &loginTest{db: db, router: router}
+}
+
+func (lt *loginTest) post(t *testing.T, target string, body interface{}, dest interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("encode body: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	lt.router.ServeHTTP(w, httptest.NewRequest("POST", target, bytes.NewReader(payload)))
+	if dest != nil && w.Code < http.StatusBadRequest {
+		if err := json.Unmarshal(w.Body.Bytes(), dest); err != nil {
+			t.Fatalf("POST %s: decode %q: %v", target, w.Body.String(), err)
+		}
+	}
+	return w
+}
+
+// attempt logs user in with password and returns response
+func (lt *loginTest) attempt(t *testing.T, email, password string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	credential := models.Credential{
+		SessionData: models.SessionData{DeviceID: "device-1"},
+		Email:       email,
+		Password:    password,
+	}
+	return lt.post(t, "/login", credential, nil)
+}
+
+// login logs user in from device and returns refresh token of new session
+func (lt *loginTest) login(t *testing.T, email string, deviceID models.DeviceID) string {
+	t.Helper()
+
+	credential := models.Credential{
+		SessionData: models.SessionData{DeviceID: deviceID},
+		Email:       email,
+		Password:    "password-1",
+	}
+
+	var response TokenResponse
+	if w := lt.post(t, "/login", credential, &response); w.Code != http.StatusOK {
+		t.Fatalf("login status = %d, want %d", w.Code, http.StatusOK)
+	}
+	return response.Tokens.RefreshToken
+}
+
+func (lt *loginTest) refresh(t *testing.T, refreshToken string, deviceID models.DeviceID) (int, string) {
+	t.Helper()
+
+	var response TokenResponse
+	w := lt.post(t, "/refresh", RefreshTokenRequest{RefreshToken: refreshToken, DeviceID: deviceID}, &response)
+	if w.Code != http.StatusOK {
+		return w.Code, ""
+	}
+	return w.Code, response.Tokens.RefreshToken
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	tests := []struct {
+		name        string
+		reuse       bool // send token which was already rotated
+		deviceID    models.DeviceID
+		wantStatus  int
+		wantRevoked bool
+	}{
+		{name: "rotated token", deviceID: "device-1", wantStatus: http.StatusOK},
+		{name: "reused token", reuse: true, deviceID: "device-1", wantStatus: http.StatusUnauthorized, wantRevoked: true},
+		{name: "token of other device", deviceID: "device-2", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lt := newLoginTest(t)
+			user := lt.db.addUser("owner@example.com")
+
+			first := lt.login(t, *user.Email, "device-1")
+			status, second := lt.refresh(t, first, "device-1")
+			if status != http.StatusOK {
+				t.Fatalf("first refresh status = %d, want %d", status, http.StatusOK)
+			}
+
+			token := second
+			if tt.reuse {
+				token = first
+			}
+			if status, _ := lt.refresh(t, token, tt.deviceID); status != tt.wantStatus {
+				t.Errorf("refresh status = %d, want %d", status, tt.wantStatus)
+			}
+
+			stored, _ := lt.db.GetRefreshToken(context.Background(), auth.HashToken(second))
+			if revoked := lt.db.familyRevoked(stored.FamilyID); revoked != tt.wantRevoked {
+				t.Errorf("family revoked = %v, want %v", revoked, tt.wantRevoked)
+			}
+		})
+	}
+}
+
+func TestRefreshTokenReuseRevokesRotatedToken(t *testing.T) {
+	lt := newLoginTest(t)
+	user := lt.db.addUser("owner@example.com")
+
+	first := lt.login(t, *user.Email, "device-1")
+	_, second := lt.refresh(t, first, "device-1")
+
+	// * Attacker replays stolen token after owner rotated it, owner's token dies with family
+	if status, _ := lt.refresh(t, first, "device-1"); status != http.StatusUnauthorized {
+		t.Fatalf("replay status = %d, want %d", status, http.StatusUnauthorized)
+	}
+	if status, _ := lt.refresh(t, second, "device-1"); status != http.StatusUnauthorized {
+		t.Errorf("rotated token status = %d, want %d", status, http.StatusUnauthorized)
+	}
+
+	// * New login starts new family
+	third := lt.login(t, *user.Email, "device-1")
+	if status, _ := lt.refresh(t, third, "device-1"); status != http.StatusOK {
+		t.Errorf("new session status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestLoginThrottle(t *testing.T) {
+	tests := []struct {
+		name string
+		// * Every char is one login attempt: f - wrong password, s - right password,
+		// w - wait until lockout ends, a - wait longer than failures window
+		attempts   string
+		wantStatus int
+	}{
+		{name: "below threshold", attempts: "ffff", wantStatus: http.StatusOK},
+		{name: "locked after threshold", attempts: "fffff", wantStatus: http.StatusTooManyRequests},
+		{name: "success resets counter", attempts: "ffffsffff", wantStatus: http.StatusOK},
+		{name: "failures expire after window", attempts: "ffffaffff", wantStatus: http.StatusOK},
+		{name: "lockout ends", attempts: "fffffw", wantStatus: http.StatusOK},
+		{name: "failure after lockout locks again", attempts: "fffffwf", wantStatus: http.StatusTooManyRequests},
+		{name: "lockout and failures expire after window", attempts: "fffffaf", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lt := newLoginTest(t)
+			user := lt.db.addUser("owner@example.com")
+
+			for i, attempt := range tt.attempts {
+				switch attempt {
+				case 'w':
+					lt.db.ageThrottles(time.Minute)
+				case 'a':
+					lt.db.ageThrottles(2 * time.Hour)
+				case 'f', 's':
+					password := "wrong-password"
+					if attempt == 's' {
+						password = "password-1"
+					}
+					if w := lt.attempt(t, *user.Email, password); w.Code == http.StatusTooManyRequests {
+						t.Fatalf("attempt %d was locked", i+1)
+					}
+				}
+			}
+
+			w := lt.attempt(t, *user.Email, "password-1")
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+				t.Error("locked response has no Retry-After header")
+			}
+		})
+	}
+}
+
+func TestLoginSuccessKeepsIPFailures(t *testing.T) {
+	lt := newLoginTest(t)
+	user := lt.db.addUser("owner@example.com")
+
+	for i := 0; i < 3; i++ {
+		lt.attempt(t, *user.Email, "wrong-password")
+	}
+	if w := lt.attempt(t, *user.Email, "password-1"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	// * Attacker can't reset IP counter by logging in to own account, only successful attempt is taken back
+	limits := auth.LoginLimits(*user.Email, "192.0.2.1")
+	if failures := lt.db.failures(limits[0].Key); failures != 0 {
+		t.Errorf("email failures = %d, want 0", failures)
+	}
+	if failures := lt.db.failures(limits[1].Key); failures != 3 {
+		t.Errorf("ip failures = %d, want 3", failures)
+	}
+}
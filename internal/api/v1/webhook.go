@@ -0,0 +1,378 @@
+package v1
+
+import (
+	"encoding/json"
+	"finance/internal/api/auth"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/utils"
+	"finance/internal/webhooks"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// WebhookAPI - provides REST for webhooks. Webhooks under /users/{userID} receive events of user,
+// webhooks under /webhooks are managed by admin and receive events of all users.
+type WebhookAPI struct {
+	DB database.Database
+}
+
+func SetWebhookAPI(db database.Database, router *mux.Router, permissons auth.Permissions) {
+	api := WebhookAPI{
+		DB: db,
+	}
+
+	apis := []API{
+		/* ---------- USER WEBHOOKS ---------- */
+		NewAPI("/users/{userID}/webhooks", "POST", api.Create, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/webhooks", "GET", api.List, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/webhooks/{webhookID}", "GET", api.Get, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/webhooks/{webhookID}", "PATCH", api.Update, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/webhooks/{webhookID}", "DELETE", api.Delete, auth.Admin, auth.MemberIsTarget),
+		NewAPI("/users/{userID}/webhooks/{webhookID}/deliveries", "GET", api.Deliveries, auth.Admin, auth.MemberIsTarget),
+
+		/* ---------- GLOBAL WEBHOOKS ---------- */
+		NewAPI("/webhooks", "POST", api.Create, auth.Admin),
+		NewAPI("/webhooks", "GET", api.List, auth.Admin),
+		NewAPI("/webhooks/{webhookID}", "GET", api.Get, auth.Admin),
+		NewAPI("/webhooks/{webhookID}", "PATCH", api.Update, auth.Admin),
+		NewAPI("/webhooks/{webhookID}", "DELETE", api.Delete, auth.Admin),
+		NewAPI("/webhooks/{webhookID}/deliveries", "GET", api.Deliveries, auth.Admin),
+	}
+
+	for _, api := range apis {
+		router.HandleFunc(api.Path, permissons.Wrap(api.Func, api.Permissions...)).Methods(api.Method)
+	}
+}
+
+// CreatedWebhook - secret is returned only once, when webhook is created
+type CreatedWebhook struct {
+	*models.Webhook
+
+	Secret string `json:"secret"`
+}
+
+// POST - /users/{userID}/webhooks, /webhooks
+// Permission - MemberIsTarget, Admin
+func (api *WebhookAPI) Create(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "webhook.go -> Create()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	// Decode parameters
+	var webhook models.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhook); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	webhook.UserID = webhookOwner(userID)
+	if webhook.EventTypes == nil {
+		webhook.EventTypes = make([]string, 0)
+	}
+	if webhook.Active == nil {
+		active := true
+		webhook.Active = &active
+	}
+
+	if err := webhook.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	secret, err := webhooks.GenerateSecret()
+	if err != nil {
+		utils.ResponseErr(err, w, "Error generating webhook secret.", http.StatusInternalServerError)
+		return
+	}
+	webhook.Secret = secret
+
+	ctx := r.Context()
+	err = api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.CreateWebhook(ctx, &webhook); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditCreate, models.AuditWebhook, string(webhook.ID), nil, &webhook)
+	})
+	if err != nil {
+		logger.WithError(err).Warn("Error creating webhook.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error creating webhook.", nil)
+		return
+	}
+
+	logger.WithField("webhookID", webhook.ID).Info("Webhook created")
+	utils.WriteJSON(w, http.StatusCreated, &CreatedWebhook{
+		Webhook: &webhook,
+		Secret:  secret,
+	})
+}
+
+// PATCH - /users/{userID}/webhooks/{webhookID}, /webhooks/{webhookID}
+// Permission - MemberIsTarget, Admin
+func (api *WebhookAPI) Update(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "webhook.go -> Update()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	webhookID := models.WebhookID(vars["webhookID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"webhook_id": webhookID,
+	})
+
+	// Decode parameters
+	var webhookRequest models.Webhook
+	if err := json.NewDecoder(r.Body).Decode(&webhookRequest); err != nil {
+		utils.ResponseErrWithMap(err, w, "Could not decode parametrs.", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	webhook, ok := api.getWebhook(w, r, userID, webhookID)
+	if !ok {
+		return
+	}
+	before := *webhook
+
+	if !checkIfMatch(w, r, webhook.Version) {
+		return
+	}
+
+	if webhookRequest.URL != nil && len(*webhookRequest.URL) != 0 {
+		webhook.URL = webhookRequest.URL
+	}
+	if webhookRequest.EventTypes != nil {
+		webhook.EventTypes = webhookRequest.EventTypes
+	}
+	if webhookRequest.Active != nil {
+		webhook.Active = webhookRequest.Active
+	}
+
+	if err := webhook.Verify(); err != nil {
+		utils.ResponseErrWithMap(err, w, "Not all fields found.", http.StatusBadRequest)
+		return
+	}
+
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		if err := db.UpdateWebhook(ctx, webhook); err != nil {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditUpdate, models.AuditWebhook, string(webhookID), &before, webhook)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, webhookID)
+		return
+	} else if err != nil {
+		logger.WithError(err).Warn("Error updating webhook.")
+		utils.WriteError(w, http.StatusInternalServerError, "Error updating webhook.", nil)
+		return
+	}
+
+	logger.Info("Webhook update")
+	writeETag(w, webhook.Version)
+	utils.WriteJSON(w, http.StatusOK, webhook)
+}
+
+// GET - /users/{userID}/webhooks, /webhooks
+// Permission - MemberIsTarget, Admin
+func (api *WebhookAPI) List(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "webhook.go -> List()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":   userID,
+		"principal": principal,
+	})
+
+	ctx := r.Context()
+	var list []*models.Webhook
+	var err error
+	if userID == models.NilUserID {
+		list, err = api.DB.ListGlobalWebhooks(ctx)
+	} else {
+		list, err = api.DB.ListWebhooksByUserID(ctx, userID)
+	}
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting webhooks.", http.StatusConflict)
+		return
+	}
+
+	if list == nil {
+		list = make([]*models.Webhook, 0)
+	}
+
+	logger.Info("Webhooks returned")
+	utils.WriteJSON(w, http.StatusOK, list)
+}
+
+// GET - /users/{userID}/webhooks/{webhookID}, /webhooks/{webhookID}
+// Permission - MemberIsTarget, Admin
+func (api *WebhookAPI) Get(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "webhook.go -> Get()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	webhookID := models.WebhookID(vars["webhookID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"webhook_id": webhookID,
+	})
+
+	webhook, ok := api.getWebhook(w, r, userID, webhookID)
+	if !ok {
+		return
+	}
+
+	logger.Info("Webhook returned")
+	writeETag(w, webhook.Version)
+	utils.WriteJSON(w, http.StatusOK, webhook)
+}
+
+// DELETE - /users/{userID}/webhooks/{webhookID}, /webhooks/{webhookID}
+// Permission - MemberIsTarget, Admin
+func (api *WebhookAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "webhook.go -> Delete()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	webhookID := models.WebhookID(vars["webhookID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"webhook_id": webhookID,
+	})
+
+	before, ok := api.getWebhook(w, r, userID, webhookID)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, before.Version) {
+		return
+	}
+
+	ctx := r.Context()
+	var deleted bool
+	err := api.DB.WithTx(ctx, func(db database.Database) error {
+		var err error
+		if deleted, err = db.DeleteWebhook(ctx, webhookID, before.Version); err != nil || !deleted {
+			return err
+		}
+		return writeAudit(db, r, userID, models.AuditDelete, models.AuditWebhook, string(webhookID), before, nil)
+	})
+	if err == database.ErrVersionConflict {
+		api.writeChanged(w, r, webhookID)
+		return
+	} else if err != nil {
+		utils.ResponseErr(err, w, "Error deleting webhook.", http.StatusConflict)
+		return
+	}
+
+	logger.Info("Webhook deleted")
+	utils.WriteJSON(w, http.StatusOK, &ActDeleted{
+		Deleted: deleted,
+	})
+}
+
+// GET - /users/{userID}/webhooks/{webhookID}/deliveries?limit={limit}, /webhooks/{webhookID}/deliveries?limit={limit}
+// Permission - MemberIsTarget, Admin
+func (api *WebhookAPI) Deliveries(w http.ResponseWriter, r *http.Request) {
+	logger := logrus.WithField("func", "webhook.go -> Deliveries()")
+
+	vars := mux.Vars(r)
+	userID := models.UserID(vars["userID"])
+	webhookID := models.WebhookID(vars["webhookID"])
+	principal := auth.GetPrincipal(r)
+
+	logger = logger.WithFields(logrus.Fields{
+		"user_id":    userID,
+		"principal":  principal,
+		"webhook_id": webhookID,
+	})
+
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			utils.ResponseErrWithMap(err, w, "Could not parse limit.", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, ok := api.getWebhook(w, r, userID, webhookID); !ok {
+		return
+	}
+
+	ctx := r.Context()
+	deliveries, err := api.DB.ListWebhookDeliveries(ctx, webhookID, limit)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting webhook deliveries.", http.StatusConflict)
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = make([]*models.WebhookDelivery, 0)
+	}
+
+	logger.Info("Webhook deliveries returned")
+	utils.WriteJSON(w, http.StatusOK, deliveries)
+}
+
+// getWebhook - webhook is found only under path of its owner, so user can't reach webhook of admin or another user
+func (api *WebhookAPI) getWebhook(w http.ResponseWriter, r *http.Request, userID models.UserID, webhookID models.WebhookID) (*models.Webhook, bool) {
+	webhook, err := api.DB.GetWebhookByID(r.Context(), webhookID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting webhook.", http.StatusConflict)
+		return nil, false
+	}
+
+	owner := models.NilUserID
+	if webhook.UserID != nil {
+		owner = *webhook.UserID
+	}
+	if owner != userID {
+		utils.WriteError(w, http.StatusNotFound, "Webhook not found.", nil)
+		return nil, false
+	}
+
+	return webhook, true
+}
+
+// webhookOwner - webhook created under /webhooks has no user
+func webhookOwner(userID models.UserID) *models.UserID {
+	if userID == models.NilUserID {
+		return nil
+	}
+	return &userID
+}
+
+// writeChanged - webhook was changed since client has read it, client gets ETag of current version
+func (api *WebhookAPI) writeChanged(w http.ResponseWriter, r *http.Request, webhookID models.WebhookID) {
+	webhook, err := api.DB.GetWebhookByID(r.Context(), webhookID)
+	if err != nil {
+		utils.ResponseErr(err, w, "Error getting webhook.", http.StatusConflict)
+		return
+	}
+
+	writePreconditionFailed(w, webhook.Version)
+}
@@ -4,3 +4,9 @@ import "github.com/namsral/flag"
 
 // DataDirectory is the path used for loading templates/database migrations
 var DataDirectory = flag.String("data-directory", "", "Path for loading templates and migration scripts.")
+
+// AppURL is used to build links we send to users (email verification, password reset)
+var AppURL = flag.String("app-url", "http://localhost:8080", "Public URL of application.")
+
+// TrashRetentionDays is how long deleted rows are kept in trash before they are purged
+var TrashRetentionDays = flag.Int("trash-retention-days", 30, "Days deleted rows are kept in trash before they are permanently removed.")
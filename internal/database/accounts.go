@@ -3,7 +3,9 @@ package database
 import (
 	"context"
 	"finance/internal/models"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
@@ -12,27 +14,35 @@ type AccountDB interface {
 	UpdateAccount(ctx context.Context, account *models.Account) error
 	GetAccountByID(ctx context.Context, accountID models.AccountID) (*models.Account, error)
 	ListAccountByUserID(ctx context.Context, userID models.UserID) ([]*models.Account, error)
-	DeleteAccount(ctx context.Context, accountID models.AccountID) (bool, error)
+	DeleteAccount(ctx context.Context, accountID models.AccountID, version int64, lockDate *time.Time) (bool, error)
+	RestoreAccount(ctx context.Context, accountID models.AccountID) (bool, error)
+	GetAccountBalance(ctx context.Context, accountID models.AccountID, until time.Time) (int64, error)
+	SumAccountTransactions(ctx context.Context, accountID models.AccountID, transactionType models.TransactionType, from, until time.Time) (int64, error)
 }
 
 const createAccountQuery = `
-	INSERT INTO accounts (user_id, start_balance, account_type, account_name, currency)
-		VALUES (:user_id, :start_balance, :account_type, :account_name, :currency)
+	INSERT INTO accounts (user_id, start_balance, account_type, account_name, currency, credit_limit, statement_day, payment_due_day, apr,
+	                      interest_rate, loan_principal, loan_term, loan_start)
+		VALUES (:user_id, :start_balance, :account_type, :account_name, :currency, :credit_limit, :statement_day, :payment_due_day, :apr,
+		        :interest_rate, :loan_principal, :loan_term, :loan_start)
 	RETURNING account_id;
 `
 func (d *database) CreateAccount(ctx context.Context, account *models.Account) error {
-	rows, err := d.conn.NamedQueryContext(ctx, createAccountQuery, account)
-	if err != nil {
-		return err
-	}
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		rows, err := sqlx.NamedQueryContext(ctx, tx, createAccountQuery, account)
+		if err != nil {
+			return err
+		}
 
-	defer rows.Close()
-	rows.Next()
-	if err := rows.Scan(&account.ID); err != nil {
-		return err
-	}
+		rows.Next()
+		err = rows.Scan(&account.ID)
+		rows.Close()
+		if err != nil {
+			return err
+		}
 
-	return nil
+		return emitAccountEvent(ctx, tx, models.EventAccountCreated, account.ID)
+	})
 }
 
 const UpdateAccountQuery = `
@@ -40,25 +50,37 @@ const UpdateAccountQuery = `
 		SET start_balance = :start_balance,
 				account_type = :account_type,
 				account_name = :account_name,
-				currency = :currency
-		WHERE account_id = :account_id;
+				currency = :currency,
+				credit_limit = :credit_limit,
+				statement_day = :statement_day,
+				payment_due_day = :payment_due_day,
+				apr = :apr,
+				interest_rate = :interest_rate,
+				loan_principal = :loan_principal,
+				loan_term = :loan_term,
+				loan_start = :loan_start
+		WHERE account_id = :account_id AND version = :version
+		RETURNING updated_at, version;
 `
+// UpdateAccount updates account only if it is still at version, which caller has read
 func (d *database) UpdateAccount(ctx context.Context, account *models.Account) error {
-	result, err := d.conn.NamedExecContext(ctx, UpdateAccountQuery, account)
-	if err != nil {
-		return err
-	}
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		rows, err := sqlx.NamedQueryContext(ctx, tx, UpdateAccountQuery, account)
+		if err != nil {
+			return err
+		}
 
-	rows, err := result.RowsAffected()
-	if err != nil || rows == 0 {
-		return errors.New("Account not found")
-	}
+		if err := scanVersion(rows, &account.UpdatedAt, &account.Version); err != nil {
+			return err
+		}
 
-	return nil
+		return emitAccountEvent(ctx, tx, models.EventAccountUpdated, account.ID)
+	})
 }
 
 const getAccountByIDQuery = `
-	SELECT account_id, user_id, start_balance, account_type, account_name, currency, created_at, deleted_at
+	SELECT account_id, user_id, start_balance, account_type, account_name, currency, created_at, deleted_at,
+	       credit_limit, statement_day, payment_due_day, apr, interest_rate, loan_principal, loan_term, loan_start, updated_at, version
 	FROM accounts
 	WHERE account_id = $1;
 `
@@ -72,7 +94,8 @@ func (d *database) GetAccountByID(ctx context.Context, accountID models.AccountI
 }
 
 const listAccountByIDQuery = `
-	SELECT account_id, user_id, start_balance, account_type, account_name, currency, created_at, deleted_at
+	SELECT account_id, user_id, start_balance, account_type, account_name, currency, created_at, deleted_at,
+	       credit_limit, statement_day, payment_due_day, apr, interest_rate, loan_principal, loan_term, loan_start, updated_at, version
 	FROM accounts
 	WHERE user_id = $1 AND deleted_at IS NULL;
 `
@@ -85,21 +108,194 @@ func (d *database) ListAccountByUserID(ctx context.Context, userID models.UserID
 	return accounts, nil
 }
 
+// * Transactions and trades of account go to trash with the same deleted_at, so they can be restored together
 const DeleteAccountQuery = `
-	UPDATE accounts
-	SET deleted_at = NOW()
+	WITH deleted AS (
+		UPDATE accounts
+		SET deleted_at = NOW()
+		WHERE account_id = $1 AND deleted_at IS NULL AND version = $2
+		RETURNING account_id, deleted_at
+	), deleted_transactions AS (
+		UPDATE transactions t
+		SET deleted_at = deleted.deleted_at
+		FROM deleted
+		WHERE t.account_id = deleted.account_id AND t.deleted_at IS NULL
+	), deleted_trades AS (
+		UPDATE investment_trades it
+		SET deleted_at = deleted.deleted_at
+		FROM deleted
+		WHERE it.account_id = deleted.account_id AND it.deleted_at IS NULL
+	)
+	SELECT COUNT(*) FROM deleted;
+`
+const accountLiveQuery = `SELECT EXISTS (SELECT 1 FROM accounts WHERE account_id = $1 AND deleted_at IS NULL);`
+
+// DeleteAccount moves account with its transactions and trades to trash if it is still at version, which caller has read.
+// lockDate is nil when caller may change closed period.
+func (d *database) DeleteAccount(ctx context.Context, accountID models.AccountID, version int64, lockDate *time.Time) (bool, error) {
+	var deleted int
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := checkAccountDeletable(ctx, tx, accountID, lockDate); err != nil {
+			return err
+		}
+
+		if err := tx.GetContext(ctx, &deleted, DeleteAccountQuery, accountID, version); err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return checkDeleted(ctx, tx, accountLiveQuery, accountID)
+		}
+
+		return emitAccountDeleted(ctx, tx, accountID)
+	})
+
+	return deleted > 0, err
+}
+
+// * Transactions deleted together with account have the same deleted_at
+const listAccountDeletedTransactionsQuery = `
+	SELECT t.transaction_id
+	FROM transactions t
+	JOIN accounts a ON a.account_id = t.account_id
+	WHERE a.account_id = $1 AND t.deleted_at = a.deleted_at;
+`
+
+// emitAccountDeleted - events of account and of transactions which went to trash with it
+func emitAccountDeleted(ctx context.Context, tx *sqlx.Tx, accountID models.AccountID) error {
+	var transactionIDs []models.TransactionID
+	if err := tx.SelectContext(ctx, &transactionIDs, listAccountDeletedTransactionsQuery, accountID); err != nil {
+		return errors.Wrap(err, "could not get deleted transactions")
+	}
+	if err := emitTransactionEvents(ctx, tx, models.EventTransactionDeleted, transactionIDs); err != nil {
+		return err
+	}
+
+	return emitAccountEvent(ctx, tx, models.EventAccountDeleted, accountID)
+}
+
+const accountDeletableQuery = `
+	SELECT COALESCE(BOOL_OR(reconciled_at IS NOT NULL), FALSE) AS reconciled,
+	       COALESCE(BOOL_OR(transaction_date < $2::DATE + 1), FALSE) AS locked
+	FROM transactions
 	WHERE account_id = $1 AND deleted_at IS NULL;
 `
-func (d *database) DeleteAccount(ctx context.Context, accountID models.AccountID) (bool, error) {
-	result, err := d.conn.ExecContext(ctx, DeleteAccountQuery, accountID)
-	if err != nil {
-		return false, err
+
+// checkAccountDeletable - reconciled transactions and transactions of closed period can't go to trash with their account,
+// so account with them can't be deleted. Transactions are locked the same way as by finalization of reconciliation,
+// so they can't be reconciled until delete is done.
+func checkAccountDeletable(ctx context.Context, tx *sqlx.Tx, accountID models.AccountID, lockDate *time.Time) error {
+	if _, err := tx.ExecContext(ctx, `SELECT 1 FROM transactions WHERE account_id = $1 FOR UPDATE;`, accountID); err != nil {
+		return errors.Wrap(err, "could not lock transactions")
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return false, err
+	var state struct {
+		Reconciled bool `db:"reconciled"`
+		Locked     bool `db:"locked"`
+	}
+	if err := tx.GetContext(ctx, &state, accountDeletableQuery, accountID, lockDate); err != nil {
+		return errors.Wrap(err, "could not check account's transactions")
+	}
+
+	if state.Reconciled {
+		return ErrAccountReconciled
+	}
+	if state.Locked {
+		return ErrAccountLocked
+	}
+	return nil
+}
+
+// * Restores transactions and trades deleted together with account. Transaction stays in trash if its category is deleted.
+const restoreAccountQuery = `
+	WITH restored AS (
+		UPDATE accounts a
+		SET deleted_at = NULL
+		FROM accounts old
+		WHERE a.account_id = $1 AND old.account_id = a.account_id AND a.deleted_at IS NOT NULL
+		RETURNING a.account_id, old.deleted_at
+	), restored_transactions AS (
+		UPDATE transactions t
+		SET deleted_at = NULL
+		FROM restored
+		WHERE t.account_id = restored.account_id
+		      AND t.deleted_at = restored.deleted_at
+		      AND t.category_id IN (SELECT category_id FROM categories WHERE deleted_at IS NULL)
+	), restored_trades AS (
+		UPDATE investment_trades it
+		SET deleted_at = NULL
+		FROM restored
+		WHERE it.account_id = restored.account_id AND it.deleted_at = restored.deleted_at
+	)
+	SELECT COUNT(*) FROM restored;
+`
+
+// * The same transactions as restoreAccountQuery restores, they are listed before deleted_at of account is cleared
+const listAccountRestoredTransactionsQuery = `
+	SELECT t.transaction_id
+	FROM transactions t
+	JOIN accounts a ON a.account_id = t.account_id
+	WHERE a.account_id = $1
+	      AND a.deleted_at IS NOT NULL
+	      AND t.deleted_at = a.deleted_at
+	      AND t.category_id IN (SELECT category_id FROM categories WHERE deleted_at IS NULL);
+`
+
+func (d *database) RestoreAccount(ctx context.Context, accountID models.AccountID) (bool, error) {
+	var restored int
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		var transactionIDs []models.TransactionID
+		if err := tx.SelectContext(ctx, &transactionIDs, listAccountRestoredTransactionsQuery, accountID); err != nil {
+			return errors.Wrap(err, "could not get restored transactions")
+		}
+
+		if err := tx.GetContext(ctx, &restored, restoreAccountQuery, accountID); err != nil || restored == 0 {
+			return err
+		}
+
+		if err := emitTransactionEvents(ctx, tx, models.EventTransactionRestored, transactionIDs); err != nil {
+			return err
+		}
+		return emitAccountEvent(ctx, tx, models.EventAccountRestored, accountID)
+	})
+
+	return restored > 0, err
+}
+
+// * Balance before until, income increases balance and expense decreases it
+const getAccountBalanceQuery = `
+	SELECT a.start_balance + COALESCE(SUM(CASE WHEN t.transaction_type = 'income' THEN t.amount ELSE -t.amount END), 0)
+	FROM accounts a
+	LEFT JOIN transactions t ON t.account_id = a.account_id
+	     AND t.deleted_at IS NULL
+	     AND t.transaction_date < $2
+	WHERE a.account_id = $1
+	GROUP BY a.start_balance;
+`
+
+func (d *database) GetAccountBalance(ctx context.Context, accountID models.AccountID, until time.Time) (int64, error) {
+	var balance int64
+	if err := d.conn.GetContext(ctx, &balance, getAccountBalanceQuery, accountID, until); err != nil {
+		return 0, errors.Wrap(err, "could not get account balance")
+	}
+
+	return balance, nil
+}
+
+const sumAccountTransactionsQuery = `
+	SELECT COALESCE(SUM(amount), 0)
+	FROM transactions
+	WHERE account_id = $1
+	      AND transaction_type = $2
+	      AND deleted_at IS NULL
+	      AND transaction_date >= $3
+	      AND transaction_date < $4;
+`
+
+func (d *database) SumAccountTransactions(ctx context.Context, accountID models.AccountID, transactionType models.TransactionType, from, until time.Time) (int64, error) {
+	var sum int64
+	if err := d.conn.GetContext(ctx, &sum, sumAccountTransactionsQuery, accountID, transactionType, from, until); err != nil {
+		return 0, errors.Wrap(err, "could not sum account transactions")
 	}
 
-	return rows > 0, nil
+	return sum, nil
 }
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type APIKeyDB interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	ListAPIKeys(ctx context.Context, userID models.UserID) ([]*models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, userID models.UserID, apiKeyID models.APIKeyID) (bool, error)
+	UseAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error)
+	IsAPIKeyActive(ctx context.Context, apiKeyID models.APIKeyID) (bool, error)
+}
+
+const createAPIKeyQuery = `
+	INSERT INTO api_keys (user_id, name, prefix, key_hash, scope, expires_at)
+	VALUES (:user_id, :name, :prefix, :key_hash, :scope, :expires_at)
+	RETURNING api_key_id, created_at;
+`
+
+func (d *database) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createAPIKeyQuery, key)
+	if err != nil {
+		return errors.Wrap(err, "could not create api key")
+	}
+
+	defer rows.Close()
+	rows.Next()
+	if err := rows.Scan(&key.ID, &key.CreatedAt); err != nil {
+		return errors.Wrap(err, "could not get created api key")
+	}
+
+	return nil
+}
+
+const listAPIKeysQuery = `
+	SELECT api_key_id, user_id, name, prefix, key_hash, scope, created_at, expires_at, last_used_at, revoked_at
+	FROM api_keys
+	WHERE user_id = $1
+	ORDER BY created_at DESC;
+`
+
+func (d *database) ListAPIKeys(ctx context.Context, userID models.UserID) ([]*models.APIKey, error) {
+	var keys []*models.APIKey
+	if err := d.conn.SelectContext(ctx, &keys, listAPIKeysQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "could not get user's api keys")
+	}
+	return keys, nil
+}
+
+const revokeAPIKeyQuery = `
+	UPDATE api_keys
+	SET revoked_at = NOW()
+	WHERE user_id = $1 AND api_key_id = $2 AND revoked_at IS NULL;
+`
+
+func (d *database) RevokeAPIKey(ctx context.Context, userID models.UserID, apiKeyID models.APIKeyID) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, revokeAPIKeyQuery, userID, apiKeyID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// * Finds active key and tracks its usage with one query
+const useAPIKeyQuery = `
+	UPDATE api_keys
+	SET last_used_at = NOW()
+	WHERE key_hash = $1
+	      AND revoked_at IS NULL
+	      AND (expires_at IS NULL OR expires_at > NOW())
+	      AND user_id IN (SELECT user_id FROM users WHERE deleted_at IS NULL)
+	RETURNING api_key_id, user_id, name, prefix, key_hash, scope, created_at, expires_at, last_used_at, revoked_at;
+`
+
+func (d *database) UseAPIKey(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := d.conn.GetContext(ctx, &key, useAPIKeyQuery, keyHash); err != nil {
+		return nil, errors.Wrap(err, "could not get api key")
+	}
+	return &key, nil
+}
+
+const isAPIKeyActiveQuery = `
+	SELECT EXISTS (
+		SELECT 1 FROM api_keys
+		WHERE api_key_id = $1
+		      AND revoked_at IS NULL
+		      AND (expires_at IS NULL OR expires_at > NOW())
+		      AND user_id IN (SELECT user_id FROM users WHERE deleted_at IS NULL)
+	);
+`
+
+// IsAPIKeyActive checks that key used by open request wasn't revoked
+func (d *database) IsAPIKeyActive(ctx context.Context, apiKeyID models.APIKeyID) (bool, error) {
+	var active bool
+	if err := d.conn.GetContext(ctx, &active, isAPIKeyActiveQuery, apiKeyID); err != nil {
+		return false, errors.Wrap(err, "could not check api key")
+	}
+	return active, nil
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+	"fmt"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// AuditDB - audit log is append-only, so there are no update or delete methods
+type AuditDB interface {
+	CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error
+	ListAuditEvents(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error)
+}
+
+const createAuditEventQuery = `
+	INSERT INTO audit_events (principal_id, api_key_id, user_id, action, resource_type, resource_id, before, after, ip_address, request_id)
+	VALUES (:principal_id, :api_key_id, :user_id, :action, :resource_type, :resource_id, :before, :after, :ip_address, :request_id)
+	RETURNING audit_event_id, created_at;
+`
+
+func (d *database) CreateAuditEvent(ctx context.Context, event *models.AuditEvent) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createAuditEventQuery, event)
+	if err != nil {
+		return errors.Wrap(err, "could not create audit event")
+	}
+
+	defer rows.Close()
+	rows.Next()
+	if err := rows.Scan(&event.ID, &event.CreatedAt); err != nil {
+		return errors.Wrap(err, "could not get created audit event")
+	}
+
+	return nil
+}
+
+const listAuditEventsQuery = `
+	SELECT audit_event_id, principal_id, api_key_id, user_id, action, resource_type, resource_id, before, after, ip_address, request_id, created_at
+	FROM audit_events
+	WHERE %s
+	ORDER BY created_at DESC
+	LIMIT %d;
+`
+
+const defaultAuditLimit = 100
+const maxAuditLimit = 1000
+
+func (d *database) ListAuditEvents(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEvent, error) {
+	conditions := []string{"TRUE"}
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != models.NilUserID {
+		addCondition("user_id = $%d", filter.UserID)
+	}
+	if filter.PrincipalID != models.NilUserID {
+		addCondition("principal_id = $%d", filter.PrincipalID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.ResourceType != "" {
+		addCondition("resource_type = $%d", filter.ResourceType)
+	}
+	if filter.ResourceID != "" {
+		addCondition("resource_id = $%d", filter.ResourceID)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultAuditLimit
+	}
+	if limit > maxAuditLimit {
+		limit = maxAuditLimit
+	}
+
+	query := fmt.Sprintf(listAuditEventsQuery, strings.Join(conditions, " AND "), limit)
+
+	var events []*models.AuditEvent
+	if err := d.conn.SelectContext(ctx, &events, query, args...); err != nil {
+		return nil, errors.Wrap(err, "could not get audit events")
+	}
+	return events, nil
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type BackupDB interface {
+	GetBackup(ctx context.Context, userID models.UserID) (*models.Backup, error)
+	ImportBackup(ctx context.Context, userID models.UserID, backup *models.Backup) (*models.BackupImport, error)
+}
+
+const backupAccountsQuery = `
+	SELECT account_id, user_id, start_balance, account_type, account_name, currency, created_at, deleted_at,
+	       credit_limit, statement_day, payment_due_day, apr, interest_rate, loan_principal, loan_term, loan_start
+	FROM accounts
+	WHERE user_id = $1 AND deleted_at IS NULL;
+`
+
+const backupCategoriesQuery = `
+	SELECT category_id, parent_id, user_id, name, created_at, deleted_at
+	FROM categories
+	WHERE user_id = $1 AND deleted_at IS NULL;
+`
+
+const backupMerchantsQuery = `
+	SELECT merchant_id, user_id, name, created_at, deleted_at
+	FROM merchants
+	WHERE user_id = $1 AND deleted_at IS NULL;
+`
+
+const backupTransactionsQuery = `
+	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes
+	FROM transactions
+	WHERE user_id = $1 AND deleted_at IS NULL
+	ORDER BY transaction_date;
+`
+
+// GetBackup reads all live rows of user's ledger in one snapshot
+func (d *database) GetBackup(ctx context.Context, userID models.UserID) (*models.Backup, error) {
+	now := time.Now()
+	backup := models.Backup{
+		Version:   models.BackupVersion,
+		CreatedAt: &now,
+	}
+
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, `SET TRANSACTION ISOLATION LEVEL REPEATABLE READ READ ONLY;`); err != nil {
+			return err
+		}
+
+		selects := []struct {
+			dest  interface{}
+			query string
+		}{
+			{&backup.Accounts, backupAccountsQuery},
+			{&backup.Categories, backupCategoriesQuery},
+			{&backup.Merchants, backupMerchantsQuery},
+			{&backup.Transactions, backupTransactionsQuery},
+		}
+		for _, s := range selects {
+			if err := tx.SelectContext(ctx, s.dest, s.query, userID); err != nil {
+				return errors.Wrap(err, "could not get user's backup")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &backup, nil
+}
+
+// ImportBackup inserts backup into user's ledger. New IDs are generated and references are remapped.
+// Backup must be verified before import. Nothing is imported if any row fails. Every imported row emits its created event.
+func (d *database) ImportBackup(ctx context.Context, userID models.UserID, backup *models.Backup) (*models.BackupImport, error) {
+	categories, err := backup.SortedCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	var result models.BackupImport
+	err = d.withTx(ctx, func(tx *sqlx.Tx) error {
+		accountIDs := make(map[models.AccountID]models.AccountID, len(backup.Accounts))
+		for _, account := range backup.Accounts {
+			oldID := account.ID
+			account.UserID = &userID
+			if err := insertReturningID(ctx, tx, createAccountQuery, account, &account.ID); err != nil {
+				return errors.Wrap(err, "could not import account")
+			}
+			if err := emitAccountEvent(ctx, tx, models.EventAccountCreated, account.ID); err != nil {
+				return err
+			}
+			accountIDs[oldID] = account.ID
+			result.Accounts++
+		}
+
+		// * Categories are sorted parent first, so parent's new ID is already known
+		categoryIDs := make(map[models.CategoryID]models.CategoryID, len(categories))
+		for _, category := range categories {
+			oldID := category.ID
+			category.UserID = &userID
+			if category.ParentID != models.NilCategoryID {
+				category.ParentID = categoryIDs[category.ParentID]
+			}
+			if err := insertReturningID(ctx, tx, createCategoryQuery, category, &category.ID); err != nil {
+				return errors.Wrap(err, "could not import category")
+			}
+			if err := emitCategoryEvent(ctx, tx, models.EventCategoryCreated, category.ID); err != nil {
+				return err
+			}
+			categoryIDs[oldID] = category.ID
+			result.Categories++
+		}
+
+		for _, merchant := range backup.Merchants {
+			merchant.UserID = &userID
+			if err := insertReturningID(ctx, tx, createMerchantQuery, merchant, &merchant.ID); err != nil {
+				return errors.Wrap(err, "could not import merchant")
+			}
+			result.Merchants++
+		}
+
+		for _, transaction := range backup.Transactions {
+			accountID := accountIDs[*transaction.AccountID]
+			categoryID := categoryIDs[*transaction.CategoryID]
+			transaction.UserID = &userID
+			transaction.AccountID = &accountID
+			transaction.CategoryID = &categoryID
+			if transaction.Notes == nil {
+				notes := ""
+				transaction.Notes = &notes
+			}
+			if err := insertReturningID(ctx, tx, createTransactionQuery, transaction, &transaction.ID); err != nil {
+				return errors.Wrap(err, "could not import transaction")
+			}
+			if err := emitTransactionEvent(ctx, tx, models.EventTransactionCreated, transaction.ID); err != nil {
+				return err
+			}
+			result.Transactions++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// insertReturningID runs named insert query which returns ID of created row
+func insertReturningID(ctx context.Context, tx *sqlx.Tx, query string, arg interface{}, id interface{}) error {
+	rows, err := sqlx.NamedQueryContext(ctx, tx, query, arg)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+	if !rows.Next() {
+		return errors.New("no id returned")
+	}
+	return rows.Scan(id)
+}
@@ -0,0 +1,259 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrBillPaid            = errors.New("bill is already paid")
+	ErrTransactionPaysBill = errors.New("transaction already pays bill")
+)
+
+type BillDB interface {
+	CreateBill(ctx context.Context, bill *models.Bill) error
+	UpdateBill(ctx context.Context, bill *models.Bill) error
+	GetBillByID(ctx context.Context, billID models.BillID) (*models.Bill, error)
+	ListBillsByUserID(ctx context.Context, userID models.UserID) ([]*models.Bill, error)
+	ListBillsDueBefore(ctx context.Context, until time.Time) ([]*models.Bill, error)
+	DeleteBill(ctx context.Context, billID models.BillID, version int64) (bool, error)
+	RestoreBill(ctx context.Context, billID models.BillID) (bool, error)
+	PayBill(ctx context.Context, payment *models.BillPayment) error
+	ListBillPayments(ctx context.Context, billID models.BillID) ([]*models.BillPayment, error)
+	ListBillPaymentsByUserID(ctx context.Context, userID models.UserID, from, to time.Time) ([]*models.BillPayment, error)
+}
+
+const createBillQuery = `
+	INSERT INTO bills (user_id, account_id, name, amount, due_date, due_day, frequency, notes)
+	VALUES (:user_id, :account_id, :name, :amount, :due_date, :due_day, :frequency, :notes)
+	RETURNING bill_id;
+`
+
+func (d *database) CreateBill(ctx context.Context, bill *models.Bill) error {
+	bill.SetDueDate(*bill.DueDate)
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createBillQuery, bill)
+	if err != nil {
+		return errors.Wrap(err, "could not create bill")
+	}
+
+	defer rows.Close()
+	rows.Next()
+	if err := rows.Scan(&bill.ID); err != nil {
+		return errors.Wrap(err, "could not get created bill")
+	}
+
+	return nil
+}
+
+const updateBillQuery = `
+	UPDATE bills
+	SET account_id = :account_id,
+	    name = :name,
+	    amount = :amount,
+	    due_date = :due_date,
+	    due_day = :due_day,
+	    frequency = :frequency,
+	    notes = :notes
+	WHERE bill_id = :bill_id AND version = :version
+	RETURNING updated_at, version;
+`
+
+// UpdateBill updates bill only if it is still at version, which caller has read
+func (d *database) UpdateBill(ctx context.Context, bill *models.Bill) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, updateBillQuery, bill)
+	if err != nil {
+		return errors.Wrap(err, "could not update bill")
+	}
+
+	return scanVersion(rows, &bill.UpdatedAt, &bill.Version)
+}
+
+const getBillByIDQuery = `
+	SELECT bill_id, user_id, account_id, name, amount, due_date, due_day, frequency, paid_at, notes, created_at, deleted_at, updated_at, version
+	FROM bills
+	WHERE bill_id = $1;
+`
+
+func (d *database) GetBillByID(ctx context.Context, billID models.BillID) (*models.Bill, error) {
+	var bill models.Bill
+	if err := d.conn.GetContext(ctx, &bill, getBillByIDQuery, billID); err != nil {
+		return nil, errors.Wrap(err, "could not get bill")
+	}
+
+	return &bill, nil
+}
+
+const listBillsByUserIDQuery = `
+	SELECT bill_id, user_id, account_id, name, amount, due_date, due_day, frequency, paid_at, notes, created_at, deleted_at, updated_at, version
+	FROM bills
+	WHERE user_id = $1 AND deleted_at IS NULL
+	ORDER BY due_date;
+`
+
+func (d *database) ListBillsByUserID(ctx context.Context, userID models.UserID) ([]*models.Bill, error) {
+	var bills []*models.Bill
+	if err := d.conn.SelectContext(ctx, &bills, listBillsByUserIDQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "could not get user's bills")
+	}
+
+	return bills, nil
+}
+
+const listBillsDueBeforeQuery = `
+	SELECT bill_id, user_id, account_id, name, amount, due_date, due_day, frequency, paid_at, notes, created_at, deleted_at, updated_at, version
+	FROM bills
+	WHERE due_date < $1 AND paid_at IS NULL AND deleted_at IS NULL
+	ORDER BY due_date;
+`
+
+// ListBillsDueBefore returns unpaid bills of all users which are due before until, overdue bills included
+func (d *database) ListBillsDueBefore(ctx context.Context, until time.Time) ([]*models.Bill, error) {
+	var bills []*models.Bill
+	if err := d.conn.SelectContext(ctx, &bills, listBillsDueBeforeQuery, until); err != nil {
+		return nil, errors.Wrap(err, "could not get due bills")
+	}
+
+	return bills, nil
+}
+
+const deleteBillQuery = `
+	UPDATE bills
+	SET deleted_at = NOW()
+	WHERE bill_id = $1 AND deleted_at IS NULL AND version = $2;
+`
+
+const billLiveQuery = `SELECT EXISTS (SELECT 1 FROM bills WHERE bill_id = $1 AND deleted_at IS NULL);`
+
+// DeleteBill moves bill to trash if it is still at version, which caller has read
+func (d *database) DeleteBill(ctx context.Context, billID models.BillID, version int64) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, deleteBillQuery, billID, version)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, checkDeleted(ctx, d.conn, billLiveQuery, billID)
+	}
+	return true, nil
+}
+
+const restoreBillQuery = `
+	UPDATE bills
+	SET deleted_at = NULL
+	WHERE bill_id = $1 AND deleted_at IS NOT NULL;
+`
+
+func (d *database) RestoreBill(ctx context.Context, billID models.BillID) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, restoreBillQuery, billID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+const lockBillQuery = `
+	SELECT bill_id, user_id, account_id, name, amount, due_date, due_day, frequency, paid_at, notes, created_at, deleted_at, updated_at, version
+	FROM bills
+	WHERE bill_id = $1
+	FOR UPDATE;
+`
+
+const createBillPaymentQuery = `
+	INSERT INTO bill_payments (bill_id, user_id, transaction_id, due_date)
+	VALUES (:bill_id, :user_id, :transaction_id, :due_date)
+	RETURNING payment_id, paid_at;
+`
+
+const advanceBillQuery = `
+	UPDATE bills
+	SET due_date = $2, paid_at = $3
+	WHERE bill_id = $1;
+`
+
+// PayBill pays current occurrence of bill, recurring bill moves to its next occurrence
+func (d *database) PayBill(ctx context.Context, payment *models.BillPayment) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		var bill models.Bill
+		if err := tx.GetContext(ctx, &bill, lockBillQuery, payment.BillID); err != nil {
+			return errors.Wrap(err, "could not get bill")
+		}
+		if bill.IsPaid() {
+			return ErrBillPaid
+		}
+		payment.DueDate = bill.DueDate
+
+		rows, err := sqlx.NamedQueryContext(ctx, tx, createBillPaymentQuery, payment)
+		if pqError, ok := err.(*pq.Error); ok && pqError.Code.Name() == UniqueViolation {
+			return ErrTransactionPaysBill
+		}
+		if err != nil {
+			return errors.Wrap(err, "could not create bill payment")
+		}
+		rows.Next()
+		err = rows.Scan(&payment.ID, &payment.PaidAt)
+		rows.Close()
+		if err != nil {
+			return errors.Wrap(err, "could not get created bill payment")
+		}
+
+		dueDate, paidAt := bill.NextDueDate(*bill.DueDate), (*time.Time)(nil)
+		if *bill.Frequency == models.Once {
+			paidAt = payment.PaidAt
+		}
+		if _, err := tx.ExecContext(ctx, advanceBillQuery, bill.ID, dueDate, paidAt); err != nil {
+			return errors.Wrap(err, "could not update bill")
+		}
+		return nil
+	})
+}
+
+// * Payment by deleted transaction doesn't count
+const listBillPaymentsQuery = `
+	SELECT p.payment_id, p.bill_id, p.user_id, p.transaction_id, p.due_date, p.paid_at
+	FROM bill_payments p
+	JOIN transactions t ON t.transaction_id = p.transaction_id AND t.deleted_at IS NULL
+	WHERE p.bill_id = $1
+	ORDER BY p.due_date;
+`
+
+func (d *database) ListBillPayments(ctx context.Context, billID models.BillID) ([]*models.BillPayment, error) {
+	var payments []*models.BillPayment
+	if err := d.conn.SelectContext(ctx, &payments, listBillPaymentsQuery, billID); err != nil {
+		return nil, errors.Wrap(err, "could not get bill's payments")
+	}
+
+	return payments, nil
+}
+
+const listBillPaymentsByUserIDQuery = `
+	SELECT p.payment_id, p.bill_id, p.user_id, p.transaction_id, p.due_date, p.paid_at
+	FROM bill_payments p
+	JOIN transactions t ON t.transaction_id = p.transaction_id AND t.deleted_at IS NULL
+	WHERE p.user_id = $1 AND p.due_date >= $2 AND p.due_date <= $3
+	ORDER BY p.due_date;
+`
+
+func (d *database) ListBillPaymentsByUserID(ctx context.Context, userID models.UserID, from, to time.Time) ([]*models.BillPayment, error) {
+	var payments []*models.BillPayment
+	if err := d.conn.SelectContext(ctx, &payments, listBillPaymentsByUserIDQuery, userID, from, to); err != nil {
+		return nil, errors.Wrap(err, "could not get user's bill payments")
+	}
+
+	return payments, nil
+}
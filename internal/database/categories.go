@@ -4,6 +4,7 @@ import (
 	"context"
 	"finance/internal/models"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
@@ -12,7 +13,8 @@ type CategoryDB interface {
 	UpdateCategory(ctx context.Context, category *models.Category) error
 	GetCategoryByID(ctx context.Context, categoryID models.CategoryID) (*models.Category, error)
 	ListCategoryByUserID(ctx context.Context, userID models.UserID) ([]*models.Category, error)
-	DeleteCategory(ctx context.Context, categoryID models.CategoryID) (bool, error)
+	DeleteCategory(ctx context.Context, categoryID models.CategoryID, version int64) (bool, error)
+	RestoreCategory(ctx context.Context, categoryID models.CategoryID) (bool, error)
 }
 
 const createCategoryQuery = `
@@ -21,42 +23,48 @@ const createCategoryQuery = `
 	RETURNING category_id;
 `
 func (d *database) CreateCategory(ctx context.Context, category *models.Category) error {
-	rows, err := d.conn.NamedQueryContext(ctx, createCategoryQuery, category)
-	if err != nil {
-		return err
-	}
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		rows, err := sqlx.NamedQueryContext(ctx, tx, createCategoryQuery, category)
+		if err != nil {
+			return err
+		}
 
-	defer rows.Close()
-	rows.Next()
-	if err := rows.Scan(&category.ID); err != nil {
-		return err
-	}
+		rows.Next()
+		err = rows.Scan(&category.ID)
+		rows.Close()
+		if err != nil {
+			return err
+		}
 
-	return nil
+		return emitCategoryEvent(ctx, tx, models.EventCategoryCreated, category.ID)
+	})
 }
 
 const updateCategoryQuery = `
 	UPDATE categories
 		SET parent_id = :parent_id,
 				name = :name
-		WHERE category_id = :category_id;
+		WHERE category_id = :category_id AND version = :version
+		RETURNING updated_at, version;
 `
+// UpdateCategory updates category only if it is still at version, which caller has read
 func (d *database) UpdateCategory(ctx context.Context, category *models.Category) error {
-	result, err := d.conn.NamedExecContext(ctx, updateCategoryQuery, category)
-	if err != nil {
-		return err
-	}
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		rows, err := sqlx.NamedQueryContext(ctx, tx, updateCategoryQuery, category)
+		if err != nil {
+			return err
+		}
 
-	rows, err := result.RowsAffected()
-	if err != nil || rows == 0 {
-		return errors.New("Category not found")
-	}
+		if err := scanVersion(rows, &category.UpdatedAt, &category.Version); err != nil {
+			return err
+		}
 
-	return nil
+		return emitCategoryEvent(ctx, tx, models.EventCategoryUpdated, category.ID)
+	})
 }
 
 const getCategoryByIDQuery = `
-	SELECT category_id, parent_id, user_id, name, created_at, deleted_at
+	SELECT category_id, parent_id, user_id, name, created_at, deleted_at, updated_at, version
 	FROM categories
 	WHERE category_id = $1;
 `
@@ -69,7 +77,7 @@ func (d *database) GetCategoryByID(ctx context.Context, categoryID models.Catego
 }
 
 const listCategoryByIDQuery = `
-	SELECT category_id, parent_id, user_id, name, created_at, deleted_at
+	SELECT category_id, parent_id, user_id, name, created_at, deleted_at, updated_at, version
 	FROM categories
 	WHERE user_id = $1 AND deleted_at IS NULL;
 `
@@ -81,21 +89,76 @@ func (d *database) ListCategoryByUserID(ctx context.Context, userID models.UserI
 	return categories, nil
 }
 
+// * Subcategories and transactions go to trash with the same deleted_at, so they can be restored together
 const DeleteCategoryQuery = `
-	UPDATE categories
-	SET deleted_at = NOW()
-	WHERE category_id = $1 AND deleted_at IS NULL;
+	WITH RECURSIVE tree AS (
+		SELECT category_id FROM categories WHERE category_id = $1 AND deleted_at IS NULL AND version = $2
+		UNION
+		SELECT c.category_id FROM categories c JOIN tree ON c.parent_id = tree.category_id::text WHERE c.deleted_at IS NULL
+	), deleted AS (
+		UPDATE categories
+		SET deleted_at = NOW()
+		WHERE category_id IN (SELECT category_id FROM tree)
+		RETURNING category_id
+	), deleted_transactions AS (
+		UPDATE transactions
+		SET deleted_at = NOW()
+		WHERE category_id IN (SELECT category_id FROM deleted) AND deleted_at IS NULL
+	)
+	SELECT COUNT(*) FROM deleted;
 `
-func (d *database) DeleteCategory(ctx context.Context, categoryID models.CategoryID) (bool, error) {
-	result, err := d.conn.ExecContext(ctx, DeleteCategoryQuery, categoryID)
-	if err != nil {
-		return false, err
-	}
+const categoryLiveQuery = `SELECT EXISTS (SELECT 1 FROM categories WHERE category_id = $1 AND deleted_at IS NULL);`
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return false, err
-	}
+// DeleteCategory moves category with its subtree to trash if category is still at version, which caller has read
+func (d *database) DeleteCategory(ctx context.Context, categoryID models.CategoryID, version int64) (bool, error) {
+	var deleted int
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.GetContext(ctx, &deleted, DeleteCategoryQuery, categoryID, version); err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return checkDeleted(ctx, tx, categoryLiveQuery, categoryID)
+		}
+
+		return emitCategoryEvent(ctx, tx, models.EventCategoryDeleted, categoryID)
+	})
+
+	return deleted > 0, err
+}
+
+// * Restores subcategories and transactions deleted together with category. Transaction stays in trash if its account is deleted.
+const restoreCategoryQuery = `
+	WITH RECURSIVE root AS (
+		SELECT category_id, deleted_at FROM categories WHERE category_id = $1 AND deleted_at IS NOT NULL
+	), tree AS (
+		SELECT category_id FROM root
+		UNION
+		SELECT c.category_id FROM categories c JOIN tree ON c.parent_id = tree.category_id::text, root WHERE c.deleted_at = root.deleted_at
+	), restored AS (
+		UPDATE categories
+		SET deleted_at = NULL
+		WHERE category_id IN (SELECT category_id FROM tree)
+		RETURNING category_id
+	), restored_transactions AS (
+		UPDATE transactions t
+		SET deleted_at = NULL
+		FROM root
+		WHERE t.category_id IN (SELECT category_id FROM restored)
+		      AND t.deleted_at = root.deleted_at
+		      AND t.account_id IN (SELECT account_id FROM accounts WHERE deleted_at IS NULL)
+	)
+	SELECT COUNT(*) FROM restored;
+`
+
+func (d *database) RestoreCategory(ctx context.Context, categoryID models.CategoryID) (bool, error) {
+	var restored int
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.GetContext(ctx, &restored, restoreCategoryQuery, categoryID); err != nil || restored == 0 {
+			return err
+		}
+
+		return emitCategoryEvent(ctx, tx, models.EventCategoryRestored, categoryID)
+	})
 
-	return rows > 0, nil
+	return restored > 0, err
 }
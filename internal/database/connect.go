@@ -35,6 +35,7 @@ func Connect() (*sqlx.DB, error) {
 
 	// Connect to database:
 	dbURL := *databaseURL
+	connURL = dbURL
 
 	logrus.Debug("Connecting to database.")
 	conn, err := sqlx.Open("postgres", dbURL)
@@ -65,6 +66,7 @@ func New() (Database, error) {
 	}
 
 	d := &database{
+		db:   conn,
 		conn: conn,
 	}
 
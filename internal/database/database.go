@@ -1,9 +1,12 @@
 package database
 
 import (
+	"context"
+	"database/sql"
 	"io"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 )
 
 // UniqueViolation Postgres error string for a unique index violation
@@ -13,19 +16,79 @@ const UniqueViolation = "unique_violation"
 type Database interface {
 	UsersDB
 	SessionsDB
+	UserTokenDB
+	TwoFactorDB
+	LoginThrottleDB
+	IdentityDB
+	APIKeyDB
+	AuditDB
 	UserRoleDB
 	AccountDB
 	CategoryDB
 	MerchantDB
 	TransactionDB
+	TrashDB
+	UserDataDB
+	BackupDB
+	ReconciliationDB
+	ReportDB
+	DebtDB
+	SecurityDB
+	TradeDB
+	GoalDB
+	BillDB
+	NotificationDB
+	WebhookDB
+	SyncDB
+
+	// WithTx runs fn with database which runs every query in one transaction. Transaction is commited only if fn succeeds.
+	WithTx(ctx context.Context, fn func(db Database) error) error
 
 	io.Closer
 }
 
+// connection is implemented by both connection pool and transaction
+type connection interface {
+	sqlx.ExtContext
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+}
+
 type database struct {
-	conn *sqlx.DB
+	db   *sqlx.DB
+	conn connection
+
+	// * Set when database is used inside of WithTx
+	tx *sqlx.Tx
 }
 
 func (d *database) Close() error {
-	return d.conn.Close()
+	return d.db.Close()
+}
+
+func (d *database) WithTx(ctx context.Context, fn func(db Database) error) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		return fn(&database{db: d.db, conn: tx, tx: tx})
+	})
+}
+
+// withTx runs fn inside of database transaction. Transaction is commited only if fn succeeds.
+// * Inside of WithTx fn joins transaction which is already running
+func (d *database) withTx(ctx context.Context, fn func(tx *sqlx.Tx) error) error {
+	if d.tx != nil {
+		return fn(d.tx)
+	}
+
+	tx, err := d.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "could not begin transaction")
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return errors.Wrap(tx.Commit(), "could not commit transaction")
 }
@@ -0,0 +1,163 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type DebtDB interface {
+	CreateDebt(ctx context.Context, debt *models.Debt) error
+	UpdateDebt(ctx context.Context, debt *models.Debt) error
+	GetDebtByID(ctx context.Context, debtID models.DebtID) (*models.Debt, error)
+	ListDebtsByUserID(ctx context.Context, userID models.UserID) ([]*models.Debt, error)
+	ListDebtRepayments(ctx context.Context, debtID models.DebtID) ([]*models.Transaction, error)
+	DeleteDebt(ctx context.Context, debtID models.DebtID, version int64) (bool, error)
+	RestoreDebt(ctx context.Context, debtID models.DebtID) (bool, error)
+}
+
+const createDebtQuery = `
+	INSERT INTO debts (user_id, counterparty, direction, principal, interest_rate, term, start_date, currency, notes)
+	VALUES (:user_id, :counterparty, :direction, :principal, COALESCE(:interest_rate, 0), :term, :start_date, :currency, :notes)
+	RETURNING debt_id, interest_rate;
+`
+
+func (d *database) CreateDebt(ctx context.Context, debt *models.Debt) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createDebtQuery, debt)
+	if err != nil {
+		return errors.Wrap(err, "could not create debt")
+	}
+
+	defer rows.Close()
+	rows.Next()
+	if err := rows.Scan(&debt.ID, &debt.InterestRate); err != nil {
+		return errors.Wrap(err, "could not get created debt")
+	}
+
+	return nil
+}
+
+const updateDebtQuery = `
+	UPDATE debts
+	SET counterparty = :counterparty,
+	    direction = :direction,
+	    principal = :principal,
+	    interest_rate = :interest_rate,
+	    term = :term,
+	    start_date = :start_date,
+	    currency = :currency,
+	    notes = :notes
+	WHERE debt_id = :debt_id AND version = :version
+	RETURNING updated_at, version;
+`
+
+// UpdateDebt updates debt only if it is still at version, which caller has read
+func (d *database) UpdateDebt(ctx context.Context, debt *models.Debt) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, updateDebtQuery, debt)
+	if err != nil {
+		return errors.Wrap(err, "could not update debt")
+	}
+
+	return scanVersion(rows, &debt.UpdatedAt, &debt.Version)
+}
+
+// * Repaid is sum of live repayments
+const getDebtByIDQuery = `
+	SELECT d.debt_id, d.user_id, d.counterparty, d.direction, d.principal, d.interest_rate, d.term, d.start_date, d.currency, d.notes,
+	       d.created_at, d.deleted_at, d.updated_at, d.version,
+	       COALESCE((SELECT SUM(amount) FROM transactions t WHERE t.debt_id = d.debt_id AND t.deleted_at IS NULL), 0) AS repaid
+	FROM debts d
+	WHERE d.debt_id = $1;
+`
+
+func (d *database) GetDebtByID(ctx context.Context, debtID models.DebtID) (*models.Debt, error) {
+	var debt models.Debt
+	if err := d.conn.GetContext(ctx, &debt, getDebtByIDQuery, debtID); err != nil {
+		return nil, errors.Wrap(err, "could not get debt")
+	}
+
+	return &debt, nil
+}
+
+const listDebtsByUserIDQuery = `
+	SELECT d.debt_id, d.user_id, d.counterparty, d.direction, d.principal, d.interest_rate, d.term, d.start_date, d.currency, d.notes,
+	       d.created_at, d.deleted_at, d.updated_at, d.version,
+	       COALESCE((SELECT SUM(amount) FROM transactions t WHERE t.debt_id = d.debt_id AND t.deleted_at IS NULL), 0) AS repaid
+	FROM debts d
+	WHERE d.user_id = $1 AND d.deleted_at IS NULL
+	ORDER BY d.counterparty, d.start_date;
+`
+
+func (d *database) ListDebtsByUserID(ctx context.Context, userID models.UserID) ([]*models.Debt, error) {
+	var debts []*models.Debt
+	if err := d.conn.SelectContext(ctx, &debts, listDebtsByUserIDQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "could not get user's debts")
+	}
+
+	return debts, nil
+}
+
+const listDebtRepaymentsQuery = `
+	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes, cleared, reconciliation_id, reconciled_at, debt_id, updated_at, version
+	FROM transactions
+	WHERE debt_id = $1 AND deleted_at IS NULL
+	ORDER BY transaction_date;
+`
+
+func (d *database) ListDebtRepayments(ctx context.Context, debtID models.DebtID) ([]*models.Transaction, error) {
+	var transactions []*models.Transaction
+	if err := d.conn.SelectContext(ctx, &transactions, listDebtRepaymentsQuery, debtID); err != nil {
+		return nil, errors.Wrap(err, "could not get debt's repayments")
+	}
+
+	return transactions, nil
+}
+
+// * Repayments stay in ledger, they are still transactions of account
+const deleteDebtQuery = `
+	UPDATE debts
+	SET deleted_at = NOW()
+	WHERE debt_id = $1 AND deleted_at IS NULL AND version = $2;
+`
+
+const debtLiveQuery = `SELECT EXISTS (SELECT 1 FROM debts WHERE debt_id = $1 AND deleted_at IS NULL);`
+
+// DeleteDebt moves debt to trash if it is still at version, which caller has read
+func (d *database) DeleteDebt(ctx context.Context, debtID models.DebtID, version int64) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, deleteDebtQuery, debtID, version)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, checkDeleted(ctx, d.conn, debtLiveQuery, debtID)
+	}
+	return true, nil
+}
+
+const restoreDebtQuery = `
+	UPDATE debts
+	SET deleted_at = NULL
+	WHERE debt_id = $1 AND deleted_at IS NOT NULL;
+`
+
+func (d *database) RestoreDebt(ctx context.Context, debtID models.DebtID) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, restoreDebtQuery, debtID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
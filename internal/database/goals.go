@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var ErrContributionExists = errors.New("transaction already contributes to goal")
+
+type GoalDB interface {
+	CreateGoal(ctx context.Context, goal *models.Goal) error
+	UpdateGoal(ctx context.Context, goal *models.Goal) error
+	GetGoalByID(ctx context.Context, goalID models.GoalID) (*models.Goal, error)
+	ListGoalsByUserID(ctx context.Context, userID models.UserID) ([]*models.Goal, error)
+	DeleteGoal(ctx context.Context, goalID models.GoalID, version int64) (bool, error)
+	RestoreGoal(ctx context.Context, goalID models.GoalID) (bool, error)
+	CreateGoalContribution(ctx context.Context, contribution *models.GoalContribution) error
+	ListGoalContributions(ctx context.Context, goalID models.GoalID) ([]*models.GoalContribution, error)
+	DeleteGoalContribution(ctx context.Context, userID models.UserID, goalID models.GoalID, contributionID models.GoalContributionID) (bool, error)
+}
+
+const createGoalQuery = `
+	INSERT INTO goals (user_id, account_id, name, target_amount, target_date)
+	VALUES (:user_id, :account_id, :name, :target_amount, :target_date)
+	RETURNING goal_id;
+`
+
+func (d *database) CreateGoal(ctx context.Context, goal *models.Goal) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createGoalQuery, goal)
+	if err != nil {
+		return errors.Wrap(err, "could not create goal")
+	}
+
+	defer rows.Close()
+	rows.Next()
+	if err := rows.Scan(&goal.ID); err != nil {
+		return errors.Wrap(err, "could not get created goal")
+	}
+
+	return nil
+}
+
+const updateGoalQuery = `
+	UPDATE goals
+	SET account_id = :account_id,
+	    name = :name,
+	    target_amount = :target_amount,
+	    target_date = :target_date
+	WHERE goal_id = :goal_id AND version = :version
+	RETURNING updated_at, version;
+`
+
+// UpdateGoal updates goal only if it is still at version, which caller has read
+func (d *database) UpdateGoal(ctx context.Context, goal *models.Goal) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, updateGoalQuery, goal)
+	if err != nil {
+		return errors.Wrap(err, "could not update goal")
+	}
+
+	return scanVersion(rows, &goal.UpdatedAt, &goal.Version)
+}
+
+const getGoalByIDQuery = `
+	SELECT goal_id, user_id, account_id, name, target_amount, target_date, created_at, deleted_at, updated_at, version
+	FROM goals
+	WHERE goal_id = $1;
+`
+
+func (d *database) GetGoalByID(ctx context.Context, goalID models.GoalID) (*models.Goal, error) {
+	var goal models.Goal
+	if err := d.conn.GetContext(ctx, &goal, getGoalByIDQuery, goalID); err != nil {
+		return nil, errors.Wrap(err, "could not get goal")
+	}
+
+	return &goal, nil
+}
+
+const listGoalsByUserIDQuery = `
+	SELECT goal_id, user_id, account_id, name, target_amount, target_date, created_at, deleted_at, updated_at, version
+	FROM goals
+	WHERE user_id = $1 AND deleted_at IS NULL
+	ORDER BY target_date NULLS LAST, name;
+`
+
+func (d *database) ListGoalsByUserID(ctx context.Context, userID models.UserID) ([]*models.Goal, error) {
+	var goals []*models.Goal
+	if err := d.conn.SelectContext(ctx, &goals, listGoalsByUserIDQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "could not get user's goals")
+	}
+
+	return goals, nil
+}
+
+const deleteGoalQuery = `
+	UPDATE goals
+	SET deleted_at = NOW()
+	WHERE goal_id = $1 AND deleted_at IS NULL AND version = $2;
+`
+
+const goalLiveQuery = `SELECT EXISTS (SELECT 1 FROM goals WHERE goal_id = $1 AND deleted_at IS NULL);`
+
+// DeleteGoal moves goal to trash if it is still at version, which caller has read
+func (d *database) DeleteGoal(ctx context.Context, goalID models.GoalID, version int64) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, deleteGoalQuery, goalID, version)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, checkDeleted(ctx, d.conn, goalLiveQuery, goalID)
+	}
+	return true, nil
+}
+
+const restoreGoalQuery = `
+	UPDATE goals
+	SET deleted_at = NULL
+	WHERE goal_id = $1 AND deleted_at IS NOT NULL;
+`
+
+func (d *database) RestoreGoal(ctx context.Context, goalID models.GoalID) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, restoreGoalQuery, goalID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+const createGoalContributionQuery = `
+	INSERT INTO goal_contributions (goal_id, user_id, transaction_id, amount, contribution_date, notes)
+	VALUES (:goal_id, :user_id, :transaction_id, :amount, :contribution_date, :notes)
+	RETURNING contribution_id;
+`
+
+func (d *database) CreateGoalContribution(ctx context.Context, contribution *models.GoalContribution) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createGoalContributionQuery, contribution)
+	if pqError, ok := err.(*pq.Error); ok && pqError.Code.Name() == UniqueViolation {
+		return ErrContributionExists
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not create goal contribution")
+	}
+
+	defer rows.Close()
+	rows.Next()
+	if err := rows.Scan(&contribution.ID); err != nil {
+		return errors.Wrap(err, "could not get created goal contribution")
+	}
+
+	return nil
+}
+
+// * Contribution by deleted transaction doesn't count. Linked contribution follows current amount and date of its transaction,
+// so editing transaction after it was linked doesn't leave goal with stale values.
+const listGoalContributionsQuery = `
+	SELECT c.contribution_id, c.goal_id, c.user_id, c.transaction_id,
+	       CASE
+	         WHEN t.transaction_id IS NULL THEN c.amount
+	         WHEN t.transaction_type = 'expense' THEN -t.amount
+	         ELSE t.amount
+	       END AS amount,
+	       COALESCE(t.transaction_date, c.contribution_date) AS contribution_date,
+	       c.notes, c.created_at
+	FROM goal_contributions c
+	LEFT JOIN transactions t ON t.transaction_id = c.transaction_id
+	WHERE c.goal_id = $1 AND (c.transaction_id IS NULL OR t.deleted_at IS NULL)
+	ORDER BY c.contribution_date;
+`
+
+func (d *database) ListGoalContributions(ctx context.Context, goalID models.GoalID) ([]*models.GoalContribution, error) {
+	var contributions []*models.GoalContribution
+	if err := d.conn.SelectContext(ctx, &contributions, listGoalContributionsQuery, goalID); err != nil {
+		return nil, errors.Wrap(err, "could not get goal's contributions")
+	}
+
+	return contributions, nil
+}
+
+const deleteGoalContributionQuery = `
+	DELETE FROM goal_contributions
+	WHERE goal_id = $1 AND contribution_id = $2 AND user_id = $3;
+`
+
+// DeleteGoalContribution removes contribution of user, contribution of another user is not found
+func (d *database) DeleteGoalContribution(ctx context.Context, userID models.UserID, goalID models.GoalID, contributionID models.GoalContributionID) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, deleteGoalContributionQuery, goalID, contributionID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
@@ -0,0 +1,132 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"finance/internal/models"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type IdentityDB interface {
+	CreateOAuthState(ctx context.Context, state *models.OAuthState) error
+	UseOAuthState(ctx context.Context, state string) (*models.OAuthState, error)
+	GetUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error)
+	LinkIdentity(ctx context.Context, identity *models.Identity) error
+	ListIdentities(ctx context.Context, userID models.UserID) ([]*models.Identity, error)
+	UnlinkIdentity(ctx context.Context, userID models.UserID, provider string) (bool, error)
+}
+
+var ErrIdentityLinked = errors.New("identity is linked to another user")
+var ErrOAuthStateInvalid = errors.New("authorization request is invalid or expired")
+
+const createOAuthStateQuery = `
+	INSERT INTO oauth_states (state, provider, code_verifier, nonce, user_id, device_id, expires_at)
+	VALUES (:state, :provider, :code_verifier, :nonce, :user_id, :device_id, :expires_at);
+`
+
+func (d *database) CreateOAuthState(ctx context.Context, state *models.OAuthState) error {
+	if _, err := d.conn.NamedExecContext(ctx, createOAuthStateQuery, state); err != nil {
+		return errors.Wrap(err, "could not create oauth state")
+	}
+	return nil
+}
+
+// * State can be used only once
+const useOAuthStateQuery = `
+	DELETE FROM oauth_states
+	WHERE state = $1 AND expires_at > NOW()
+	RETURNING state, provider, code_verifier, nonce, user_id, device_id, expires_at;
+`
+
+func (d *database) UseOAuthState(ctx context.Context, state string) (*models.OAuthState, error) {
+	var oauthState models.OAuthState
+	if err := d.conn.GetContext(ctx, &oauthState, useOAuthStateQuery, state); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrOAuthStateInvalid
+		}
+		return nil, errors.Wrap(err, "could not use oauth state")
+	}
+	return &oauthState, nil
+}
+
+const getUserByIdentityQuery = `
+	SELECT u.user_id, u.email, u.password_hash, u.token_version, u.email_verified_at, u.totp_secret, u.totp_enabled_at, u.created_at
+	FROM user_identities i
+	JOIN users u ON u.user_id = i.user_id
+	WHERE i.provider = $1 AND i.subject = $2 AND u.deleted_at IS NULL;
+`
+
+func (d *database) GetUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	var user models.User
+	if err := d.conn.GetContext(ctx, &user, getUserByIdentityQuery, provider, subject); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+const linkIdentityQuery = `
+	INSERT INTO user_identities (provider, subject, user_id, email)
+	VALUES (:provider, :subject, :user_id, :email)
+
+	ON CONFLICT (provider, subject)
+	DO
+		UPDATE
+			SET email = :email
+			WHERE user_identities.user_id = :user_id;
+`
+
+func (d *database) LinkIdentity(ctx context.Context, identity *models.Identity) error {
+	result, err := d.conn.NamedExecContext(ctx, linkIdentityQuery, identity)
+	if err != nil {
+		if pqError, ok := err.(*pq.Error); ok && pqError.Code.Name() == UniqueViolation {
+			return ErrIdentityLinked
+		}
+		return errors.Wrap(err, "could not link identity")
+	}
+
+	// * Nothing is updated if identity belongs to another user
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrIdentityLinked
+	}
+
+	return nil
+}
+
+const listIdentitiesQuery = `
+	SELECT provider, subject, user_id, email, created_at
+	FROM user_identities
+	WHERE user_id = $1;
+`
+
+func (d *database) ListIdentities(ctx context.Context, userID models.UserID) ([]*models.Identity, error) {
+	var identities []*models.Identity
+	if err := d.conn.SelectContext(ctx, &identities, listIdentitiesQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "could not get user's identities")
+	}
+	return identities, nil
+}
+
+const unlinkIdentityQuery = `
+	DELETE FROM user_identities
+	WHERE user_id = $1 AND provider = $2;
+`
+
+func (d *database) UnlinkIdentity(ctx context.Context, userID models.UserID, provider string) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, unlinkIdentityQuery, userID, provider)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// EventsChannel is Postgres channel which announces ID of every new outbox event
+const EventsChannel = "outbox_events"
+
+// connURL is URL used by Connect, listeners open their own connection with it
+var connURL string
+
+// NewListener opens dedicated connection which listens to channel. Lost connection is reconnected,
+// listener then sends nil notification because notifications sent in the meantime are lost.
+func NewListener(channel string) (*pq.Listener, error) {
+	listener := pq.NewListener(connURL, 10*time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			logrus.WithField("func", "listener.go -> NewListener()").WithError(err).Warn("Listener connection failed.")
+		}
+	})
+
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return nil, errors.Wrap(err, "could not listen to channel")
+	}
+	return listener, nil
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type LoginThrottleDB interface {
+	RecordLoginAttempt(ctx context.Context, key string, window time.Duration, backoff func(failures int) time.Duration) (*time.Time, error)
+	RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	UndoLoginFailure(ctx context.Context, key string) error
+	ResetLoginFailures(ctx context.Context, key string) error
+	CreateAuthEvent(ctx context.Context, event *models.AuthEvent) error
+}
+
+const insertLoginThrottleQuery = `
+	INSERT INTO login_throttles (throttle_key, failures, last_failure_at)
+	VALUES ($1, 0, NOW())
+	ON CONFLICT (throttle_key) DO NOTHING;
+`
+
+// * Row is locked until attempt is counted, so parallel attempts can't pass the same check
+const getLoginThrottleQuery = `
+	SELECT failures,
+	       last_failure_at < NOW() - $2 * INTERVAL '1 second' AS expired,
+	       CASE WHEN locked_until > NOW() THEN locked_until END AS locked_until
+	FROM login_throttles
+	WHERE throttle_key = $1
+	FOR UPDATE;
+`
+
+const updateLoginThrottleQuery = `
+	UPDATE login_throttles
+	SET failures = $2,
+	    last_failure_at = NOW(),
+	    locked_until = COALESCE($3, locked_until)
+	WHERE throttle_key = $1;
+`
+
+// RecordLoginAttempt counts attempt as failure before credentials are checked and locks key when backoff says so.
+// Returns time key is locked until if it is locked, attempt is not counted then.
+func (d *database) RecordLoginAttempt(ctx context.Context, key string, window time.Duration, backoff func(failures int) time.Duration) (*time.Time, error) {
+	var lockedUntil *time.Time
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, insertLoginThrottleQuery, key); err != nil {
+			return errors.Wrap(err, "could not record login attempt")
+		}
+
+		var throttle struct {
+			Failures    int        `db:"failures"`
+			Expired     bool       `db:"expired"`
+			LockedUntil *time.Time `db:"locked_until"`
+		}
+		if err := tx.GetContext(ctx, &throttle, getLoginThrottleQuery, key, window.Seconds()); err != nil {
+			return errors.Wrap(err, "could not get login lock")
+		}
+		if throttle.LockedUntil != nil {
+			lockedUntil = throttle.LockedUntil
+			return nil
+		}
+
+		failures := throttle.Failures + 1
+		if throttle.Expired {
+			failures = 1
+		}
+
+		var lock *time.Time
+		if delay := backoff(failures); delay > 0 {
+			until := time.Now().Add(delay)
+			lock = &until
+		}
+
+		if _, err := tx.ExecContext(ctx, updateLoginThrottleQuery, key, failures, lock); err != nil {
+			return errors.Wrap(err, "could not record login attempt")
+		}
+		return nil
+	})
+
+	return lockedUntil, err
+}
+
+const recordLoginFailureQuery = `
+	INSERT INTO login_throttles (throttle_key, failures, last_failure_at)
+	VALUES ($1, 1, NOW())
+
+	ON CONFLICT (throttle_key)
+	DO
+		UPDATE
+			SET failures = CASE WHEN login_throttles.last_failure_at < NOW() - $2 * INTERVAL '1 second' THEN 1 ELSE login_throttles.failures + 1 END,
+					last_failure_at = NOW()
+	RETURNING failures;
+`
+
+func (d *database) RecordLoginFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	var failures int
+	if err := d.conn.GetContext(ctx, &failures, recordLoginFailureQuery, key, window.Seconds()); err != nil {
+		return 0, errors.Wrap(err, "could not record login failure")
+	}
+	return failures, nil
+}
+
+// * Attempt which passed was counted as failure in advance
+const undoLoginFailureQuery = `
+	UPDATE login_throttles
+	SET failures = GREATEST(failures - 1, 0)
+	WHERE throttle_key = $1;
+`
+
+func (d *database) UndoLoginFailure(ctx context.Context, key string) error {
+	if _, err := d.conn.ExecContext(ctx, undoLoginFailureQuery, key); err != nil {
+		return errors.Wrap(err, "could not undo login failure")
+	}
+	return nil
+}
+
+const resetLoginFailuresQuery = `
+	DELETE FROM login_throttles
+	WHERE throttle_key = $1;
+`
+
+func (d *database) ResetLoginFailures(ctx context.Context, key string) error {
+	if _, err := d.conn.ExecContext(ctx, resetLoginFailuresQuery, key); err != nil {
+		return errors.Wrap(err, "could not reset login failures")
+	}
+	return nil
+}
+
+const createAuthEventQuery = `
+	INSERT INTO auth_events (event_type, user_id, email, ip_address, user_agent)
+	VALUES (:event_type, :user_id, :email, :ip_address, :user_agent);
+`
+
+func (d *database) CreateAuthEvent(ctx context.Context, event *models.AuthEvent) error {
+	if _, err := d.conn.NamedExecContext(ctx, createAuthEventQuery, event); err != nil {
+		return errors.Wrap(err, "could not create auth event")
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"finance/internal/models"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
@@ -12,7 +13,8 @@ type MerchantDB interface {
 	UpdateMerchant(ctx context.Context, merchant *models.Merchant) error
 	GetMerchantByID(ctx context.Context, merchantID models.MerchantID) (*models.Merchant, error)
 	ListMerchantByUserID(ctx context.Context, userID models.UserID) ([]*models.Merchant, error)
-	DeleteMerchant(ctx context.Context, merchantID models.MerchantID) (bool, error)
+	DeleteMerchant(ctx context.Context, merchantID models.MerchantID, version int64) (bool, error)
+	RestoreMerchant(ctx context.Context, merchantID models.MerchantID) (bool, error)
 }
 
 const createMerchantQuery = `
@@ -22,7 +24,7 @@ const createMerchantQuery = `
 `
 
 func (d *database) CreateMerchant(ctx context.Context, merchant *models.Merchant) error {
-	rows, err := d.conn.NamedQueryContext(ctx, createMerchantQuery, merchant)
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createMerchantQuery, merchant)
 	if err != nil {
 		return err
 	}
@@ -39,25 +41,22 @@ func (d *database) CreateMerchant(ctx context.Context, merchant *models.Merchant
 const updateMerchantQuery = `
 	UPDATE merchants
 	SET name = :name
-	WHERE merchant_id = :merchant_id;
+	WHERE merchant_id = :merchant_id AND version = :version
+	RETURNING updated_at, version;
 `
 
+// UpdateMerchant updates merchant only if it is still at version, which caller has read
 func (d *database) UpdateMerchant(ctx context.Context, merchant *models.Merchant) error {
-	result, err := d.conn.NamedExecContext(ctx, updateMerchantQuery, merchant)
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, updateMerchantQuery, merchant)
 	if err != nil {
 		return err
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil || rows == 0 {
-		return errors.New("Merchant not found")
-	}
-
-	return nil
+	return scanVersion(rows, &merchant.UpdatedAt, &merchant.Version)
 }
 
 const getMerchantByIDQuery = `
-	SELECT merchant_id, user_id, name, created_at, deleted_at
+	SELECT merchant_id, user_id, name, created_at, deleted_at, updated_at, version
 	FROM merchants
 	WHERE merchant_id = $1;
 `
@@ -71,7 +70,7 @@ func (d *database) GetMerchantByID(ctx context.Context, merchantID models.Mercha
 }
 
 const listMerchantByIDQuery = `
-	SELECT merchant_id, user_id, name, created_at, deleted_at
+	SELECT merchant_id, user_id, name, created_at, deleted_at, updated_at, version
 	FROM merchants
 	WHERE user_id = $1 AND deleted_at IS NULL;
 `
@@ -86,10 +85,36 @@ func (d *database) ListMerchantByUserID(ctx context.Context, userID models.UserI
 const DeleteMerchantQuery = `
 	UPDATE merchants
 	SET deleted_at = NOW()
-	WHERE merchant_id = $1 AND deleted_at IS NULL;
+	WHERE merchant_id = $1 AND deleted_at IS NULL AND version = $2;
+`
+const merchantLiveQuery = `SELECT EXISTS (SELECT 1 FROM merchants WHERE merchant_id = $1 AND deleted_at IS NULL);`
+
+// DeleteMerchant moves merchant to trash if it is still at version, which caller has read
+func (d *database) DeleteMerchant(ctx context.Context, merchantID models.MerchantID, version int64) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, DeleteMerchantQuery, merchantID, version)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, checkDeleted(ctx, d.conn, merchantLiveQuery, merchantID)
+	}
+	return true, nil
+}
+
+const restoreMerchantQuery = `
+	UPDATE merchants
+	SET deleted_at = NULL
+	WHERE merchant_id = $1 AND deleted_at IS NOT NULL;
 `
-func (d *database) DeleteMerchant(ctx context.Context, merchantID models.MerchantID) (bool, error) {
-	result, err := d.conn.ExecContext(ctx, DeleteMerchantQuery, merchantID)
+
+func (d *database) RestoreMerchant(ctx context.Context, merchantID models.MerchantID) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, restoreMerchantQuery, merchantID)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,4 @@
+DROP TABLE refresh_tokens;
+
+ALTER TABLE sessions DROP COLUMN family_id;
+ALTER TABLE sessions ADD COLUMN refresh_token TEXT;
\ No newline at end of file
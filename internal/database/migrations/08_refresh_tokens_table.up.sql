@@ -0,0 +1,19 @@
+-- Refresh tokens are opaque now and only their hashes are stored.
+-- Every login starts a new token family, every refresh rotates token inside of family.
+DELETE FROM sessions;
+
+ALTER TABLE sessions DROP COLUMN refresh_token;
+ALTER TABLE sessions ADD COLUMN family_id TEXT NOT NULL;
+
+CREATE TABLE refresh_tokens (
+  token_hash TEXT PRIMARY KEY,
+  family_id TEXT NOT NULL,
+  user_id UUID NOT NULL REFERENCES users,
+  device_id TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires_at INTEGER NOT NULL,
+  used_at TIMESTAMP,
+  revoked_at TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_family ON refresh_tokens (family_id);
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE users DROP COLUMN token_version;
+
+ALTER TABLE sessions DROP COLUMN user_agent;
+ALTER TABLE sessions DROP COLUMN ip_address;
+ALTER TABLE sessions DROP COLUMN last_used_at;
+ALTER TABLE sessions DROP COLUMN created_at;
\ No newline at end of file
@@ -0,0 +1,8 @@
+ALTER TABLE sessions ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE sessions ADD COLUMN last_used_at TIMESTAMP NOT NULL DEFAULT NOW();
+ALTER TABLE sessions ADD COLUMN ip_address TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+
+-- Access tokens are issued with user's token version.
+-- Incrementing version invalidates all outstanding access tokens of user.
+ALTER TABLE users ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
\ No newline at end of file
@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+
+CREATE TYPE user_token_purpose AS ENUM ('verify_email', 'reset_password');
+
+-- Single-use tokens sent to user by email. Only hash of token is stored.
+CREATE TABLE user_tokens (
+  token_hash TEXT PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users,
+  purpose user_token_purpose NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP NOT NULL,
+  used_at TIMESTAMP
+);
+
+CREATE INDEX user_tokens_user ON user_tokens (user_id);
\ No newline at end of file
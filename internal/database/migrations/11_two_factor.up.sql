@@ -0,0 +1,12 @@
+-- Secret is set on enrollment, 2FA is enabled only after user confirms first code
+ALTER TABLE users ADD COLUMN totp_secret TEXT;
+ALTER TABLE users ADD COLUMN totp_enabled_at TIMESTAMP;
+
+-- Recovery codes are used when user lost device with authenticator. Only hashes are stored.
+CREATE TABLE recovery_codes (
+  user_id UUID NOT NULL REFERENCES users,
+  code_hash TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  used_at TIMESTAMP,
+  PRIMARY KEY (user_id, code_hash)
+);
\ No newline at end of file
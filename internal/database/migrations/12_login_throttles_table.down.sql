@@ -0,0 +1,3 @@
+DROP TABLE auth_events;
+DROP TYPE auth_event_type;
+DROP TABLE login_throttles;
\ No newline at end of file
@@ -0,0 +1,22 @@
+-- Failed login counters. Key is 'email:{email}' or 'ip:{address}'
+CREATE TABLE login_throttles (
+  throttle_key TEXT PRIMARY KEY,
+  failures INTEGER NOT NULL DEFAULT 0,
+  last_failure_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  locked_until TIMESTAMP
+);
+
+CREATE TYPE auth_event_type AS ENUM ('login_failed', 'login_locked');
+
+-- Security events, user_id is empty if email is not registered
+CREATE TABLE auth_events (
+  auth_event_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  event_type auth_event_type NOT NULL,
+  user_id UUID,
+  email TEXT NOT NULL DEFAULT '',
+  ip_address TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX auth_events_email ON auth_events (email, created_at);
\ No newline at end of file
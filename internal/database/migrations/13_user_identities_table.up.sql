@@ -0,0 +1,22 @@
+-- External identities (Google, Facebook) linked to users
+CREATE TABLE user_identities (
+  provider TEXT NOT NULL,
+  subject TEXT NOT NULL,
+  user_id UUID NOT NULL REFERENCES users,
+  email TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX user_identities_user ON user_identities (user_id);
+
+-- Pending authorization requests. user_id is set when logged in user links new identity.
+CREATE TABLE oauth_states (
+  state TEXT PRIMARY KEY,
+  provider TEXT NOT NULL,
+  code_verifier TEXT NOT NULL,
+  nonce TEXT NOT NULL,
+  user_id UUID REFERENCES users,
+  device_id TEXT NOT NULL DEFAULT '',
+  expires_at TIMESTAMP NOT NULL
+);
\ No newline at end of file
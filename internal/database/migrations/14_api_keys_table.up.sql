@@ -0,0 +1,18 @@
+CREATE TYPE api_key_scope AS ENUM ('read', 'write');
+
+-- Personal API keys for scripts. Full key is shown only once, we store hash and prefix.
+CREATE TABLE api_keys (
+  api_key_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users,
+  name TEXT NOT NULL DEFAULT '',
+  prefix TEXT NOT NULL,
+  key_hash TEXT NOT NULL,
+  scope api_key_scope NOT NULL DEFAULT 'write',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  expires_at TIMESTAMP,
+  last_used_at TIMESTAMP,
+  revoked_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX api_keys_hash ON api_keys (key_hash);
+CREATE INDEX api_keys_user ON api_keys (user_id);
\ No newline at end of file
@@ -0,0 +1,31 @@
+-- Record of every mutating operation. principal_id is who did it, user_id is owner of resource.
+-- before and after contain only changed fields for updates.
+CREATE TABLE audit_events (
+  audit_event_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  principal_id UUID,
+  api_key_id UUID,
+  user_id UUID,
+  action TEXT NOT NULL,
+  resource_type TEXT NOT NULL,
+  resource_id TEXT NOT NULL DEFAULT '',
+  before JSONB,
+  after JSONB,
+  ip_address TEXT NOT NULL DEFAULT '',
+  request_id TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX audit_events_user ON audit_events (user_id, created_at);
+CREATE INDEX audit_events_principal ON audit_events (principal_id, created_at);
+CREATE INDEX audit_events_resource ON audit_events (resource_type, resource_id);
+
+-- Audit log is append-only
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+  BEFORE UPDATE OR DELETE ON audit_events
+  FOR EACH ROW EXECUTE PROCEDURE audit_events_append_only();
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE transaction_versions;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- Prior versions of transactions. Row is copied here before every update.
+CREATE TABLE transaction_versions (
+  transaction_id UUID NOT NULL REFERENCES transactions,
+  version INTEGER NOT NULL,
+  user_id UUID NOT NULL,
+  account_id UUID NOT NULL,
+  category_id UUID NOT NULL,
+  transaction_date TIMESTAMP NOT NULL,
+  transaction_type transaction_type NOT NULL,
+  amount INTEGER NOT NULL,
+  notes TEXT NOT NULL DEFAULT '',
+  replaced_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+  PRIMARY KEY (transaction_id, version)
+);
\ No newline at end of file
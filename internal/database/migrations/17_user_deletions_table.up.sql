@@ -0,0 +1,24 @@
+-- Deletion of user's data runs in steps. Finished step is stored, so failed deletion continues where it stopped.
+CREATE TABLE user_deletions (
+  user_id UUID PRIMARY KEY REFERENCES users,
+  requested_by UUID,
+  requested_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  completed_step TEXT NOT NULL DEFAULT '',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  completed_at TIMESTAMP
+);
+
+-- Audit events of deleted user are redacted. Redaction is allowed only in transaction which sets audit.redact
+-- and it can only clear personal data, what was done, by whom and when stays.
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  IF TG_OP = 'UPDATE' AND current_setting('audit.redact', true) = 'on'
+     AND (NEW.audit_event_id, NEW.principal_id, NEW.api_key_id, NEW.user_id, NEW.action, NEW.resource_type, NEW.resource_id, NEW.request_id, NEW.created_at)
+         IS NOT DISTINCT FROM
+         (OLD.audit_event_id, OLD.principal_id, OLD.api_key_id, OLD.user_id, OLD.action, OLD.resource_type, OLD.resource_id, OLD.request_id, OLD.created_at) THEN
+    RETURN NEW;
+  END IF;
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE transactions DROP COLUMN reconciled_at;
+ALTER TABLE transactions DROP COLUMN reconciliation_id;
+ALTER TABLE transactions DROP COLUMN cleared;
+
+DROP TABLE reconciliations;
\ No newline at end of file
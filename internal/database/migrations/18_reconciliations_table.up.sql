@@ -0,0 +1,17 @@
+-- Reconciliation of account against bank statement. Account has at most one open reconciliation.
+CREATE TABLE reconciliations (
+  reconciliation_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users,
+  account_id UUID NOT NULL REFERENCES accounts,
+  statement_date TIMESTAMP NOT NULL,
+  ending_balance INTEGER NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  finalized_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX reconciliations_open ON reconciliations (account_id) WHERE finalized_at IS NULL;
+
+-- Cleared transaction appeared on bank statement. Reconciled transaction is locked from edits.
+ALTER TABLE transactions ADD COLUMN cleared BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE transactions ADD COLUMN reconciliation_id UUID REFERENCES reconciliations;
+ALTER TABLE transactions ADD COLUMN reconciled_at TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN lock_date;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Transactions dated on or before lock date can be changed only by admin
+ALTER TABLE users ADD COLUMN lock_date TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE accounts DROP COLUMN apr;
+ALTER TABLE accounts DROP COLUMN payment_due_day;
+ALTER TABLE accounts DROP COLUMN statement_day;
+ALTER TABLE accounts DROP COLUMN credit_limit;
\ No newline at end of file
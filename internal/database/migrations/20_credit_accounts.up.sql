@@ -0,0 +1,5 @@
+-- Credit account settings. Limit is in minor units of currency, apr is in basis points (1999 = 19.99%)
+ALTER TABLE accounts ADD COLUMN credit_limit INTEGER CHECK (credit_limit >= 0);
+ALTER TABLE accounts ADD COLUMN statement_day INTEGER CHECK (statement_day BETWEEN 1 AND 31);
+ALTER TABLE accounts ADD COLUMN payment_due_day INTEGER CHECK (payment_due_day BETWEEN 1 AND 31);
+ALTER TABLE accounts ADD COLUMN apr INTEGER CHECK (apr >= 0);
\ No newline at end of file
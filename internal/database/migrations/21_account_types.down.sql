@@ -0,0 +1,6 @@
+ALTER TABLE accounts DROP COLUMN loan_start;
+ALTER TABLE accounts DROP COLUMN loan_term;
+ALTER TABLE accounts DROP COLUMN loan_principal;
+ALTER TABLE accounts DROP COLUMN interest_rate;
+
+ALTER TABLE accounts DROP CONSTRAINT accounts_account_type_check;
\ No newline at end of file
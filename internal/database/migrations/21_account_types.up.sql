@@ -0,0 +1,9 @@
+-- Existing rows are not checked, they were never validated by API. Run VALIDATE CONSTRAINT after fixing them.
+ALTER TABLE accounts ADD CONSTRAINT accounts_account_type_check
+	CHECK (account_type IN ('cash', 'credit', 'savings', 'investment', 'loan', 'wallet')) NOT VALID;
+
+-- Rates are in basis points (450 = 4.5%), loan term is in months
+ALTER TABLE accounts ADD COLUMN interest_rate INTEGER CHECK (interest_rate >= 0);
+ALTER TABLE accounts ADD COLUMN loan_principal INTEGER CHECK (loan_principal > 0);
+ALTER TABLE accounts ADD COLUMN loan_term INTEGER CHECK (loan_term > 0);
+ALTER TABLE accounts ADD COLUMN loan_start TIMESTAMP;
\ No newline at end of file
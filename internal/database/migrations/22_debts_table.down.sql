@@ -0,0 +1,3 @@
+ALTER TABLE transactions DROP COLUMN debt_id;
+
+DROP TABLE IF EXISTS debts;
\ No newline at end of file
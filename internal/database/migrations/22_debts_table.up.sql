@@ -0,0 +1,23 @@
+-- Money lent to or borrowed from counterparty. Rate is annual in basis points, term is number of monthly payments.
+-- Debt without term has no repayment schedule.
+CREATE TABLE debts (
+  debt_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users,
+  counterparty TEXT NOT NULL,
+  direction TEXT NOT NULL CHECK (direction IN ('lent', 'borrowed')),
+  principal INTEGER NOT NULL CHECK (principal > 0),
+  interest_rate INTEGER NOT NULL DEFAULT 0 CHECK (interest_rate >= 0),
+  term INTEGER CHECK (term > 0),
+  start_date TIMESTAMP NOT NULL,
+  currency TEXT NOT NULL,
+  notes TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  deleted_at TIMESTAMP
+);
+
+CREATE INDEX debts_user ON debts (user_id);
+
+-- Repayment of debt is transaction linked to it
+ALTER TABLE transactions ADD COLUMN debt_id UUID REFERENCES debts;
+
+CREATE INDEX transactions_debt ON transactions (debt_id) WHERE debt_id IS NOT NULL;
\ No newline at end of file
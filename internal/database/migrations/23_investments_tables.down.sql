@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS investment_trades;
+DROP TABLE IF EXISTS security_prices;
+DROP TABLE IF EXISTS securities;
\ No newline at end of file
@@ -0,0 +1,36 @@
+-- Securities and their prices are shared by all users, prices are imported by admin
+CREATE TABLE securities (
+  security_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  symbol TEXT NOT NULL UNIQUE,
+  name TEXT NOT NULL,
+  security_type TEXT NOT NULL CHECK (security_type IN ('stock', 'fund', 'bond', 'other')),
+  currency TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Closing price of security in minor units of its currency
+CREATE TABLE security_prices (
+  security_id UUID NOT NULL REFERENCES securities,
+  price_date DATE NOT NULL,
+  price INTEGER NOT NULL CHECK (price >= 0),
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (security_id, price_date)
+);
+
+-- Trade of security in investment account. Unit price of dividend is dividend per unit.
+CREATE TABLE investment_trades (
+  trade_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users,
+  account_id UUID NOT NULL REFERENCES accounts,
+  security_id UUID NOT NULL REFERENCES securities,
+  trade_type TEXT NOT NULL CHECK (trade_type IN ('buy', 'sell', 'dividend')),
+  trade_date TIMESTAMP NOT NULL,
+  quantity NUMERIC(20, 6) NOT NULL CHECK (quantity > 0),
+  unit_price INTEGER NOT NULL CHECK (unit_price >= 0),
+  fees INTEGER NOT NULL DEFAULT 0 CHECK (fees >= 0),
+  notes TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  deleted_at TIMESTAMP
+);
+
+CREATE INDEX investment_trades_account ON investment_trades (account_id, trade_date);
\ No newline at end of file
@@ -0,0 +1,26 @@
+-- Savings goal, money saved for it is sum of contributions
+CREATE TABLE goals (
+  goal_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users,
+  account_id UUID REFERENCES accounts,
+  name TEXT NOT NULL,
+  target_amount INTEGER NOT NULL CHECK (target_amount > 0),
+  target_date TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  deleted_at TIMESTAMP
+);
+
+CREATE INDEX goals_user ON goals (user_id);
+
+-- Contribution is linked transaction or allocation of money which is already saved. Negative amount is withdrawal.
+CREATE TABLE goal_contributions (
+  contribution_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  goal_id UUID NOT NULL REFERENCES goals,
+  user_id UUID NOT NULL REFERENCES users,
+  transaction_id UUID REFERENCES transactions,
+  amount INTEGER NOT NULL,
+  contribution_date TIMESTAMP NOT NULL,
+  notes TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (goal_id, transaction_id)
+);
\ No newline at end of file
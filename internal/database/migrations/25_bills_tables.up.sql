@@ -0,0 +1,28 @@
+-- Bill is due at due_date. Paying recurring bill moves due_date to next occurrence, one-time bill gets paid_at.
+CREATE TABLE bills (
+  bill_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users,
+  account_id UUID REFERENCES accounts,
+  name TEXT NOT NULL,
+  amount INTEGER NOT NULL CHECK (amount > 0),
+  due_date TIMESTAMP NOT NULL,
+  frequency TEXT NOT NULL DEFAULT 'once' CHECK (frequency IN ('once', 'weekly', 'monthly', 'yearly')),
+  paid_at TIMESTAMP,
+  notes TEXT,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  deleted_at TIMESTAMP
+);
+
+CREATE INDEX bills_user ON bills (user_id);
+
+-- Payment of one occurrence of bill by transaction
+CREATE TABLE bill_payments (
+  payment_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  bill_id UUID NOT NULL REFERENCES bills,
+  user_id UUID NOT NULL REFERENCES users,
+  transaction_id UUID NOT NULL UNIQUE REFERENCES transactions,
+  due_date TIMESTAMP NOT NULL,
+  paid_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX bill_payments_user ON bill_payments (user_id, due_date);
\ No newline at end of file
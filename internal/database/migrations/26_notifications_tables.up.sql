@@ -0,0 +1,47 @@
+-- Notification of event, dedupe_key prevents repeated notification of the same occurrence.
+-- delivered_at is set by in-app channel, only delivered notifications are in inbox.
+CREATE TABLE notifications (
+  notification_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users,
+  event_type TEXT NOT NULL,
+  title TEXT NOT NULL,
+  body TEXT NOT NULL,
+  resource_id TEXT,
+  dedupe_key TEXT,
+  delivered_at TIMESTAMP,
+  read_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (user_id, event_type, dedupe_key)
+);
+
+CREATE INDEX notifications_user ON notifications (user_id, created_at);
+
+-- Channels of event type chosen by user, event type without row uses defaults
+CREATE TABLE notification_preferences (
+  user_id UUID NOT NULL REFERENCES users,
+  event_type TEXT NOT NULL,
+  in_app BOOLEAN NOT NULL DEFAULT TRUE,
+  email BOOLEAN NOT NULL DEFAULT FALSE,
+  webhook_url TEXT,
+  threshold BIGINT CHECK (threshold >= 0),
+  updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (user_id, event_type)
+);
+
+-- Delivery of notification by one channel. Failed delivery is retried at next_attempt_at until attempts run out.
+CREATE TABLE notification_deliveries (
+  delivery_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  notification_id UUID NOT NULL REFERENCES notifications,
+  user_id UUID NOT NULL REFERENCES users,
+  channel TEXT NOT NULL CHECK (channel IN ('in_app', 'email', 'webhook')),
+  target TEXT,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  sent_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX notification_deliveries_user ON notification_deliveries (user_id);
+CREATE INDEX notification_deliveries_pending ON notification_deliveries (next_attempt_at) WHERE status = 'pending';
\ No newline at end of file
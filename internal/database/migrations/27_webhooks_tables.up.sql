@@ -0,0 +1,46 @@
+-- Endpoint which receives events. Webhook without user receives events of all users and is managed by admin.
+-- Empty event_types subscribes to all events.
+CREATE TABLE webhooks (
+  webhook_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID REFERENCES users,
+  url TEXT NOT NULL,
+  secret TEXT NOT NULL,
+  event_types TEXT[] NOT NULL DEFAULT '{}',
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  deleted_at TIMESTAMP
+);
+
+CREATE INDEX webhooks_user ON webhooks (user_id);
+
+-- Event is written in the same transaction as change of resource, dispatched_at is set when deliveries are created
+CREATE TABLE outbox_events (
+  event_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  user_id UUID NOT NULL REFERENCES users,
+  event_type TEXT NOT NULL,
+  resource_id TEXT NOT NULL,
+  payload JSONB NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  dispatched_at TIMESTAMP
+);
+
+CREATE INDEX outbox_events_user ON outbox_events (user_id);
+CREATE INDEX outbox_events_pending ON outbox_events (created_at) WHERE dispatched_at IS NULL;
+
+-- Delivery of event to webhook. Failed delivery is retried at next_attempt_at until attempts run out.
+CREATE TABLE webhook_deliveries (
+  delivery_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+  webhook_id UUID NOT NULL REFERENCES webhooks,
+  event_id UUID NOT NULL REFERENCES outbox_events,
+  status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  response_status INTEGER,
+  last_error TEXT,
+  next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  sent_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  UNIQUE (webhook_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_event ON webhook_deliveries (event_id);
+CREATE INDEX webhook_deliveries_pending ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
\ No newline at end of file
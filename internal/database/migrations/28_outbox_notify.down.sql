@@ -0,0 +1,2 @@
+DROP TRIGGER outbox_events_notify ON outbox_events;
+DROP FUNCTION outbox_events_notify();
\ No newline at end of file
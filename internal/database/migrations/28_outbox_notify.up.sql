@@ -0,0 +1,12 @@
+-- Every outbox event is announced on channel outbox_events, so all server instances can push it to connected clients.
+-- Payload is only event_id, because size of notification payload is limited.
+CREATE FUNCTION outbox_events_notify() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('outbox_events', NEW.event_id::text);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER outbox_events_notify
+  AFTER INSERT ON outbox_events
+  FOR EACH ROW EXECUTE PROCEDURE outbox_events_notify();
\ No newline at end of file
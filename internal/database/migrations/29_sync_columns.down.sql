@@ -0,0 +1,19 @@
+DROP TRIGGER transactions_sync_deleted ON transactions;
+DROP TRIGGER merchants_sync_deleted ON merchants;
+DROP TRIGGER categories_sync_deleted ON categories;
+DROP TRIGGER accounts_sync_deleted ON accounts;
+DROP FUNCTION sync_row_deleted();
+DROP TABLE IF EXISTS sync_deletions;
+
+DROP TRIGGER transactions_sync ON transactions;
+DROP TRIGGER merchants_sync ON merchants;
+DROP TRIGGER categories_sync ON categories;
+DROP TRIGGER accounts_sync ON accounts;
+DROP FUNCTION sync_row_changed();
+
+ALTER TABLE transactions DROP COLUMN change_seq, DROP COLUMN updated_at, DROP COLUMN version;
+ALTER TABLE merchants DROP COLUMN change_seq, DROP COLUMN updated_at, DROP COLUMN version;
+ALTER TABLE categories DROP COLUMN change_seq, DROP COLUMN updated_at, DROP COLUMN version;
+ALTER TABLE accounts DROP COLUMN change_seq, DROP COLUMN updated_at, DROP COLUMN version;
+
+DROP SEQUENCE sync_change_seq;
\ No newline at end of file
@@ -0,0 +1,70 @@
+-- Every change of synced row takes next value of sync_change_seq, so client asks for rows changed after last value it saw.
+-- version counts changes of row and is used to detect conflicting writes of offline clients.
+CREATE SEQUENCE sync_change_seq;
+
+ALTER TABLE accounts
+  ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('sync_change_seq'),
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE categories
+  ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('sync_change_seq'),
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE merchants
+  ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('sync_change_seq'),
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE transactions
+  ADD COLUMN change_seq BIGINT NOT NULL DEFAULT nextval('sync_change_seq'),
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- Transaction already has its older versions in history
+UPDATE transactions t
+SET version = 1 + (SELECT COUNT(*) FROM transaction_versions v WHERE v.transaction_id = t.transaction_id);
+
+CREATE INDEX accounts_sync ON accounts (user_id, change_seq);
+CREATE INDEX categories_sync ON categories (user_id, change_seq);
+CREATE INDEX merchants_sync ON merchants (user_id, change_seq);
+CREATE INDEX transactions_sync ON transactions (user_id, change_seq);
+
+CREATE FUNCTION sync_row_changed() RETURNS trigger AS $$
+BEGIN
+  NEW.change_seq := nextval('sync_change_seq');
+  NEW.updated_at := NOW();
+  NEW.version := OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER accounts_sync BEFORE UPDATE ON accounts FOR EACH ROW EXECUTE PROCEDURE sync_row_changed();
+CREATE TRIGGER categories_sync BEFORE UPDATE ON categories FOR EACH ROW EXECUTE PROCEDURE sync_row_changed();
+CREATE TRIGGER merchants_sync BEFORE UPDATE ON merchants FOR EACH ROW EXECUTE PROCEDURE sync_row_changed();
+CREATE TRIGGER transactions_sync BEFORE UPDATE ON transactions FOR EACH ROW EXECUTE PROCEDURE sync_row_changed();
+
+-- Rows removed permanently (purge of trash) are remembered, so clients remove them too
+CREATE TABLE sync_deletions (
+  user_id UUID NOT NULL,
+  resource_type TEXT NOT NULL,
+  resource_id TEXT NOT NULL,
+  change_seq BIGINT NOT NULL DEFAULT nextval('sync_change_seq'),
+  deleted_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX sync_deletions_user ON sync_deletions (user_id, change_seq);
+
+CREATE FUNCTION sync_row_deleted() RETURNS trigger AS $$
+BEGIN
+  INSERT INTO sync_deletions (user_id, resource_type, resource_id)
+  VALUES (OLD.user_id, TG_ARGV[0], (to_jsonb(OLD) ->> TG_ARGV[1]));
+  RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER accounts_sync_deleted AFTER DELETE ON accounts FOR EACH ROW EXECUTE PROCEDURE sync_row_deleted('account', 'account_id');
+CREATE TRIGGER categories_sync_deleted AFTER DELETE ON categories FOR EACH ROW EXECUTE PROCEDURE sync_row_deleted('category', 'category_id');
+CREATE TRIGGER merchants_sync_deleted AFTER DELETE ON merchants FOR EACH ROW EXECUTE PROCEDURE sync_row_deleted('merchant', 'merchant_id');
+CREATE TRIGGER transactions_sync_deleted AFTER DELETE ON transactions FOR EACH ROW EXECUTE PROCEDURE sync_row_deleted('transaction', 'transaction_id');
\ No newline at end of file
@@ -0,0 +1,10 @@
+DROP TRIGGER webhooks_version ON webhooks;
+DROP TRIGGER bills_version ON bills;
+DROP TRIGGER goals_version ON goals;
+DROP TRIGGER debts_version ON debts;
+DROP FUNCTION row_version_changed();
+
+ALTER TABLE webhooks DROP COLUMN updated_at, DROP COLUMN version;
+ALTER TABLE bills DROP COLUMN updated_at, DROP COLUMN version;
+ALTER TABLE goals DROP COLUMN updated_at, DROP COLUMN version;
+ALTER TABLE debts DROP COLUMN updated_at, DROP COLUMN version;
\ No newline at end of file
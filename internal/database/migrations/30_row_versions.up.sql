@@ -0,0 +1,29 @@
+-- version counts changes of row, API returns it as ETag and checks it in If-Match
+ALTER TABLE debts
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE goals
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE bills
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE webhooks
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+CREATE FUNCTION row_version_changed() RETURNS trigger AS $$
+BEGIN
+  NEW.updated_at := NOW();
+  NEW.version := OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER debts_version BEFORE UPDATE ON debts FOR EACH ROW EXECUTE PROCEDURE row_version_changed();
+CREATE TRIGGER goals_version BEFORE UPDATE ON goals FOR EACH ROW EXECUTE PROCEDURE row_version_changed();
+CREATE TRIGGER bills_version BEFORE UPDATE ON bills FOR EACH ROW EXECUTE PROCEDURE row_version_changed();
+CREATE TRIGGER webhooks_version BEFORE UPDATE ON webhooks FOR EACH ROW EXECUTE PROCEDURE row_version_changed();
\ No newline at end of file
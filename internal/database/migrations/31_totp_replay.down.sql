@@ -0,0 +1,3 @@
+DROP TABLE used_mfa_tokens;
+
+ALTER TABLE users DROP COLUMN totp_last_counter;
\ No newline at end of file
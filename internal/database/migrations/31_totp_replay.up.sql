@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN totp_last_counter BIGINT NOT NULL DEFAULT 0;
+
+-- MFA tokens are single-use, token id is stored when token is exchanged for access token
+CREATE TABLE used_mfa_tokens (
+  token_id TEXT PRIMARY KEY,
+  user_id UUID NOT NULL REFERENCES users,
+  expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX used_mfa_tokens_expires ON used_mfa_tokens (expires_at);
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE transaction_versions DROP COLUMN version;
+ALTER TABLE transaction_versions RENAME COLUMN revision TO version;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- History number of prior state is revision, version is row version of transaction which was replaced
+ALTER TABLE transaction_versions RENAME COLUMN version TO revision;
+ALTER TABLE transaction_versions ADD COLUMN version BIGINT;
+
+-- Every update of transaction is stored in history, so revision matches row version
+UPDATE transaction_versions SET version = revision;
+
+ALTER TABLE transaction_versions ALTER COLUMN version SET NOT NULL;
\ No newline at end of file
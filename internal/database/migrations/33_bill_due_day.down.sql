@@ -0,0 +1 @@
+ALTER TABLE bills DROP COLUMN due_day;
\ No newline at end of file
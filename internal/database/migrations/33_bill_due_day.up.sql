@@ -0,0 +1,11 @@
+-- Day of month of monthly and yearly bill. Due date moves to the last day of shorter month, anchor keeps the original day.
+ALTER TABLE bills ADD COLUMN due_day SMALLINT;
+
+-- First paid occurrence still has the original day, bill without payments hasn't moved yet
+UPDATE bills b
+SET due_day = COALESCE(
+  (SELECT EXTRACT(DAY FROM p.due_date) FROM bill_payments p WHERE p.bill_id = b.bill_id ORDER BY p.due_date LIMIT 1),
+  EXTRACT(DAY FROM b.due_date)
+);
+
+ALTER TABLE bills ALTER COLUMN due_day SET NOT NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE notification_preferences DROP COLUMN webhook_secret;
\ No newline at end of file
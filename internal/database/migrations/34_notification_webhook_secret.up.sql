@@ -0,0 +1,6 @@
+-- Webhook of notifications is signed like webhooks of events. Existing webhooks get secret, user sees it after setting webhook_url again.
+ALTER TABLE notification_preferences ADD COLUMN webhook_secret TEXT;
+
+UPDATE notification_preferences
+SET webhook_secret = 'whsec_' || REPLACE(uuid_generate_v4()::TEXT || uuid_generate_v4()::TEXT, '-', '')
+WHERE webhook_url IS NOT NULL;
\ No newline at end of file
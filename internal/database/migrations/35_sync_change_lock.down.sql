@@ -0,0 +1,18 @@
+DROP TRIGGER sync_deletions_inserted ON sync_deletions;
+DROP TRIGGER transactions_sync_inserted ON transactions;
+DROP TRIGGER merchants_sync_inserted ON merchants;
+DROP TRIGGER categories_sync_inserted ON categories;
+DROP TRIGGER accounts_sync_inserted ON accounts;
+
+DROP FUNCTION sync_row_inserted();
+
+CREATE OR REPLACE FUNCTION sync_row_changed() RETURNS trigger AS $$
+BEGIN
+  NEW.change_seq := nextval('sync_change_seq');
+  NEW.updated_at := NOW();
+  NEW.version := OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP FUNCTION next_sync_change_seq(UUID);
\ No newline at end of file
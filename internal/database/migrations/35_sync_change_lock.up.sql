@@ -0,0 +1,30 @@
+-- Change sequence is taken under lock of user, which is held until commit. So writes of one user commit in order of their
+-- sequence and reader never sees change of later sequence while change of earlier one is still in flight.
+CREATE FUNCTION next_sync_change_seq(owner UUID) RETURNS BIGINT AS $$
+BEGIN
+  PERFORM pg_advisory_xact_lock(hashtext('sync_change_seq'), hashtext(owner::TEXT));
+  RETURN nextval('sync_change_seq');
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION sync_row_changed() RETURNS trigger AS $$
+BEGIN
+  NEW.change_seq := next_sync_change_seq(NEW.user_id);
+  NEW.updated_at := NOW();
+  NEW.version := OLD.version + 1;
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION sync_row_inserted() RETURNS trigger AS $$
+BEGIN
+  NEW.change_seq := next_sync_change_seq(NEW.user_id);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER accounts_sync_inserted BEFORE INSERT ON accounts FOR EACH ROW EXECUTE PROCEDURE sync_row_inserted();
+CREATE TRIGGER categories_sync_inserted BEFORE INSERT ON categories FOR EACH ROW EXECUTE PROCEDURE sync_row_inserted();
+CREATE TRIGGER merchants_sync_inserted BEFORE INSERT ON merchants FOR EACH ROW EXECUTE PROCEDURE sync_row_inserted();
+CREATE TRIGGER transactions_sync_inserted BEFORE INSERT ON transactions FOR EACH ROW EXECUTE PROCEDURE sync_row_inserted();
+CREATE TRIGGER sync_deletions_inserted BEFORE INSERT ON sync_deletions FOR EACH ROW EXECUTE PROCEDURE sync_row_inserted();
\ No newline at end of file
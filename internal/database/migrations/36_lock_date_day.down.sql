@@ -0,0 +1 @@
+ALTER TABLE users ALTER COLUMN lock_date TYPE TIMESTAMP;
\ No newline at end of file
@@ -0,0 +1,2 @@
+-- Lock date closes whole day, transactions dated later on that day belong to closed period too
+ALTER TABLE users ALTER COLUMN lock_date TYPE DATE;
\ No newline at end of file
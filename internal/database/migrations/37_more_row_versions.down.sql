@@ -0,0 +1,11 @@
+DROP TRIGGER reconciliations_version ON reconciliations;
+DROP TRIGGER securities_version ON securities;
+DROP TRIGGER investment_trades_version ON investment_trades;
+DROP TRIGGER notification_preferences_version ON notification_preferences;
+DROP TRIGGER users_version ON users;
+
+ALTER TABLE reconciliations DROP COLUMN updated_at, DROP COLUMN version;
+ALTER TABLE securities DROP COLUMN updated_at, DROP COLUMN version;
+ALTER TABLE investment_trades DROP COLUMN updated_at, DROP COLUMN version;
+ALTER TABLE notification_preferences DROP COLUMN version;
+ALTER TABLE users DROP COLUMN updated_at, DROP COLUMN version;
\ No newline at end of file
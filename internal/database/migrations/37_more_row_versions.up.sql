@@ -0,0 +1,27 @@
+-- versions of resources which were left unversioned by 30_row_versions
+ALTER TABLE users
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE notification_preferences
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE investment_trades
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE securities
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE reconciliations
+  ADD COLUMN updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+  ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+
+-- token version and last TOTP counter change on every login and logout, they are not part of user resource
+CREATE TRIGGER users_version BEFORE UPDATE OF email, password_hash, email_verified_at, totp_secret, totp_enabled_at, lock_date, deleted_at
+  ON users FOR EACH ROW EXECUTE PROCEDURE row_version_changed();
+CREATE TRIGGER notification_preferences_version BEFORE UPDATE ON notification_preferences FOR EACH ROW EXECUTE PROCEDURE row_version_changed();
+CREATE TRIGGER investment_trades_version BEFORE UPDATE ON investment_trades FOR EACH ROW EXECUTE PROCEDURE row_version_changed();
+CREATE TRIGGER securities_version BEFORE UPDATE ON securities FOR EACH ROW EXECUTE PROCEDURE row_version_changed();
+CREATE TRIGGER reconciliations_version BEFORE UPDATE ON reconciliations FOR EACH ROW EXECUTE PROCEDURE row_version_changed();
\ No newline at end of file
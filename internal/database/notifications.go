@@ -0,0 +1,253 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const defaultNotificationLimit = 50
+const maxNotificationLimit = 500
+
+type NotificationDB interface {
+	CreateNotification(ctx context.Context, notification *models.Notification, deliveries []*models.NotificationDelivery) (bool, error)
+	GetNotificationByID(ctx context.Context, notificationID models.NotificationID) (*models.Notification, error)
+	ListNotifications(ctx context.Context, userID models.UserID, unread bool, limit int) ([]*models.Notification, error)
+	MarkNotificationRead(ctx context.Context, userID models.UserID, notificationID models.NotificationID) (bool, error)
+	MarkAllNotificationsRead(ctx context.Context, userID models.UserID) (bool, error)
+	MarkNotificationDelivered(ctx context.Context, notificationID models.NotificationID) error
+	ListNotificationPreferences(ctx context.Context, userID models.UserID) ([]*models.NotificationPreference, error)
+	SetNotificationPreference(ctx context.Context, preference *models.NotificationPreference) error
+	ClaimNotificationDeliveries(ctx context.Context, now, lease time.Time, limit int) ([]*models.NotificationDelivery, error)
+	UpdateNotificationDelivery(ctx context.Context, delivery *models.NotificationDelivery) error
+}
+
+// * Notification which was already created for the same occurrence is skipped
+const createNotificationQuery = `
+	INSERT INTO notifications (user_id, event_type, title, body, resource_id, dedupe_key)
+	VALUES (:user_id, :event_type, :title, :body, :resource_id, :dedupe_key)
+	ON CONFLICT (user_id, event_type, dedupe_key) DO NOTHING
+	RETURNING notification_id, created_at;
+`
+
+const createNotificationDeliveryQuery = `
+	INSERT INTO notification_deliveries (notification_id, user_id, channel, target)
+	VALUES (:notification_id, :user_id, :channel, :target)
+	RETURNING delivery_id;
+`
+
+// CreateNotification stores notification with its deliveries. It returns false if notification is duplicate.
+func (d *database) CreateNotification(ctx context.Context, notification *models.Notification, deliveries []*models.NotificationDelivery) (bool, error) {
+	created := false
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		rows, err := sqlx.NamedQueryContext(ctx, tx, createNotificationQuery, notification)
+		if err != nil {
+			return errors.Wrap(err, "could not create notification")
+		}
+		if created = rows.Next(); created {
+			err = rows.Scan(&notification.ID, &notification.CreatedAt)
+		}
+		rows.Close()
+		if err != nil {
+			return errors.Wrap(err, "could not get created notification")
+		}
+		if !created {
+			return nil
+		}
+
+		for _, delivery := range deliveries {
+			delivery.NotificationID = notification.ID
+			delivery.UserID = notification.UserID
+			delivery.Status = models.DeliveryPending
+
+			rows, err := sqlx.NamedQueryContext(ctx, tx, createNotificationDeliveryQuery, delivery)
+			if err != nil {
+				return errors.Wrap(err, "could not create notification delivery")
+			}
+			rows.Next()
+			err = rows.Scan(&delivery.ID)
+			rows.Close()
+			if err != nil {
+				return errors.Wrap(err, "could not get created notification delivery")
+			}
+		}
+		return nil
+	})
+
+	return created, err
+}
+
+const getNotificationByIDQuery = `
+	SELECT notification_id, user_id, event_type, title, body, resource_id, dedupe_key, delivered_at, read_at, created_at
+	FROM notifications
+	WHERE notification_id = $1;
+`
+
+func (d *database) GetNotificationByID(ctx context.Context, notificationID models.NotificationID) (*models.Notification, error) {
+	var notification models.Notification
+	if err := d.conn.GetContext(ctx, &notification, getNotificationByIDQuery, notificationID); err != nil {
+		return nil, errors.Wrap(err, "could not get notification")
+	}
+
+	return &notification, nil
+}
+
+const listNotificationsQuery = `
+	SELECT notification_id, user_id, event_type, title, body, resource_id, dedupe_key, delivered_at, read_at, created_at
+	FROM notifications
+	WHERE user_id = $1 AND delivered_at IS NOT NULL AND (NOT $2 OR read_at IS NULL)
+	ORDER BY created_at DESC
+	LIMIT $3;
+`
+
+// ListNotifications returns inbox of user, newest first
+func (d *database) ListNotifications(ctx context.Context, userID models.UserID, unread bool, limit int) ([]*models.Notification, error) {
+	if limit <= 0 {
+		limit = defaultNotificationLimit
+	}
+	if limit > maxNotificationLimit {
+		limit = maxNotificationLimit
+	}
+
+	var notifications []*models.Notification
+	if err := d.conn.SelectContext(ctx, &notifications, listNotificationsQuery, userID, unread, limit); err != nil {
+		return nil, errors.Wrap(err, "could not get user's notifications")
+	}
+
+	return notifications, nil
+}
+
+const markNotificationReadQuery = `
+	UPDATE notifications
+	SET read_at = NOW()
+	WHERE user_id = $1 AND notification_id = $2 AND read_at IS NULL;
+`
+
+func (d *database) MarkNotificationRead(ctx context.Context, userID models.UserID, notificationID models.NotificationID) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, markNotificationReadQuery, userID, notificationID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+const markAllNotificationsReadQuery = `
+	UPDATE notifications
+	SET read_at = NOW()
+	WHERE user_id = $1 AND delivered_at IS NOT NULL AND read_at IS NULL;
+`
+
+func (d *database) MarkAllNotificationsRead(ctx context.Context, userID models.UserID) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, markAllNotificationsReadQuery, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+const markNotificationDeliveredQuery = `
+	UPDATE notifications
+	SET delivered_at = COALESCE(delivered_at, NOW())
+	WHERE notification_id = $1;
+`
+
+func (d *database) MarkNotificationDelivered(ctx context.Context, notificationID models.NotificationID) error {
+	_, err := d.conn.ExecContext(ctx, markNotificationDeliveredQuery, notificationID)
+	return errors.Wrap(err, "could not mark notification delivered")
+}
+
+const listNotificationPreferencesQuery = `
+	SELECT user_id, event_type, in_app, email, webhook_url, webhook_secret, threshold, updated_at, version
+	FROM notification_preferences
+	WHERE user_id = $1;
+`
+
+// ListNotificationPreferences returns only preferences user has chosen, other event types use defaults
+func (d *database) ListNotificationPreferences(ctx context.Context, userID models.UserID) ([]*models.NotificationPreference, error) {
+	var preferences []*models.NotificationPreference
+	if err := d.conn.SelectContext(ctx, &preferences, listNotificationPreferencesQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "could not get user's notification preferences")
+	}
+
+	return preferences, nil
+}
+
+// * Stored preference is updated only at version caller has read. Default preference has version 0,
+// so if another client has stored it meanwhile, nothing is updated.
+const setNotificationPreferenceQuery = `
+	INSERT INTO notification_preferences (user_id, event_type, in_app, email, webhook_url, webhook_secret, threshold)
+	VALUES (:user_id, :event_type, :in_app, :email, :webhook_url, :webhook_secret, :threshold)
+	ON CONFLICT (user_id, event_type) DO UPDATE
+	SET in_app = EXCLUDED.in_app,
+	    email = EXCLUDED.email,
+	    webhook_url = EXCLUDED.webhook_url,
+	    webhook_secret = EXCLUDED.webhook_secret,
+	    threshold = EXCLUDED.threshold
+	WHERE notification_preferences.version = :version
+	RETURNING updated_at, version;
+`
+
+// SetNotificationPreference stores preference only if it is still at version, which caller has read
+func (d *database) SetNotificationPreference(ctx context.Context, preference *models.NotificationPreference) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, setNotificationPreferenceQuery, preference)
+	if err != nil {
+		return errors.Wrap(err, "could not set notification preference")
+	}
+
+	return scanVersion(rows, &preference.UpdatedAt, &preference.Version)
+}
+
+// * Claimed deliveries are hidden from other workers until lease, so delivery of crashed worker is retried after it
+const claimNotificationDeliveriesQuery = `
+	UPDATE notification_deliveries
+	SET next_attempt_at = $2
+	WHERE delivery_id IN (
+		SELECT delivery_id
+		FROM notification_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING delivery_id, notification_id, user_id, channel, target, status, attempts, last_error, next_attempt_at, sent_at, created_at;
+`
+
+// ClaimNotificationDeliveries returns pending deliveries which are due at now
+func (d *database) ClaimNotificationDeliveries(ctx context.Context, now, lease time.Time, limit int) ([]*models.NotificationDelivery, error) {
+	var deliveries []*models.NotificationDelivery
+	if err := d.conn.SelectContext(ctx, &deliveries, claimNotificationDeliveriesQuery, now, lease, limit); err != nil {
+		return nil, errors.Wrap(err, "could not claim notification deliveries")
+	}
+
+	return deliveries, nil
+}
+
+const updateNotificationDeliveryQuery = `
+	UPDATE notification_deliveries
+	SET status = :status,
+	    attempts = :attempts,
+	    last_error = :last_error,
+	    next_attempt_at = :next_attempt_at,
+	    sent_at = :sent_at
+	WHERE delivery_id = :delivery_id;
+`
+
+func (d *database) UpdateNotificationDelivery(ctx context.Context, delivery *models.NotificationDelivery) error {
+	_, err := d.conn.NamedExecContext(ctx, updateNotificationDeliveryQuery, delivery)
+	return errors.Wrap(err, "could not update notification delivery")
+}
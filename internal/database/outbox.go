@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"finance/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const createOutboxEventQuery = `
+	INSERT INTO outbox_events (user_id, event_type, resource_id, payload)
+	VALUES ($1, $2, $3, $4);
+`
+
+// emitEvent stores event in outbox inside of transaction which changes resource, so event exists only if change is commited
+func emitEvent(ctx context.Context, tx *sqlx.Tx, userID models.UserID, eventType models.WebhookEventType, resourceID string, resource interface{}) error {
+	payload, err := json.Marshal(resource)
+	if err != nil {
+		return errors.Wrap(err, "could not encode outbox event")
+	}
+
+	if _, err := tx.ExecContext(ctx, createOutboxEventQuery, userID, eventType, resourceID, payload); err != nil {
+		return errors.Wrap(err, "could not store outbox event")
+	}
+	return nil
+}
+
+// emitTransactionEvent - event with transaction as it is after change
+func emitTransactionEvent(ctx context.Context, tx *sqlx.Tx, eventType models.WebhookEventType, transactionID models.TransactionID) error {
+	var transaction models.Transaction
+	if err := tx.GetContext(ctx, &transaction, getTransactionByIDQuery, transactionID); err != nil {
+		return errors.Wrap(err, "could not get transaction")
+	}
+	return emitEvent(ctx, tx, *transaction.UserID, eventType, string(transaction.ID), &transaction)
+}
+
+// emitTransactionEvents - event of every transaction changed together with its account, so consumers don't have to expand it
+func emitTransactionEvents(ctx context.Context, tx *sqlx.Tx, eventType models.WebhookEventType, transactionIDs []models.TransactionID) error {
+	for _, transactionID := range transactionIDs {
+		if err := emitTransactionEvent(ctx, tx, eventType, transactionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// emitAccountEvent - event with account as it is after change
+func emitAccountEvent(ctx context.Context, tx *sqlx.Tx, eventType models.WebhookEventType, accountID models.AccountID) error {
+	var account models.Account
+	if err := tx.GetContext(ctx, &account, getAccountByIDQuery, accountID); err != nil {
+		return errors.Wrap(err, "could not get account")
+	}
+	return emitEvent(ctx, tx, *account.UserID, eventType, string(account.ID), &account)
+}
+
+// emitCategoryEvent - event with category as it is after change
+func emitCategoryEvent(ctx context.Context, tx *sqlx.Tx, eventType models.WebhookEventType, categoryID models.CategoryID) error {
+	var category models.Category
+	if err := tx.GetContext(ctx, &category, getCategoryByIDQuery, categoryID); err != nil {
+		return errors.Wrap(err, "could not get category")
+	}
+	return emitEvent(ctx, tx, *category.UserID, eventType, string(category.ID), &category)
+}
@@ -0,0 +1,207 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrReconciliationOpen       = errors.New("account has open reconciliation")
+	ErrReconciliationFinalized  = errors.New("reconciliation is finalized")
+	ErrReconciliationUnbalanced = errors.New("cleared balance doesn't match statement")
+	ErrAccountReconciled        = errors.New("account has reconciled transactions")
+	ErrAccountLocked            = errors.New("account has transactions in closed period")
+)
+
+type ReconciliationDB interface {
+	CreateReconciliation(ctx context.Context, reconciliation *models.Reconciliation) error
+	GetReconciliationByID(ctx context.Context, reconciliationID models.ReconciliationID) (*models.Reconciliation, error)
+	ListReconciliationsByAccountID(ctx context.Context, accountID models.AccountID) ([]*models.Reconciliation, error)
+	GetReconciliationSummary(ctx context.Context, reconciliation *models.Reconciliation) (*models.ReconciliationSummary, error)
+	FinalizeReconciliation(ctx context.Context, reconciliationID models.ReconciliationID, version int64) error
+	DeleteReconciliation(ctx context.Context, reconciliationID models.ReconciliationID, version int64) (bool, error)
+}
+
+const createReconciliationQuery = `
+	INSERT INTO reconciliations (user_id, account_id, statement_date, ending_balance)
+	VALUES (:user_id, :account_id, :statement_date, :ending_balance)
+	RETURNING reconciliation_id, created_at;
+`
+
+func (d *database) CreateReconciliation(ctx context.Context, reconciliation *models.Reconciliation) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createReconciliationQuery, reconciliation)
+	if pqError, ok := err.(*pq.Error); ok && pqError.Code.Name() == UniqueViolation {
+		return ErrReconciliationOpen
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not create reconciliation")
+	}
+
+	defer rows.Close()
+	rows.Next()
+	if err := rows.Scan(&reconciliation.ID, &reconciliation.CreatedAt); err != nil {
+		return errors.Wrap(err, "could not get created reconciliation")
+	}
+
+	return nil
+}
+
+const getReconciliationByIDQuery = `
+	SELECT reconciliation_id, user_id, account_id, statement_date, ending_balance, created_at, finalized_at, updated_at, version
+	FROM reconciliations
+	WHERE reconciliation_id = $1;
+`
+
+func (d *database) GetReconciliationByID(ctx context.Context, reconciliationID models.ReconciliationID) (*models.Reconciliation, error) {
+	var reconciliation models.Reconciliation
+	if err := d.conn.GetContext(ctx, &reconciliation, getReconciliationByIDQuery, reconciliationID); err != nil {
+		return nil, errors.Wrap(err, "could not get reconciliation")
+	}
+	return &reconciliation, nil
+}
+
+const listReconciliationsByAccountIDQuery = `
+	SELECT reconciliation_id, user_id, account_id, statement_date, ending_balance, created_at, finalized_at, updated_at, version
+	FROM reconciliations
+	WHERE account_id = $1
+	ORDER BY statement_date DESC;
+`
+
+func (d *database) ListReconciliationsByAccountID(ctx context.Context, accountID models.AccountID) ([]*models.Reconciliation, error) {
+	var reconciliations []*models.Reconciliation
+	if err := d.conn.SelectContext(ctx, &reconciliations, listReconciliationsByAccountIDQuery, accountID); err != nil {
+		return nil, errors.Wrap(err, "could not get account's reconciliations")
+	}
+	return reconciliations, nil
+}
+
+// * Balance of account at statement date counting only transactions confirmed by bank
+const clearedBalanceQuery = `
+	SELECT a.start_balance + COALESCE(SUM(CASE WHEN t.transaction_type = 'income' THEN t.amount ELSE -t.amount END), 0)
+	FROM accounts a
+	LEFT JOIN transactions t ON t.account_id = a.account_id
+	     AND t.deleted_at IS NULL
+	     AND (t.cleared OR t.reconciled_at IS NOT NULL)
+	     AND t.transaction_date <= $2
+	WHERE a.account_id = $1
+	GROUP BY a.start_balance;
+`
+
+const listUnreconciledTransactionsQuery = `
+	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes, cleared, reconciliation_id, reconciled_at, debt_id, updated_at, version
+	FROM transactions
+	WHERE account_id = $1
+	      AND deleted_at IS NULL
+	      AND reconciled_at IS NULL
+	      AND transaction_date <= $2
+	ORDER BY transaction_date;
+`
+
+func (d *database) GetReconciliationSummary(ctx context.Context, reconciliation *models.Reconciliation) (*models.ReconciliationSummary, error) {
+	summary := models.ReconciliationSummary{
+		Reconciliation: reconciliation,
+	}
+
+	if err := d.conn.GetContext(ctx, &summary.ClearedBalance, clearedBalanceQuery, reconciliation.AccountID, reconciliation.StatementDate); err != nil {
+		return nil, errors.Wrap(err, "could not get cleared balance")
+	}
+	summary.Difference = *reconciliation.EndingBalance - summary.ClearedBalance
+
+	if err := d.conn.SelectContext(ctx, &summary.Transactions, listUnreconciledTransactionsQuery, reconciliation.AccountID, reconciliation.StatementDate); err != nil {
+		return nil, errors.Wrap(err, "could not get unreconciled transactions")
+	}
+
+	return &summary, nil
+}
+
+const lockReconciliationQuery = `
+	SELECT reconciliation_id, user_id, account_id, statement_date, ending_balance, created_at, finalized_at, updated_at, version
+	FROM reconciliations
+	WHERE reconciliation_id = $1
+	FOR UPDATE;
+`
+
+const reconcileTransactionsQuery = `
+	UPDATE transactions
+	SET reconciliation_id = $1, reconciled_at = NOW()
+	WHERE account_id = $2
+	      AND deleted_at IS NULL
+	      AND cleared
+	      AND reconciled_at IS NULL
+	      AND transaction_date <= $3;
+`
+
+const finalizeReconciliationQuery = `
+	UPDATE reconciliations
+	SET finalized_at = NOW()
+	WHERE reconciliation_id = $1;
+`
+
+// FinalizeReconciliation locks cleared transactions. Balance is checked in the same transaction, so it can't change in between.
+// Reconciliation must still be at version, which caller has read.
+func (d *database) FinalizeReconciliation(ctx context.Context, reconciliationID models.ReconciliationID, version int64) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		var reconciliation models.Reconciliation
+		if err := tx.GetContext(ctx, &reconciliation, lockReconciliationQuery, reconciliationID); err != nil {
+			return errors.Wrap(err, "could not get reconciliation")
+		}
+		if reconciliation.FinalizedAt != nil {
+			return ErrReconciliationFinalized
+		}
+		if reconciliation.Version != version {
+			return ErrVersionConflict
+		}
+
+		// * Transactions of account are locked, so nobody clears or edits them until we are done
+		if _, err := tx.ExecContext(ctx, `SELECT 1 FROM transactions WHERE account_id = $1 FOR UPDATE;`, reconciliation.AccountID); err != nil {
+			return errors.Wrap(err, "could not lock transactions")
+		}
+
+		var clearedBalance int64
+		if err := tx.GetContext(ctx, &clearedBalance, clearedBalanceQuery, reconciliation.AccountID, reconciliation.StatementDate); err != nil {
+			return errors.Wrap(err, "could not get cleared balance")
+		}
+		if clearedBalance != *reconciliation.EndingBalance {
+			return ErrReconciliationUnbalanced
+		}
+
+		if _, err := tx.ExecContext(ctx, reconcileTransactionsQuery, reconciliationID, reconciliation.AccountID, reconciliation.StatementDate); err != nil {
+			return errors.Wrap(err, "could not reconcile transactions")
+		}
+
+		if _, err := tx.ExecContext(ctx, finalizeReconciliationQuery, reconciliationID); err != nil {
+			return errors.Wrap(err, "could not finalize reconciliation")
+		}
+		return nil
+	})
+}
+
+// * Only open reconciliation can be canceled, finalized one keeps transactions locked
+const deleteReconciliationQuery = `
+	DELETE FROM reconciliations
+	WHERE reconciliation_id = $1 AND finalized_at IS NULL AND version = $2;
+`
+
+const reconciliationLiveQuery = `SELECT EXISTS (SELECT 1 FROM reconciliations WHERE reconciliation_id = $1 AND finalized_at IS NULL);`
+
+// DeleteReconciliation deletes open reconciliation if it is still at version, which caller has read
+func (d *database) DeleteReconciliation(ctx context.Context, reconciliationID models.ReconciliationID, version int64) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, deleteReconciliationQuery, reconciliationID, version)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, checkDeleted(ctx, d.conn, reconciliationLiveQuery, reconciliationID)
+	}
+	return true, nil
+}
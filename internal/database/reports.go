@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type ReportDB interface {
+	ListAccountBalances(ctx context.Context, userID models.UserID, until time.Time) ([]*models.AccountBalance, error)
+}
+
+const listAccountBalancesQuery = `
+	SELECT a.account_id, a.account_name, a.account_type, a.currency,
+	       a.start_balance + COALESCE(SUM(CASE WHEN t.transaction_type = 'income' THEN t.amount ELSE -t.amount END), 0) AS balance
+	FROM accounts a
+	LEFT JOIN transactions t ON t.account_id = a.account_id
+	     AND t.deleted_at IS NULL
+	     AND t.transaction_date < $2
+	WHERE a.user_id = $1 AND a.deleted_at IS NULL
+	GROUP BY a.account_id
+	ORDER BY a.currency, a.account_name;
+`
+
+func (d *database) ListAccountBalances(ctx context.Context, userID models.UserID, until time.Time) ([]*models.AccountBalance, error) {
+	var balances []*models.AccountBalance
+	if err := d.conn.SelectContext(ctx, &balances, listAccountBalancesQuery, userID, until); err != nil {
+		return nil, errors.Wrap(err, "could not get account balances")
+	}
+
+	return balances, nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"finance/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrSecurityExists  = errors.New("security with this symbol already exists")
+	ErrUnknownSecurity = errors.New("unknown security")
+)
+
+type SecurityDB interface {
+	CreateSecurity(ctx context.Context, security *models.Security) error
+	GetSecurityByID(ctx context.Context, securityID models.SecurityID) (*models.Security, error)
+	ListSecurities(ctx context.Context) ([]*models.Security, error)
+	ImportSecurityPrices(ctx context.Context, prices []*models.SecurityPrice) error
+	ListSecurityPrices(ctx context.Context, securityID models.SecurityID) ([]*models.SecurityPrice, error)
+	ListLatestPricesByAccountID(ctx context.Context, accountID models.AccountID) ([]*models.SecurityPrice, error)
+}
+
+const createSecurityQuery = `
+	INSERT INTO securities (symbol, name, security_type, currency)
+	VALUES (:symbol, :name, :security_type, :currency)
+	RETURNING security_id;
+`
+
+func (d *database) CreateSecurity(ctx context.Context, security *models.Security) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createSecurityQuery, security)
+	if pqError, ok := err.(*pq.Error); ok && pqError.Code.Name() == UniqueViolation {
+		return ErrSecurityExists
+	}
+	if err != nil {
+		return errors.Wrap(err, "could not create security")
+	}
+
+	defer rows.Close()
+	rows.Next()
+	if err := rows.Scan(&security.ID); err != nil {
+		return errors.Wrap(err, "could not get created security")
+	}
+
+	return nil
+}
+
+const getSecurityByIDQuery = `
+	SELECT security_id, symbol, name, security_type, currency, created_at, updated_at, version
+	FROM securities
+	WHERE security_id = $1;
+`
+
+func (d *database) GetSecurityByID(ctx context.Context, securityID models.SecurityID) (*models.Security, error) {
+	var security models.Security
+	if err := d.conn.GetContext(ctx, &security, getSecurityByIDQuery, securityID); err != nil {
+		return nil, errors.Wrap(err, "could not get security")
+	}
+
+	return &security, nil
+}
+
+const listSecuritiesQuery = `
+	SELECT security_id, symbol, name, security_type, currency, created_at, updated_at, version
+	FROM securities
+	ORDER BY symbol;
+`
+
+func (d *database) ListSecurities(ctx context.Context) ([]*models.Security, error) {
+	var securities []*models.Security
+	if err := d.conn.SelectContext(ctx, &securities, listSecuritiesQuery); err != nil {
+		return nil, errors.Wrap(err, "could not get securities")
+	}
+
+	return securities, nil
+}
+
+const getSecurityIDBySymbolQuery = `
+	SELECT security_id FROM securities WHERE symbol = $1;
+`
+
+// * Imported price replaces price of the same date
+const importSecurityPriceQuery = `
+	INSERT INTO security_prices (security_id, price_date, price)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (security_id, price_date) DO UPDATE
+		SET price = EXCLUDED.price, created_at = NOW();
+`
+
+// ImportSecurityPrices stores all prices or none of them
+func (d *database) ImportSecurityPrices(ctx context.Context, prices []*models.SecurityPrice) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		ids := make(map[string]models.SecurityID)
+		for _, price := range prices {
+			if price.SecurityID == models.NilSecurityID {
+				id, ok := ids[price.Symbol]
+				if !ok {
+					err := tx.GetContext(ctx, &id, getSecurityIDBySymbolQuery, price.Symbol)
+					if err == sql.ErrNoRows {
+						return errors.Wrap(ErrUnknownSecurity, price.Symbol)
+					}
+					if err != nil {
+						return errors.Wrap(err, "could not get security")
+					}
+					ids[price.Symbol] = id
+				}
+				price.SecurityID = id
+			}
+
+			if _, err := tx.ExecContext(ctx, importSecurityPriceQuery, price.SecurityID, price.Date, price.Price); err != nil {
+				return errors.Wrap(err, "could not import security price")
+			}
+		}
+		return nil
+	})
+}
+
+const listSecurityPricesQuery = `
+	SELECT security_id, price_date, price
+	FROM security_prices
+	WHERE security_id = $1
+	ORDER BY price_date DESC;
+`
+
+func (d *database) ListSecurityPrices(ctx context.Context, securityID models.SecurityID) ([]*models.SecurityPrice, error) {
+	var prices []*models.SecurityPrice
+	if err := d.conn.SelectContext(ctx, &prices, listSecurityPricesQuery, securityID); err != nil {
+		return nil, errors.Wrap(err, "could not get security prices")
+	}
+
+	return prices, nil
+}
+
+const listLatestPricesByAccountIDQuery = `
+	SELECT DISTINCT ON (p.security_id) p.security_id, p.price_date, p.price
+	FROM security_prices p
+	WHERE p.security_id IN (SELECT security_id FROM investment_trades WHERE account_id = $1 AND deleted_at IS NULL)
+	ORDER BY p.security_id, p.price_date DESC;
+`
+
+func (d *database) ListLatestPricesByAccountID(ctx context.Context, accountID models.AccountID) ([]*models.SecurityPrice, error) {
+	var prices []*models.SecurityPrice
+	if err := d.conn.SelectContext(ctx, &prices, listLatestPricesByAccountIDQuery, accountID); err != nil {
+		return nil, errors.Wrap(err, "could not get latest security prices")
+	}
+
+	return prices, nil
+}
@@ -3,42 +3,231 @@ package database
 import (
 	"context"
 	"finance/internal/models"
-	"finance/internal/utils"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
 )
 
 type SessionsDB interface {
-	SaveRefreshToken(ctx context.Context, session models.Session) error
-	GetSession(ctx context.Context, session models.Session) (*models.Session, error)
+	SaveRefreshToken(ctx context.Context, session models.Session, tokenHash string) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	UseRefreshToken(ctx context.Context, tokenHash string) (bool, error)
+	RevokeTokenFamily(ctx context.Context, familyID models.FamilyID) error
+	ListSessions(ctx context.Context, userID models.UserID) ([]*models.Session, error)
+	RevokeSession(ctx context.Context, userID models.UserID, deviceID models.DeviceID) (bool, error)
+	RevokeAllSessions(ctx context.Context, userID models.UserID) error
+	IsSessionActive(ctx context.Context, userID models.UserID, familyID models.FamilyID) (bool, error)
 }
 
+// * Session is started again if device logged in with new token family
 const insertOrUpdateSession = `
-	INSERT INTO sessions (user_id, device_id, refresh_token, expires_at)
-	VALUES(:user_id, :device_id, :refresh_token, :expires_at)
+	INSERT INTO sessions (user_id, device_id, family_id, expires_at, ip_address, user_agent)
+	VALUES(:user_id, :device_id, :family_id, :expires_at, :ip_address, :user_agent)
 
 	ON CONFLICT (user_id, device_id)
 	DO 
 		UPDATE
-			SET refresh_token = :refresh_token,
-					expires_at = :expires_at;
+			SET family_id = :family_id,
+					expires_at = :expires_at,
+					ip_address = :ip_address,
+					user_agent = :user_agent,
+					last_used_at = NOW(),
+					created_at = CASE WHEN sessions.family_id = :family_id THEN sessions.created_at ELSE NOW() END;
+`
+
+// * If device logged in again we don't want old token family to be alive
+const revokeOtherFamiliesQuery = `
+	UPDATE refresh_tokens
+	SET revoked_at = NOW()
+	WHERE user_id = :user_id
+	      AND device_id = :device_id
+	      AND family_id <> :family_id
+	      AND revoked_at IS NULL;
+`
+
+const insertRefreshTokenQuery = `
+	INSERT INTO refresh_tokens (token_hash, family_id, user_id, device_id, expires_at)
+	VALUES (:token_hash, :family_id, :user_id, :device_id, :expires_at);
+`
+
+func (d *database) SaveRefreshToken(ctx context.Context, session models.Session, tokenHash string) error {
+	token := models.RefreshToken{
+		TokenHash: tokenHash,
+		FamilyID:  session.FamilyID,
+		UserID:    session.UserID,
+		DeviceID:  session.DeviceID,
+		ExpiresAt: session.ExpiresAt,
+	}
+
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.NamedExecContext(ctx, insertOrUpdateSession, session); err != nil {
+			return errors.Wrap(err, "could not save session")
+		}
+		if _, err := tx.NamedExecContext(ctx, revokeOtherFamiliesQuery, token); err != nil {
+			return errors.Wrap(err, "could not revoke old refresh tokens")
+		}
+		if _, err := tx.NamedExecContext(ctx, insertRefreshTokenQuery, token); err != nil {
+			return errors.Wrap(err, "could not save refresh token")
+		}
+		return nil
+	})
+}
+
+const getRefreshTokenQuery = `
+	SELECT token_hash, family_id, user_id, device_id, created_at, expires_at, used_at, revoked_at
+	FROM refresh_tokens
+	WHERE token_hash = $1;
+`
+
+func (d *database) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := d.conn.GetContext(ctx, &token, getRefreshTokenQuery, tokenHash); err != nil {
+		return nil, errors.Wrap(err, "could not get refresh token")
+	}
+
+	return &token, nil
+}
+
+// * Token can be used only once. If two requests use the same token only one of them will update the row.
+const useRefreshTokenQuery = `
+	UPDATE refresh_tokens
+	SET used_at = NOW()
+	WHERE token_hash = $1 AND used_at IS NULL AND revoked_at IS NULL;
+`
+
+func (d *database) UseRefreshToken(ctx context.Context, tokenHash string) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, useRefreshTokenQuery, tokenHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+const revokeTokenFamilyQuery = `
+	UPDATE refresh_tokens
+	SET revoked_at = NOW()
+	WHERE family_id = $1 AND revoked_at IS NULL;
+`
+
+const deleteSessionByFamilyQuery = `
+	DELETE FROM sessions
+	WHERE family_id = $1;
 `
-func (d *database) SaveRefreshToken(ctx context.Context, session models.Session) error {
-	_, err := d.conn.NamedQueryContext(ctx, insertOrUpdateSession, session)
-	return utils.CheckError(err)
+
+func (d *database) RevokeTokenFamily(ctx context.Context, familyID models.FamilyID) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, revokeTokenFamilyQuery, familyID); err != nil {
+			return errors.Wrap(err, "could not revoke token family")
+		}
+		if _, err := tx.ExecContext(ctx, deleteSessionByFamilyQuery, familyID); err != nil {
+			return errors.Wrap(err, "could not delete session")
+		}
+		return nil
+	})
 }
 
-const getSessionQuery = `
-	SELECT user_id, device_id, refresh_token, expires_at
+const listSessionsQuery = `
+	SELECT user_id, device_id, family_id, expires_at, created_at, last_used_at, ip_address, user_agent
 	FROM sessions
-	WHERE user_id = $1
-	      AND device_id = $2
-	      AND refresh_token = $3
-	      AND to_timestamp(expires_at) > NOW()
+	WHERE user_id = $1 AND to_timestamp(expires_at) > NOW()
+	ORDER BY last_used_at DESC;
 `
-func (d *database) GetSession(ctx context.Context, data models.Session) (*models.Session, error) {
-	var session models.Session
-	if err := d.conn.GetContext(ctx, &session, getSessionQuery, data.UserID, data.DeviceID, data.RefreshToken); err != nil {
-		return nil, err
+
+func (d *database) ListSessions(ctx context.Context, userID models.UserID) ([]*models.Session, error) {
+	var sessions []*models.Session
+	if err := d.conn.SelectContext(ctx, &sessions, listSessionsQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "could not get user's sessions")
 	}
+	return sessions, nil
+}
+
+const revokeDeviceTokensQuery = `
+	UPDATE refresh_tokens
+	SET revoked_at = NOW()
+	WHERE user_id = $1 AND device_id = $2 AND revoked_at IS NULL;
+`
+
+const deleteSessionQuery = `
+	DELETE FROM sessions
+	WHERE user_id = $1 AND device_id = $2;
+`
 
-	return &session, nil
+// * Access tokens of the session die with it, because they are bound to its token family. Other devices are not affected.
+func (d *database) RevokeSession(ctx context.Context, userID models.UserID, deviceID models.DeviceID) (bool, error) {
+	var deleted bool
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, revokeDeviceTokensQuery, userID, deviceID); err != nil {
+			return errors.Wrap(err, "could not revoke refresh tokens")
+		}
+
+		result, err := tx.ExecContext(ctx, deleteSessionQuery, userID, deviceID)
+		if err != nil {
+			return errors.Wrap(err, "could not delete session")
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		deleted = rows > 0
+		return nil
+	})
+
+	return deleted, err
+}
+
+const revokeUserTokensQuery = `
+	UPDATE refresh_tokens
+	SET revoked_at = NOW()
+	WHERE user_id = $1 AND revoked_at IS NULL;
+`
+
+const deleteUserSessionsQuery = `
+	DELETE FROM sessions
+	WHERE user_id = $1;
+`
+
+func (d *database) RevokeAllSessions(ctx context.Context, userID models.UserID) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		return revokeAllSessions(ctx, tx, userID)
+	})
+}
+
+// * Used inside of transactions which change user's credentials
+func revokeAllSessions(ctx context.Context, tx *sqlx.Tx, userID models.UserID) error {
+	if _, err := tx.ExecContext(ctx, revokeUserTokensQuery, userID); err != nil {
+		return errors.Wrap(err, "could not revoke refresh tokens")
+	}
+	if _, err := tx.ExecContext(ctx, deleteUserSessionsQuery, userID); err != nil {
+		return errors.Wrap(err, "could not delete sessions")
+	}
+	if _, err := tx.ExecContext(ctx, incrementTokenVersionQuery, userID); err != nil {
+		return errors.Wrap(err, "could not increment token version")
+	}
+	return nil
+}
+
+const isSessionActiveQuery = `
+	SELECT EXISTS (
+		SELECT 1 FROM sessions
+		WHERE user_id = $1 AND family_id = $2 AND to_timestamp(expires_at) > NOW()
+	);
+`
+
+// IsSessionActive checks that session of access token wasn't revoked
+func (d *database) IsSessionActive(ctx context.Context, userID models.UserID, familyID models.FamilyID) (bool, error) {
+	if familyID == models.NilFamilyID {
+		return false, nil
+	}
+
+	var active bool
+	if err := d.conn.GetContext(ctx, &active, isSessionActiveQuery, userID, familyID); err != nil {
+		return false, errors.Wrap(err, "could not check session")
+	}
+	return active, nil
 }
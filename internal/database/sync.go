@@ -0,0 +1,358 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"finance/internal/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const defaultSyncLimit = 500
+const maxSyncLimit = 1000
+
+type SyncDB interface {
+	ListSyncChanges(ctx context.Context, userID models.UserID, since int64, limit int) (*models.SyncChanges, error)
+	PushAccount(ctx context.Context, account *models.Account) error
+	PushCategory(ctx context.Context, category *models.Category) error
+	PushMerchant(ctx context.Context, merchant *models.Merchant) error
+	PushTransaction(ctx context.Context, transaction *models.Transaction) error
+	PushDeletion(ctx context.Context, userID models.UserID, deletion *models.SyncDeletion, lockDate *time.Time) error
+}
+
+const listAccountChangesQuery = `
+	SELECT account_id, user_id, start_balance, account_type, account_name, currency, created_at, deleted_at,
+	       credit_limit, statement_day, payment_due_day, apr, interest_rate, loan_principal, loan_term, loan_start, updated_at, version, change_seq
+	FROM accounts
+	WHERE user_id = $1 AND change_seq > $2
+	ORDER BY change_seq
+	LIMIT $3;
+`
+
+const listCategoryChangesQuery = `
+	SELECT category_id, parent_id, user_id, name, created_at, deleted_at, updated_at, version, change_seq
+	FROM categories
+	WHERE user_id = $1 AND change_seq > $2
+	ORDER BY change_seq
+	LIMIT $3;
+`
+
+const listMerchantChangesQuery = `
+	SELECT merchant_id, user_id, name, created_at, deleted_at, updated_at, version, change_seq
+	FROM merchants
+	WHERE user_id = $1 AND change_seq > $2
+	ORDER BY change_seq
+	LIMIT $3;
+`
+
+const listTransactionChangesQuery = `
+	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes, cleared, reconciliation_id, reconciled_at, debt_id, updated_at, version, change_seq
+	FROM transactions
+	WHERE user_id = $1 AND change_seq > $2
+	ORDER BY change_seq
+	LIMIT $3;
+`
+
+const listSyncDeletionsQuery = `
+	SELECT resource_type, resource_id, change_seq
+	FROM sync_deletions
+	WHERE user_id = $1 AND change_seq > $2
+	ORDER BY change_seq
+	LIMIT $3;
+`
+
+// ListSyncChanges returns changes of user after change sequence since. All lists are read from one snapshot;
+// writers of user commit in order of change sequence (migration 35), so the snapshot never misses earlier change.
+func (d *database) ListSyncChanges(ctx context.Context, userID models.UserID, since int64, limit int) (*models.SyncChanges, error) {
+	if limit <= 0 {
+		limit = defaultSyncLimit
+	}
+	if limit > maxSyncLimit {
+		limit = maxSyncLimit
+	}
+
+	tx, err := d.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not begin transaction")
+	}
+	defer tx.Rollback()
+
+	var accounts []*models.Account
+	if err := tx.SelectContext(ctx, &accounts, listAccountChangesQuery, userID, since, limit); err != nil {
+		return nil, errors.Wrap(err, "could not get changed accounts")
+	}
+
+	var categories []*models.Category
+	if err := tx.SelectContext(ctx, &categories, listCategoryChangesQuery, userID, since, limit); err != nil {
+		return nil, errors.Wrap(err, "could not get changed categories")
+	}
+
+	var merchants []*models.Merchant
+	if err := tx.SelectContext(ctx, &merchants, listMerchantChangesQuery, userID, since, limit); err != nil {
+		return nil, errors.Wrap(err, "could not get changed merchants")
+	}
+
+	var transactions []*models.Transaction
+	if err := tx.SelectContext(ctx, &transactions, listTransactionChangesQuery, userID, since, limit); err != nil {
+		return nil, errors.Wrap(err, "could not get changed transactions")
+	}
+
+	var deletions []*models.SyncDeletion
+	if err := tx.SelectContext(ctx, &deletions, listSyncDeletionsQuery, userID, since, limit); err != nil {
+		return nil, errors.Wrap(err, "could not get deleted resources")
+	}
+
+	return models.NewSyncChanges(since, limit, accounts, categories, merchants, transactions, deletions), nil
+}
+
+// * Rows are locked, so version can't change between check and update
+const lockAccountVersionQuery = `
+	SELECT version FROM accounts WHERE account_id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE;
+`
+
+const lockCategoryVersionQuery = `
+	SELECT version FROM categories WHERE category_id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE;
+`
+
+const lockMerchantVersionQuery = `
+	SELECT version FROM merchants WHERE merchant_id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE;
+`
+
+const lockTransactionVersionQuery = `
+	SELECT version FROM transactions WHERE transaction_id = $1 AND user_id = $2 AND deleted_at IS NULL FOR UPDATE;
+`
+
+// checkVersion locks row of user and checks that it is still at version
+func checkVersion(ctx context.Context, tx *sqlx.Tx, query string, id string, userID models.UserID, version int64) error {
+	var current int64
+	if err := tx.GetContext(ctx, &current, query, id, userID); err == sql.ErrNoRows {
+		return ErrVersionConflict
+	} else if err != nil {
+		return errors.Wrap(err, "could not lock row")
+	}
+
+	if current != version {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// insertWithID runs insert of row with client generated ID, it returns ErrVersionConflict if ID exists
+func insertWithID(ctx context.Context, tx *sqlx.Tx, query string, arg interface{}) error {
+	rows, err := sqlx.NamedQueryContext(ctx, tx, query, arg)
+	if err != nil {
+		return err
+	}
+
+	inserted := rows.Next()
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !inserted {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+const pushAccountQuery = `
+	INSERT INTO accounts (account_id, user_id, start_balance, account_type, account_name, currency, credit_limit, statement_day, payment_due_day, apr,
+	                      interest_rate, loan_principal, loan_term, loan_start)
+	VALUES (:account_id, :user_id, :start_balance, :account_type, :account_name, :currency, :credit_limit, :statement_day, :payment_due_day, :apr,
+	        :interest_rate, :loan_principal, :loan_term, :loan_start)
+	ON CONFLICT (account_id) DO NOTHING
+	RETURNING account_id;
+`
+
+// PushAccount creates account with client generated ID if version is 0, otherwise it updates account if it is still at version.
+// Account is refreshed with values stored in database.
+func (d *database) PushAccount(ctx context.Context, account *models.Account) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		eventType := models.EventAccountUpdated
+		if account.Version == 0 {
+			eventType = models.EventAccountCreated
+			if err := insertWithID(ctx, tx, pushAccountQuery, account); err != nil {
+				return err
+			}
+		} else {
+			if err := checkVersion(ctx, tx, lockAccountVersionQuery, string(account.ID), *account.UserID, account.Version); err != nil {
+				return err
+			}
+			rows, err := sqlx.NamedQueryContext(ctx, tx, UpdateAccountQuery, account)
+			if err != nil {
+				return err
+			}
+			if err := scanVersion(rows, &account.UpdatedAt, &account.Version); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.GetContext(ctx, account, getAccountByIDQuery, account.ID); err != nil {
+			return errors.Wrap(err, "could not get account")
+		}
+		return emitAccountEvent(ctx, tx, eventType, account.ID)
+	})
+}
+
+const pushCategoryQuery = `
+	INSERT INTO categories (category_id, parent_id, user_id, name)
+	VALUES (:category_id, :parent_id, :user_id, :name)
+	ON CONFLICT (category_id) DO NOTHING
+	RETURNING category_id;
+`
+
+// PushCategory creates or updates category the same way as PushAccount
+func (d *database) PushCategory(ctx context.Context, category *models.Category) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		eventType := models.EventCategoryUpdated
+		if category.Version == 0 {
+			eventType = models.EventCategoryCreated
+			if err := insertWithID(ctx, tx, pushCategoryQuery, category); err != nil {
+				return err
+			}
+		} else {
+			if err := checkVersion(ctx, tx, lockCategoryVersionQuery, string(category.ID), *category.UserID, category.Version); err != nil {
+				return err
+			}
+			rows, err := sqlx.NamedQueryContext(ctx, tx, updateCategoryQuery, category)
+			if err != nil {
+				return err
+			}
+			if err := scanVersion(rows, &category.UpdatedAt, &category.Version); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.GetContext(ctx, category, getCategoryByIDQuery, category.ID); err != nil {
+			return errors.Wrap(err, "could not get category")
+		}
+		return emitCategoryEvent(ctx, tx, eventType, category.ID)
+	})
+}
+
+const pushMerchantQuery = `
+	INSERT INTO merchants (merchant_id, user_id, name)
+	VALUES (:merchant_id, :user_id, :name)
+	ON CONFLICT (merchant_id) DO NOTHING
+	RETURNING merchant_id;
+`
+
+// PushMerchant creates or updates merchant the same way as PushAccount
+func (d *database) PushMerchant(ctx context.Context, merchant *models.Merchant) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if merchant.Version == 0 {
+			if err := insertWithID(ctx, tx, pushMerchantQuery, merchant); err != nil {
+				return err
+			}
+		} else {
+			if err := checkVersion(ctx, tx, lockMerchantVersionQuery, string(merchant.ID), *merchant.UserID, merchant.Version); err != nil {
+				return err
+			}
+			rows, err := sqlx.NamedQueryContext(ctx, tx, updateMerchantQuery, merchant)
+			if err != nil {
+				return err
+			}
+			if err := scanVersion(rows, &merchant.UpdatedAt, &merchant.Version); err != nil {
+				return err
+			}
+		}
+
+		return errors.Wrap(tx.GetContext(ctx, merchant, getMerchantByIDQuery, merchant.ID), "could not get merchant")
+	})
+}
+
+const pushTransactionQuery = `
+	INSERT INTO transactions (transaction_id, user_id, account_id, category_id, transaction_date, transaction_type, amount, notes, debt_id)
+	VALUES (:transaction_id, :user_id, :account_id, :category_id, :transaction_date, :transaction_type, :amount, :notes, :debt_id)
+	ON CONFLICT (transaction_id) DO NOTHING
+	RETURNING transaction_id;
+`
+
+// PushTransaction creates or updates transaction the same way as PushAccount. Updated transaction keeps its history.
+func (d *database) PushTransaction(ctx context.Context, transaction *models.Transaction) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		eventType := models.EventTransactionUpdated
+		if transaction.Version == 0 {
+			eventType = models.EventTransactionCreated
+			if err := insertWithID(ctx, tx, pushTransactionQuery, transaction); err != nil {
+				return err
+			}
+		} else {
+			if err := checkVersion(ctx, tx, lockTransactionVersionQuery, string(transaction.ID), *transaction.UserID, transaction.Version); err != nil {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx, storeTransactionVersionQuery, transaction.ID); err != nil {
+				return errors.Wrap(err, "could not store transaction version")
+			}
+
+			rows, err := sqlx.NamedQueryContext(ctx, tx, updateTransactionQuery, transaction)
+			if err != nil {
+				return err
+			}
+			if err := scanVersion(rows, &transaction.UpdatedAt, &transaction.Version); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.GetContext(ctx, transaction, getTransactionByIDQuery, transaction.ID); err != nil {
+			return errors.Wrap(err, "could not get transaction")
+		}
+		return emitTransactionEvent(ctx, tx, eventType, transaction.ID)
+	})
+}
+
+// PushDeletion moves resource to trash if it is still at version. Dependent resources go to trash the same way as
+// when resource is deleted by REST API. lockDate is nil when caller may change closed period.
+func (d *database) PushDeletion(ctx context.Context, userID models.UserID, deletion *models.SyncDeletion, lockDate *time.Time) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		switch deletion.Type {
+		case models.SyncAccount:
+			if err := checkVersion(ctx, tx, lockAccountVersionQuery, deletion.ID, userID, deletion.Version); err != nil {
+				return err
+			}
+			if err := checkAccountDeletable(ctx, tx, models.AccountID(deletion.ID), lockDate); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, DeleteAccountQuery, deletion.ID, deletion.Version); err != nil {
+				return err
+			}
+			return emitAccountDeleted(ctx, tx, models.AccountID(deletion.ID))
+
+		case models.SyncCategory:
+			if err := checkVersion(ctx, tx, lockCategoryVersionQuery, deletion.ID, userID, deletion.Version); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, DeleteCategoryQuery, deletion.ID, deletion.Version); err != nil {
+				return err
+			}
+			return emitCategoryEvent(ctx, tx, models.EventCategoryDeleted, models.CategoryID(deletion.ID))
+
+		case models.SyncMerchant:
+			if err := checkVersion(ctx, tx, lockMerchantVersionQuery, deletion.ID, userID, deletion.Version); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, DeleteMerchantQuery, deletion.ID, deletion.Version)
+			return err
+
+		case models.SyncTransaction:
+			if err := checkVersion(ctx, tx, lockTransactionVersionQuery, deletion.ID, userID, deletion.Version); err != nil {
+				return err
+			}
+			result, err := tx.ExecContext(ctx, DeleteTransactionQuery, deletion.ID, deletion.Version)
+			if err != nil {
+				return err
+			}
+
+			rows, err := result.RowsAffected()
+			if err != nil || rows == 0 {
+				return errors.New("Transaction is reconciled")
+			}
+			return emitTransactionEvent(ctx, tx, models.EventTransactionDeleted, models.TransactionID(deletion.ID))
+		}
+
+		return errors.Errorf("unknown resource type %q", deletion.Type)
+	})
+}
@@ -0,0 +1,120 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type TradeDB interface {
+	CreateTrade(ctx context.Context, trade *models.Trade) error
+	GetTradeByID(ctx context.Context, tradeID models.TradeID) (*models.Trade, error)
+	ListTradesByAccountID(ctx context.Context, accountID models.AccountID) ([]*models.Trade, error)
+	DeleteTrade(ctx context.Context, tradeID models.TradeID, version int64) (bool, error)
+	RestoreTrade(ctx context.Context, tradeID models.TradeID) (bool, error)
+}
+
+const createTradeQuery = `
+	INSERT INTO investment_trades (user_id, account_id, security_id, trade_type, trade_date, quantity, unit_price, fees, notes)
+	VALUES (:user_id, :account_id, :security_id, :trade_type, :trade_date, :quantity, :unit_price, COALESCE(:fees, 0), :notes)
+	RETURNING trade_id, fees;
+`
+
+func (d *database) CreateTrade(ctx context.Context, trade *models.Trade) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createTradeQuery, trade)
+	if err != nil {
+		return errors.Wrap(err, "could not create trade")
+	}
+
+	defer rows.Close()
+	rows.Next()
+	if err := rows.Scan(&trade.ID, &trade.Fees); err != nil {
+		return errors.Wrap(err, "could not get created trade")
+	}
+
+	return nil
+}
+
+const getTradeByIDQuery = `
+	SELECT trade_id, user_id, account_id, security_id, trade_type, trade_date, quantity, unit_price, fees, notes, created_at, deleted_at,
+	       updated_at, version
+	FROM investment_trades
+	WHERE trade_id = $1;
+`
+
+func (d *database) GetTradeByID(ctx context.Context, tradeID models.TradeID) (*models.Trade, error) {
+	var trade models.Trade
+	if err := d.conn.GetContext(ctx, &trade, getTradeByIDQuery, tradeID); err != nil {
+		return nil, errors.Wrap(err, "could not get trade")
+	}
+
+	return &trade, nil
+}
+
+const listTradesByAccountIDQuery = `
+	SELECT trade_id, user_id, account_id, security_id, trade_type, trade_date, quantity, unit_price, fees, notes, created_at, deleted_at,
+	       updated_at, version
+	FROM investment_trades
+	WHERE account_id = $1 AND deleted_at IS NULL
+	ORDER BY trade_date, created_at;
+`
+
+func (d *database) ListTradesByAccountID(ctx context.Context, accountID models.AccountID) ([]*models.Trade, error) {
+	var trades []*models.Trade
+	if err := d.conn.SelectContext(ctx, &trades, listTradesByAccountIDQuery, accountID); err != nil {
+		return nil, errors.Wrap(err, "could not get account's trades")
+	}
+
+	return trades, nil
+}
+
+const deleteTradeQuery = `
+	UPDATE investment_trades
+	SET deleted_at = NOW()
+	WHERE trade_id = $1 AND deleted_at IS NULL AND version = $2;
+`
+
+const tradeLiveQuery = `SELECT EXISTS (SELECT 1 FROM investment_trades WHERE trade_id = $1 AND deleted_at IS NULL);`
+
+// DeleteTrade moves trade to trash if it is still at version, which caller has read
+func (d *database) DeleteTrade(ctx context.Context, tradeID models.TradeID, version int64) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, deleteTradeQuery, tradeID, version)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, checkDeleted(ctx, d.conn, tradeLiveQuery, tradeID)
+	}
+	return true, nil
+}
+
+// * Trade of deleted account is restored together with account
+const restoreTradeQuery = `
+	UPDATE investment_trades
+	SET deleted_at = NULL
+	WHERE trade_id = $1
+	      AND deleted_at IS NOT NULL
+	      AND account_id IN (SELECT account_id FROM accounts WHERE deleted_at IS NULL);
+`
+
+func (d *database) RestoreTrade(ctx context.Context, tradeID models.TradeID) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, restoreTradeQuery, tradeID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
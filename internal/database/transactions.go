@@ -5,6 +5,7 @@ import (
 	"finance/internal/models"
 	"time"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/pkg/errors"
 )
 
@@ -15,52 +16,85 @@ type TransactionDB interface {
 	ListTransactionByUserID(ctx context.Context, userID models.UserID, from, to time.Time) ([]*models.Transaction, error) //we will filter by selected time frame (current month, last month etc)
 	ListTransactionByAccountID(ctx context.Context, accountID models.AccountID, from, to time.Time) ([]*models.Transaction, error)
 	ListTransactionByCategoryID(ctx context.Context, categoryID models.CategoryID, from, to time.Time) ([]*models.Transaction, error)
-	DeleteTransaction(ctx context.Context, transactionID models.TransactionID) (bool, error)
+	DeleteTransaction(ctx context.Context, transactionID models.TransactionID, version int64) (bool, error)
+	RestoreTransaction(ctx context.Context, transactionID models.TransactionID) (bool, error)
+	ListTransactionVersions(ctx context.Context, transactionID models.TransactionID) ([]*models.TransactionVersion, error)
+	GetTransactionVersion(ctx context.Context, transactionID models.TransactionID, revision int) (*models.TransactionVersion, error)
 }
 
 const createTransactionQuery = `
-	INSERT INTO transactions (user_id, account_id, category_id, transaction_date, transaction_type, amount, notes)
-	VALUES (:user_id, :account_id, :category_id, :transaction_date, :transaction_type, :amount, :notes)
+	INSERT INTO transactions (user_id, account_id, category_id, transaction_date, transaction_type, amount, notes, debt_id)
+	VALUES (:user_id, :account_id, :category_id, :transaction_date, :transaction_type, :amount, :notes, :debt_id)
 	RETURNING transaction_id;
 `
 
 func (d *database) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
-	rows, err := d.conn.NamedQueryContext(ctx, createTransactionQuery, transaction)
-	if err != nil {
-		return err
-	}
-
-	defer rows.Close()
-	rows.Next()
-	if err := rows.Scan(&transaction.ID); err != nil {
-		return err
-	}
-
-	return nil
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		rows, err := sqlx.NamedQueryContext(ctx, tx, createTransactionQuery, transaction)
+		if err != nil {
+			return err
+		}
+
+		rows.Next()
+		err = rows.Scan(&transaction.ID)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		return emitTransactionEvent(ctx, tx, models.EventTransactionCreated, transaction.ID)
+	})
 }
 
 const updateTransactionQuery = `
 	UPDATE transactions
-	SET account_id = :account_id, category_id = :category_id, transaction_date = :transaction_date, transaction_type = :transaction_type, amount = :amount, notes = :notes
-	WHERE transaction_id = :transaction_id;
+	SET account_id = :account_id, category_id = :category_id, transaction_date = :transaction_date, transaction_type = :transaction_type, amount = :amount, notes = :notes,
+	    cleared = COALESCE(:cleared, cleared)
+	WHERE transaction_id = :transaction_id AND reconciled_at IS NULL AND version = :version
+	RETURNING updated_at, version;
 `
 
-func (d *database) UpdateTransaction(ctx context.Context, transaction *models.Transaction) error {
-	result, err := d.conn.NamedExecContext(ctx, updateTransactionQuery, transaction)
-	if err != nil {
-		return err
-	}
+// * Row is locked, so concurrent updates get different version numbers
+const lockTransactionQuery = `
+	SELECT transaction_id FROM transactions WHERE transaction_id = $1 FOR UPDATE;
+`
 
-	rows, err := result.RowsAffected()
-	if err != nil || rows == 0 {
-		return errors.New("Transaction not found")
-	}
+const storeTransactionVersionQuery = `
+	INSERT INTO transaction_versions (transaction_id, revision, version, user_id, account_id, category_id, transaction_date, transaction_type, amount, notes)
+	SELECT transaction_id,
+	       COALESCE((SELECT MAX(revision) FROM transaction_versions WHERE transaction_id = $1), 0) + 1,
+	       version, user_id, account_id, category_id, transaction_date, transaction_type, amount, notes
+	FROM transactions
+	WHERE transaction_id = $1;
+`
 
-	return nil
+// UpdateTransaction stores current values of transaction in history and updates it, if it is still at version which caller has read
+func (d *database) UpdateTransaction(ctx context.Context, transaction *models.Transaction) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		var transactionID models.TransactionID
+		if err := tx.GetContext(ctx, &transactionID, lockTransactionQuery, transaction.ID); err != nil {
+			return errors.New("Transaction not found")
+		}
+
+		if _, err := tx.ExecContext(ctx, storeTransactionVersionQuery, transaction.ID); err != nil {
+			return errors.Wrap(err, "could not store transaction version")
+		}
+
+		rows, err := sqlx.NamedQueryContext(ctx, tx, updateTransactionQuery, transaction)
+		if err != nil {
+			return err
+		}
+
+		if err := scanVersion(rows, &transaction.UpdatedAt, &transaction.Version); err != nil {
+			return err
+		}
+
+		return emitTransactionEvent(ctx, tx, models.EventTransactionUpdated, transaction.ID)
+	})
 }
 
 const getTransactionByIDQuery = `
-	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes
+	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes, cleared, reconciliation_id, reconciled_at, debt_id, updated_at, version
 	FROM transactions
 	WHERE transaction_id = $1;
 `
@@ -74,7 +108,7 @@ func (d *database) GetTransactionByID(ctx context.Context, transactionID models.
 }
 
 const listTransactioByUserIDQuery = `
-	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes
+	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes, cleared, reconciliation_id, reconciled_at, debt_id, updated_at, version
 	FROM transactions
 	WHERE user_id = $1 
 				AND deleted_at IS NULL
@@ -91,7 +125,7 @@ func (d *database) ListTransactionByUserID(ctx context.Context, userID models.Us
 
 
 const listTransactioByAccountIDQuery = `
-	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes
+	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes, cleared, reconciliation_id, reconciled_at, debt_id, updated_at, version
 	FROM transactions
 	WHERE account_id = $1 
 				AND deleted_at IS NULL
@@ -108,7 +142,7 @@ func (d *database) ListTransactionByAccountID(ctx context.Context, accountID mod
 
 
 const listTransactioByACategoryQuery = `
-	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes
+	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes, cleared, reconciliation_id, reconciled_at, debt_id, updated_at, version
 	FROM transactions
 	WHERE category_id = $1 
 				AND deleted_at IS NULL
@@ -126,18 +160,86 @@ func (d *database) ListTransactionByCategoryID(ctx context.Context, categoryID m
 const DeleteTransactionQuery = `
 	UPDATE transactions
 	SET deleted_at = NOW()
-	WHERE transaction_id = $1 AND deleted_at IS NULL;
+	WHERE transaction_id = $1 AND deleted_at IS NULL AND reconciled_at IS NULL AND version = $2;
 `
-func (d *database) DeleteTransaction(ctx context.Context, transactionID models.TransactionID) (bool, error) {
-	result, err := d.conn.ExecContext(ctx, DeleteTransactionQuery, transactionID)
-	if err != nil {
-		return false, err
-	}
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return false, err
+const transactionLiveQuery = `SELECT EXISTS (SELECT 1 FROM transactions WHERE transaction_id = $1 AND deleted_at IS NULL AND reconciled_at IS NULL);`
+
+// DeleteTransaction moves transaction to trash if it is still at version, which caller has read. Reconciled transaction stays.
+func (d *database) DeleteTransaction(ctx context.Context, transactionID models.TransactionID, version int64) (bool, error) {
+	var changed bool
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		result, err := tx.ExecContext(ctx, DeleteTransactionQuery, transactionID, version)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return checkDeleted(ctx, tx, transactionLiveQuery, transactionID)
+		}
+
+		changed = true
+		return emitTransactionEvent(ctx, tx, models.EventTransactionDeleted, transactionID)
+	})
+
+	return changed, err
+}
+
+const restoreTransactionQuery = `
+	UPDATE transactions
+	SET deleted_at = NULL
+	WHERE transaction_id = $1 AND deleted_at IS NOT NULL;
+`
+
+func (d *database) RestoreTransaction(ctx context.Context, transactionID models.TransactionID) (bool, error) {
+	var changed bool
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		result, err := tx.ExecContext(ctx, restoreTransactionQuery, transactionID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil || rows == 0 {
+			return err
+		}
+
+		changed = true
+		return emitTransactionEvent(ctx, tx, models.EventTransactionRestored, transactionID)
+	})
+
+	return changed, err
+}
+
+const listTransactionVersionsQuery = `
+	SELECT transaction_id, revision, version, user_id, account_id, category_id, transaction_date, transaction_type, amount, notes, replaced_at
+	FROM transaction_versions
+	WHERE transaction_id = $1
+	ORDER BY revision DESC;
+`
+
+func (d *database) ListTransactionVersions(ctx context.Context, transactionID models.TransactionID) ([]*models.TransactionVersion, error) {
+	var versions []*models.TransactionVersion
+	if err := d.conn.SelectContext(ctx, &versions, listTransactionVersionsQuery, transactionID); err != nil {
+		return nil, errors.Wrap(err, "could not get transaction's history")
 	}
+	return versions, nil
+}
 
-	return rows > 0, nil
+const getTransactionVersionQuery = `
+	SELECT transaction_id, revision, version, user_id, account_id, category_id, transaction_date, transaction_type, amount, notes, replaced_at
+	FROM transaction_versions
+	WHERE transaction_id = $1 AND revision = $2;
+`
+
+func (d *database) GetTransactionVersion(ctx context.Context, transactionID models.TransactionID, revision int) (*models.TransactionVersion, error) {
+	var transactionVersion models.TransactionVersion
+	if err := d.conn.GetContext(ctx, &transactionVersion, getTransactionVersionQuery, transactionID, revision); err != nil {
+		return nil, errors.Wrap(err, "could not get transaction version")
+	}
+	return &transactionVersion, nil
 }
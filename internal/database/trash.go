@@ -0,0 +1,112 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type TrashDB interface {
+	ListTrash(ctx context.Context, userID models.UserID) ([]*models.TrashItem, error)
+	PurgeTrash(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+const listTrashQuery = `
+	SELECT 'account' AS resource_type, account_id::text AS resource_id, account_name AS name, deleted_at
+	FROM accounts
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'category', category_id::text, name, deleted_at
+	FROM categories
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'merchant', merchant_id::text, name, deleted_at
+	FROM merchants
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'transaction', transaction_id::text, notes, deleted_at
+	FROM transactions
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'debt', debt_id::text, counterparty, deleted_at
+	FROM debts
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'trade', trade_id::text, notes, deleted_at
+	FROM investment_trades
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'goal', goal_id::text, name, deleted_at
+	FROM goals
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	UNION ALL
+	SELECT 'bill', bill_id::text, name, deleted_at
+	FROM bills
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC;
+`
+
+func (d *database) ListTrash(ctx context.Context, userID models.UserID) ([]*models.TrashItem, error) {
+	var items []*models.TrashItem
+	if err := d.conn.SelectContext(ctx, &items, listTrashQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "could not get user's trash")
+	}
+	return items, nil
+}
+
+// * Children are purged before parents. Account, category or debt is kept while anything references it.
+// Reconciled transaction is never purged, it must stay in ledger as it was matched with bank statement.
+var purgeTrashQueries = []string{
+	`DELETE FROM transaction_versions
+	 WHERE transaction_id IN (SELECT transaction_id FROM transactions WHERE deleted_at < $1 AND reconciled_at IS NULL);`,
+	`DELETE FROM goal_contributions
+	 WHERE transaction_id IN (SELECT transaction_id FROM transactions WHERE deleted_at < $1 AND reconciled_at IS NULL)
+	       OR goal_id IN (SELECT goal_id FROM goals WHERE deleted_at < $1);`,
+	`DELETE FROM bill_payments
+	 WHERE transaction_id IN (SELECT transaction_id FROM transactions WHERE deleted_at < $1 AND reconciled_at IS NULL)
+	       OR bill_id IN (SELECT bill_id FROM bills WHERE deleted_at < $1);`,
+	`DELETE FROM transactions WHERE deleted_at < $1 AND reconciled_at IS NULL;`,
+	`DELETE FROM goals WHERE deleted_at < $1;`,
+	`DELETE FROM bills WHERE deleted_at < $1;`,
+	`DELETE FROM investment_trades WHERE deleted_at < $1;`,
+	`DELETE FROM merchants WHERE deleted_at < $1;`,
+	`DELETE FROM debts d
+	 WHERE deleted_at < $1
+	       AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.debt_id = d.debt_id);`,
+	`DELETE FROM categories c
+	 WHERE deleted_at < $1
+	       AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.category_id = c.category_id);`,
+	`DELETE FROM reconciliations r
+	 WHERE account_id IN (SELECT account_id FROM accounts WHERE deleted_at < $1)
+	       AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.account_id = r.account_id);`,
+	`DELETE FROM accounts a
+	 WHERE deleted_at < $1
+	       AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.account_id = a.account_id)
+	       AND NOT EXISTS (SELECT 1 FROM investment_trades it WHERE it.account_id = a.account_id)
+	       AND NOT EXISTS (SELECT 1 FROM goals g WHERE g.account_id = a.account_id)
+	       AND NOT EXISTS (SELECT 1 FROM bills b WHERE b.account_id = a.account_id);`,
+}
+
+// PurgeTrash permanently removes rows deleted before deletedBefore and returns number of removed rows
+func (d *database) PurgeTrash(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	var purged int64
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		for _, query := range purgeTrashQueries {
+			result, err := tx.ExecContext(ctx, query, deletedBefore)
+			if err != nil {
+				return errors.Wrap(err, "could not purge trash")
+			}
+
+			rows, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			purged += rows
+		}
+		return nil
+	})
+	return purged, err
+}
@@ -0,0 +1,168 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type TwoFactorDB interface {
+	SetTOTPSecret(ctx context.Context, userID models.UserID, secret string) error
+	EnableTOTP(ctx context.Context, userID models.UserID, recoveryCodeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userID models.UserID, codeHash string) (bool, error)
+	ResetTOTP(ctx context.Context, userID models.UserID) error
+	UseTOTPCounter(ctx context.Context, userID models.UserID, counter int64) (bool, error)
+	UseMFAToken(ctx context.Context, userID models.UserID, tokenID string, expiresAt time.Time) (bool, error)
+}
+
+// * Secret can be replaced only until 2FA is confirmed
+const setTOTPSecretQuery = `
+	UPDATE users
+	SET totp_secret = $2
+	WHERE user_id = $1 AND totp_enabled_at IS NULL;
+`
+
+func (d *database) SetTOTPSecret(ctx context.Context, userID models.UserID, secret string) error {
+	result, err := d.conn.ExecContext(ctx, setTOTPSecretQuery, userID, secret)
+	if err != nil {
+		return errors.Wrap(err, "could not set totp secret")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil || rows == 0 {
+		return errors.New("User not found or 2FA already enabled")
+	}
+
+	return nil
+}
+
+const enableTOTPQuery = `
+	UPDATE users
+	SET totp_enabled_at = NOW()
+	WHERE user_id = $1 AND totp_secret IS NOT NULL;
+`
+
+const deleteRecoveryCodesQuery = `
+	DELETE FROM recovery_codes
+	WHERE user_id = $1;
+`
+
+const insertRecoveryCodeQuery = `
+	INSERT INTO recovery_codes (user_id, code_hash)
+	VALUES ($1, $2);
+`
+
+func (d *database) EnableTOTP(ctx context.Context, userID models.UserID, recoveryCodeHashes []string) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, enableTOTPQuery, userID); err != nil {
+			return errors.Wrap(err, "could not enable totp")
+		}
+		if _, err := tx.ExecContext(ctx, deleteRecoveryCodesQuery, userID); err != nil {
+			return errors.Wrap(err, "could not delete recovery codes")
+		}
+		for _, codeHash := range recoveryCodeHashes {
+			if _, err := tx.ExecContext(ctx, insertRecoveryCodeQuery, userID, codeHash); err != nil {
+				return errors.Wrap(err, "could not save recovery code")
+			}
+		}
+		return nil
+	})
+}
+
+const useRecoveryCodeQuery = `
+	UPDATE recovery_codes
+	SET used_at = NOW()
+	WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+`
+
+func (d *database) UseRecoveryCode(ctx context.Context, userID models.UserID, codeHash string) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, useRecoveryCodeQuery, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+const resetTOTPQuery = `
+	UPDATE users
+	SET totp_secret = NULL,
+			totp_enabled_at = NULL
+	WHERE user_id = $1;
+`
+
+func (d *database) ResetTOTP(ctx context.Context, userID models.UserID) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, resetTOTPQuery, userID); err != nil {
+			return errors.Wrap(err, "could not reset totp")
+		}
+		if _, err := tx.ExecContext(ctx, deleteRecoveryCodesQuery, userID); err != nil {
+			return errors.Wrap(err, "could not delete recovery codes")
+		}
+		return nil
+	})
+}
+
+// * Code is accepted only if its time step is after the last accepted one, so the same code can't be used twice
+const useTOTPCounterQuery = `
+	UPDATE users
+	SET totp_last_counter = $2
+	WHERE user_id = $1 AND totp_last_counter < $2;
+`
+
+func (d *database) UseTOTPCounter(ctx context.Context, userID models.UserID, counter int64) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, useTOTPCounterQuery, userID, counter)
+	if err != nil {
+		return false, errors.Wrap(err, "could not use totp code")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+const deleteExpiredMFATokensQuery = `
+	DELETE FROM used_mfa_tokens
+	WHERE expires_at < NOW();
+`
+
+const useMFATokenQuery = `
+	INSERT INTO used_mfa_tokens (token_id, user_id, expires_at)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (token_id) DO NOTHING;
+`
+
+// * Returns false if token was already exchanged. Expired tokens are rejected by signature check, so we don't keep them.
+func (d *database) UseMFAToken(ctx context.Context, userID models.UserID, tokenID string, expiresAt time.Time) (bool, error) {
+	var used bool
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if _, err := tx.ExecContext(ctx, deleteExpiredMFATokensQuery); err != nil {
+			return errors.Wrap(err, "could not delete expired mfa tokens")
+		}
+
+		result, err := tx.ExecContext(ctx, useMFATokenQuery, tokenID, userID, expiresAt)
+		if err != nil {
+			return errors.Wrap(err, "could not use mfa token")
+		}
+
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		used = rows > 0
+		return nil
+	})
+
+	return used, err
+}
@@ -0,0 +1,334 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// UserDataDB - export and deletion of all data which belongs to user
+type UserDataDB interface {
+	ExportUserData(ctx context.Context, userID models.UserID) (*models.UserExport, error)
+	RequestUserDeletion(ctx context.Context, userID models.UserID, requestedBy *models.UserID) error
+	GetUserDeletion(ctx context.Context, userID models.UserID) (*models.UserDeletion, error)
+	ListPendingUserDeletions(ctx context.Context) ([]*models.UserDeletion, error)
+	RunUserDeletionStep(ctx context.Context, userID models.UserID, step models.UserDeletionStep) error
+	FailUserDeletion(ctx context.Context, userID models.UserID, reason error) error
+}
+
+// * Export contains deleted rows too, they are still stored until purge
+const exportAccountsQuery = `
+	SELECT account_id, user_id, start_balance, account_type, account_name, currency, created_at, deleted_at,
+	       credit_limit, statement_day, payment_due_day, apr, interest_rate, loan_principal, loan_term, loan_start, updated_at, version
+	FROM accounts
+	WHERE user_id = $1;
+`
+
+const exportCategoriesQuery = `
+	SELECT category_id, parent_id, user_id, name, created_at, deleted_at, updated_at, version
+	FROM categories
+	WHERE user_id = $1;
+`
+
+const exportMerchantsQuery = `
+	SELECT merchant_id, user_id, name, created_at, deleted_at, updated_at, version
+	FROM merchants
+	WHERE user_id = $1;
+`
+
+const exportTransactionsQuery = `
+	SELECT transaction_id, user_id, account_id, category_id, created_at, deleted_at, transaction_date, transaction_type, amount, notes, cleared, reconciliation_id, reconciled_at, debt_id, updated_at, version
+	FROM transactions
+	WHERE user_id = $1
+	ORDER BY transaction_date;
+`
+
+const exportTransactionVersionsQuery = `
+	SELECT transaction_id, revision, version, user_id, account_id, category_id, transaction_date, transaction_type, amount, notes, replaced_at
+	FROM transaction_versions
+	WHERE user_id = $1
+	ORDER BY transaction_id, revision;
+`
+
+const exportReconciliationsQuery = `
+	SELECT reconciliation_id, user_id, account_id, statement_date, ending_balance, created_at, finalized_at, updated_at, version
+	FROM reconciliations
+	WHERE user_id = $1
+	ORDER BY statement_date;
+`
+
+const exportDebtsQuery = `
+	SELECT debt_id, user_id, counterparty, direction, principal, interest_rate, term, start_date, currency, notes, created_at, deleted_at, updated_at, version
+	FROM debts
+	WHERE user_id = $1
+	ORDER BY start_date;
+`
+
+const exportTradesQuery = `
+	SELECT trade_id, user_id, account_id, security_id, trade_type, trade_date, quantity, unit_price, fees, notes, created_at, deleted_at, updated_at, version
+	FROM investment_trades
+	WHERE user_id = $1
+	ORDER BY trade_date;
+`
+
+const exportGoalsQuery = `
+	SELECT goal_id, user_id, account_id, name, target_amount, target_date, created_at, deleted_at, updated_at, version
+	FROM goals
+	WHERE user_id = $1;
+`
+
+const exportGoalContributionsQuery = `
+	SELECT contribution_id, goal_id, user_id, transaction_id, amount, contribution_date, notes, created_at
+	FROM goal_contributions
+	WHERE user_id = $1
+	ORDER BY contribution_date;
+`
+
+const exportBillsQuery = `
+	SELECT bill_id, user_id, account_id, name, amount, due_date, due_day, frequency, paid_at, notes, created_at, deleted_at, updated_at, version
+	FROM bills
+	WHERE user_id = $1;
+`
+
+const exportBillPaymentsQuery = `
+	SELECT payment_id, bill_id, user_id, transaction_id, due_date, paid_at
+	FROM bill_payments
+	WHERE user_id = $1
+	ORDER BY due_date;
+`
+
+const exportNotificationsQuery = `
+	SELECT notification_id, user_id, event_type, title, body, resource_id, dedupe_key, delivered_at, read_at, created_at
+	FROM notifications
+	WHERE user_id = $1
+	ORDER BY created_at;
+`
+
+const exportNotificationPreferencesQuery = `
+	SELECT user_id, event_type, in_app, email, webhook_url, threshold, updated_at, version
+	FROM notification_preferences
+	WHERE user_id = $1;
+`
+
+const exportWebhooksQuery = `
+	SELECT webhook_id, user_id, url, secret, event_types, active, created_at, deleted_at, updated_at, version
+	FROM webhooks
+	WHERE user_id = $1;
+`
+
+const exportAuthEventsQuery = `
+	SELECT auth_event_id, event_type, user_id, email, ip_address, user_agent, created_at
+	FROM auth_events
+	WHERE user_id = $1
+	ORDER BY created_at;
+`
+
+const exportAuditEventsQuery = `
+	SELECT audit_event_id, principal_id, api_key_id, user_id, action, resource_type, resource_id, before, after, ip_address, request_id, created_at
+	FROM audit_events
+	WHERE user_id = $1 OR principal_id = $1
+	ORDER BY created_at;
+`
+
+func (d *database) ExportUserData(ctx context.Context, userID models.UserID) (*models.UserExport, error) {
+	user, err := d.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := models.UserExport{
+		User: user,
+	}
+
+	if export.Roles, err = d.GetRolesByUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	if export.Sessions, err = d.ListSessions(ctx, userID); err != nil {
+		return nil, err
+	}
+	if export.APIKeys, err = d.ListAPIKeys(ctx, userID); err != nil {
+		return nil, err
+	}
+	if export.Identities, err = d.ListIdentities(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	selects := []struct {
+		dest  interface{}
+		query string
+	}{
+		{&export.Accounts, exportAccountsQuery},
+		{&export.Categories, exportCategoriesQuery},
+		{&export.Merchants, exportMerchantsQuery},
+		{&export.Transactions, exportTransactionsQuery},
+		{&export.TransactionVersions, exportTransactionVersionsQuery},
+		{&export.Reconciliations, exportReconciliationsQuery},
+		{&export.Debts, exportDebtsQuery},
+		{&export.Trades, exportTradesQuery},
+		{&export.Goals, exportGoalsQuery},
+		{&export.GoalContributions, exportGoalContributionsQuery},
+		{&export.Bills, exportBillsQuery},
+		{&export.BillPayments, exportBillPaymentsQuery},
+		{&export.Notifications, exportNotificationsQuery},
+		{&export.NotificationPreferences, exportNotificationPreferencesQuery},
+		{&export.Webhooks, exportWebhooksQuery},
+		{&export.AuthEvents, exportAuthEventsQuery},
+		{&export.AuditEvents, exportAuditEventsQuery},
+	}
+	for _, s := range selects {
+		if err := d.conn.SelectContext(ctx, s.dest, s.query, userID); err != nil {
+			return nil, errors.Wrap(err, "could not export user's data")
+		}
+	}
+
+	return &export, nil
+}
+
+// * Request is idempotent, deletion which is already running is not started again
+const requestUserDeletionQuery = `
+	INSERT INTO user_deletions (user_id, requested_by)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO NOTHING;
+`
+
+func (d *database) RequestUserDeletion(ctx context.Context, userID models.UserID, requestedBy *models.UserID) error {
+	_, err := d.conn.ExecContext(ctx, requestUserDeletionQuery, userID, requestedBy)
+	return errors.Wrap(err, "could not request user deletion")
+}
+
+const getUserDeletionQuery = `
+	SELECT user_id, requested_by, requested_at, completed_step, attempts, last_error, completed_at
+	FROM user_deletions
+	WHERE user_id = $1;
+`
+
+func (d *database) GetUserDeletion(ctx context.Context, userID models.UserID) (*models.UserDeletion, error) {
+	var deletion models.UserDeletion
+	if err := d.conn.GetContext(ctx, &deletion, getUserDeletionQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "could not get user deletion")
+	}
+	return &deletion, nil
+}
+
+const listPendingUserDeletionsQuery = `
+	SELECT user_id, requested_by, requested_at, completed_step, attempts, last_error, completed_at
+	FROM user_deletions
+	WHERE completed_at IS NULL
+	ORDER BY requested_at;
+`
+
+func (d *database) ListPendingUserDeletions(ctx context.Context) ([]*models.UserDeletion, error) {
+	var deletions []*models.UserDeletion
+	if err := d.conn.SelectContext(ctx, &deletions, listPendingUserDeletionsQuery); err != nil {
+		return nil, errors.Wrap(err, "could not get pending user deletions")
+	}
+	return deletions, nil
+}
+
+// * Every query of step can be run again, so step interrupted in the middle is simply repeated
+var userDeletionQueries = map[models.UserDeletionStep][]string{
+	models.DeleteSessions: {
+		`DELETE FROM refresh_tokens WHERE user_id = $1;`,
+		`DELETE FROM sessions WHERE user_id = $1;`,
+		`UPDATE users SET token_version = token_version + 1 WHERE user_id = $1;`,
+	},
+	models.DeleteCredentials: {
+		`DELETE FROM api_keys WHERE user_id = $1;`,
+		`DELETE FROM user_identities WHERE user_id = $1;`,
+		`DELETE FROM oauth_states WHERE user_id = $1;`,
+		`DELETE FROM user_tokens WHERE user_id = $1;`,
+		`DELETE FROM recovery_codes WHERE user_id = $1;`,
+		`DELETE FROM used_mfa_tokens WHERE user_id = $1;`,
+		`DELETE FROM user_roles WHERE user_id = $1;`,
+		// DeleteUser keeps original email before '-DELETED-'
+		`DELETE FROM login_throttles
+		 WHERE throttle_key IN (SELECT 'email:' || LOWER(SPLIT_PART(email, '-DELETED-', 1)) FROM users WHERE user_id = $1);`,
+		`UPDATE auth_events SET email = '', ip_address = '', user_agent = '' WHERE user_id = $1;`,
+	},
+	models.DeleteLedger: {
+		`DELETE FROM webhook_deliveries
+		 WHERE event_id IN (SELECT event_id FROM outbox_events WHERE user_id = $1)
+		    OR webhook_id IN (SELECT webhook_id FROM webhooks WHERE user_id = $1);`,
+		`DELETE FROM outbox_events WHERE user_id = $1;`,
+		`DELETE FROM webhooks WHERE user_id = $1;`,
+		`DELETE FROM notification_deliveries WHERE user_id = $1;`,
+		`DELETE FROM notifications WHERE user_id = $1;`,
+		`DELETE FROM notification_preferences WHERE user_id = $1;`,
+		`DELETE FROM transaction_versions WHERE user_id = $1;`,
+		`DELETE FROM goal_contributions WHERE user_id = $1;`,
+		`DELETE FROM bill_payments WHERE user_id = $1;`,
+		`DELETE FROM transactions WHERE user_id = $1;`,
+		`DELETE FROM goals WHERE user_id = $1;`,
+		`DELETE FROM bills WHERE user_id = $1;`,
+		`DELETE FROM debts WHERE user_id = $1;`,
+		`DELETE FROM investment_trades WHERE user_id = $1;`,
+		`DELETE FROM reconciliations WHERE user_id = $1;`,
+		`DELETE FROM merchants WHERE user_id = $1;`,
+		`DELETE FROM categories WHERE user_id = $1;`,
+		`DELETE FROM accounts WHERE user_id = $1;`,
+		`DELETE FROM sync_deletions WHERE user_id = $1;`,
+	},
+	models.RedactAudit: {
+		`UPDATE audit_events SET before = NULL, after = NULL, ip_address = '' WHERE user_id = $1 OR principal_id = $1;`,
+	},
+	models.AnonymizeUser: {
+		`UPDATE users
+		 SET email = 'deleted-' || user_id || '@deleted.invalid',
+		     password_hash = NULL,
+		     totp_secret = NULL,
+		     totp_enabled_at = NULL,
+		     email_verified_at = NULL,
+		     deleted_at = COALESCE(deleted_at, NOW())
+		 WHERE user_id = $1;`,
+	},
+}
+
+const completeUserDeletionStepQuery = `
+	UPDATE user_deletions
+	SET completed_step = $2,
+	    completed_at = CASE WHEN $3 THEN NOW() END
+	WHERE user_id = $1;
+`
+
+// RunUserDeletionStep removes one part of user's data and stores progress in the same transaction
+func (d *database) RunUserDeletionStep(ctx context.Context, userID models.UserID, step models.UserDeletionStep) error {
+	queries, ok := userDeletionQueries[step]
+	if !ok {
+		return errors.Errorf("unknown user deletion step %q", step)
+	}
+
+	last := step == models.UserDeletionSteps[len(models.UserDeletionSteps)-1]
+
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		// * audit_events trigger allows only redaction and only in transaction which asks for it
+		if step == models.RedactAudit {
+			if _, err := tx.ExecContext(ctx, `SET LOCAL audit.redact = 'on';`); err != nil {
+				return errors.Wrap(err, "could not allow audit redaction")
+			}
+		}
+
+		for _, query := range queries {
+			if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+				return errors.Wrapf(err, "could not run user deletion step %q", step)
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, completeUserDeletionStepQuery, userID, step, last); err != nil {
+			return errors.Wrap(err, "could not store user deletion progress")
+		}
+		return nil
+	})
+}
+
+const failUserDeletionQuery = `
+	UPDATE user_deletions
+	SET attempts = attempts + 1, last_error = $2
+	WHERE user_id = $1;
+`
+
+func (d *database) FailUserDeletion(ctx context.Context, userID models.UserID, reason error) error {
+	_, err := d.conn.ExecContext(ctx, failUserDeletionQuery, userID, reason.Error())
+	return errors.Wrap(err, "could not store user deletion failure")
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"finance/internal/models"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+type UserTokenDB interface {
+	CreateUserToken(ctx context.Context, token *models.UserToken) error
+	UseUserToken(ctx context.Context, tokenHash string, purpose models.UserTokenPurpose) (*models.UserToken, error)
+}
+
+// ErrUserTokenInvalid is returned when token not exists, expired or was already used
+var ErrUserTokenInvalid = errors.New("token is invalid or expired")
+
+const createUserTokenQuery = `
+	INSERT INTO user_tokens (token_hash, user_id, purpose, expires_at)
+	VALUES (:token_hash, :user_id, :purpose, :expires_at);
+`
+
+func (d *database) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	if _, err := d.conn.NamedExecContext(ctx, createUserTokenQuery, token); err != nil {
+		return errors.Wrap(err, "could not create user token")
+	}
+	return nil
+}
+
+const useUserTokenQuery = `
+	UPDATE user_tokens
+	SET used_at = NOW()
+	WHERE token_hash = $1
+	      AND purpose = $2
+	      AND used_at IS NULL
+	      AND expires_at > NOW()
+	RETURNING token_hash, user_id, purpose, created_at, expires_at, used_at;
+`
+
+// * When one token is used, other tokens with the same purpose are not needed anymore
+const useOtherUserTokensQuery = `
+	UPDATE user_tokens
+	SET used_at = NOW()
+	WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL;
+`
+
+func (d *database) UseUserToken(ctx context.Context, tokenHash string, purpose models.UserTokenPurpose) (*models.UserToken, error) {
+	var token models.UserToken
+	err := d.withTx(ctx, func(tx *sqlx.Tx) error {
+		if err := tx.GetContext(ctx, &token, useUserTokenQuery, tokenHash, purpose); err != nil {
+			if err == sql.ErrNoRows {
+				return ErrUserTokenInvalid
+			}
+			return errors.Wrap(err, "could not use user token")
+		}
+
+		if _, err := tx.ExecContext(ctx, useOtherUserTokensQuery, token.UserID, purpose); err != nil {
+			return errors.Wrap(err, "could not use user tokens")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
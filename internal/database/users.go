@@ -4,6 +4,7 @@ import (
 	"context"
 	"finance/internal/models"
 
+	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
@@ -15,7 +16,11 @@ type UsersDB interface {
 	GetUserByEmail(ctx context.Context, emial string) (*models.User, error)
 	ListUsers(ctx context.Context) ([]*models.User, error)
 	UpdateUser(ctx context.Context, user *models.User) error
-	DeleteUser(ctx context.Context, userID models.UserID) (bool, error)
+	ResetUserPassword(ctx context.Context, user *models.User) error
+	DeleteUser(ctx context.Context, userID models.UserID, version int64) (bool, error)
+	GetTokenVersion(ctx context.Context, userID models.UserID) (int64, error)
+	VerifyUserEmail(ctx context.Context, userID models.UserID) error
+	SetLockDate(ctx context.Context, user *models.User) error
 }
 
 var ErrUserExists = errors.New("user with that email exists")
@@ -30,7 +35,7 @@ const createUserQuery = `
 	RETURNING user_id
 `
 func (d *database) CreateUser(ctx context.Context, user *models.User) error {
-	rows, err := d.conn.NamedQueryContext(ctx, createUserQuery, user)
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createUserQuery, user)
 	if rows != nil {
 		defer rows.Close()
 	}
@@ -56,7 +61,8 @@ func (d *database) CreateUser(ctx context.Context, user *models.User) error {
 }
 
 const getUserByIDQuery = `
-	SELECT user_id, email, password_hash, created_at
+	SELECT user_id, email, password_hash, token_version, email_verified_at, totp_secret, totp_enabled_at, lock_date, created_at,
+	       updated_at, version
 	FROM users 
 	WHERE user_id = $1 AND deleted_at IS NULL;
 `
@@ -70,7 +76,8 @@ func (d *database) GetUserByID(ctx context.Context, userID models.UserID) (*mode
 }
 
 const getUserByEmailQuery = `
-	SELECT user_id, email, password_hash, created_at
+	SELECT user_id, email, password_hash, token_version, email_verified_at, totp_secret, totp_enabled_at, lock_date, created_at,
+	       updated_at, version
 	FROM users 
 	WHERE email = $1 AND deleted_at IS NULL;
 `
@@ -84,7 +91,8 @@ func (d *database) GetUserByEmail(ctx context.Context, email string) (*models.Us
 }
 
 const listUsersQuery = `
-	SELECT user_id, email, password_hash, created_at
+	SELECT user_id, email, password_hash, token_version, email_verified_at, totp_secret, totp_enabled_at, lock_date, created_at,
+	       updated_at, version
 	FROM users
 	WHERE deleted_at IS NULL;
 `
@@ -99,30 +107,53 @@ func (d *database) ListUsers(ctx context.Context) ([]*models.User, error) {
 const updateUserQuery = `
 	UPDATE users
 	SET	password_hash = :password_hash
-	WHERE user_id = :user_id;
+	WHERE user_id = :user_id AND deleted_at IS NULL AND version = :version
+	RETURNING updated_at, version;
 `
+// UpdateUser updates user only if it is still at version, which caller has read
 func (d *database) UpdateUser(ctx context.Context, user *models.User) error {
-	result, err := d.conn.NamedExecContext(ctx, updateUserQuery, user)
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, updateUserQuery, user)
 	if err != nil {
-		return err
+		return errors.Wrap(err, "could not update user")
 	}
 
-	rows, err := result.RowsAffected()
-	if err != nil || rows == 0 {
-		return errors.New("User not found")
-	}
+	return scanVersion(rows, &user.UpdatedAt, &user.Version)
+}
 
-	return nil
+// * Reset is proved by emailed token, not by version which user has read, so the last reset wins
+const resetUserPasswordQuery = `
+	UPDATE users
+	SET	password_hash = :password_hash
+	WHERE user_id = :user_id;
+`
+
+// ResetUserPassword - Set new password and close all sessions of user in one transaction
+func (d *database) ResetUserPassword(ctx context.Context, user *models.User) error {
+	return d.withTx(ctx, func(tx *sqlx.Tx) error {
+		result, err := tx.NamedExecContext(ctx, resetUserPasswordQuery, user)
+		if err != nil {
+			return errors.Wrap(err, "could not update user")
+		}
+		if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+			return errors.New("User not found")
+		}
+
+		return revokeAllSessions(ctx, tx, user.ID)
+	})
 }
 
 const DeleteUserQuery = `
 	UPDATE users
 	SET deleted_at = NOW(),
 			email = CONCAT(email, '-DELETED-', uuid_generate_v4())
-	WHERE user_id = $1 AND deleted_at IS NULL;
+	WHERE user_id = $1 AND deleted_at IS NULL AND version = $2;
 `
-func (d *database) DeleteUser(ctx context.Context, userID models.UserID) (bool, error) {
-	result, err := d.conn.ExecContext(ctx, DeleteUserQuery, userID)
+
+const userLiveQuery = `SELECT EXISTS (SELECT 1 FROM users WHERE user_id = $1 AND deleted_at IS NULL);`
+
+// DeleteUser deletes user if it is still at version, which caller has read
+func (d *database) DeleteUser(ctx context.Context, userID models.UserID, version int64) (bool, error) {
+	result, err := d.conn.ExecContext(ctx, DeleteUserQuery, userID, version)
 	if err != nil {
 		return false, err
 	}
@@ -132,5 +163,56 @@ func (d *database) DeleteUser(ctx context.Context, userID models.UserID) (bool,
 		return false, err
 	}
 
-	return rows > 0, nil
+	if rows == 0 {
+		return false, checkDeleted(ctx, d.conn, userLiveQuery, userID)
+	}
+	return true, nil
+}
+
+const getTokenVersionQuery = `
+	SELECT token_version
+	FROM users
+	WHERE user_id = $1 AND deleted_at IS NULL;
+`
+func (d *database) GetTokenVersion(ctx context.Context, userID models.UserID) (int64, error) {
+	var version int64
+	if err := d.conn.GetContext(ctx, &version, getTokenVersionQuery, userID); err != nil {
+		return 0, errors.Wrap(err, "could not get token version")
+	}
+	return version, nil
+}
+
+// * Used inside of session transactions
+const incrementTokenVersionQuery = `
+	UPDATE users
+	SET token_version = token_version + 1
+	WHERE user_id = $1;
+`
+
+const verifyUserEmailQuery = `
+	UPDATE users
+	SET email_verified_at = NOW()
+	WHERE user_id = $1 AND email_verified_at IS NULL;
+`
+func (d *database) VerifyUserEmail(ctx context.Context, userID models.UserID) error {
+	if _, err := d.conn.ExecContext(ctx, verifyUserEmailQuery, userID); err != nil {
+		return errors.Wrap(err, "could not verify user's email")
+	}
+	return nil
+}
+
+const setLockDateQuery = `
+	UPDATE users
+	SET lock_date = :lock_date
+	WHERE user_id = :user_id AND deleted_at IS NULL AND version = :version
+	RETURNING updated_at, version;
+`
+// SetLockDate sets lock date of user only if user is still at version, which caller has read
+func (d *database) SetLockDate(ctx context.Context, user *models.User) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, setLockDateQuery, user)
+	if err != nil {
+		return errors.Wrap(err, "could not set user's lock date")
+	}
+
+	return scanVersion(rows, &user.UpdatedAt, &user.Version)
 }
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// ErrVersionConflict - row was changed since version caller has read, or client generated ID is already used
+var ErrVersionConflict = errors.New("resource was changed by another client")
+
+// scanVersion reads new version of row updated with condition on version. No row means condition failed.
+func scanVersion(rows *sqlx.Rows, updatedAt **time.Time, version *int64) error {
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	}
+
+	return rows.Scan(updatedAt, version)
+}
+
+// checkDeleted tells why delete conditioned on version changed nothing. Row still in place at other version is conflict,
+// row which is missing or already in trash is not. liveQuery checks whether row can still be deleted.
+func checkDeleted(ctx context.Context, conn connection, liveQuery string, id interface{}) error {
+	var live bool
+	if err := conn.GetContext(ctx, &live, liveQuery, id); err != nil {
+		return errors.Wrap(err, "could not check version")
+	}
+
+	if live {
+		return ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package database
+
+import (
+	"context"
+	"finance/internal/models"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+const defaultWebhookDeliveryLimit = 100
+const maxWebhookDeliveryLimit = 1000
+
+type WebhookDB interface {
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	UpdateWebhook(ctx context.Context, webhook *models.Webhook) error
+	GetWebhookByID(ctx context.Context, webhookID models.WebhookID) (*models.Webhook, error)
+	ListWebhooksByUserID(ctx context.Context, userID models.UserID) ([]*models.Webhook, error)
+	ListGlobalWebhooks(ctx context.Context) ([]*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID models.WebhookID, version int64) (bool, error)
+	DispatchOutboxEvents(ctx context.Context, limit int) (int64, error)
+	GetOutboxEventByID(ctx context.Context, eventID models.OutboxEventID) (*models.OutboxEvent, error)
+	ListOutboxEventsAfter(ctx context.Context, userID models.UserID, eventID models.OutboxEventID, limit int) ([]*models.OutboxEvent, error)
+	ClaimWebhookDeliveries(ctx context.Context, now, lease time.Time, limit int) ([]*models.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, webhookID models.WebhookID, limit int) ([]*models.WebhookDelivery, error)
+}
+
+const createWebhookQuery = `
+	INSERT INTO webhooks (user_id, url, secret, event_types, active)
+	VALUES (:user_id, :url, :secret, :event_types, :active)
+	RETURNING webhook_id, created_at;
+`
+
+func (d *database) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, createWebhookQuery, webhook)
+	if err != nil {
+		return errors.Wrap(err, "could not create webhook")
+	}
+
+	defer rows.Close()
+	rows.Next()
+	if err := rows.Scan(&webhook.ID, &webhook.CreatedAt); err != nil {
+		return errors.Wrap(err, "could not get created webhook")
+	}
+
+	return nil
+}
+
+const updateWebhookQuery = `
+	UPDATE webhooks
+	SET url = :url,
+	    event_types = :event_types,
+	    active = :active
+	WHERE webhook_id = :webhook_id AND deleted_at IS NULL AND version = :version
+	RETURNING updated_at, version;
+`
+
+// UpdateWebhook updates webhook only if it is still at version, which caller has read
+func (d *database) UpdateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	rows, err := sqlx.NamedQueryContext(ctx, d.conn, updateWebhookQuery, webhook)
+	if err != nil {
+		return errors.Wrap(err, "could not update webhook")
+	}
+
+	return scanVersion(rows, &webhook.UpdatedAt, &webhook.Version)
+}
+
+const getWebhookByIDQuery = `
+	SELECT webhook_id, user_id, url, secret, event_types, active, created_at, deleted_at, updated_at, version
+	FROM webhooks
+	WHERE webhook_id = $1 AND deleted_at IS NULL;
+`
+
+func (d *database) GetWebhookByID(ctx context.Context, webhookID models.WebhookID) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := d.conn.GetContext(ctx, &webhook, getWebhookByIDQuery, webhookID); err != nil {
+		return nil, errors.Wrap(err, "could not get webhook")
+	}
+
+	return &webhook, nil
+}
+
+const listWebhooksByUserIDQuery = `
+	SELECT webhook_id, user_id, url, secret, event_types, active, created_at, deleted_at, updated_at, version
+	FROM webhooks
+	WHERE user_id = $1 AND deleted_at IS NULL
+	ORDER BY created_at;
+`
+
+func (d *database) ListWebhooksByUserID(ctx context.Context, userID models.UserID) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	if err := d.conn.SelectContext(ctx, &webhooks, listWebhooksByUserIDQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "could not get user's webhooks")
+	}
+
+	return webhooks, nil
+}
+
+const listGlobalWebhooksQuery = `
+	SELECT webhook_id, user_id, url, secret, event_types, active, created_at, deleted_at, updated_at, version
+	FROM webhooks
+	WHERE user_id IS NULL AND deleted_at IS NULL
+	ORDER BY created_at;
+`
+
+// ListGlobalWebhooks returns webhooks registered by admin, they receive events of all users
+func (d *database) ListGlobalWebhooks(ctx context.Context) ([]*models.Webhook, error) {
+	var webhooks []*models.Webhook
+	if err := d.conn.SelectContext(ctx, &webhooks, listGlobalWebhooksQuery); err != nil {
+		return nil, errors.Wrap(err, "could not get global webhooks")
+	}
+
+	return webhooks, nil
+}
+
+// * Pending deliveries of deleted webhook are not sent, delivery log stays
+const deleteWebhookQuery = `
+	WITH deleted AS (
+		UPDATE webhooks
+		SET deleted_at = NOW()
+		WHERE webhook_id = $1 AND deleted_at IS NULL AND version = $2
+		RETURNING webhook_id
+	), failed AS (
+		UPDATE webhook_deliveries wd
+		SET status = 'failed', last_error = 'webhook deleted'
+		FROM deleted
+		WHERE wd.webhook_id = deleted.webhook_id AND wd.status = 'pending'
+	)
+	SELECT COUNT(*) FROM deleted;
+`
+
+const webhookLiveQuery = `SELECT EXISTS (SELECT 1 FROM webhooks WHERE webhook_id = $1 AND deleted_at IS NULL);`
+
+// DeleteWebhook moves webhook to trash if it is still at version, which caller has read
+func (d *database) DeleteWebhook(ctx context.Context, webhookID models.WebhookID, version int64) (bool, error) {
+	var deleted int
+	if err := d.conn.GetContext(ctx, &deleted, deleteWebhookQuery, webhookID, version); err != nil {
+		return false, err
+	}
+
+	if deleted == 0 {
+		return false, checkDeleted(ctx, d.conn, webhookLiveQuery, webhookID)
+	}
+	return true, nil
+}
+
+// * Event gets delivery for every active webhook of its user and every global webhook which subscribes to it.
+// Events are locked, so concurrent dispatch doesn't create deliveries twice.
+const dispatchOutboxEventsQuery = `
+	WITH events AS (
+		SELECT event_id, user_id, event_type
+		FROM outbox_events
+		WHERE dispatched_at IS NULL
+		ORDER BY created_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	), deliveries AS (
+		INSERT INTO webhook_deliveries (webhook_id, event_id)
+		SELECT w.webhook_id, e.event_id
+		FROM events e
+		JOIN webhooks w ON (w.user_id = e.user_id OR w.user_id IS NULL)
+		     AND w.active AND w.deleted_at IS NULL
+		     AND (w.event_types = '{}' OR e.event_type = ANY(w.event_types))
+		ON CONFLICT (webhook_id, event_id) DO NOTHING
+	)
+	UPDATE outbox_events
+	SET dispatched_at = NOW()
+	WHERE event_id IN (SELECT event_id FROM events);
+`
+
+// DispatchOutboxEvents creates deliveries of events which were not dispatched yet and returns number of dispatched events
+func (d *database) DispatchOutboxEvents(ctx context.Context, limit int) (int64, error) {
+	result, err := d.conn.ExecContext(ctx, dispatchOutboxEventsQuery, limit)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not dispatch outbox events")
+	}
+
+	return result.RowsAffected()
+}
+
+const getOutboxEventByIDQuery = `
+	SELECT event_id, user_id, event_type, resource_id, payload, created_at, dispatched_at
+	FROM outbox_events
+	WHERE event_id = $1;
+`
+
+func (d *database) GetOutboxEventByID(ctx context.Context, eventID models.OutboxEventID) (*models.OutboxEvent, error) {
+	var event models.OutboxEvent
+	if err := d.conn.GetContext(ctx, &event, getOutboxEventByIDQuery, eventID); err != nil {
+		return nil, errors.Wrap(err, "could not get outbox event")
+	}
+
+	return &event, nil
+}
+
+// * Events created at the same time are ordered by event_id, so replay doesn't skip or repeat them
+const listOutboxEventsAfterQuery = `
+	SELECT e.event_id, e.user_id, e.event_type, e.resource_id, e.payload, e.created_at, e.dispatched_at
+	FROM outbox_events e, outbox_events after
+	WHERE after.event_id = $2
+	      AND e.user_id = $1
+	      AND (e.created_at, e.event_id) > (after.created_at, after.event_id)
+	ORDER BY e.created_at, e.event_id
+	LIMIT $3;
+`
+
+// ListOutboxEventsAfter returns events of user which were created after event, oldest first
+func (d *database) ListOutboxEventsAfter(ctx context.Context, userID models.UserID, eventID models.OutboxEventID, limit int) ([]*models.OutboxEvent, error) {
+	var events []*models.OutboxEvent
+	if err := d.conn.SelectContext(ctx, &events, listOutboxEventsAfterQuery, userID, eventID, limit); err != nil {
+		return nil, errors.Wrap(err, "could not get user's outbox events")
+	}
+
+	return events, nil
+}
+
+// * Claimed deliveries are hidden from other workers until lease, so delivery of crashed worker is retried after it
+const claimWebhookDeliveriesQuery = `
+	UPDATE webhook_deliveries
+	SET next_attempt_at = $2
+	WHERE delivery_id IN (
+		SELECT delivery_id
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= $1
+		ORDER BY next_attempt_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING delivery_id, webhook_id, event_id, status, attempts, response_status, last_error, next_attempt_at, sent_at, created_at;
+`
+
+// ClaimWebhookDeliveries returns pending deliveries which are due at now
+func (d *database) ClaimWebhookDeliveries(ctx context.Context, now, lease time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	var deliveries []*models.WebhookDelivery
+	if err := d.conn.SelectContext(ctx, &deliveries, claimWebhookDeliveriesQuery, now, lease, limit); err != nil {
+		return nil, errors.Wrap(err, "could not claim webhook deliveries")
+	}
+
+	return deliveries, nil
+}
+
+const updateWebhookDeliveryQuery = `
+	UPDATE webhook_deliveries
+	SET status = :status,
+	    attempts = :attempts,
+	    response_status = :response_status,
+	    last_error = :last_error,
+	    next_attempt_at = :next_attempt_at,
+	    sent_at = :sent_at
+	WHERE delivery_id = :delivery_id;
+`
+
+func (d *database) UpdateWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	_, err := d.conn.NamedExecContext(ctx, updateWebhookDeliveryQuery, delivery)
+	return errors.Wrap(err, "could not update webhook delivery")
+}
+
+const listWebhookDeliveriesQuery = `
+	SELECT wd.delivery_id, wd.webhook_id, wd.event_id, e.event_type, wd.status, wd.attempts, wd.response_status, wd.last_error,
+	       wd.next_attempt_at, wd.sent_at, wd.created_at
+	FROM webhook_deliveries wd
+	JOIN outbox_events e ON e.event_id = wd.event_id
+	WHERE wd.webhook_id = $1
+	ORDER BY wd.created_at DESC
+	LIMIT $2;
+`
+
+// ListWebhookDeliveries returns delivery log of webhook, newest first
+func (d *database) ListWebhookDeliveries(ctx context.Context, webhookID models.WebhookID, limit int) ([]*models.WebhookDelivery, error) {
+	if limit <= 0 {
+		limit = defaultWebhookDeliveryLimit
+	}
+	if limit > maxWebhookDeliveryLimit {
+		limit = maxWebhookDeliveryLimit
+	}
+
+	var deliveries []*models.WebhookDelivery
+	if err := d.conn.SelectContext(ctx, &deliveries, listWebhookDeliveriesQuery, webhookID, limit); err != nil {
+		return nil, errors.Wrap(err, "could not get webhook's deliveries")
+	}
+
+	return deliveries, nil
+}
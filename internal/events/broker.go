@@ -0,0 +1,132 @@
+package events
+
+import (
+	"context"
+	"finance/internal/database"
+	"finance/internal/models"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+)
+
+// subscriptionBuffer - events waiting for slow subscriber, newer events are dropped when buffer is full
+const subscriptionBuffer = 64
+
+// pingInterval - listener connection is checked when no notification comes for this long
+const pingInterval = 90 * time.Second
+
+// Subscription receives events of one user. Nil event means some events could be lost and client should resync.
+type Subscription struct {
+	UserID models.UserID
+	Events chan *models.OutboxEvent
+}
+
+// Broker receives outbox events announced by Postgres and passes them to subscriptions of event's user.
+// Every server instance has its own broker, so client gets events no matter which instance changed data.
+type Broker struct {
+	db database.WebhookDB
+
+	mu            sync.RWMutex
+	subscriptions map[models.UserID]map[*Subscription]struct{}
+}
+
+func NewBroker(db database.WebhookDB) *Broker {
+	return &Broker{
+		db:            db,
+		subscriptions: make(map[models.UserID]map[*Subscription]struct{}),
+	}
+}
+
+// Subscribe returns subscription to events of user, it must be closed with Unsubscribe
+func (b *Broker) Subscribe(userID models.UserID) *Subscription {
+	subscription := &Subscription{
+		UserID: userID,
+		Events: make(chan *models.OutboxEvent, subscriptionBuffer),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscriptions[userID] == nil {
+		b.subscriptions[userID] = make(map[*Subscription]struct{})
+	}
+	b.subscriptions[userID][subscription] = struct{}{}
+
+	return subscription
+}
+
+func (b *Broker) Unsubscribe(subscription *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subscriptions[subscription.UserID], subscription)
+	if len(b.subscriptions[subscription.UserID]) == 0 {
+		delete(b.subscriptions, subscription.UserID)
+	}
+}
+
+// Run passes notifications of listener to subscriptions until ctx is canceled
+func (b *Broker) Run(ctx context.Context, listener *pq.Listener) {
+	logger := logrus.WithField("func", "broker.go -> Run()")
+	defer listener.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Debug("Broker stopped")
+			return
+		case notification := <-listener.Notify:
+			// * Connection was lost and events sent in the meantime are not known
+			if notification == nil {
+				logger.Warn("Listener reconnected, subscribers are asked to resync.")
+				b.publishAll(nil)
+				continue
+			}
+
+			event, err := b.db.GetOutboxEventByID(ctx, models.OutboxEventID(notification.Extra))
+			if err != nil {
+				logger.WithError(err).WithField("event_id", notification.Extra).Warn("Error getting outbox event.")
+				continue
+			}
+			b.publish(*event.UserID, event)
+		case <-time.After(pingInterval):
+			if err := listener.Ping(); err != nil {
+				logger.WithError(err).Warn("Listener ping failed.")
+			}
+		}
+	}
+}
+
+func (b *Broker) publish(userID models.UserID, event *models.OutboxEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for subscription := range b.subscriptions[userID] {
+		send(subscription, event)
+	}
+}
+
+func (b *Broker) publishAll(event *models.OutboxEvent) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, subscriptions := range b.subscriptions {
+		for subscription := range subscriptions {
+			send(subscription, event)
+		}
+	}
+}
+
+// send - broker never waits for slow subscriber
+func send(subscription *Subscription, event *models.OutboxEvent) {
+	select {
+	case subscription.Events <- event:
+	default:
+		logrus.WithFields(logrus.Fields{
+			"func":    "broker.go -> send()",
+			"user_id": subscription.UserID,
+		}).Warn("Subscription is full, event dropped.")
+	}
+}
@@ -0,0 +1,95 @@
+package identity
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"finance/internal/config"
+	"finance/internal/models"
+	"fmt"
+
+	"github.com/namsral/flag"
+	"github.com/pkg/errors"
+)
+
+var (
+	googleClientID       = flag.String("google-client-id", "", "Google OAuth client ID. Google login is disabled if empty.")
+	googleClientSecret   = flag.String("google-client-secret", "", "Google OAuth client secret.")
+	facebookClientID     = flag.String("facebook-client-id", "", "Facebook app ID. Facebook login is disabled if empty.")
+	facebookClientSecret = flag.String("facebook-client-secret", "", "Facebook app secret.")
+)
+
+// ErrInvalidIdentity is returned when provider response can't be trusted
+var ErrInvalidIdentity = errors.New("invalid identity")
+
+// ErrUnverifiedAccount is returned when account with the same email exists but nobody proved owning its email.
+// Such account could be created by attacker before the owner came, so it is never linked automatically.
+var ErrUnverifiedAccount = errors.New("account with this email is not verified")
+
+// Provider is external identity provider. Login uses authorization code flow with PKCE.
+type Provider interface {
+	Name() string
+	// AuthCodeURL returns URL where user is redirected to login at provider
+	AuthCodeURL(state, nonce, codeChallenge string) (string, error)
+	// Exchange exchanges authorization code for user's identity
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*models.Identity, error)
+}
+
+// NewProviders creates providers configured with flags
+func NewProviders() map[string]Provider {
+	providers := make(map[string]Provider)
+
+	if *googleClientID != "" {
+		providers["google"] = NewOIDCProvider(OIDCConfig{
+			Name:         "google",
+			Issuer:       "https://accounts.google.com",
+			ClientID:     *googleClientID,
+			ClientSecret: *googleClientSecret,
+			RedirectURL:  RedirectURL("google"),
+			Scopes:       []string{"openid", "email"},
+		})
+	}
+
+	// * Facebook doesn't return ID token for web login, identity is loaded from Graph API. Facebook returns only confirmed emails.
+	if *facebookClientID != "" {
+		providers["facebook"] = NewOIDCProvider(OIDCConfig{
+			Name:         "facebook",
+			ClientID:     *facebookClientID,
+			ClientSecret: *facebookClientSecret,
+			RedirectURL:  RedirectURL("facebook"),
+			Scopes:       []string{"email"},
+			AuthURL:      "https://www.facebook.com/v15.0/dialog/oauth",
+			TokenURL:     "https://graph.facebook.com/v15.0/oauth/access_token",
+			UserInfoURL:  "https://graph.facebook.com/me?fields=id,email",
+			TrustEmail:   true,
+		})
+	}
+
+	return providers
+}
+
+// RedirectURL is callback URL registered at provider
+func RedirectURL(provider string) string {
+	return fmt.Sprintf("%s/api/%s/auth/%s/callback", *config.AppURL, config.Version, provider)
+}
+
+// NewCodeVerifier generates PKCE code verifier (RFC 7636)
+func NewCodeVerifier() (string, error) {
+	return RandomString(32)
+}
+
+// CodeChallenge returns S256 challenge of verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RandomString is used for state, nonce and code verifier
+func RandomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "could not generate random string")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,256 @@
+package identity
+
+import (
+	"context"
+	"encoding/json"
+	"finance/internal/models"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+// OIDCConfig configures OpenID Connect provider.
+// If Issuer is set, endpoints which are empty are loaded from discovery document.
+type OIDCConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	AuthURL     string
+	TokenURL    string
+	UserInfoURL string
+
+	// * Provider returns only confirmed emails but doesn't send email_verified claim
+	TrustEmail bool
+}
+
+// OIDCProvider implements authorization code flow with PKCE
+type OIDCProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu         sync.Mutex
+	discovered bool
+}
+
+func NewOIDCProvider(config OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *OIDCProvider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	if err := p.discover(context.Background()); err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", p.config.ClientID)
+	query.Set("redirect_uri", p.config.RedirectURL)
+	query.Set("scope", strings.Join(p.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge)
+	query.Set("code_challenge_method", "S256")
+
+	return p.config.AuthURL + "?" + query.Encode(), nil
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*models.Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.config.RedirectURL)
+	form.Set("client_id", p.config.ClientID)
+	form.Set("client_secret", p.config.ClientSecret)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var tokens tokenResponse
+	if err := p.doJSON(req, &tokens); err != nil {
+		return nil, errors.Wrap(err, "could not exchange code")
+	}
+
+	if tokens.IDToken != "" {
+		return p.identityFromIDToken(tokens.IDToken, nonce)
+	}
+	if tokens.AccessToken != "" && p.config.UserInfoURL != "" {
+		return p.identityFromUserInfo(ctx, tokens.AccessToken)
+	}
+	return nil, ErrInvalidIdentity
+}
+
+type idTokenClaims struct {
+	Nonce         string      `json:"nonce"`
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // some providers send "true" as string
+	jwt.StandardClaims
+}
+
+// * ID token is received directly from token endpoint over TLS, so we validate claims
+// * and rely on TLS instead of token signature (OpenID Connect Core 3.1.3.7).
+func (p *OIDCProvider) identityFromIDToken(idToken, nonce string) (*models.Identity, error) {
+	var claims idTokenClaims
+	if _, _, err := new(jwt.Parser).ParseUnverified(idToken, &claims); err != nil {
+		return nil, errors.Wrap(err, "could not parse id token")
+	}
+
+	if err := claims.Valid(); err != nil {
+		return nil, errors.Wrap(err, "id token expired")
+	}
+	if p.config.Issuer != "" && !claims.VerifyIssuer(p.config.Issuer, true) {
+		return nil, errors.Wrap(ErrInvalidIdentity, "wrong issuer")
+	}
+	if !claims.VerifyAudience(p.config.ClientID, true) {
+		return nil, errors.Wrap(ErrInvalidIdentity, "wrong audience")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.Wrap(ErrInvalidIdentity, "wrong nonce")
+	}
+	if claims.Subject == "" {
+		return nil, errors.Wrap(ErrInvalidIdentity, "subject is empty")
+	}
+
+	verified := p.config.TrustEmail
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+
+	return &models.Identity{
+		Provider:      p.config.Name,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: verified && claims.Email != "",
+	}, nil
+}
+
+type userInfo struct {
+	Subject       string `json:"sub"`
+	ID            string `json:"id"` // Facebook Graph API
+	Email         string `json:"email"`
+	EmailVerified *bool  `json:"email_verified"`
+}
+
+func (p *OIDCProvider) identityFromUserInfo(ctx context.Context, accessToken string) (*models.Identity, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.config.UserInfoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	var info userInfo
+	if err := p.doJSON(req, &info); err != nil {
+		return nil, errors.Wrap(err, "could not get user info")
+	}
+
+	subject := info.Subject
+	if subject == "" {
+		subject = info.ID
+	}
+	if subject == "" {
+		return nil, errors.Wrap(ErrInvalidIdentity, "subject is empty")
+	}
+
+	verified := p.config.TrustEmail
+	if info.EmailVerified != nil {
+		verified = *info.EmailVerified
+	}
+
+	return &models.Identity{
+		Provider:      p.config.Name,
+		Subject:       subject,
+		Email:         info.Email,
+		EmailVerified: verified && info.Email != "",
+	}, nil
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserInfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// discover loads endpoints from issuer. Failed discovery is retried with next request.
+func (p *OIDCProvider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovered || p.config.Issuer == "" {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return err
+	}
+
+	var document discoveryDocument
+	if err := p.doJSON(req, &document); err != nil {
+		return errors.Wrap(err, "could not load discovery document")
+	}
+	if document.Issuer != p.config.Issuer {
+		return errors.Errorf("discovery issuer %q doesn't match %q", document.Issuer, p.config.Issuer)
+	}
+
+	if p.config.AuthURL == "" {
+		p.config.AuthURL = document.AuthorizationEndpoint
+	}
+	if p.config.TokenURL == "" {
+		p.config.TokenURL = document.TokenEndpoint
+	}
+	if p.config.UserInfoURL == "" {
+		p.config.UserInfoURL = document.UserInfoEndpoint
+	}
+
+	p.discovered = true
+	return nil
+}
+
+func (p *OIDCProvider) doJSON(req *http.Request, dest interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, req.URL.Host)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
@@ -0,0 +1,167 @@
+package identity_test
+
+import (
+	"context"
+	"finance/internal/identity"
+	"finance/internal/identity/identitytest"
+	"net/url"
+	"testing"
+)
+
+const testRedirectURL = "http://app.test/api/v1/auth/test/callback"
+
+func newTestProvider(fake *identitytest.Provider) *identity.OIDCProvider {
+	return identity.NewOIDCProvider(identity.OIDCConfig{
+		Name:         "test",
+		Issuer:       fake.Issuer(),
+		ClientID:     fake.ClientID,
+		ClientSecret: fake.ClientSecret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"openid", "email"},
+	})
+}
+
+func TestDiscovery(t *testing.T) {
+	fake := identitytest.NewProvider("client", "secret")
+	defer fake.Close()
+
+	authURL, err := newTestProvider(fake).AuthCodeURL("state", "nonce", identity.CodeChallenge("verifier"))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("parse %q: %v", authURL, err)
+	}
+	if got, want := parsed.Scheme+"://"+parsed.Host+parsed.Path, fake.Issuer()+"/authorize"; got != want {
+		t.Errorf("authorization endpoint = %q, want %q", got, want)
+	}
+
+	query := parsed.Query()
+	for key, want := range map[string]string{
+		"client_id":             "client",
+		"redirect_uri":          testRedirectURL,
+		"state":                 "state",
+		"nonce":                 "nonce",
+		"code_challenge":        identity.CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+	} {
+		if got := query.Get(key); got != want {
+			t.Errorf("%s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	fake := identitytest.NewProvider("client", "secret")
+	defer fake.Close()
+
+	provider := identity.NewOIDCProvider(identity.OIDCConfig{
+		Name:        "test",
+		Issuer:      fake.Issuer() + "/other",
+		ClientID:    fake.ClientID,
+		RedirectURL: testRedirectURL,
+	})
+	if _, err := provider.AuthCodeURL("state", "nonce", "challenge"); err == nil {
+		t.Fatal("AuthCodeURL succeeded with wrong issuer")
+	}
+}
+
+func TestExchangeWithPKCE(t *testing.T) {
+	fake := identitytest.NewProvider("client", "secret")
+	defer fake.Close()
+
+	provider := newTestProvider(fake)
+	verifier, err := identity.NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authURL, err := provider.AuthCodeURL("state", "nonce", identity.CodeChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, state, err := fake.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if state != "state" {
+		t.Errorf("state = %q, want %q", state, "state")
+	}
+
+	externalIdentity, err := provider.Exchange(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if externalIdentity.Provider != "test" || externalIdentity.Subject != fake.Subject || externalIdentity.Email != fake.Email || !externalIdentity.EmailVerified {
+		t.Errorf("identity = %+v", externalIdentity)
+	}
+
+	// * Code is single-use
+	if _, err := provider.Exchange(context.Background(), code, verifier, "nonce"); err == nil {
+		t.Error("code was exchanged twice")
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	fake := identitytest.NewProvider("client", "secret")
+	defer fake.Close()
+
+	provider := newTestProvider(fake)
+	authURL, err := provider.AuthCodeURL("state", "nonce", identity.CodeChallenge("verifier"))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, _, err := fake.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	if _, err := provider.Exchange(context.Background(), code, "other-verifier", "nonce"); err == nil {
+		t.Fatal("Exchange succeeded with wrong code verifier")
+	}
+}
+
+func TestExchangeWrongNonce(t *testing.T) {
+	fake := identitytest.NewProvider("client", "secret")
+	defer fake.Close()
+
+	provider := newTestProvider(fake)
+	authURL, err := provider.AuthCodeURL("state", "nonce", identity.CodeChallenge("verifier"))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, _, err := fake.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	if _, err := provider.Exchange(context.Background(), code, "verifier", "other-nonce"); err == nil {
+		t.Fatal("Exchange succeeded with wrong nonce")
+	}
+}
+
+func TestExchangeUnverifiedEmail(t *testing.T) {
+	fake := identitytest.NewProvider("client", "secret")
+	defer fake.Close()
+	fake.EmailVerified = false
+
+	provider := newTestProvider(fake)
+	authURL, err := provider.AuthCodeURL("state", "nonce", identity.CodeChallenge("verifier"))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	code, _, err := fake.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+
+	externalIdentity, err := provider.Exchange(context.Background(), code, "verifier", "nonce")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if externalIdentity.EmailVerified {
+		t.Error("email is verified, provider said it isn't")
+	}
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Job is work which is repeated in background
+type Job func(ctx context.Context) error
+
+// Run calls job immediately and then every interval until ctx is canceled. Failed run is logged and retried with next tick.
+func Run(ctx context.Context, name string, interval time.Duration, job Job) {
+	logger := logrus.WithFields(logrus.Fields{
+		"func": "jobs.go -> Run()",
+		"job":  name,
+	})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(ctx); err != nil {
+			logger.WithError(err).Error("Job failed.")
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Debug("Job stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"context"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/notify"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationInterval is how often pending deliveries are sent
+var NotificationInterval = 15 * time.Second
+
+// DeliveryLease is how long claimed delivery is hidden from other workers
+var DeliveryLease = 5 * time.Minute
+
+// DeliveryBatch is maximum of deliveries sent in one run
+var DeliveryBatch = 100
+
+// BillDueInterval is how often bills are checked
+var BillDueInterval = time.Hour
+
+// BillDueAhead is how long before due date user is notified
+var BillDueAhead = 24 * time.Hour
+
+// DeliverNotifications sends pending deliveries by their channels. Failed delivery is retried with backoff.
+func DeliverNotifications(db database.NotificationDB, channels notify.Channels) Job {
+	return func(ctx context.Context) error {
+		now := time.Now()
+		deliveries, err := db.ClaimNotificationDeliveries(ctx, now, now.Add(DeliveryLease), DeliveryBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			logger := logrus.WithFields(logrus.Fields{
+				"func":        "notifications.go -> DeliverNotifications()",
+				"delivery_id": delivery.ID,
+				"channel":     delivery.Channel,
+			})
+
+			if err := send(ctx, db, channels, delivery); err != nil {
+				logger.WithError(err).Warn("Notification delivery failed.")
+				delivery.Fail(err, time.Now())
+			} else {
+				delivery.Sent(time.Now())
+			}
+
+			if err := db.UpdateNotificationDelivery(ctx, delivery); err != nil {
+				logger.WithError(err).Warn("Error storing notification delivery.")
+			}
+		}
+
+		return nil
+	}
+}
+
+func send(ctx context.Context, db database.NotificationDB, channels notify.Channels, delivery *models.NotificationDelivery) error {
+	channel, ok := channels[delivery.Channel]
+	if !ok {
+		return errors.Errorf("unknown channel %q", delivery.Channel)
+	}
+
+	notification, err := db.GetNotificationByID(ctx, delivery.NotificationID)
+	if err != nil {
+		return err
+	}
+
+	return channel.Send(ctx, notification, delivery)
+}
+
+// NotifyBillsDue notifies users about bills which are due soon or overdue. Every occurrence is notified once.
+func NotifyBillsDue(db database.Database) Job {
+	return func(ctx context.Context) error {
+		bills, err := db.ListBillsDueBefore(ctx, time.Now().Add(BillDueAhead))
+		if err != nil {
+			return err
+		}
+
+		for _, bill := range bills {
+			if _, err := notify.BillDue(ctx, db, bill); err != nil {
+				logrus.WithFields(logrus.Fields{
+					"func":    "notifications.go -> NotifyBillsDue()",
+					"bill_id": bill.ID,
+				}).WithError(err).Warn("Error notifying due bill.")
+			}
+		}
+
+		return nil
+	}
+}
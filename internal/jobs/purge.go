@@ -0,0 +1,33 @@
+package jobs
+
+import (
+	"context"
+	"finance/internal/config"
+	"finance/internal/database"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// PurgeInterval is how often trash is checked for expired rows
+var PurgeInterval = time.Hour
+
+// TrashRetention is how long deleted rows stay in trash
+func TrashRetention() time.Duration {
+	return time.Duration(*config.TrashRetentionDays) * 24 * time.Hour
+}
+
+// PurgeTrash permanently removes rows which are in trash longer than retention period
+func PurgeTrash(db database.TrashDB) Job {
+	return func(ctx context.Context) error {
+		purged, err := db.PurgeTrash(ctx, time.Now().Add(-TrashRetention()))
+		if err != nil {
+			return err
+		}
+
+		if purged > 0 {
+			logrus.WithField("rows", purged).Info("Trash purged")
+		}
+		return nil
+	}
+}
@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"context"
+	"finance/internal/database"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UserDeletionInterval is how often pending deletions are continued
+var UserDeletionInterval = time.Minute
+
+// DeleteUsers continues every unfinished user deletion from its last completed step.
+// Failure of one user doesn't stop others, failed step is retried with next run.
+func DeleteUsers(db database.UserDataDB) Job {
+	return func(ctx context.Context) error {
+		deletions, err := db.ListPendingUserDeletions(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, deletion := range deletions {
+			logger := logrus.WithFields(logrus.Fields{
+				"func":    "user_deletion.go -> DeleteUsers()",
+				"user_id": deletion.UserID,
+			})
+
+			for _, step := range deletion.RemainingSteps() {
+				if err := db.RunUserDeletionStep(ctx, deletion.UserID, step); err != nil {
+					logger.WithError(err).WithField("step", step).Warn("User deletion step failed.")
+					if err := db.FailUserDeletion(ctx, deletion.UserID, err); err != nil {
+						logger.WithError(err).Warn("Error storing user deletion failure.")
+					}
+					break
+				}
+				logger.WithField("step", step).Debug("User deletion step completed")
+			}
+		}
+
+		return nil
+	}
+}
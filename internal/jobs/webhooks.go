@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"context"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/webhooks"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WebhookInterval is how often outbox is dispatched and pending deliveries are sent
+var WebhookInterval = 10 * time.Second
+
+// OutboxBatch is maximum of outbox events dispatched in one run
+var OutboxBatch = 500
+
+// DeliverWebhooks creates deliveries of new outbox events and sends pending deliveries.
+// Failed delivery is retried with backoff.
+func DeliverWebhooks(db database.WebhookDB, sender *webhooks.Sender) Job {
+	return func(ctx context.Context) error {
+		if _, err := db.DispatchOutboxEvents(ctx, OutboxBatch); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		deliveries, err := db.ClaimWebhookDeliveries(ctx, now, now.Add(DeliveryLease), DeliveryBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range deliveries {
+			logger := logrus.WithFields(logrus.Fields{
+				"func":        "webhooks.go -> DeliverWebhooks()",
+				"delivery_id": delivery.ID,
+				"webhook_id":  delivery.WebhookID,
+			})
+
+			if status, err := sendWebhook(ctx, db, sender, delivery); err != nil {
+				logger.WithError(err).Warn("Webhook delivery failed.")
+				var responseStatus *int
+				if status != 0 {
+					responseStatus = &status
+				}
+				delivery.Fail(err, responseStatus, time.Now())
+			} else {
+				delivery.Sent(status, time.Now())
+			}
+
+			if err := db.UpdateWebhookDelivery(ctx, delivery); err != nil {
+				logger.WithError(err).Warn("Error storing webhook delivery.")
+			}
+		}
+
+		return nil
+	}
+}
+
+func sendWebhook(ctx context.Context, db database.WebhookDB, sender *webhooks.Sender, delivery *models.WebhookDelivery) (int, error) {
+	webhook, err := db.GetWebhookByID(ctx, delivery.WebhookID)
+	if err != nil {
+		return 0, err
+	}
+
+	event, err := db.GetOutboxEventByID(ctx, delivery.EventID)
+	if err != nil {
+		return 0, err
+	}
+
+	return sender.Send(ctx, webhook, event, delivery.ID)
+}
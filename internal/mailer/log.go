@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// LogMailer doesn't send emails. It logs them and writes them to directory if it is set.
+type LogMailer struct {
+	directory string
+	from      string
+}
+
+func NewLogMailer(directory, from string) *LogMailer {
+	return &LogMailer{
+		directory: directory,
+		from:      from,
+	}
+}
+
+func (m *LogMailer) Send(ctx context.Context, message Message) error {
+	logrus.WithFields(logrus.Fields{
+		"from":    m.from,
+		"to":      message.To,
+		"subject": message.Subject,
+	}).Info(message.Body)
+
+	if m.directory == "" {
+		return nil
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), message.To)
+	content := fmt.Sprintf("From: %s\nTo: %s\nSubject: %s\n\n%s\n", m.from, message.To, message.Subject, message.Body)
+	if err := os.WriteFile(filepath.Join(m.directory, name), []byte(content), 0o600); err != nil {
+		return errors.Wrap(err, "could not write email")
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+
+	"github.com/namsral/flag"
+	"github.com/pkg/errors"
+)
+
+var (
+	mailerType   = flag.String("mailer", "log", "Mailer used to send emails: smtp or log.")
+	mailFrom     = flag.String("mail-from", "no-reply@finance.local", "Sender address of emails.")
+	mailDir      = flag.String("mail-directory", "", "Directory where log mailer writes emails. Emails are only logged if empty.")
+	smtpHost     = flag.String("smtp-host", "localhost", "SMTP server host.")
+	smtpPort     = flag.Int("smtp-port", 587, "SMTP server port.")
+	smtpUsername = flag.String("smtp-username", "", "SMTP username.")
+	smtpPassword = flag.String("smtp-password", "", "SMTP password.")
+)
+
+// Message is an email sent to user
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails. SMTP mailer is used in production, log mailer for local development and tests.
+type Mailer interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// New creates mailer selected with flags
+func New() (Mailer, error) {
+	switch *mailerType {
+	case "smtp":
+		return NewSMTPMailer(*smtpHost, *smtpPort, *smtpUsername, *smtpPassword, *mailFrom), nil
+	case "log":
+		return NewLogMailer(*mailDir, *mailFrom), nil
+	}
+	return nil, errors.Errorf("unknown mailer %q", *mailerType)
+}
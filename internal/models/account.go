@@ -16,10 +16,33 @@ var NilAccountID AccountID
 type AccountType string
 
 const (
-	Cash   AccountType = "cash"
-	Credit AccountType = "credit"
+	Cash       AccountType = "cash"
+	Credit     AccountType = "credit"
+	Savings    AccountType = "savings"
+	Investment AccountType = "investment"
+	Loan       AccountType = "loan"
+	Wallet     AccountType = "wallet"
 )
 
+// AccountTypes - all supported types, database has the same check constraint
+var AccountTypes = []AccountType{Cash, Credit, Savings, Investment, Loan, Wallet}
+
+// IsValid - type is one of supported types
+func (t AccountType) IsValid() bool {
+	for _, accountType := range AccountTypes {
+		if t == accountType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsLiability - balance of account of this type is money owed, not money owned
+func (t AccountType) IsLiability() bool {
+	return t == Credit || t == Loan
+}
+
 // Account is structure for Account
 type Account struct {
 	ID           AccountID    `json:"id,omitempty" db:"account_id"`
@@ -30,6 +53,28 @@ type Account struct {
 	Currency     *string      `json:"currency,omitempty" db:"currency"`
 	CreatedAt    *time.Time   `json:"-" db:"created_at"`
 	DeletedAt    *time.Time   `json:"-" db:"deleted_at"`
+
+	// Credit account settings
+	CreditLimit   *int64 `json:"credit_limit,omitempty" db:"credit_limit"`
+	StatementDay  *int   `json:"statement_day,omitempty" db:"statement_day"`     // day of month when statement closes
+	PaymentDueDay *int   `json:"payment_due_day,omitempty" db:"payment_due_day"` // day of month when payment for closed statement is due
+	APR           *int64 `json:"apr,omitempty" db:"apr"`                         // annual percentage rate in basis points
+
+	// Savings and loan account settings
+	InterestRate  *int64     `json:"interest_rate,omitempty" db:"interest_rate"` // annual rate in basis points
+	LoanPrincipal *int64     `json:"loan_principal,omitempty" db:"loan_principal"`
+	LoanTerm      *int       `json:"loan_term,omitempty" db:"loan_term"` // number of monthly payments
+	LoanStart     *time.Time `json:"loan_start,omitempty" db:"loan_start"`
+
+	// * Maintained by database on every change, change_seq orders changes for sync
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+	ChangeSeq int64      `json:"-" db:"change_seq"`
+}
+
+// IsLiability - balance of account is money owed, not money owned
+func (a *Account) IsLiability() bool {
+	return a.Type != nil && a.Type.IsLiability()
 }
 
 func (a *Account) Verify() error {
@@ -45,6 +90,10 @@ func (a *Account) Verify() error {
 		return errors.New("type is required")
 	}
 
+	if !a.Type.IsValid() {
+		return errors.Errorf("type must be one of %v", AccountTypes)
+	}
+
 	if a.StartBalance == nil {
 		return errors.New("startBalance is required")
 	}
@@ -53,5 +102,56 @@ func (a *Account) Verify() error {
 		return errors.New("currency is required")
 	}
 
+	switch *a.Type {
+	case Credit:
+		return a.verifyCredit()
+	case Savings:
+		if a.InterestRate != nil && *a.InterestRate < 0 {
+			return errors.New("interest_rate can't be negative")
+		}
+	case Loan:
+		return a.verifyLoan()
+	}
+
+	return nil
+}
+
+func (a *Account) verifyCredit() error {
+	if a.CreditLimit == nil || *a.CreditLimit < 0 {
+		return errors.New("credit_limit is required")
+	}
+
+	if a.StatementDay == nil || *a.StatementDay < 1 || *a.StatementDay > 31 {
+		return errors.New("statement_day must be between 1 and 31")
+	}
+
+	if a.PaymentDueDay == nil || *a.PaymentDueDay < 1 || *a.PaymentDueDay > 31 {
+		return errors.New("payment_due_day must be between 1 and 31")
+	}
+
+	if a.APR != nil && *a.APR < 0 {
+		return errors.New("apr can't be negative")
+	}
+
+	return nil
+}
+
+func (a *Account) verifyLoan() error {
+	if a.LoanPrincipal == nil || *a.LoanPrincipal <= 0 {
+		return errors.New("loan_principal is required")
+	}
+
+	if a.InterestRate == nil || *a.InterestRate < 0 {
+		return errors.New("interest_rate is required")
+	}
+
+	if a.LoanTerm == nil || *a.LoanTerm <= 0 {
+		return errors.New("loan_term is required")
+	}
+
+	if a.LoanStart == nil {
+		return errors.New("loan_start is required")
+	}
+
 	return nil
 }
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// AmortizationPayment is one monthly payment of loan
+type AmortizationPayment struct {
+	Number    int       `json:"number"`
+	Date      time.Time `json:"date"`
+	Payment   int64     `json:"payment"`
+	Principal int64     `json:"principal"`
+	Interest  int64     `json:"interest"`
+	Balance   int64     `json:"balance"` // principal left after payment
+}
+
+// AmortizationSchedule is schedule of equal monthly payments which repay loan in its term
+type AmortizationSchedule struct {
+	AccountID      AccountID              `json:"account_id,omitempty"`
+	DebtID         DebtID                 `json:"debt_id,omitempty"`
+	Principal      int64                  `json:"principal"`
+	InterestRate   int64                  `json:"interest_rate"`
+	Term           int                    `json:"term"`
+	MonthlyPayment int64                  `json:"monthly_payment"`
+	TotalInterest  int64                  `json:"total_interest"`
+	Payments       []*AmortizationPayment `json:"payments"`
+}
+
+// NewAmortizationSchedule - schedule of loan account
+func NewAmortizationSchedule(account *Account) *AmortizationSchedule {
+	schedule := amortize(*account.LoanPrincipal, *account.InterestRate, *account.LoanTerm, *account.LoanStart)
+	schedule.AccountID = account.ID
+	return schedule
+}
+
+// amortize - schedule of equal monthly payments, first payment is month after start.
+// Amounts are rounded to minor units, last payment takes the rounding difference.
+func amortize(principal, rate int64, term int, start time.Time) *AmortizationSchedule {
+	schedule := AmortizationSchedule{
+		Principal:      principal,
+		InterestRate:   rate,
+		Term:           term,
+		MonthlyPayment: MonthlyPayment(principal, rate, term),
+		Payments:       make([]*AmortizationPayment, 0, term),
+	}
+
+	monthlyRate := float64(rate) / 10000 / 12
+	balance := principal
+	for number := 1; number <= term; number++ {
+		interest := int64(math.Round(float64(balance) * monthlyRate))
+		payment := schedule.MonthlyPayment
+		if number == term || payment > balance+interest {
+			payment = balance + interest
+		}
+
+		balance -= payment - interest
+		schedule.TotalInterest += interest
+		schedule.Payments = append(schedule.Payments, &AmortizationPayment{
+			Number:    number,
+			Date:      dayOfMonth(start.Year(), start.Month()+time.Month(number), start.Day(), start.Location()),
+			Payment:   payment,
+			Principal: payment - interest,
+			Interest:  interest,
+			Balance:   balance,
+		})
+
+		if balance == 0 {
+			break
+		}
+	}
+
+	return &schedule
+}
+
+// MonthlyPayment - annuity payment of principal with annual rate in basis points over term months
+func MonthlyPayment(principal, rate int64, term int) int64 {
+	if rate == 0 {
+		return int64(math.Ceil(float64(principal) / float64(term)))
+	}
+
+	monthlyRate := float64(rate) / 10000 / 12
+	return int64(math.Round(float64(principal) * monthlyRate / (1 - math.Pow(1+monthlyRate, -float64(term)))))
+}
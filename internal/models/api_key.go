@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// APIKeyID is identifier of APIKey
+type APIKeyID string
+
+// NilAPIKeyID is an empty identifier of APIKey
+var NilAPIKeyID APIKeyID
+
+// APIKeyScope limits what API key can do
+type APIKeyScope string
+
+const (
+	// Key can only read data (GET requests)
+	ScopeRead APIKeyScope = "read"
+	// Key can do everything user can do
+	ScopeWrite APIKeyScope = "write"
+)
+
+// APIKey is personal key used by scripts and integrations instead of password
+type APIKey struct {
+	ID         APIKeyID    `json:"id,omitempty" db:"api_key_id"`
+	UserID     *UserID     `json:"user_id,omitempty" db:"user_id"`
+	Name       *string     `json:"name,omitempty" db:"name"`
+	Prefix     string      `json:"prefix" db:"prefix"`
+	KeyHash    string      `json:"-" db:"key_hash"`
+	Scope      APIKeyScope `json:"scope" db:"scope"`
+	CreatedAt  *time.Time  `json:"created_at,omitempty" db:"created_at"`
+	ExpiresAt  *time.Time  `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time  `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time  `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+func (k *APIKey) Verify() error {
+	if k.UserID == nil || len(*k.UserID) == 0 {
+		return errors.New("user_id is required")
+	}
+
+	if k.Name == nil || len(*k.Name) == 0 {
+		return errors.New("name is required")
+	}
+
+	if k.Scope != ScopeRead && k.Scope != ScopeWrite {
+		return errors.New("scope must be read or write")
+	}
+
+	if k.ExpiresAt != nil && k.ExpiresAt.Before(time.Now()) {
+		return errors.New("expires_at must be in future")
+	}
+
+	return nil
+}
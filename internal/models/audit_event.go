@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEventID is identifier of AuditEvent
+type AuditEventID string
+
+// AuditAction is what was done with resource
+type AuditAction string
+
+const (
+	AuditCreate  AuditAction = "create"
+	AuditUpdate  AuditAction = "update"
+	AuditDelete  AuditAction = "delete"
+	AuditRestore AuditAction = "restore"
+
+	AuditFinalize AuditAction = "finalize"
+
+	AuditClosePeriod  AuditAction = "close_period"
+	AuditReopenPeriod AuditAction = "reopen_period"
+)
+
+// AuditResourceType is type of changed resource
+type AuditResourceType string
+
+const (
+	AuditUser        AuditResourceType = "user"
+	AuditRole        AuditResourceType = "role"
+	AuditSession     AuditResourceType = "session"
+	AuditAPIKey      AuditResourceType = "api_key"
+	AuditTwoFactor   AuditResourceType = "two_factor"
+	AuditIdentity    AuditResourceType = "identity"
+	AuditAccount     AuditResourceType = "account"
+	AuditCategory    AuditResourceType = "category"
+	AuditMerchant    AuditResourceType = "merchant"
+	AuditTransaction AuditResourceType = "transaction"
+	AuditBackup      AuditResourceType = "backup"
+
+	AuditReconciliation AuditResourceType = "reconciliation"
+	AuditPeriod         AuditResourceType = "period"
+	AuditDebt           AuditResourceType = "debt"
+	AuditSecurity       AuditResourceType = "security"
+	AuditSecurityPrice  AuditResourceType = "security_price"
+	AuditTrade          AuditResourceType = "trade"
+	AuditGoal           AuditResourceType = "goal"
+	AuditContribution   AuditResourceType = "goal_contribution"
+	AuditBill           AuditResourceType = "bill"
+	AuditBillPayment    AuditResourceType = "bill_payment"
+
+	AuditNotificationPreference AuditResourceType = "notification_preference"
+	AuditWebhook                AuditResourceType = "webhook"
+)
+
+// AuditEvent is record of mutating operation
+type AuditEvent struct {
+	ID           AuditEventID      `json:"id,omitempty" db:"audit_event_id"`
+	PrincipalID  *UserID           `json:"principal_id,omitempty" db:"principal_id"`
+	APIKeyID     *APIKeyID         `json:"api_key_id,omitempty" db:"api_key_id"`
+	UserID       *UserID           `json:"user_id,omitempty" db:"user_id"`
+	Action       AuditAction       `json:"action" db:"action"`
+	ResourceType AuditResourceType `json:"resource_type" db:"resource_type"`
+	ResourceID   string            `json:"resource_id" db:"resource_id"`
+	Before       *json.RawMessage  `json:"before,omitempty" db:"before"`
+	After        *json.RawMessage  `json:"after,omitempty" db:"after"`
+	IPAddress    string            `json:"ip_address" db:"ip_address"`
+	RequestID    string            `json:"request_id" db:"request_id"`
+	CreatedAt    *time.Time        `json:"created_at,omitempty" db:"created_at"`
+}
+
+// AuditFilter is used to search audit events. Empty fields are not used.
+type AuditFilter struct {
+	UserID       UserID
+	PrincipalID  UserID
+	Action       AuditAction
+	ResourceType AuditResourceType
+	ResourceID   string
+	From         time.Time
+	To           time.Time
+	Limit        int
+}
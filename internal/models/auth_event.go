@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// AuthEventType is type of security event
+type AuthEventType string
+
+const (
+	LoginFailed AuthEventType = "login_failed"
+	LoginLocked AuthEventType = "login_locked"
+)
+
+// AuthEvent is security event related to authentication
+type AuthEvent struct {
+	ID        string        `json:"id,omitempty" db:"auth_event_id"`
+	Type      AuthEventType `json:"type" db:"event_type"`
+	UserID    *UserID       `json:"user_id,omitempty" db:"user_id"`
+	Email     string        `json:"email" db:"email"`
+	IPAddress string        `json:"ip_address" db:"ip_address"`
+	UserAgent string        `json:"user_agent" db:"user_agent"`
+	CreatedAt *time.Time    `json:"created_at,omitempty" db:"created_at"`
+}
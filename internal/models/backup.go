@@ -0,0 +1,129 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BackupVersion is version of backup format. It is increased when format changes in incompatible way.
+const BackupVersion = 1
+
+// Backup is user's ledger which can be imported into another user or environment.
+// IDs are used only to keep relationships inside of backup, new IDs are generated on import.
+type Backup struct {
+	Version      int            `json:"version"`
+	CreatedAt    *time.Time     `json:"created_at,omitempty"`
+	Accounts     []*Account     `json:"accounts"`
+	Categories   []*Category    `json:"categories"`
+	Merchants    []*Merchant    `json:"merchants"`
+	Transactions []*Transaction `json:"transactions"`
+}
+
+// BackupImport is number of imported rows
+type BackupImport struct {
+	Accounts     int `json:"accounts"`
+	Categories   int `json:"categories"`
+	Merchants    int `json:"merchants"`
+	Transactions int `json:"transactions"`
+}
+
+// Verify checks version, required fields and that all references point inside of backup.
+// Every row gets userID, so backup of one user can't write data of another.
+func (b *Backup) Verify(userID UserID) error {
+	if b.Version != BackupVersion {
+		return errors.Errorf("unsupported backup version %d, expected %d", b.Version, BackupVersion)
+	}
+
+	accounts := make(map[AccountID]bool, len(b.Accounts))
+	for _, account := range b.Accounts {
+		account.UserID = &userID
+		if err := account.Verify(); err != nil {
+			return errors.Wrapf(err, "account %s", account.ID)
+		}
+		if account.ID == NilAccountID || accounts[account.ID] {
+			return errors.Errorf("account id %q is empty or duplicated", account.ID)
+		}
+		accounts[account.ID] = true
+	}
+
+	if _, err := b.SortedCategories(); err != nil {
+		return err
+	}
+	categories := make(map[CategoryID]bool, len(b.Categories))
+	for _, category := range b.Categories {
+		category.UserID = &userID
+		if err := category.Verify(); err != nil {
+			return errors.Wrapf(err, "category %s", category.ID)
+		}
+		categories[category.ID] = true
+	}
+
+	for _, merchant := range b.Merchants {
+		merchant.UserID = &userID
+		if err := merchant.Verify(); err != nil {
+			return errors.Wrapf(err, "merchant %s", merchant.ID)
+		}
+	}
+
+	for _, transaction := range b.Transactions {
+		transaction.UserID = &userID
+		transaction.DebtID = nil // * debts are not part of backup
+		if err := transaction.Verify(); err != nil {
+			return errors.Wrapf(err, "transaction %s", transaction.ID)
+		}
+		if !accounts[*transaction.AccountID] {
+			return errors.Errorf("transaction %s references unknown account %s", transaction.ID, *transaction.AccountID)
+		}
+		if !categories[*transaction.CategoryID] {
+			return errors.Errorf("transaction %s references unknown category %s", transaction.ID, *transaction.CategoryID)
+		}
+	}
+
+	return nil
+}
+
+// SortedCategories returns categories with every parent before its children, so parent's new ID is known when child is inserted
+func (b *Backup) SortedCategories() ([]*Category, error) {
+	byID := make(map[CategoryID]*Category, len(b.Categories))
+	for _, category := range b.Categories {
+		if category.ID == NilCategoryID || byID[category.ID] != nil {
+			return nil, errors.Errorf("category id %q is empty or duplicated", category.ID)
+		}
+		byID[category.ID] = category
+	}
+
+	sorted := make([]*Category, 0, len(b.Categories))
+	state := make(map[CategoryID]int, len(b.Categories)) // 1 - visiting, 2 - done
+
+	var visit func(category *Category) error
+	visit = func(category *Category) error {
+		switch state[category.ID] {
+		case 1:
+			return errors.Errorf("category %s is its own ancestor", category.ID)
+		case 2:
+			return nil
+		}
+
+		state[category.ID] = 1
+		if category.ParentID != NilCategoryID {
+			parent, ok := byID[category.ParentID]
+			if !ok {
+				return errors.Errorf("category %s references unknown parent %s", category.ID, category.ParentID)
+			}
+			if err := visit(parent); err != nil {
+				return err
+			}
+		}
+		state[category.ID] = 2
+		sorted = append(sorted, category)
+		return nil
+	}
+
+	for _, category := range b.Categories {
+		if err := visit(category); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
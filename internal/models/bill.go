@@ -0,0 +1,198 @@
+package models
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxOccurrences - limit of occurrences of one bill in calendar
+const maxOccurrences = 500
+
+// BillID is identifier of Bill
+type BillID string
+
+// NilBillID is an empty identifier of Bill
+var NilBillID BillID
+
+// BillPaymentID is identifier of BillPayment
+type BillPaymentID string
+
+// BillFrequency is how often bill repeats
+type BillFrequency string
+
+const (
+	Once    BillFrequency = "once"
+	Weekly  BillFrequency = "weekly"
+	Monthly BillFrequency = "monthly"
+	Yearly  BillFrequency = "yearly"
+)
+
+// BillStatus is state of bill occurrence in calendar
+type BillStatus string
+
+const (
+	BillUpcoming BillStatus = "upcoming"
+	BillOverdue  BillStatus = "overdue"
+	BillPaid     BillStatus = "paid"
+)
+
+// Bill is payment which is due at due date. Due date of recurring bill is date of its next unpaid occurrence.
+type Bill struct {
+	ID        BillID         `json:"id,omitempty" db:"bill_id"`
+	UserID    *UserID        `json:"user_id,omitempty" db:"user_id"`
+	AccountID *AccountID     `json:"account_id,omitempty" db:"account_id"`
+	Name      *string        `json:"name,omitempty" db:"name"`
+	Amount    *int64         `json:"amount,omitempty" db:"amount"`
+	DueDate   *time.Time     `json:"due_date,omitempty" db:"due_date"`
+	DueDay    int            `json:"-" db:"due_day"` // day of month which due date keeps, set with due date
+	Frequency *BillFrequency `json:"frequency,omitempty" db:"frequency"`
+	PaidAt    *time.Time     `json:"paid_at,omitempty" db:"paid_at"` // set when one-time bill is paid
+	Notes     *string        `json:"notes,omitempty" db:"notes"`
+	CreatedAt *time.Time     `json:"-" db:"created_at"`
+	DeletedAt *time.Time     `json:"-" db:"deleted_at"`
+
+	// * Maintained by database on every change
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+}
+
+// BillPayment links occurrence of bill with transaction which paid it
+type BillPayment struct {
+	ID            BillPaymentID  `json:"id,omitempty" db:"payment_id"`
+	BillID        *BillID        `json:"bill_id,omitempty" db:"bill_id"`
+	UserID        *UserID        `json:"user_id,omitempty" db:"user_id"`
+	TransactionID *TransactionID `json:"transaction_id,omitempty" db:"transaction_id"`
+	DueDate       *time.Time     `json:"due_date,omitempty" db:"due_date"`
+	PaidAt        *time.Time     `json:"paid_at,omitempty" db:"paid_at"`
+}
+
+// CalendarEntry is one occurrence of bill
+type CalendarEntry struct {
+	BillID        BillID         `json:"bill_id"`
+	Name          string         `json:"name"`
+	Amount        int64          `json:"amount"`
+	DueDate       time.Time      `json:"due_date"`
+	Status        BillStatus     `json:"status"`
+	TransactionID *TransactionID `json:"transaction_id,omitempty"`
+}
+
+func (c *Bill) Verify() error {
+	if c.UserID == nil || len(*c.UserID) == 0 {
+		return errors.New("user_id is required")
+	}
+
+	if c.Name == nil || len(*c.Name) == 0 {
+		return errors.New("name is required")
+	}
+
+	if c.Amount == nil || *c.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+
+	if c.DueDate == nil {
+		return errors.New("due_date is required")
+	}
+
+	if c.Frequency == nil {
+		return errors.New("frequency is required")
+	}
+	switch *c.Frequency {
+	case Once, Weekly, Monthly, Yearly:
+	default:
+		return errors.Errorf("unknown frequency %q", *c.Frequency)
+	}
+
+	return nil
+}
+
+// IsPaid - one-time bill which is paid, recurring bill is never paid
+func (c *Bill) IsPaid() bool {
+	return c.PaidAt != nil
+}
+
+// SetDueDate - due date set by user is also the day of month of next occurrences
+func (c *Bill) SetDueDate(date time.Time) {
+	c.DueDate = &date
+	c.DueDay = date.Day()
+}
+
+// NextDueDate - due date of occurrence after date. Monthly and yearly bills keep day of month of the bill,
+// it is moved to the last day of shorter months and back to the day of month in longer ones.
+func (c *Bill) NextDueDate(date time.Time) time.Time {
+	day := c.DueDay
+	if day == 0 {
+		day = c.DueDate.Day()
+	}
+
+	var next time.Time
+	switch *c.Frequency {
+	case Weekly:
+		return date.AddDate(0, 0, 7)
+	case Monthly:
+		next = dayOfMonth(date.Year(), date.Month()+1, day, date.Location())
+	case Yearly:
+		next = dayOfMonth(date.Year()+1, date.Month(), day, date.Location())
+	default:
+		return date
+	}
+
+	return time.Date(next.Year(), next.Month(), next.Day(), date.Hour(), date.Minute(), date.Second(), date.Nanosecond(), date.Location())
+}
+
+// NewCalendar - occurrences of bills and payments between from and to. Unpaid occurrences before now are overdue
+// and they are listed even if they are before from.
+func NewCalendar(bills []*Bill, payments []*BillPayment, from, to, now time.Time) []*CalendarEntry {
+	entries := make([]*CalendarEntry, 0)
+	byID := make(map[BillID]*Bill, len(bills))
+	for _, bill := range bills {
+		byID[bill.ID] = bill
+		if bill.IsPaid() {
+			continue
+		}
+
+		due := *bill.DueDate
+		for i := 0; i < maxOccurrences && !due.After(to); i++ {
+			if due.Before(now) || !due.Before(from) {
+				status := BillUpcoming
+				if due.Before(now) {
+					status = BillOverdue
+				}
+				entries = append(entries, &CalendarEntry{
+					BillID:  bill.ID,
+					Name:    *bill.Name,
+					Amount:  *bill.Amount,
+					DueDate: due,
+					Status:  status,
+				})
+			}
+
+			if *bill.Frequency == Once {
+				break
+			}
+			due = bill.NextDueDate(due)
+		}
+	}
+
+	for _, payment := range payments {
+		bill, ok := byID[*payment.BillID]
+		if !ok {
+			continue
+		}
+		entries = append(entries, &CalendarEntry{
+			BillID:        bill.ID,
+			Name:          *bill.Name,
+			Amount:        *bill.Amount,
+			DueDate:       *payment.DueDate,
+			Status:        BillPaid,
+			TransactionID: payment.TransactionID,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].DueDate.Before(entries[j].DueDate)
+	})
+
+	return entries
+}
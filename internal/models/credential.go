@@ -10,18 +10,28 @@ type Credential struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 
-	// * In future we will have google and facebook login as well
+	// * Google and facebook login use identity providers (see /auth/{provider}/login)
 }
 
 // * Principal is an authenticated entity
 type Principal struct {
 	UserID UserID `json:"userID,omitempty"`
+
+	// * Set if request is authenticated with API key instead of access token
+	APIKeyID APIKeyID `json:"apiKeyID,omitempty"`
+
+	// * Session and token version of access token, long requests check them again while they run
+	SessionID    FamilyID `json:"-"`
+	TokenVersion int64    `json:"-"`
 }
 
 // * NilPrincipal is an uninitialized Principal
 var NilPrincipal Principal
 
 func (p Principal) String() string {
+	if p.UserID != "" && p.APIKeyID != "" {
+		return fmt.Sprintf("UserID[%s] APIKeyID[%s]", p.UserID, p.APIKeyID)
+	}
 	if p.UserID != "" {
 		return fmt.Sprintf("UserID[%s]", p.UserID)
 	}
@@ -0,0 +1,108 @@
+package models
+
+import "time"
+
+// Minimum payment is the larger of fixed amount and percent of statement balance with interest of the cycle,
+// but never more than statement balance
+const (
+	MinimumPaymentAmount  int64 = 2500
+	MinimumPaymentPercent int64 = 1
+)
+
+// CreditStatement is state of credit account in current statement cycle. Owed amounts are positive.
+type CreditStatement struct {
+	AccountID         AccountID `json:"account_id"`
+	Currency          *string   `json:"currency"`
+	StatementDate     time.Time `json:"statement_date"`      // closing date of last statement
+	NextStatementDate time.Time `json:"next_statement_date"` // closing date of current cycle
+	DueDate           time.Time `json:"due_date"`            // payment of last statement is due
+	StatementBalance  int64     `json:"statement_balance"`   // owed at closing of last statement
+	PaidSinceClosing  int64     `json:"paid_since_closing"`
+	MinimumPayment    int64     `json:"minimum_payment"` // what is left of minimum payment after payments since closing
+	CurrentBalance    int64     `json:"current_balance"` // owed now
+	CreditLimit       int64     `json:"credit_limit"`
+	AvailableCredit   int64     `json:"available_credit"`
+}
+
+// StatementDates - closing date of last statement on or before date and closing date of the next one
+func (a *Account) StatementDates(date time.Time) (last, next time.Time) {
+	year, month, _ := date.Date()
+	last = dayOfMonth(year, month, *a.StatementDay, date.Location())
+	if last.After(date) {
+		last = dayOfMonth(year, month-1, *a.StatementDay, date.Location())
+	}
+
+	next = dayOfMonth(last.Year(), last.Month()+1, *a.StatementDay, date.Location())
+	return last, next
+}
+
+// DueDate - first payment due day after statement closing date
+func (a *Account) DueDate(statementDate time.Time) time.Time {
+	year, month, _ := statementDate.Date()
+	due := dayOfMonth(year, month, *a.PaymentDueDay, statementDate.Location())
+	if !due.After(statementDate) {
+		due = dayOfMonth(year, month+1, *a.PaymentDueDay, statementDate.Location())
+	}
+
+	return due
+}
+
+// NewCreditStatement - builds statement of credit account at date.
+// Balances are account balances (negative when money is owed), paid is income of account since closing.
+func NewCreditStatement(account *Account, date time.Time, statementBalance, currentBalance, paid int64) *CreditStatement {
+	last, next := account.StatementDates(date)
+	statement := CreditStatement{
+		AccountID:         account.ID,
+		Currency:          account.Currency,
+		StatementDate:     last,
+		NextStatementDate: next,
+		DueDate:           account.DueDate(last),
+		StatementBalance:  -statementBalance,
+		PaidSinceClosing:  paid,
+		CurrentBalance:    -currentBalance,
+	}
+
+	if account.CreditLimit != nil {
+		statement.CreditLimit = *account.CreditLimit
+	}
+	statement.AvailableCredit = statement.CreditLimit - statement.CurrentBalance
+
+	var apr int64
+	if account.APR != nil {
+		apr = *account.APR
+	}
+	statement.MinimumPayment = MinimumPayment(statement.StatementBalance, apr) - paid
+	if statement.MinimumPayment < 0 {
+		statement.MinimumPayment = 0
+	}
+
+	return &statement
+}
+
+// MinimumPayment - minimum payment of statement balance with apr in basis points
+func MinimumPayment(statementBalance, apr int64) int64 {
+	if statementBalance <= 0 {
+		return 0
+	}
+
+	interest := statementBalance * apr / 10000 / 12
+	payment := statementBalance*MinimumPaymentPercent/100 + interest
+	if payment < MinimumPaymentAmount {
+		payment = MinimumPaymentAmount
+	}
+	if payment > statementBalance {
+		payment = statementBalance
+	}
+
+	return payment
+}
+
+// dayOfMonth - date of day in month, day is moved to the last day of shorter months
+func dayOfMonth(year int, month time.Month, day int, loc *time.Location) time.Time {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, loc)
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+
+	return first.AddDate(0, 0, day-1)
+}
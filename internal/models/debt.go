@@ -0,0 +1,150 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DebtID is identifier of Debt
+type DebtID string
+
+// NilDebtID is an empty identifier of Debt
+var NilDebtID DebtID
+
+// DebtDirection tells who owes money
+type DebtDirection string
+
+const (
+	Lent     DebtDirection = "lent"     // counterparty owes user
+	Borrowed DebtDirection = "borrowed" // user owes counterparty
+)
+
+// Debt is money lent to or borrowed from counterparty
+type Debt struct {
+	ID           DebtID         `json:"id,omitempty" db:"debt_id"`
+	UserID       *UserID        `json:"user_id,omitempty" db:"user_id"`
+	Counterparty *string        `json:"counterparty,omitempty" db:"counterparty"`
+	Direction    *DebtDirection `json:"direction,omitempty" db:"direction"`
+	Principal    *int64         `json:"principal,omitempty" db:"principal"`
+	InterestRate *int64         `json:"interest_rate,omitempty" db:"interest_rate"` // annual rate in basis points
+	Term         *int           `json:"term,omitempty" db:"term"`                   // number of monthly payments, debt without term has no schedule
+	StartDate    *time.Time     `json:"start_date,omitempty" db:"start_date"`
+	Currency     *string        `json:"currency,omitempty" db:"currency"`
+	Notes        *string        `json:"notes,omitempty" db:"notes"`
+	CreatedAt    *time.Time     `json:"-" db:"created_at"`
+	DeletedAt    *time.Time     `json:"-" db:"deleted_at"`
+
+	// * Sum of linked repayment transactions, it is only read
+	Repaid int64 `json:"repaid" db:"repaid"`
+
+	// * Maintained by database on every change
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+}
+
+// DebtSummary is outstanding balance with one counterparty in one currency
+type DebtSummary struct {
+	Counterparty string `json:"counterparty"`
+	Currency     string `json:"currency"`
+	Lent         int64  `json:"lent"`     // counterparty owes user
+	Borrowed     int64  `json:"borrowed"` // user owes counterparty
+	Net          int64  `json:"net"`      // positive when counterparty owes user
+}
+
+func (c *Debt) Verify() error {
+	if c.UserID == nil || len(*c.UserID) == 0 {
+		return errors.New("user_id is required")
+	}
+
+	if c.Counterparty == nil || len(*c.Counterparty) == 0 {
+		return errors.New("counterparty is required")
+	}
+
+	if c.Direction == nil || (*c.Direction != Lent && *c.Direction != Borrowed) {
+		return errors.Errorf("direction must be %q or %q", Lent, Borrowed)
+	}
+
+	if c.Principal == nil || *c.Principal <= 0 {
+		return errors.New("principal is required")
+	}
+
+	if c.InterestRate != nil && *c.InterestRate < 0 {
+		return errors.New("interest_rate can't be negative")
+	}
+
+	if c.Term != nil && *c.Term <= 0 {
+		return errors.New("term must be positive")
+	}
+
+	if c.StartDate == nil {
+		return errors.New("start_date is required")
+	}
+
+	if c.Currency == nil || len(*c.Currency) == 0 {
+		return errors.New("currency is required")
+	}
+
+	return nil
+}
+
+// RepaymentType - type of repayment transaction, user receives money of lent debt and pays borrowed one
+func (c *Debt) RepaymentType() TransactionType {
+	if *c.Direction == Lent {
+		return Income
+	}
+	return Expense
+}
+
+// Schedule - amortization schedule of debt, nil when debt has no term
+func (c *Debt) Schedule() *AmortizationSchedule {
+	if c.Term == nil {
+		return nil
+	}
+
+	var rate int64
+	if c.InterestRate != nil {
+		rate = *c.InterestRate
+	}
+
+	schedule := amortize(*c.Principal, rate, *c.Term, *c.StartDate)
+	schedule.DebtID = c.ID
+	return schedule
+}
+
+// Outstanding - amount left to repay. Debt with schedule is repaid with interest of the schedule.
+func (c *Debt) Outstanding() int64 {
+	total := *c.Principal
+	if schedule := c.Schedule(); schedule != nil {
+		total += schedule.TotalInterest
+	}
+
+	if outstanding := total - c.Repaid; outstanding > 0 {
+		return outstanding
+	}
+	return 0
+}
+
+// NewDebtSummaries - outstanding balances of debts grouped by counterparty and currency
+func NewDebtSummaries(debts []*Debt) []*DebtSummary {
+	summaries := make([]*DebtSummary, 0)
+	index := make(map[[2]string]*DebtSummary)
+	for _, debt := range debts {
+		key := [2]string{*debt.Counterparty, *debt.Currency}
+		summary, ok := index[key]
+		if !ok {
+			summary = &DebtSummary{Counterparty: *debt.Counterparty, Currency: *debt.Currency}
+			index[key] = summary
+			summaries = append(summaries, summary)
+		}
+
+		if *debt.Direction == Lent {
+			summary.Lent += debt.Outstanding()
+		} else {
+			summary.Borrowed += debt.Outstanding()
+		}
+		summary.Net = summary.Lent - summary.Borrowed
+	}
+
+	return summaries
+}
@@ -0,0 +1,149 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// daysInMonth - average length of month used for contribution rate
+const daysInMonth = 30.44
+
+// GoalID is identifier of Goal
+type GoalID string
+
+// NilGoalID is an empty identifier of Goal
+var NilGoalID GoalID
+
+// GoalContributionID is identifier of GoalContribution
+type GoalContributionID string
+
+// Goal is amount user saves for, optionally in one account and until target date
+type Goal struct {
+	ID           GoalID     `json:"id,omitempty" db:"goal_id"`
+	UserID       *UserID    `json:"user_id,omitempty" db:"user_id"`
+	AccountID    *AccountID `json:"account_id,omitempty" db:"account_id"`
+	Name         *string    `json:"name,omitempty" db:"name"`
+	TargetAmount *int64     `json:"target_amount,omitempty" db:"target_amount"`
+	TargetDate   *time.Time `json:"target_date,omitempty" db:"target_date"`
+	CreatedAt    *time.Time `json:"-" db:"created_at"`
+	DeletedAt    *time.Time `json:"-" db:"deleted_at"`
+
+	// * Maintained by database on every change
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+}
+
+// GoalContribution is money put to goal, either by transaction or by allocation without transaction.
+// Negative amount is withdrawal from goal.
+type GoalContribution struct {
+	ID            GoalContributionID `json:"id,omitempty" db:"contribution_id"`
+	GoalID        *GoalID            `json:"goal_id,omitempty" db:"goal_id"`
+	UserID        *UserID            `json:"user_id,omitempty" db:"user_id"`
+	TransactionID *TransactionID     `json:"transaction_id,omitempty" db:"transaction_id"`
+	Amount        *int64             `json:"amount,omitempty" db:"amount"`
+	Date          *time.Time         `json:"date,omitempty" db:"contribution_date"`
+	Notes         *string            `json:"notes,omitempty" db:"notes"`
+	CreatedAt     *time.Time         `json:"-" db:"created_at"`
+}
+
+// GoalProgress is saved amount of goal and its projection from average monthly contribution
+type GoalProgress struct {
+	GoalID          GoalID     `json:"goal_id"`
+	TargetAmount    int64      `json:"target_amount"`
+	TargetDate      *time.Time `json:"target_date,omitempty"`
+	Saved           int64      `json:"saved"`
+	Remaining       int64      `json:"remaining"`
+	Percent         float64    `json:"percent"`
+	Achieved        bool       `json:"achieved"`
+	MonthlyRate     int64      `json:"monthly_rate"`               // average contribution per month since first contribution
+	ProjectedDate   *time.Time `json:"projected_date,omitempty"`   // nil when goal is achieved or is not growing
+	RequiredMonthly *int64     `json:"required_monthly,omitempty"` // needed per month to reach target by target date
+	OnTrack         *bool      `json:"on_track,omitempty"`
+}
+
+func (c *Goal) Verify() error {
+	if c.UserID == nil || len(*c.UserID) == 0 {
+		return errors.New("user_id is required")
+	}
+
+	if c.Name == nil || len(*c.Name) == 0 {
+		return errors.New("name is required")
+	}
+
+	if c.TargetAmount == nil || *c.TargetAmount <= 0 {
+		return errors.New("target_amount must be positive")
+	}
+
+	return nil
+}
+
+func (c *GoalContribution) Verify() error {
+	if c.GoalID == nil || len(*c.GoalID) == 0 {
+		return errors.New("goal_id is required")
+	}
+
+	if c.UserID == nil || len(*c.UserID) == 0 {
+		return errors.New("user_id is required")
+	}
+
+	if c.Amount == nil || *c.Amount == 0 {
+		return errors.New("amount is required")
+	}
+
+	if c.Date == nil {
+		return errors.New("date is required")
+	}
+
+	return nil
+}
+
+// NewGoalProgress - progress of goal at now. Contribution rate is measured from the first contribution,
+// but over at least one month, so a single early contribution doesn't project unrealistic rate.
+func NewGoalProgress(goal *Goal, contributions []*GoalContribution, now time.Time) *GoalProgress {
+	progress := GoalProgress{
+		GoalID:       goal.ID,
+		TargetAmount: *goal.TargetAmount,
+		TargetDate:   goal.TargetDate,
+	}
+
+	var first *time.Time
+	for _, contribution := range contributions {
+		progress.Saved += *contribution.Amount
+		if first == nil || contribution.Date.Before(*first) {
+			first = contribution.Date
+		}
+	}
+
+	progress.Remaining = progress.TargetAmount - progress.Saved
+	progress.Percent = float64(progress.Saved) * 100 / float64(progress.TargetAmount)
+	progress.Achieved = progress.Remaining <= 0
+	if progress.Remaining < 0 {
+		progress.Remaining = 0
+	}
+
+	if first != nil {
+		months := now.Sub(*first).Hours() / 24 / daysInMonth
+		if months < 1 {
+			months = 1
+		}
+		progress.MonthlyRate = int64(float64(progress.Saved) / months)
+	}
+
+	if !progress.Achieved && progress.MonthlyRate > 0 {
+		days := float64(progress.Remaining) / float64(progress.MonthlyRate) * daysInMonth
+		projected := now.Add(time.Duration(days * 24 * float64(time.Hour)))
+		progress.ProjectedDate = &projected
+	}
+
+	if goal.TargetDate != nil && !progress.Achieved {
+		required := progress.Remaining
+		if months := goal.TargetDate.Sub(now).Hours() / 24 / daysInMonth; months > 1 {
+			required = int64(float64(progress.Remaining)/months + 0.5)
+		}
+		onTrack := progress.ProjectedDate != nil && !progress.ProjectedDate.After(*goal.TargetDate)
+		progress.RequiredMonthly, progress.OnTrack = &required, &onTrack
+	}
+
+	return &progress
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Identity is user's account at external identity provider (Google, Facebook)
+type Identity struct {
+	Provider  string     `json:"provider" db:"provider"`
+	Subject   string     `json:"subject" db:"subject"`
+	UserID    UserID     `json:"user_id,omitempty" db:"user_id"`
+	Email     string     `json:"email" db:"email"`
+	CreatedAt *time.Time `json:"created_at,omitempty" db:"created_at"`
+
+	// * Provider confirmed that user owns email. Only verified emails are linked to existing users.
+	EmailVerified bool `json:"-" db:"-"`
+}
+
+// OAuthState is pending authorization request to identity provider
+type OAuthState struct {
+	State        string    `db:"state"`
+	Provider     string    `db:"provider"`
+	CodeVerifier string    `db:"code_verifier"`
+	Nonce        string    `db:"nonce"`
+	UserID       *UserID   `db:"user_id"`
+	DeviceID     DeviceID  `db:"device_id"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
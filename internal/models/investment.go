@@ -0,0 +1,245 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInsufficientQuantity - sell is larger than quantity held at its date
+var ErrInsufficientQuantity = errors.New("sell quantity is larger than quantity held")
+
+// quantityEpsilon - quantities are stored with 6 decimal places
+const quantityEpsilon = 0.0000005
+
+// TradeID is identifier of Trade
+type TradeID string
+
+// NilTradeID is an empty identifier of Trade
+var NilTradeID TradeID
+
+// TradeType is kind of investment trade
+type TradeType string
+
+const (
+	Buy      TradeType = "buy"
+	Sell     TradeType = "sell"
+	Dividend TradeType = "dividend"
+)
+
+// Trade is buy, sell or dividend of security in investment account
+type Trade struct {
+	ID         TradeID     `json:"id,omitempty" db:"trade_id"`
+	UserID     *UserID     `json:"user_id,omitempty" db:"user_id"`
+	AccountID  *AccountID  `json:"account_id,omitempty" db:"account_id"`
+	SecurityID *SecurityID `json:"security_id,omitempty" db:"security_id"`
+	Type       *TradeType  `json:"type,omitempty" db:"trade_type"`
+	Date       *time.Time  `json:"date,omitempty" db:"trade_date"`
+	Quantity   *float64    `json:"quantity,omitempty" db:"quantity"`
+	UnitPrice  *int64      `json:"unit_price,omitempty" db:"unit_price"`
+	Fees       *int64      `json:"fees,omitempty" db:"fees"`
+	Notes      *string     `json:"notes,omitempty" db:"notes"`
+	CreatedAt  *time.Time  `json:"-" db:"created_at"`
+	DeletedAt  *time.Time  `json:"-" db:"deleted_at"`
+
+	// * Maintained by database on every change
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+}
+
+// Lot is part of holding bought by one trade which is not sold yet
+type Lot struct {
+	TradeID   TradeID   `json:"trade_id"`
+	Date      time.Time `json:"date"`
+	Quantity  float64   `json:"quantity"`
+	UnitCost  float64   `json:"unit_cost"` // unit price with share of fees
+	CostBasis int64     `json:"cost_basis"`
+}
+
+// Holding is position in one security
+type Holding struct {
+	SecurityID     SecurityID `json:"security_id"`
+	Quantity       float64    `json:"quantity"`
+	CostBasis      int64      `json:"cost_basis"`
+	Price          *int64     `json:"price"` // nil when there is no imported price
+	PriceDate      *time.Time `json:"price_date"`
+	MarketValue    int64      `json:"market_value"`
+	UnrealizedGain int64      `json:"unrealized_gain"`
+	RealizedGain   int64      `json:"realized_gain"`
+	Dividends      int64      `json:"dividends"`
+	Lots           []*Lot     `json:"lots"`
+}
+
+// Portfolio is holdings of investment account. Sold units are matched with lots first in, first out.
+type Portfolio struct {
+	AccountID      AccountID  `json:"account_id"`
+	CostBasis      int64      `json:"cost_basis"`
+	MarketValue    int64      `json:"market_value"`
+	UnrealizedGain int64      `json:"unrealized_gain"`
+	RealizedGain   int64      `json:"realized_gain"`
+	Dividends      int64      `json:"dividends"`
+	Holdings       []*Holding `json:"holdings"`
+}
+
+func (c *Trade) Verify() error {
+	if c.UserID == nil || len(*c.UserID) == 0 {
+		return errors.New("user_id is required")
+	}
+
+	if c.AccountID == nil || len(*c.AccountID) == 0 {
+		return errors.New("account_id is required")
+	}
+
+	if c.SecurityID == nil || len(*c.SecurityID) == 0 {
+		return errors.New("security_id is required")
+	}
+
+	if c.Type == nil {
+		return errors.New("type is required")
+	}
+	switch *c.Type {
+	case Buy, Sell, Dividend:
+	default:
+		return errors.Errorf("unknown trade type %q", *c.Type)
+	}
+
+	if c.Date == nil {
+		return errors.New("date is required")
+	}
+
+	if c.Quantity == nil || *c.Quantity <= 0 {
+		return errors.New("quantity must be positive")
+	}
+
+	if c.UnitPrice == nil || *c.UnitPrice < 0 {
+		return errors.New("unit_price is required")
+	}
+
+	if c.Fees != nil && *c.Fees < 0 {
+		return errors.New("fees can't be negative")
+	}
+
+	return nil
+}
+
+// Amount - quantity times unit price rounded to minor units
+func (c *Trade) Amount() int64 {
+	return int64(math.Round(*c.Quantity * float64(*c.UnitPrice)))
+}
+
+func (c *Trade) fees() int64 {
+	if c.Fees == nil {
+		return 0
+	}
+	return *c.Fees
+}
+
+// NewPortfolio - replays trades of account in date order, prices are the latest known price of each security
+func NewPortfolio(accountID AccountID, trades []*Trade, prices []*SecurityPrice) (*Portfolio, error) {
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Date.Before(*trades[j].Date)
+	})
+
+	portfolio := Portfolio{
+		AccountID: accountID,
+		Holdings:  make([]*Holding, 0),
+	}
+	holdings := make(map[SecurityID]*Holding)
+	for _, trade := range trades {
+		holding, ok := holdings[*trade.SecurityID]
+		if !ok {
+			holding = &Holding{SecurityID: *trade.SecurityID, Lots: make([]*Lot, 0)}
+			holdings[*trade.SecurityID] = holding
+			portfolio.Holdings = append(portfolio.Holdings, holding)
+		}
+
+		if err := holding.apply(trade); err != nil {
+			return nil, errors.Wrapf(err, "trade %s", trade.ID)
+		}
+	}
+
+	for _, price := range prices {
+		if holding, ok := holdings[price.SecurityID]; ok {
+			price := price
+			holding.Price, holding.PriceDate = &price.Price, &price.Date
+		}
+	}
+
+	for _, holding := range portfolio.Holdings {
+		holding.value()
+		portfolio.CostBasis += holding.CostBasis
+		portfolio.MarketValue += holding.MarketValue
+		portfolio.UnrealizedGain += holding.UnrealizedGain
+		portfolio.RealizedGain += holding.RealizedGain
+		portfolio.Dividends += holding.Dividends
+	}
+
+	return &portfolio, nil
+}
+
+// apply - changes holding by trade, sell takes units from the oldest lots
+func (h *Holding) apply(trade *Trade) error {
+	switch *trade.Type {
+	case Buy:
+		cost := trade.Amount() + trade.fees()
+		h.Lots = append(h.Lots, &Lot{
+			TradeID:   trade.ID,
+			Date:      *trade.Date,
+			Quantity:  *trade.Quantity,
+			UnitCost:  float64(cost) / *trade.Quantity,
+			CostBasis: cost,
+		})
+	case Sell:
+		if *trade.Quantity > h.quantity()+quantityEpsilon {
+			return ErrInsufficientQuantity
+		}
+
+		var cost int64
+		left := *trade.Quantity
+		for len(h.Lots) > 0 && left > quantityEpsilon {
+			lot := h.Lots[0]
+			if lot.Quantity <= left+quantityEpsilon {
+				cost += lot.CostBasis
+				left -= lot.Quantity
+				h.Lots = h.Lots[1:]
+				continue
+			}
+
+			sold := int64(math.Round(left * lot.UnitCost))
+			cost += sold
+			lot.Quantity -= left
+			lot.CostBasis -= sold
+			left = 0
+		}
+		h.RealizedGain += trade.Amount() - trade.fees() - cost
+	case Dividend:
+		h.Dividends += trade.Amount() - trade.fees()
+	}
+
+	return nil
+}
+
+func (h *Holding) quantity() float64 {
+	var quantity float64
+	for _, lot := range h.Lots {
+		quantity += lot.Quantity
+	}
+	return quantity
+}
+
+// value - totals of open lots, holding without price is valued at cost
+func (h *Holding) value() {
+	h.Quantity = h.quantity()
+	h.CostBasis = 0
+	for _, lot := range h.Lots {
+		h.CostBasis += lot.CostBasis
+	}
+
+	h.MarketValue = h.CostBasis
+	if h.Price != nil {
+		h.MarketValue = int64(math.Round(h.Quantity * float64(*h.Price)))
+	}
+	h.UnrealizedGain = h.MarketValue - h.CostBasis
+}
@@ -18,6 +18,11 @@ type Merchant struct {
 	CreatedAt *time.Time `json:"-" db:"created_at"`
 	DeletedAt *time.Time `json:"-" db:"deleted_at"`
 	Name      *string    `json:"name,omitempty" db:"name"`
+
+	// * Maintained by database on every change, change_seq orders changes for sync
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+	ChangeSeq int64      `json:"-" db:"change_seq"`
 }
 
 func (c *Merchant) Verify() error {
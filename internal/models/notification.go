@@ -0,0 +1,189 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultLargeExpense - expense of at least this amount is large, if user didn't choose threshold
+const DefaultLargeExpense int64 = 100000
+
+// MaxDeliveryAttempts - delivery is failed after this many unsuccessful attempts
+const MaxDeliveryAttempts = 5
+
+// NotificationID is identifier of Notification
+type NotificationID string
+
+// NotificationDeliveryID is identifier of NotificationDelivery
+type NotificationDeliveryID string
+
+// NotificationEvent is type of event user can be notified about
+type NotificationEvent string
+
+const (
+	EventLargeExpense NotificationEvent = "large_expense"
+	EventBillDue      NotificationEvent = "bill_due"
+)
+
+// NotificationEvents - all events, preferences are listed in this order
+var NotificationEvents = []NotificationEvent{EventLargeExpense, EventBillDue}
+
+func (e NotificationEvent) IsValid() bool {
+	for _, event := range NotificationEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NotificationChannel is the way notification is delivered to user
+type NotificationChannel string
+
+const (
+	ChannelInApp   NotificationChannel = "in_app"
+	ChannelEmail   NotificationChannel = "email"
+	ChannelWebhook NotificationChannel = "webhook"
+)
+
+// DeliveryStatus is state of delivery
+type DeliveryStatus string
+
+const (
+	DeliveryPending DeliveryStatus = "pending"
+	DeliverySent    DeliveryStatus = "sent"
+	DeliveryFailed  DeliveryStatus = "failed"
+)
+
+// Notification is message about event. It is shown in inbox after in-app channel delivers it.
+type Notification struct {
+	ID          NotificationID    `json:"id,omitempty" db:"notification_id"`
+	UserID      *UserID           `json:"user_id,omitempty" db:"user_id"`
+	EventType   NotificationEvent `json:"event_type" db:"event_type"`
+	Title       string            `json:"title" db:"title"`
+	Body        string            `json:"body" db:"body"`
+	ResourceID  *string           `json:"resource_id,omitempty" db:"resource_id"`
+	DedupeKey   *string           `json:"-" db:"dedupe_key"`
+	DeliveredAt *time.Time        `json:"-" db:"delivered_at"`
+	ReadAt      *time.Time        `json:"read_at,omitempty" db:"read_at"`
+	CreatedAt   *time.Time        `json:"created_at,omitempty" db:"created_at"`
+}
+
+// NotificationPreference is choice of channels for event type. Threshold is used only by large expense.
+type NotificationPreference struct {
+	UserID     *UserID           `json:"user_id,omitempty" db:"user_id"`
+	EventType  NotificationEvent `json:"event_type" db:"event_type"`
+	InApp      *bool             `json:"in_app" db:"in_app"`
+	Email      *bool             `json:"email" db:"email"`
+	WebhookURL *string           `json:"webhook_url,omitempty" db:"webhook_url"`
+	Threshold  *int64            `json:"threshold,omitempty" db:"threshold"`
+
+	// * Maintained by database on every change, preference which is not stored yet has no version
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+
+	// * Signs webhook requests, it is generated with every new webhook_url
+	WebhookSecret *string `json:"-" db:"webhook_secret"`
+}
+
+// NotificationDelivery is delivery of notification by one channel. Target is email address or webhook URL.
+type NotificationDelivery struct {
+	ID             NotificationDeliveryID `json:"id,omitempty" db:"delivery_id"`
+	NotificationID NotificationID         `json:"notification_id" db:"notification_id"`
+	UserID         *UserID                `json:"user_id,omitempty" db:"user_id"`
+	Channel        NotificationChannel    `json:"channel" db:"channel"`
+	Target         *string                `json:"target,omitempty" db:"target"`
+	Status         DeliveryStatus         `json:"status" db:"status"`
+	Attempts       int                    `json:"attempts" db:"attempts"`
+	LastError      *string                `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt  *time.Time             `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	SentAt         *time.Time             `json:"sent_at,omitempty" db:"sent_at"`
+	CreatedAt      *time.Time             `json:"created_at,omitempty" db:"created_at"`
+}
+
+// NewNotificationPreference - preference of user who didn't choose channels of event
+func NewNotificationPreference(userID UserID, event NotificationEvent) *NotificationPreference {
+	inApp, email := true, false
+	preference := NotificationPreference{
+		UserID:    &userID,
+		EventType: event,
+		InApp:     &inApp,
+		Email:     &email,
+	}
+
+	if event == EventLargeExpense {
+		threshold := DefaultLargeExpense
+		preference.Threshold = &threshold
+	}
+
+	return &preference
+}
+
+func (p *NotificationPreference) Verify() error {
+	if p.UserID == nil || len(*p.UserID) == 0 {
+		return errors.New("user_id is required")
+	}
+
+	if !p.EventType.IsValid() {
+		return errors.Errorf("unknown event type %q", p.EventType)
+	}
+
+	if p.InApp == nil || p.Email == nil {
+		return errors.New("in_app and email are required")
+	}
+
+	if p.WebhookURL != nil && len(*p.WebhookURL) != 0 {
+		if err := verifyWebhookURL(*p.WebhookURL); err != nil {
+			return errors.Wrap(err, "webhook_url")
+		}
+	}
+
+	if p.Threshold != nil && *p.Threshold < 0 {
+		return errors.New("threshold can't be negative")
+	}
+
+	return nil
+}
+
+// Channels - channels enabled by preference
+func (p *NotificationPreference) Channels() []NotificationChannel {
+	channels := make([]NotificationChannel, 0, 3)
+	if p.InApp != nil && *p.InApp {
+		channels = append(channels, ChannelInApp)
+	}
+	if p.Email != nil && *p.Email {
+		channels = append(channels, ChannelEmail)
+	}
+	if p.WebhookURL != nil && len(*p.WebhookURL) != 0 {
+		channels = append(channels, ChannelWebhook)
+	}
+	return channels
+}
+
+// Fail - stores failed attempt, delivery is retried with exponential backoff until attempts run out
+func (d *NotificationDelivery) Fail(err error, now time.Time) {
+	reason := err.Error()
+	d.Attempts++
+	d.LastError = &reason
+
+	if d.Attempts >= MaxDeliveryAttempts {
+		d.Status = DeliveryFailed
+		return
+	}
+
+	next := now.Add(deliveryBackoff(d.Attempts))
+	d.NextAttemptAt = &next
+}
+
+// Sent - stores successful attempt
+func (d *NotificationDelivery) Sent(now time.Time) {
+	d.Attempts++
+	d.Status = DeliverySent
+	d.SentAt = &now
+}
+
+// deliveryBackoff - wait before next attempt, it doubles with every failed attempt starting at one minute
+func deliveryBackoff(attempts int) time.Duration {
+	return time.Minute << uint(attempts-1)
+}
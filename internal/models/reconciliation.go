@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ReconciliationID is identifier of Reconciliation
+type ReconciliationID string
+
+// NilReconciliationID is an empty identifier of Reconciliation
+var NilReconciliationID ReconciliationID
+
+// Reconciliation is check of account's cleared transactions against bank statement
+type Reconciliation struct {
+	ID            ReconciliationID `json:"id,omitempty" db:"reconciliation_id"`
+	UserID        *UserID          `json:"user_id,omitempty" db:"user_id"`
+	AccountID     *AccountID       `json:"account_id,omitempty" db:"account_id"`
+	StatementDate *time.Time       `json:"statement_date" db:"statement_date"`
+	EndingBalance *int64           `json:"ending_balance" db:"ending_balance"`
+	CreatedAt     *time.Time       `json:"created_at,omitempty" db:"created_at"`
+	FinalizedAt   *time.Time       `json:"finalized_at,omitempty" db:"finalized_at"`
+
+	// * Maintained by database on every change
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+}
+
+// ReconciliationSummary is reconciliation with transactions which are not reconciled yet
+type ReconciliationSummary struct {
+	*Reconciliation
+	ClearedBalance int64          `json:"cleared_balance"`
+	Difference     int64          `json:"difference"` // ending balance - cleared balance, reconciliation can be finalized when it is 0
+	Transactions   []*Transaction `json:"transactions"`
+}
+
+func (c *Reconciliation) Verify() error {
+	if c.UserID == nil || len(*c.UserID) == 0 {
+		return errors.New("user_id is required")
+	}
+
+	if c.AccountID == nil || len(*c.AccountID) == 0 {
+		return errors.New("account_id is required")
+	}
+
+	if c.StatementDate == nil {
+		return errors.New("statement_date is required")
+	}
+
+	if c.EndingBalance == nil {
+		return errors.New("ending_balance is required")
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// AccountBalance is balance of account at some date
+type AccountBalance struct {
+	AccountID AccountID   `json:"account_id" db:"account_id"`
+	Name      string      `json:"name" db:"account_name"`
+	Type      AccountType `json:"type" db:"account_type"`
+	Currency  string      `json:"currency" db:"currency"`
+	Balance   int64       `json:"balance" db:"balance"` // income increases balance, expense decreases it
+	Liability bool        `json:"liability" db:"-"`
+	Amount    int64       `json:"amount" db:"-"` // amount owned for asset, amount owed for liability
+}
+
+// NetWorth is sum of assets and liabilities in one currency. Liabilities are positive amounts owed.
+type NetWorth struct {
+	Currency    string `json:"currency"`
+	Assets      int64  `json:"assets"`
+	Liabilities int64  `json:"liabilities"`
+	NetWorth    int64  `json:"net_worth"`
+}
+
+// NetWorthReport is net worth of user at date, currencies are not converted
+type NetWorthReport struct {
+	Date     time.Time         `json:"date"`
+	Totals   []*NetWorth       `json:"totals"`
+	Accounts []*AccountBalance `json:"accounts"`
+}
+
+// NewNetWorthReport - sums balances by currency. Balance of liability account is negative when money is owed,
+// so amount owed is balance with reversed sign.
+func NewNetWorthReport(date time.Time, balances []*AccountBalance) *NetWorthReport {
+	report := NetWorthReport{
+		Date:     date,
+		Totals:   make([]*NetWorth, 0),
+		Accounts: balances,
+	}
+
+	totals := make(map[string]*NetWorth)
+	for _, balance := range balances {
+		total, ok := totals[balance.Currency]
+		if !ok {
+			total = &NetWorth{Currency: balance.Currency}
+			totals[balance.Currency] = total
+			report.Totals = append(report.Totals, total)
+		}
+
+		balance.Liability = balance.Type.IsLiability()
+		if balance.Liability {
+			balance.Amount = -balance.Balance
+			total.Liabilities += balance.Amount
+		} else {
+			balance.Amount = balance.Balance
+			total.Assets += balance.Amount
+		}
+		total.NetWorth = total.Assets - total.Liabilities
+	}
+
+	return &report
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SecurityID is identifier of Security
+type SecurityID string
+
+// NilSecurityID is an empty identifier of Security
+var NilSecurityID SecurityID
+
+// SecurityType is kind of security
+type SecurityType string
+
+const (
+	Stock         SecurityType = "stock"
+	Fund          SecurityType = "fund"
+	Bond          SecurityType = "bond"
+	OtherSecurity SecurityType = "other"
+)
+
+// Security is stock, fund or other instrument which can be held in investment account
+type Security struct {
+	ID        SecurityID    `json:"id,omitempty" db:"security_id"`
+	Symbol    *string       `json:"symbol,omitempty" db:"symbol"`
+	Name      *string       `json:"name,omitempty" db:"name"`
+	Type      *SecurityType `json:"type,omitempty" db:"security_type"`
+	Currency  *string       `json:"currency,omitempty" db:"currency"`
+	CreatedAt *time.Time    `json:"-" db:"created_at"`
+
+	// * Maintained by database on every change
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+}
+
+// SecurityPrice is closing price of security at date in minor units of its currency
+type SecurityPrice struct {
+	SecurityID SecurityID `json:"security_id,omitempty" db:"security_id"`
+	Symbol     string     `json:"symbol,omitempty" db:"symbol"` // * used by import instead of security_id
+	Date       time.Time  `json:"date" db:"price_date"`
+	Price      int64      `json:"price" db:"price"`
+}
+
+func (c *Security) Verify() error {
+	if c.Symbol == nil || len(*c.Symbol) == 0 {
+		return errors.New("symbol is required")
+	}
+
+	if c.Name == nil || len(*c.Name) == 0 {
+		return errors.New("name is required")
+	}
+
+	if c.Type == nil {
+		return errors.New("type is required")
+	}
+	switch *c.Type {
+	case Stock, Fund, Bond, OtherSecurity:
+	default:
+		return errors.Errorf("unknown security type %q", *c.Type)
+	}
+
+	if c.Currency == nil || len(*c.Currency) == 0 {
+		return errors.New("currency is required")
+	}
+
+	return nil
+}
+
+func (c *SecurityPrice) Verify() error {
+	if c.Symbol == "" && c.SecurityID == NilSecurityID {
+		return errors.New("symbol is required")
+	}
+
+	if c.Date.IsZero() {
+		return errors.New("date is required")
+	}
+
+	if c.Price < 0 {
+		return errors.New("price can't be negative")
+	}
+
+	return nil
+}
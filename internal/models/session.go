@@ -1,17 +1,47 @@
 package models
 
-import "github.com/pkg/errors"
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
 
 type DeviceID string
 
 var NilDeviceID DeviceID
 
+// FamilyID is identifier of refresh token family. Family is started on login and all rotated tokens belong to it.
+type FamilyID string
+
+var NilFamilyID FamilyID
+
 // Session is represent user's sessions
 type Session struct {
-	UserID       UserID   `db:"user_id"`
-	DeviceID     DeviceID `db:"device_id"`
-	RefreshToken string   `db:"refresh_token"`
-	ExpiresAt    int64    `db:"expires_at"`
+	UserID     UserID     `json:"-" db:"user_id"`
+	DeviceID   DeviceID   `json:"device_id" db:"device_id"`
+	FamilyID   FamilyID   `json:"-" db:"family_id"`
+	ExpiresAt  int64      `json:"expires_at" db:"expires_at"`
+	CreatedAt  *time.Time `json:"created_at,omitempty" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	IPAddress  string     `json:"ip_address" db:"ip_address"`
+	UserAgent  string     `json:"user_agent" db:"user_agent"`
+}
+
+// RefreshToken is one issued refresh token. We store only hash of token.
+type RefreshToken struct {
+	TokenHash string     `db:"token_hash"`
+	FamilyID  FamilyID   `db:"family_id"`
+	UserID    UserID     `db:"user_id"`
+	DeviceID  DeviceID   `db:"device_id"`
+	CreatedAt *time.Time `db:"created_at"`
+	ExpiresAt int64      `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	RevokedAt *time.Time `db:"revoked_at"`
+}
+
+// IsExpired checks if token can not be used anymore
+func (t *RefreshToken) IsExpired() bool {
+	return time.Now().Unix() >= t.ExpiresAt
 }
 
 // SessionData used to represent data sent in json body with requests
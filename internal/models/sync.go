@@ -0,0 +1,223 @@
+package models
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// uuidPattern - client generated identifiers must be UUIDs, the same as identifiers generated by database
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// SyncResourceType is type of resource synced with offline clients
+type SyncResourceType string
+
+const (
+	SyncAccount     SyncResourceType = "account"
+	SyncCategory    SyncResourceType = "category"
+	SyncMerchant    SyncResourceType = "merchant"
+	SyncTransaction SyncResourceType = "transaction"
+)
+
+// SyncResourceTypes - all synced types
+var SyncResourceTypes = []SyncResourceType{SyncAccount, SyncCategory, SyncMerchant, SyncTransaction}
+
+func (t SyncResourceType) IsValid() bool {
+	for _, resourceType := range SyncResourceTypes {
+		if t == resourceType {
+			return true
+		}
+	}
+	return false
+}
+
+// SyncStatus is result of pushed change
+type SyncStatus string
+
+const (
+	SyncApplied  SyncStatus = "applied"
+	SyncConflict SyncStatus = "conflict"
+	SyncRejected SyncStatus = "rejected"
+)
+
+// SyncDeletion is deleted resource. Version is known only for resources which are still in trash.
+type SyncDeletion struct {
+	Type      SyncResourceType `json:"type" db:"resource_type"`
+	ID        string           `json:"id" db:"resource_id"`
+	Version   int64            `json:"version,omitempty" db:"version"`
+	ChangeSeq int64            `json:"-" db:"change_seq"`
+}
+
+// SyncChanges is page of changes after token, client asks for next page while HasMore is set
+type SyncChanges struct {
+	Token        string          `json:"token"`
+	HasMore      bool            `json:"has_more"`
+	Accounts     []*Account      `json:"accounts"`
+	Categories   []*Category     `json:"categories"`
+	Merchants    []*Merchant     `json:"merchants"`
+	Transactions []*Transaction  `json:"transactions"`
+	Deleted      []*SyncDeletion `json:"deleted"`
+}
+
+// SyncPush is changes made by offline client. Version is version of resource client has changed, 0 for new resource.
+type SyncPush struct {
+	Accounts     []*Account      `json:"accounts"`
+	Categories   []*Category     `json:"categories"`
+	Merchants    []*Merchant     `json:"merchants"`
+	Transactions []*Transaction  `json:"transactions"`
+	Deleted      []*SyncDeletion `json:"deleted"`
+}
+
+// SyncResult is result of one pushed change. Conflict contains resource as it is on server, so client can merge it.
+type SyncResult struct {
+	Type    SyncResourceType `json:"type"`
+	ID      string           `json:"id"`
+	Status  SyncStatus       `json:"status"`
+	Version int64            `json:"version,omitempty"`
+	Deleted bool             `json:"deleted,omitempty"`
+	Error   string           `json:"error,omitempty"`
+	Server  interface{}      `json:"server,omitempty"`
+}
+
+// IsUUID checks if identifier generated by client is valid
+func IsUUID(id string) bool {
+	return uuidPattern.MatchString(id)
+}
+
+// ParseSyncToken - change sequence stored in token, empty token means all changes
+func ParseSyncToken(token string) (int64, error) {
+	if len(token) == 0 {
+		return 0, nil
+	}
+
+	since, err := strconv.ParseInt(token, 10, 64)
+	if err != nil || since < 0 {
+		return 0, errors.New("invalid sync token")
+	}
+	return since, nil
+}
+
+// NewSyncChanges - first limit changes after since ordered by change sequence. Every list must be ordered by change
+// sequence and contain at most limit rows. Resources which are in trash are returned as deleted.
+func NewSyncChanges(since int64, limit int, accounts []*Account, categories []*Category, merchants []*Merchant,
+	transactions []*Transaction, deletions []*SyncDeletion) *SyncChanges {
+
+	type change struct {
+		seq   int64
+		apply func(changes *SyncChanges)
+	}
+
+	all := make([]change, 0, len(accounts)+len(categories)+len(merchants)+len(transactions)+len(deletions))
+	for _, a := range accounts {
+		a := a
+		all = append(all, change{a.ChangeSeq, func(c *SyncChanges) {
+			if a.DeletedAt != nil {
+				c.Deleted = append(c.Deleted, &SyncDeletion{SyncAccount, string(a.ID), a.Version, a.ChangeSeq})
+				return
+			}
+			c.Accounts = append(c.Accounts, a)
+		}})
+	}
+	for _, category := range categories {
+		category := category
+		all = append(all, change{category.ChangeSeq, func(c *SyncChanges) {
+			if category.DeletedAt != nil {
+				c.Deleted = append(c.Deleted, &SyncDeletion{SyncCategory, string(category.ID), category.Version, category.ChangeSeq})
+				return
+			}
+			c.Categories = append(c.Categories, category)
+		}})
+	}
+	for _, m := range merchants {
+		m := m
+		all = append(all, change{m.ChangeSeq, func(c *SyncChanges) {
+			if m.DeletedAt != nil {
+				c.Deleted = append(c.Deleted, &SyncDeletion{SyncMerchant, string(m.ID), m.Version, m.ChangeSeq})
+				return
+			}
+			c.Merchants = append(c.Merchants, m)
+		}})
+	}
+	for _, t := range transactions {
+		t := t
+		all = append(all, change{t.ChangeSeq, func(c *SyncChanges) {
+			if t.DeletedAt != nil {
+				c.Deleted = append(c.Deleted, &SyncDeletion{SyncTransaction, string(t.ID), t.Version, t.ChangeSeq})
+				return
+			}
+			c.Transactions = append(c.Transactions, t)
+		}})
+	}
+	for _, d := range deletions {
+		d := d
+		all = append(all, change{d.ChangeSeq, func(c *SyncChanges) {
+			c.Deleted = append(c.Deleted, d)
+		}})
+	}
+
+	sort.Slice(all, func(i, j int) bool { return all[i].seq < all[j].seq })
+
+	changes := SyncChanges{
+		Accounts:     make([]*Account, 0),
+		Categories:   make([]*Category, 0),
+		Merchants:    make([]*Merchant, 0),
+		Transactions: make([]*Transaction, 0),
+		Deleted:      make([]*SyncDeletion, 0),
+	}
+
+	// * Changes after the page could be missing from lists, so only first limit changes are complete
+	if len(all) > limit {
+		all = all[:limit]
+		changes.HasMore = true
+	}
+
+	token := since
+	for _, c := range all {
+		c.apply(&changes)
+		token = c.seq
+	}
+	changes.Token = strconv.FormatInt(token, 10)
+
+	return &changes
+}
+
+func (d *SyncDeletion) Verify() error {
+	if !d.Type.IsValid() {
+		return errors.Errorf("type must be one of %v", SyncResourceTypes)
+	}
+
+	if !IsUUID(d.ID) {
+		return errors.New("id must be UUID")
+	}
+
+	if d.Version <= 0 {
+		return errors.New("version is required")
+	}
+
+	return nil
+}
+
+// Applied - change was stored, version is new version of resource
+func (r *SyncResult) Applied(version int64) *SyncResult {
+	r.Status = SyncApplied
+	r.Version = version
+	return r
+}
+
+// Conflicted - resource was changed on server, client gets its current state
+func (r *SyncResult) Conflicted(server interface{}, version int64, deleted bool) *SyncResult {
+	r.Status = SyncConflict
+	r.Server = server
+	r.Version = version
+	r.Deleted = deleted
+	return r
+}
+
+// Rejected - change is invalid and client must not retry it
+func (r *SyncResult) Rejected(reason string) *SyncResult {
+	r.Status = SyncRejected
+	r.Error = reason
+	return r
+}
@@ -33,6 +33,32 @@ type Transaction struct {
 	Type   *TransactionType `json:"type" db:"transaction_type"`
 	Amount *int64           `json:"amount" db:"amount"`
 	Notes  *string           `json:"notes,omitempty" db:"notes"`
+
+	// * Transaction is cleared when it appears on bank statement. Reconciled transaction can't be changed.
+	Cleared          *bool             `json:"cleared,omitempty" db:"cleared"`
+	ReconciliationID *ReconciliationID `json:"reconciliation_id,omitempty" db:"reconciliation_id"`
+	ReconciledAt     *time.Time        `json:"reconciled_at,omitempty" db:"reconciled_at"`
+
+	// * Transaction linked to debt is its repayment
+	DebtID *DebtID `json:"debt_id,omitempty" db:"debt_id"`
+
+	// * Maintained by database on every change, change_seq orders changes for sync
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+	ChangeSeq int64      `json:"-" db:"change_seq"`
+}
+
+// IsReconciled checks if transaction is locked by finalized reconciliation
+func (c *Transaction) IsReconciled() bool {
+	return c.ReconciledAt != nil
+}
+
+// TransactionVersion is state of transaction before one of its updates.
+// Revision numbers history of transaction, Version of embedded transaction is row version which was replaced.
+type TransactionVersion struct {
+	Revision   int        `json:"revision" db:"revision"`
+	ReplacedAt *time.Time `json:"replaced_at" db:"replaced_at"`
+	Transaction
 }
 
 func (c *Transaction) Verify() error {
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TrashItem is soft-deleted resource which can be restored until it is purged
+type TrashItem struct {
+	ResourceType AuditResourceType `json:"resource_type" db:"resource_type"`
+	ResourceID   string            `json:"resource_id" db:"resource_id"`
+	Name         string            `json:"name" db:"name"`
+	DeletedAt    *time.Time        `json:"deleted_at" db:"deleted_at"`
+	PurgeAt      *time.Time        `json:"purge_at,omitempty" db:"-"`
+}
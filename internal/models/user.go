@@ -20,8 +20,20 @@ type User struct {
 	ID           UserID     `json:"id,omitempty" db:"user_id"`
 	Email        *string    `json:"email" db:"email"`
 	PasswordHash *[]byte    `json:"-" db:"password_hash"`
+	TokenVersion int64      `json:"-" db:"token_version"`
 	CreatedAt    *time.Time `json:"-" db:"created_at"`
 	DeletedAt    *time.Time `json:"-" db:"deleted_at"`
+
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" db:"email_verified_at"`
+	TOTPSecret      *string    `json:"-" db:"totp_secret"`
+	TOTPEnabledAt   *time.Time `json:"totp_enabled_at,omitempty" db:"totp_enabled_at"`
+
+	// * Transactions dated on or before lock date belong to closed period
+	LockDate *time.Time `json:"lock_date,omitempty" db:"lock_date"`
+
+	// * Maintained by database on every change
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
 }
 
 // Verify all required fields before create or update
@@ -33,6 +45,39 @@ func (u *User) Verify() error {
 	return nil
 }
 
+// TwoFactorEnabled checks if user confirmed TOTP enrollment
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil && u.TOTPSecret != nil
+}
+
+// IsLocked checks if date belongs to closed period. Lock date closes the whole day, not only its midnight.
+func (u *User) IsLocked(date time.Time) bool {
+	if u.LockDate == nil {
+		return false
+	}
+
+	lock := *u.LockDate
+	end := time.Date(lock.Year(), lock.Month(), lock.Day()+1, 0, 0, 0, 0, lock.Location())
+	return date.Before(end)
+}
+
+// * bcrypt uses only first 72 bytes of password
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// VerifyPassword checks password user wants to set
+func VerifyPassword(password string) error {
+	if len(password) < minPasswordLength {
+		return errors.Errorf("Password must be at least %d characters", minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return errors.Errorf("Password must be at most %d bytes", maxPasswordLength)
+	}
+	return nil
+}
+
 // Set Password updates a user's password
 func (u *User) SetPassword(password string) error {
 	// call hash function
@@ -45,12 +90,22 @@ func (u *User) SetPassword(password string) error {
 
 // CheckPassword verifies user's password
 func (u *User) CheckPassword(password string) error {
-	if u.PasswordHash != nil && len(*u.PasswordHash) == 0 {
+	// * Users created with identity provider don't have password
+	if u.PasswordHash == nil || len(*u.PasswordHash) == 0 {
 		return errors.New("Password not set")
 	}
 	return bcrypt.CompareHashAndPassword(*u.PasswordHash, []byte(password))
 }
 
+// dummyPasswordHash is compared when user not exists, so response time doesn't show which emails are registered
+var dummyPasswordHash, _ = HashPassword("dummy-password")
+
+// CheckDummyPassword takes the same time as CheckPassword and always fails
+func CheckDummyPassword(password string) error {
+	bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+	return errors.New("Invalid email or password")
+}
+
 // HashPassword hashes a user's raw password
 func HashPassword(password string) ([]byte, error) {
 	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
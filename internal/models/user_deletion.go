@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// UserDeletionStep is part of user's data which is removed together
+type UserDeletionStep string
+
+const (
+	DeleteSessions    UserDeletionStep = "sessions"
+	DeleteCredentials UserDeletionStep = "credentials"
+	DeleteLedger      UserDeletionStep = "ledger"
+	RedactAudit       UserDeletionStep = "audit"
+	AnonymizeUser     UserDeletionStep = "user"
+)
+
+// UserDeletionSteps are run in this order. User row is anonymized last, it is referenced by other tables.
+var UserDeletionSteps = []UserDeletionStep{
+	DeleteSessions,
+	DeleteCredentials,
+	DeleteLedger,
+	RedactAudit,
+	AnonymizeUser,
+}
+
+// UserDeletion is progress of removing user's data
+type UserDeletion struct {
+	UserID        UserID           `json:"user_id" db:"user_id"`
+	RequestedBy   *UserID          `json:"requested_by,omitempty" db:"requested_by"`
+	RequestedAt   *time.Time       `json:"requested_at" db:"requested_at"`
+	CompletedStep UserDeletionStep `json:"completed_step" db:"completed_step"`
+	Attempts      int              `json:"attempts" db:"attempts"`
+	LastError     string           `json:"last_error,omitempty" db:"last_error"`
+	CompletedAt   *time.Time       `json:"completed_at,omitempty" db:"completed_at"`
+}
+
+// RemainingSteps returns steps which were not finished yet
+func (d *UserDeletion) RemainingSteps() []UserDeletionStep {
+	for i, step := range UserDeletionSteps {
+		if step == d.CompletedStep {
+			return UserDeletionSteps[i+1:]
+		}
+	}
+	return UserDeletionSteps
+}
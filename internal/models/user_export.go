@@ -0,0 +1,28 @@
+package models
+
+// UserExport is all data we store about user
+type UserExport struct {
+	User                *User                 `json:"user"`
+	Roles               []*UserRole           `json:"roles"`
+	Sessions            []*Session            `json:"sessions"`
+	APIKeys             []*APIKey             `json:"api_keys"`
+	Identities          []*Identity           `json:"identities"`
+	Accounts            []*Account            `json:"accounts"`
+	Categories          []*Category           `json:"categories"`
+	Merchants           []*Merchant           `json:"merchants"`
+	Transactions        []*Transaction        `json:"transactions"`
+	TransactionVersions []*TransactionVersion `json:"transaction_versions"`
+	Reconciliations     []*Reconciliation     `json:"reconciliations"`
+	Debts               []*Debt               `json:"debts"`
+	Trades              []*Trade              `json:"trades"`
+	Goals               []*Goal               `json:"goals"`
+	GoalContributions   []*GoalContribution   `json:"goal_contributions"`
+	Bills               []*Bill               `json:"bills"`
+	BillPayments        []*BillPayment        `json:"bill_payments"`
+	AuthEvents          []*AuthEvent          `json:"auth_events"`
+	AuditEvents         []*AuditEvent         `json:"audit_events"`
+
+	Notifications           []*Notification           `json:"notifications"`
+	NotificationPreferences []*NotificationPreference `json:"notification_preferences"`
+	Webhooks                []*Webhook                `json:"webhooks"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// UserTokenPurpose is what single-use token can be used for
+type UserTokenPurpose string
+
+const (
+	// Token sent to user's email after registration
+	VerifyEmail UserTokenPurpose = "verify_email"
+	// Token sent to user's email when user forgot password
+	ResetPassword UserTokenPurpose = "reset_password"
+)
+
+// UserToken is single-use token sent to user by email. We store only hash of token.
+type UserToken struct {
+	TokenHash string           `db:"token_hash"`
+	UserID    UserID           `db:"user_id"`
+	Purpose   UserTokenPurpose `db:"purpose"`
+	CreatedAt *time.Time       `db:"created_at"`
+	ExpiresAt time.Time        `db:"expires_at"`
+	UsedAt    *time.Time       `db:"used_at"`
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"encoding/json"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// WebhookID is identifier of Webhook
+type WebhookID string
+
+// WebhookDeliveryID is identifier of WebhookDelivery
+type WebhookDeliveryID string
+
+// OutboxEventID is identifier of OutboxEvent
+type OutboxEventID string
+
+// WebhookEventType is change of resource which is sent to webhooks.
+// Account moved to trash or restored emits event of every transaction moved with it and so does backup import.
+// Category emits only its own event, consumer expands it to subcategories and their transactions.
+type WebhookEventType string
+
+const (
+	EventTransactionCreated  WebhookEventType = "transaction.created"
+	EventTransactionUpdated  WebhookEventType = "transaction.updated"
+	EventTransactionDeleted  WebhookEventType = "transaction.deleted"
+	EventTransactionRestored WebhookEventType = "transaction.restored"
+	EventAccountCreated      WebhookEventType = "account.created"
+	EventAccountUpdated      WebhookEventType = "account.updated"
+	EventAccountDeleted      WebhookEventType = "account.deleted"
+	EventAccountRestored     WebhookEventType = "account.restored"
+	EventCategoryCreated     WebhookEventType = "category.created"
+	EventCategoryUpdated     WebhookEventType = "category.updated"
+	EventCategoryDeleted     WebhookEventType = "category.deleted"
+	EventCategoryRestored    WebhookEventType = "category.restored"
+)
+
+// WebhookEventTypes - all events webhook can subscribe to
+var WebhookEventTypes = []WebhookEventType{
+	EventTransactionCreated, EventTransactionUpdated, EventTransactionDeleted, EventTransactionRestored,
+	EventAccountCreated, EventAccountUpdated, EventAccountDeleted, EventAccountRestored,
+	EventCategoryCreated, EventCategoryUpdated, EventCategoryDeleted, EventCategoryRestored,
+}
+
+func (t WebhookEventType) IsValid() bool {
+	for _, eventType := range WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Webhook is endpoint which receives events signed with its secret.
+// Webhook without user is registered by admin and receives events of all users.
+type Webhook struct {
+	ID         WebhookID      `json:"id,omitempty" db:"webhook_id"`
+	UserID     *UserID        `json:"user_id,omitempty" db:"user_id"`
+	URL        *string        `json:"url,omitempty" db:"url"`
+	Secret     string         `json:"-" db:"secret"`
+	EventTypes pq.StringArray `json:"event_types" db:"event_types"` // empty means all events
+	Active     *bool          `json:"active,omitempty" db:"active"`
+	CreatedAt  *time.Time     `json:"created_at,omitempty" db:"created_at"`
+	DeletedAt  *time.Time     `json:"-" db:"deleted_at"`
+
+	// * Maintained by database on every change
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+	Version   int64      `json:"version,omitempty" db:"version"`
+}
+
+// OutboxEvent is change of resource stored together with the change. Data is resource after change.
+type OutboxEvent struct {
+	ID           OutboxEventID    `json:"id" db:"event_id"`
+	UserID       *UserID          `json:"user_id" db:"user_id"`
+	Type         WebhookEventType `json:"type" db:"event_type"`
+	ResourceID   string           `json:"resource_id" db:"resource_id"`
+	Payload      json.RawMessage  `json:"data" db:"payload"`
+	CreatedAt    *time.Time       `json:"created_at" db:"created_at"`
+	DispatchedAt *time.Time       `json:"-" db:"dispatched_at"`
+}
+
+// WebhookDelivery is delivery of event to webhook
+type WebhookDelivery struct {
+	ID             WebhookDeliveryID `json:"id" db:"delivery_id"`
+	WebhookID      WebhookID         `json:"webhook_id" db:"webhook_id"`
+	EventID        OutboxEventID     `json:"event_id" db:"event_id"`
+	EventType      WebhookEventType  `json:"event_type" db:"event_type"`
+	Status         DeliveryStatus    `json:"status" db:"status"`
+	Attempts       int               `json:"attempts" db:"attempts"`
+	ResponseStatus *int              `json:"response_status,omitempty" db:"response_status"`
+	LastError      *string           `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt  *time.Time        `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	SentAt         *time.Time        `json:"sent_at,omitempty" db:"sent_at"`
+	CreatedAt      *time.Time        `json:"created_at,omitempty" db:"created_at"`
+}
+
+func (w *Webhook) Verify() error {
+	if w.URL == nil || len(*w.URL) == 0 {
+		return errors.New("url is required")
+	}
+	if err := verifyWebhookURL(*w.URL); err != nil {
+		return errors.Wrap(err, "url")
+	}
+
+	for _, eventType := range w.EventTypes {
+		if !WebhookEventType(eventType).IsValid() {
+			return errors.Errorf("unknown event type %q", eventType)
+		}
+	}
+
+	if w.Active == nil {
+		return errors.New("active is required")
+	}
+
+	return nil
+}
+
+// Fail - stores failed attempt, delivery is retried with exponential backoff until attempts run out.
+// Status is nil if webhook didn't respond.
+func (d *WebhookDelivery) Fail(err error, status *int, now time.Time) {
+	reason := err.Error()
+	d.Attempts++
+	d.LastError = &reason
+	d.ResponseStatus = status
+
+	if d.Attempts >= MaxDeliveryAttempts {
+		d.Status = DeliveryFailed
+		return
+	}
+
+	next := now.Add(deliveryBackoff(d.Attempts))
+	d.NextAttemptAt = &next
+}
+
+// Sent - stores successful attempt
+func (d *WebhookDelivery) Sent(status int, now time.Time) {
+	d.Attempts++
+	d.Status = DeliverySent
+	d.ResponseStatus = &status
+	d.LastError = nil
+	d.SentAt = &now
+}
+
+// verifyWebhookURL - webhook must be absolute http or https URL of public host.
+// Sender checks address again when it connects, because DNS of host can change later.
+func verifyWebhookURL(value string) error {
+	target, err := url.Parse(value)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return errors.New("must be absolute http or https URL")
+	}
+
+	ips, err := net.LookupIP(target.Hostname())
+	if err != nil || len(ips) == 0 {
+		return errors.New("host can't be resolved")
+	}
+	for _, ip := range ips {
+		if !IsPublicIP(ip) {
+			return errors.New("host must not have local or private address")
+		}
+	}
+	return nil
+}
+
+// * Special purpose networks which methods of net.IP don't cover
+var reservedNetworks = parseNetworks(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // shared address space of carriers
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved
+)
+
+// IsPublicIP - webhooks can call only public addresses, so they can't reach server itself, internal network
+// or metadata endpoint of cloud (169.254.169.254 is link-local)
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+
+	for _, network := range reservedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"context"
+	"finance/internal/mailer"
+	"finance/internal/models"
+
+	"github.com/pkg/errors"
+)
+
+// EmailChannel sends notification to email address of user with mailer
+type EmailChannel struct {
+	mail mailer.Mailer
+}
+
+func NewEmailChannel(mail mailer.Mailer) *EmailChannel {
+	return &EmailChannel{
+		mail: mail,
+	}
+}
+
+func (c *EmailChannel) Send(ctx context.Context, notification *models.Notification, delivery *models.NotificationDelivery) error {
+	if delivery.Target == nil || len(*delivery.Target) == 0 {
+		return errors.New("email address is missing")
+	}
+
+	return c.mail.Send(ctx, mailer.Message{
+		To:      *delivery.Target,
+		Subject: notification.Title,
+		Body:    notification.Body,
+	})
+}
@@ -0,0 +1,22 @@
+package notify
+
+import (
+	"context"
+	"finance/internal/database"
+	"finance/internal/models"
+)
+
+// InAppChannel shows notification in user's inbox
+type InAppChannel struct {
+	db database.NotificationDB
+}
+
+func NewInAppChannel(db database.NotificationDB) *InAppChannel {
+	return &InAppChannel{
+		db: db,
+	}
+}
+
+func (c *InAppChannel) Send(ctx context.Context, notification *models.Notification, delivery *models.NotificationDelivery) error {
+	return c.db.MarkNotificationDelivered(ctx, notification.ID)
+}
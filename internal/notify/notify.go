@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"context"
+	"finance/internal/database"
+	"finance/internal/mailer"
+	"finance/internal/models"
+	"finance/internal/webhooks"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// Channel delivers notification to user. Returned error means delivery is retried later.
+type Channel interface {
+	Send(ctx context.Context, notification *models.Notification, delivery *models.NotificationDelivery) error
+}
+
+// Channels - channel used for each kind of delivery
+type Channels map[models.NotificationChannel]Channel
+
+// NewChannels creates all supported channels
+func NewChannels(db database.NotificationDB, mail mailer.Mailer) Channels {
+	return Channels{
+		models.ChannelInApp:   NewInAppChannel(db),
+		models.ChannelEmail:   NewEmailChannel(mail),
+		models.ChannelWebhook: NewWebhookChannel(db, webhooks.NewSender()),
+	}
+}
+
+// Preferences - preference of every event type, event types user didn't choose have defaults
+func Preferences(ctx context.Context, db database.NotificationDB, userID models.UserID) ([]*models.NotificationPreference, error) {
+	stored, err := db.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byEvent := make(map[models.NotificationEvent]*models.NotificationPreference, len(stored))
+	for _, preference := range stored {
+		byEvent[preference.EventType] = preference
+	}
+
+	preferences := make([]*models.NotificationPreference, 0, len(models.NotificationEvents))
+	for _, event := range models.NotificationEvents {
+		preference, ok := byEvent[event]
+		if !ok {
+			preference = models.NewNotificationPreference(userID, event)
+		}
+		preferences = append(preferences, preference)
+	}
+
+	return preferences, nil
+}
+
+// Preference - preference of one event type
+func Preference(ctx context.Context, db database.NotificationDB, userID models.UserID, event models.NotificationEvent) (*models.NotificationPreference, error) {
+	preferences, err := Preferences(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, preference := range preferences {
+		if preference.EventType == event {
+			return preference, nil
+		}
+	}
+	return nil, errors.Errorf("unknown event type %q", event)
+}
+
+// Notify stores notification with delivery for every channel enabled by preference. Deliveries are sent by worker.
+// It returns false if no channel is enabled or the same occurrence was already notified.
+func Notify(ctx context.Context, db database.Database, preference *models.NotificationPreference, notification *models.Notification) (bool, error) {
+	channels := preference.Channels()
+	if len(channels) == 0 {
+		return false, nil
+	}
+
+	deliveries := make([]*models.NotificationDelivery, 0, len(channels))
+	for _, channel := range channels {
+		delivery := models.NotificationDelivery{
+			Channel: channel,
+		}
+
+		switch channel {
+		case models.ChannelEmail:
+			user, err := db.GetUserByID(ctx, *notification.UserID)
+			if err != nil {
+				return false, err
+			}
+			delivery.Target = user.Email
+		case models.ChannelWebhook:
+			delivery.Target = preference.WebhookURL
+		}
+
+		deliveries = append(deliveries, &delivery)
+	}
+
+	return db.CreateNotification(ctx, notification, deliveries)
+}
+
+// LargeExpense notifies user about expense which is at least threshold of user
+func LargeExpense(ctx context.Context, db database.Database, transaction *models.Transaction) error {
+	if *transaction.Type != models.Expense {
+		return nil
+	}
+
+	preference, err := Preference(ctx, db, *transaction.UserID, models.EventLargeExpense)
+	if err != nil {
+		return err
+	}
+	if preference.Threshold == nil || *transaction.Amount < *preference.Threshold {
+		return nil
+	}
+
+	resourceID := string(transaction.ID)
+	notification := models.Notification{
+		UserID:     transaction.UserID,
+		EventType:  models.EventLargeExpense,
+		Title:      "Large expense recorded",
+		Body:       fmt.Sprintf("Expense of %s was recorded on %s.", formatAmount(*transaction.Amount), transaction.Date.Format("2006-01-02")),
+		ResourceID: &resourceID,
+		DedupeKey:  &resourceID,
+	}
+
+	_, err = Notify(ctx, db, preference, &notification)
+	return err
+}
+
+// BillDue notifies user about current occurrence of bill, every occurrence is notified once
+func BillDue(ctx context.Context, db database.Database, bill *models.Bill) (bool, error) {
+	preference, err := Preference(ctx, db, *bill.UserID, models.EventBillDue)
+	if err != nil {
+		return false, err
+	}
+
+	resourceID := string(bill.ID)
+	dedupeKey := fmt.Sprintf("%s:%s", bill.ID, bill.DueDate.Format("2006-01-02"))
+	notification := models.Notification{
+		UserID:     bill.UserID,
+		EventType:  models.EventBillDue,
+		Title:      fmt.Sprintf("Bill %s is due", *bill.Name),
+		Body:       fmt.Sprintf("Bill %s of %s is due on %s.", *bill.Name, formatAmount(*bill.Amount), bill.DueDate.Format("2006-01-02")),
+		ResourceID: &resourceID,
+		DedupeKey:  &dedupeKey,
+	}
+
+	return Notify(ctx, db, preference, &notification)
+}
+
+// formatAmount - amount in minor units with two decimal places
+func formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
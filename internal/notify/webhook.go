@@ -0,0 +1,42 @@
+package notify
+
+import (
+	"context"
+	"finance/internal/database"
+	"finance/internal/models"
+	"finance/internal/webhooks"
+
+	"github.com/pkg/errors"
+)
+
+// WebhookChannel posts notification as JSON to URL chosen by user. Request is signed with secret of user's preference
+// the same way as webhooks of events. Any status other than 2xx is failure.
+type WebhookChannel struct {
+	db     database.NotificationDB
+	sender *webhooks.Sender
+}
+
+func NewWebhookChannel(db database.NotificationDB, sender *webhooks.Sender) *WebhookChannel {
+	return &WebhookChannel{
+		db:     db,
+		sender: sender,
+	}
+}
+
+func (c *WebhookChannel) Send(ctx context.Context, notification *models.Notification, delivery *models.NotificationDelivery) error {
+	if delivery.Target == nil || len(*delivery.Target) == 0 {
+		return errors.New("webhook URL is missing")
+	}
+
+	// * Secret belongs to URL, notification is not sent when user changed webhook after it was created
+	preference, err := Preference(ctx, c.db, *notification.UserID, notification.EventType)
+	if err != nil {
+		return err
+	}
+	if preference.WebhookURL == nil || *preference.WebhookURL != *delivery.Target || preference.WebhookSecret == nil {
+		return errors.New("webhook was changed")
+	}
+
+	_, err = c.sender.Post(ctx, *delivery.Target, *preference.WebhookSecret, string(notification.EventType), string(delivery.ID), notification)
+	return err
+}
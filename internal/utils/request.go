@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/namsral/flag"
+	"github.com/sirupsen/logrus"
+)
+
+// * Without trusted proxies X-Forwarded-For is ignored, otherwise anybody could pick own IP for login throttling
+var trustedProxiesFlag = flag.String("trusted-proxies", "", "Comma separated IPs or CIDRs of proxies allowed to set X-Forwarded-For.")
+
+var (
+	trustedProxies     []*net.IPNet
+	trustedProxiesOnce sync.Once
+)
+
+// RequestIDHeader - header used to pass request ID from client or proxy and return it in response
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDContextKeyType struct{}
+
+var requestIDContextKey = requestIDContextKeyType{}
+
+// ClientIP - get client address from request. X-Forwarded-For is used only when request came from trusted proxy,
+// client address is the last one which wasn't added by trusted proxy.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(host) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		if address == "" {
+			continue
+		}
+		host = address
+		if !isTrustedProxy(address) {
+			break
+		}
+	}
+	return host
+}
+
+func isTrustedProxy(address string) bool {
+	trustedProxiesOnce.Do(loadTrustedProxies)
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadTrustedProxies() {
+	for _, proxy := range strings.Split(*trustedProxiesFlag, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			logrus.WithError(err).WithField("proxy", proxy).Warn("Invalid trusted proxy")
+			continue
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+// RequestID - middleware which gives every request an ID. ID sent by client is kept, so requests can be traced across services.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := context.WithValue(r.Context(), requestIDContextKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestID - get ID set by RequestID middleware
+func GetRequestID(r *http.Request) string {
+	requestID, _ := r.Context().Value(requestIDContextKey).(string)
+	return requestID
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
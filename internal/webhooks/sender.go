@@ -0,0 +1,90 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"finance/internal/models"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Sender posts signed events to webhooks. It is used for webhooks of events and for webhook channel of notifications.
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender - client connects only to public addresses and doesn't follow redirects, so webhook can't be used
+// to reach internal network. Proxy from environment is not used, it would connect instead of us.
+func NewSender() *Sender {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: dialControl,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &Sender{
+		client: &http.Client{
+			Timeout:   10 * time.Second,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// dialControl - address is checked when connection is made, after host was resolved
+func dialControl(network, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !models.IsPublicIP(ip) {
+		return errors.Errorf("address %s is not public", host)
+	}
+	return nil
+}
+
+// Send posts event to webhook. It returns status of response, 0 if webhook didn't respond.
+// Any status other than 2xx is failure.
+func (s *Sender) Send(ctx context.Context, webhook *models.Webhook, event *models.OutboxEvent, deliveryID models.WebhookDeliveryID) (int, error) {
+	return s.Post(ctx, *webhook.URL, webhook.Secret, string(event.Type), string(deliveryID), event)
+}
+
+// Post posts payload as JSON signed with secret. It returns status of response, 0 if webhook didn't respond.
+// Any status other than 2xx is failure.
+func (s *Sender) Post(ctx context.Context, url, secret, eventType, deliveryID string, payload interface{}) (int, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not encode event")
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Wrap(err, "could not create webhook request")
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(EventHeader, eventType)
+	request.Header.Set(DeliveryHeader, deliveryID)
+	request.Header.Set(SignatureHeader, Sign(secret, time.Now(), body))
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return 0, errors.Wrap(err, "could not call webhook")
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return response.StatusCode, errors.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
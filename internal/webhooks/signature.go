@@ -0,0 +1,44 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Headers of webhook request
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+const secretPrefix = "whsec_"
+
+// GenerateSecret returns new secret used to sign requests of webhook
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "could not generate webhook secret")
+	}
+	return secretPrefix + hex.EncodeToString(b), nil
+}
+
+// Sign returns signature header "t={unix time},v1={hex HMAC-SHA256 of "{unix time}.{body}"}".
+// Receiver computes the same HMAC with its secret and rejects old timestamps, so request can't be replayed.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%s,v1=%s", unix, hex.EncodeToString(mac.Sum(nil)))
+}
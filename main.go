@@ -1,9 +1,16 @@
 package main
 
 import (
+	"context"
 	"finance/internal/api"
 	"finance/internal/config"
 	"finance/internal/database"
+	"finance/internal/events"
+	"finance/internal/identity"
+	"finance/internal/jobs"
+	"finance/internal/mailer"
+	"finance/internal/notify"
+	"finance/internal/webhooks"
 	"fmt"
 	"net/http"
 	"os"
@@ -34,8 +41,29 @@ func main() {
 	}
 	logrus.Debug("Database is ready to use.")
 
+	// Create mailer
+	mail, err := mailer.New()
+	if err != nil {
+		logrus.WithError(err).Fatal("Error creating mailer.")
+	}
+
+	// Start background jobs
+	go jobs.Run(context.Background(), "purge-trash", jobs.PurgeInterval, jobs.PurgeTrash(db))
+	go jobs.Run(context.Background(), "delete-users", jobs.UserDeletionInterval, jobs.DeleteUsers(db))
+	go jobs.Run(context.Background(), "notify-bills-due", jobs.BillDueInterval, jobs.NotifyBillsDue(db))
+	go jobs.Run(context.Background(), "deliver-notifications", jobs.NotificationInterval, jobs.DeliverNotifications(db, notify.NewChannels(db, mail)))
+	go jobs.Run(context.Background(), "deliver-webhooks", jobs.WebhookInterval, jobs.DeliverWebhooks(db, webhooks.NewSender()))
+
+	// Create broker of real-time events
+	listener, err := database.NewListener(database.EventsChannel)
+	if err != nil {
+		logrus.WithError(err).Fatal("Error listening to events.")
+	}
+	broker := events.NewBroker(db)
+	go broker.Run(context.Background(), listener)
+
 	// Create new router
-	router, err := api.NewRouter(db)
+	router, err := api.NewRouter(db, mail, broker, identity.NewProviders())
 	if err != nil {
 		logrus.WithError(err).Fatal("Error building router")
 	}